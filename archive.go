@@ -0,0 +1,195 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"helm.sh/helm/v3/pkg/chart/loader"
+)
+
+// isArchive returns true if the file is an archive of manifests
+func isArchive(path string) bool {
+	p := strings.ToLower(path)
+	for _, ext := range []string{".tgz", ".tar.gz", ".tar", ".zip", ".gz"} {
+		if strings.HasSuffix(p, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// isArchivedManifest returns true if an archive entry should be converted.
+// Only plain manifests are read as jsonnet and CUE need to be evaluated
+// from the filesystem.
+func isArchivedManifest(name string) bool {
+	return isManifestFile(name) && !isJsonnetFile(name) && !isCUEFile(name)
+}
+
+// isGzip returns true if the data starts with the gzip magic number
+func isGzip(b []byte) bool {
+	return len(b) > 2 && b[0] == 0x1f && b[1] == 0x8b
+}
+
+// isHelmChartArchive returns true if the tarball contains a Helm chart
+func isHelmChartArchive(b []byte) bool {
+	found := false
+	walkTar(b, func(name string, _ []byte) error {
+		parts := strings.Split(strings.TrimPrefix(name, "./"), "/")
+		if len(parts) == 2 && parts[1] == "Chart.yaml" {
+			found = true
+		}
+		return nil
+	})
+	return found
+}
+
+// walkTar calls fn for every regular file in a tarball, which can be gzipped
+func walkTar(b []byte, fn func(name string, content []byte) error) error {
+	var r io.Reader = bytes.NewReader(b)
+	if isGzip(b) {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if h.Typeflag != tar.TypeReg {
+			continue
+		}
+		content, err := ioutil.ReadAll(tr)
+		if err != nil {
+			return err
+		}
+		if err := fn(h.Name, content); err != nil {
+			return err
+		}
+	}
+}
+
+// walkZip calls fn for every file in a zip archive
+func walkZip(b []byte, fn func(name string, content []byte) error) error {
+	zr, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		return err
+	}
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return err
+		}
+		content, err := ioutil.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+		if err := fn(f.Name, content); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// renderHelmChartArchive loads a packaged chart, which can be gzipped,
+// and renders it
+func renderHelmChartArchive(b []byte) ([]byte, error) {
+	files := []*loader.BufferedFile{}
+	err := walkTar(b, func(name string, content []byte) error {
+		// the files are in a directory named after the chart
+		parts := strings.SplitN(strings.TrimPrefix(name, "./"), "/", 2)
+		if len(parts) == 2 {
+			files = append(files, &loader.BufferedFile{Name: parts[1], Data: content})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	chrt, err := loader.LoadFiles(files)
+	if err != nil {
+		return nil, err
+	}
+	return renderChart(chrt, helmOptions{})
+}
+
+// tarSources returns a source for each manifest in a tarball, Helm charts
+// are rendered and returned as a single source
+func tarSources(b []byte, name string) ([]source, error) {
+	if isHelmChartArchive(b) {
+		rendered, err := renderHelmChartArchive(b)
+		if err != nil {
+			return nil, err
+		}
+		return []source{bytesSource(name, rendered)}, nil
+	}
+
+	sources := []source{}
+	err := walkTar(b, func(entry string, content []byte) error {
+		if isArchivedManifest(entry) {
+			sources = append(sources, bytesSource(name+"//"+strings.TrimPrefix(entry, "./"), content))
+		}
+		return nil
+	})
+	return sources, err
+}
+
+// archiveSources reads an archive and returns a source for each manifest
+// in it, so errors can be reported for the entry they came from
+func archiveSources(path string, client *http.Client) ([]source, error) {
+	r, err := openInput(path, client)
+	if err != nil {
+		return nil, err
+	}
+	b, err := ioutil.ReadAll(r)
+	r.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	p := strings.ToLower(path)
+	switch {
+	case strings.HasSuffix(p, ".zip"):
+		sources := []source{}
+		err := walkZip(b, func(entry string, content []byte) error {
+			if isArchivedManifest(entry) {
+				sources = append(sources, bytesSource(path+"//"+entry, content))
+			}
+			return nil
+		})
+		return sources, err
+	case strings.HasSuffix(p, ".tgz"), strings.HasSuffix(p, ".tar.gz"), strings.HasSuffix(p, ".tar"):
+		return tarSources(b, path)
+	}
+
+	// a single gzipped manifest
+	gz, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+	content, err := ioutil.ReadAll(gz)
+	if err != nil {
+		return nil, err
+	}
+	return []source{bytesSource(strings.TrimSuffix(path, filepath.Ext(path)), content)}, nil
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func tarGz(t *testing.T, files map[string]string) []byte {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	names := []string{}
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		content := files[name]
+		tw.WriteHeader(&tar.Header{
+			Name:     name,
+			Mode:     0644,
+			Size:     int64(len(content)),
+			Typeflag: tar.TypeReg,
+		})
+		tw.Write([]byte(content))
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func writeArchive(t *testing.T, dir, name string, b []byte) string {
+	p := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(p, b, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func convertSources(t *testing.T, sources []source) (names []string, output string) {
+	for _, s := range sources {
+		names = append(names, s.name)
+		r, err := s.open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		hcl, err := YAMLToTerraformResources(r)
+		r.Close()
+		if err != nil {
+			t.Fatalf("Converting %s to HCL failed: %s", s.name, err)
+		}
+		output += hcl
+	}
+	return names, output
+}
+
+func TestArchiveSources(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tfk8s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"dump/default/configmaps.json": `{"apiVersion": "v1", "kind": "ConfigMapList", "items": [{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "dumped"}}]}`,
+		"dump/default/pods.log":        "not a manifest",
+		"dump/namespace.yaml":          "apiVersion: v1\nkind: Namespace\nmetadata:\n  name: dumped\n",
+	}
+
+	var zipped bytes.Buffer
+	zw := zip.NewWriter(&zipped)
+	for _, name := range []string{"dump/default/configmaps.json", "dump/default/pods.log", "dump/namespace.yaml"} {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(files[name]))
+	}
+	zw.Close()
+
+	var gzipped bytes.Buffer
+	gz := gzip.NewWriter(&gzipped)
+	gz.Write([]byte(files["dump/namespace.yaml"]))
+	gz.Close()
+
+	tgzPath := writeArchive(t, dir, "dump.tar.gz", tarGz(t, files))
+	zipPath := writeArchive(t, dir, "dump.zip", zipped.Bytes())
+	gzPath := writeArchive(t, dir, "namespace.yaml.gz", gzipped.Bytes())
+
+	for _, path := range []string{tgzPath, zipPath} {
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			sources, err := archiveSources(path, nil)
+			if err != nil {
+				t.Fatal("Reading archive failed:", err)
+			}
+			names, output := convertSources(t, sources)
+			assert.Equal(t, []string{
+				path + "//dump/default/configmaps.json",
+				path + "//dump/namespace.yaml",
+			}, names)
+			assert.Contains(t, output, `resource "kubernetes_manifest" "configmap_dumped"`)
+			assert.Contains(t, output, `resource "kubernetes_manifest" "namespace_dumped"`)
+		})
+	}
+
+	sources, err := archiveSources(gzPath, nil)
+	if err != nil {
+		t.Fatal("Reading archive failed:", err)
+	}
+	names, output := convertSources(t, sources)
+	assert.Equal(t, []string{filepath.Join(dir, "namespace.yaml")}, names)
+	assert.Contains(t, output, `resource "kubernetes_manifest" "namespace_dumped"`)
+}
+
+func TestArchiveSourcesHelmChart(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tfk8s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	chart := tarGz(t, map[string]string{
+		"mychart/Chart.yaml":              "apiVersion: v2\nname: mychart\nversion: 0.1.0\n",
+		"mychart/templates/service.yaml":  "apiVersion: v1\nkind: Service\nmetadata:\n  name: {{ .Release.Name }}-{{ .Chart.Name }}\n",
+		"mychart/templates/_helpers.tpl":  "",
+		"mychart/values.yaml":             "replicas: 1\n",
+		"mychart/templates/NOTES.txt":     "",
+		"mychart/charts/dep/Chart.yaml":   "apiVersion: v2\nname: dep\nversion: 0.1.0\n",
+		"mychart/templates/configmap.yml": "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: replicas-{{ .Values.replicas }}\n",
+	})
+	path := writeArchive(t, dir, "mychart-0.1.0.tgz", chart)
+
+	sources, err := archiveSources(path, nil)
+	if err != nil {
+		t.Fatal("Reading archive failed:", err)
+	}
+	names, output := convertSources(t, sources)
+	assert.Equal(t, []string{path}, names)
+	assert.Contains(t, output, `resource "kubernetes_manifest" "service_release_name_mychart"`)
+	assert.Contains(t, output, `resource "kubernetes_manifest" "configmap_replicas_1"`)
+}
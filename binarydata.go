@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	cty "github.com/zclconf/go-cty/cty"
+
+	"github.com/jrhouston/tfk8s/contrib/hashicorp/terraform"
+)
+
+// binaryDataField returns the field of a manifest that holds base64
+// encoded data, or an empty string if it doesn't have one
+func binaryDataField(kind string) string {
+	switch kind {
+	case "ConfigMap":
+		return "binaryData"
+	case "Secret":
+		return "data"
+	}
+	return ""
+}
+
+// extractBinaryData writes the base64 encoded values of a ConfigMap's
+// binaryData or a Secret's data to files in the --extract-binary-data
+// directory, and replaces them with filebase64() calls that read them back
+// from the path the directory has from the Terraform config.
+func extractBinaryData(doc cty.Value, name, resourceName string, o options) (cty.Value, error) {
+	m := doc.AsValueMap()
+	kind, _ := stringAttr(m, "kind")
+	field := binaryDataField(kind)
+	data, ok := m[field]
+	if field == "" || !ok || data.IsNull() || !data.Type().IsObjectType() {
+		return doc, nil
+	}
+
+	values := data.AsValueMap()
+	keys := []string{}
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		v := values[k]
+		if v.IsNull() || v.Type() != cty.String {
+			continue
+		}
+		b, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(v.AsString()), ""))
+		if err != nil {
+			return doc, fmt.Errorf("%s.%s is not valid base64: %s", field, k, err)
+		}
+
+		f := pendingFile{filename: filepath.Join(o.binaryDataDir, resourceName, k), data: b}
+		path := formatPath(cty.GetAttrPath(field).GetAttr(k))
+		if kind == "Secret" {
+			f.secret = &secretFinding{kind, name, path, "is the value of the Secret, written to " + f.filename}
+		} else if reason := looksLikeSecret(string(b)); reason != "" {
+			f.secret = &secretFinding{kind, name, path, reason + ", written to " + f.filename}
+		}
+		if err := o.writeFile(f); err != nil {
+			return doc, err
+		}
+
+		p := modulePath(o.binaryDataRef, resourceName, k)
+		values[k] = cty.StringVal(fmt.Sprintf("filebase64(%q)", p)).Mark(terraform.Expression)
+	}
+
+	m[field] = cty.ObjectVal(values)
+	return cty.ObjectVal(m), nil
+}
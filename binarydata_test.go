@@ -0,0 +1,85 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestYAMLToTerraformResourcesBinaryData(t *testing.T) {
+	yaml := `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: test
+binaryData:
+  logo.png: iVBORw0KGgoAAAANSUhEUgAAAAEAAAABCAYAAAAfFcSJAAAADUlEQVR42mP8/5+hHgAHggJ/PchI7wAAAABJRU5ErkJggg==
+`
+
+	// values are kept as they are unless they are extracted
+	output, err := YAMLToTerraformResources(strings.NewReader(yaml))
+	if err != nil {
+		t.Fatal("Converting to HCL failed:", err)
+	}
+	assert.Contains(t, output, `"logo.png" = "iVBORw0KGgoAAAANSUhEUgAAAAEAAAABCAYAAAAfFcSJAAAADUlEQVR42mP8/5+hHgAHggJ/PchI7wAAAABJRU5ErkJggg=="`)
+
+	dir, err := ioutil.TempDir("", "tfk8s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	output, err = YAMLToTerraformResources(strings.NewReader(yaml), WithExtractBinaryData(dir, "files"))
+	if err != nil {
+		t.Fatal("Converting to HCL failed:", err)
+	}
+	assert.Contains(t, output, `"logo.png" = filebase64("${path.module}/files/configmap_test/logo.png")`)
+
+	b, err := ioutil.ReadFile(filepath.Join(dir, "configmap_test", "logo.png"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "\x89PNG", string(b[:4]))
+}
+
+func TestExtractBinaryDataSecret(t *testing.T) {
+	yaml := `apiVersion: v1
+kind: Secret
+metadata:
+  name: tls
+  namespace: web
+data:
+  tls.key: |
+    c2VjcmV0
+    IGtleQ==
+`
+
+	dir, err := ioutil.TempDir("", "tfk8s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	output, err := YAMLToTerraformResources(strings.NewReader(yaml), WithExtractBinaryData(dir, dir))
+	if err != nil {
+		t.Fatal("Converting to HCL failed:", err)
+	}
+	assert.Contains(t, output, `"tls.key" = filebase64("`+filepath.ToSlash(dir)+`/secret_web_tls/tls.key")`)
+
+	b, err := ioutil.ReadFile(filepath.Join(dir, "secret_web_tls", "tls.key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "secret key", string(b))
+
+	_, err = YAMLToTerraformResources(strings.NewReader(`apiVersion: v1
+kind: Secret
+metadata:
+  name: broken
+data:
+  key: not base64!`), WithExtractBinaryData(dir, dir))
+	assert.Error(t, err)
+}
@@ -0,0 +1,344 @@
+package main
+
+import (
+	"fmt"
+	"go/format"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	cty "github.com/zclconf/go-cty/cty"
+
+	"github.com/jrhouston/tfk8s/contrib/hashicorp/terraform"
+)
+
+// cdktfConstruct is the name of the construct the CDKTF formats generate
+const cdktfConstruct = "KubernetesManifests"
+
+// tsIdentifier matches keys that don't need quoting in a TypeScript object
+var tsIdentifier = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*$`)
+
+// cdktfLanguage is the syntax of one of the languages CDK for Terraform
+// supports. The formats share the same resources and construct, and only
+// differ in how the literals and the code around them are written.
+type cdktfLanguage struct {
+	// format is the name used with --format
+	format    string
+	extension string
+	indent    string
+
+	null, true, false                      string
+	mapOpen, mapClose, listOpen, listClose string
+	// keySeparator goes between a key and its value in a map
+	keySeparator string
+
+	// key returns a map key, quoted if it needs to be
+	key func(k string) string
+	// quote returns a string literal
+	quote func(s string) string
+	// rawString returns a call that stops CDKTF and Terraform
+	// interpolating the string literal quoted
+	rawString func(quoted string) string
+
+	// resource returns the statement that creates the Manifest for r,
+	// manifest is its literal
+	resource func(r resource, manifest string) string
+	// file returns the source file for the construct that runs the
+	// statements
+	file func(statements []string, rawStrings, provider bool) (string, error)
+}
+
+// quoteJSString returns s as a double quoted string literal
+func quoteJSString(s string) string {
+	quoted, _ := marshalJSON(s)
+	return strings.TrimSuffix(quoted, "\n")
+}
+
+// indentLines indents every line of s but the first by prefix
+func indentLines(s, prefix string) string {
+	return strings.ReplaceAll(s, "\n", "\n"+prefix)
+}
+
+// hasTemplateSequences returns true if a string in v would be interpolated
+// by Terraform, so it has to be passed through the rawString function
+func hasTemplateSequences(v cty.Value) bool {
+	if v.IsMarked() || v.IsNull() {
+		return false
+	}
+	ty := v.Type()
+	switch {
+	case ty == cty.String:
+		s := v.AsString()
+		return strings.Contains(s, "${") || strings.Contains(s, "%{")
+	case ty.IsObjectType() || ty.IsMapType() || ty.IsTupleType() || ty.IsListType() || ty.IsSetType():
+		for it := v.ElementIterator(); it.Next(); {
+			_, v := it.Element()
+			if hasTemplateSequences(v) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// value formats a manifest value as a literal in the language
+func (l *cdktfLanguage) value(v cty.Value, depth int) string {
+	if v.HasMark(terraform.Expression) {
+		// CDKTF passes strings through to Terraform, which evaluates the
+		// expression
+		expr, _ := v.Unmark()
+		return l.quote("${" + expr.AsString() + "}")
+	}
+	if v.IsNull() {
+		return l.null
+	}
+
+	ty := v.Type()
+	switch {
+	case ty == cty.String:
+		if hasTemplateSequences(v) {
+			return l.rawString(l.quote(v.AsString()))
+		}
+		return l.quote(v.AsString())
+	case ty == cty.Number:
+		return v.AsBigFloat().Text('f', -1)
+	case ty == cty.Bool:
+		if v.True() {
+			return l.true
+		}
+		return l.false
+	case ty.IsObjectType() || ty.IsMapType():
+		m := v.AsValueMap()
+		if len(m) == 0 {
+			return l.mapOpen + l.mapClose
+		}
+		keys := []string{}
+		for k := range m {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		pad := strings.Repeat(l.indent, depth+1)
+		var buf strings.Builder
+		buf.WriteString(l.mapOpen + "\n")
+		for _, k := range keys {
+			fmt.Fprintf(&buf, "%s%s%s%s,\n", pad, l.key(k), l.keySeparator, l.value(m[k], depth+1))
+		}
+		buf.WriteString(strings.Repeat(l.indent, depth) + l.mapClose)
+		return buf.String()
+	case ty.IsTupleType() || ty.IsListType() || ty.IsSetType():
+		items := v.AsValueSlice()
+		if len(items) == 0 {
+			return l.listOpen + l.listClose
+		}
+		pad := strings.Repeat(l.indent, depth+1)
+		var buf strings.Builder
+		buf.WriteString(l.listOpen + "\n")
+		for _, v := range items {
+			fmt.Fprintf(&buf, "%s%s,\n", pad, l.value(v, depth+1))
+		}
+		buf.WriteString(strings.Repeat(l.indent, depth) + l.listClose)
+		return buf.String()
+	}
+	return l.null
+}
+
+// cdktfFormat writes a CDK for Terraform construct that creates a Manifest
+// for each resource. When --provider is set the construct takes the
+// provider to use as an argument.
+type cdktfFormat struct {
+	language *cdktfLanguage
+}
+
+func (f cdktfFormat) resource(r resource, o options) (string, error) {
+	if o.mapOnly {
+		return "", fmt.Errorf("--map-only can't be used with --format %s", f.language.format)
+	}
+	if r.resourceType != resourceType {
+		return "", fmt.Errorf("--target %s can't be used with --format %s", r.resourceType, f.language.format)
+	}
+	return f.language.resource(r, f.language.value(r.manifest, 1)), nil
+}
+
+func (f cdktfFormat) join(resources []resource) (string, error) {
+	rawStrings := false
+	provider := false
+	statements := []string{}
+	for _, r := range resources {
+		rawStrings = rawStrings || hasTemplateSequences(r.manifest)
+		provider = provider || r.provider != ""
+		statements = append(statements, strings.TrimSuffix(r.text, "\n"))
+	}
+	return f.language.file(statements, rawStrings, provider)
+}
+
+func (f cdktfFormat) extension() string {
+	return f.language.extension
+}
+
+// cdktfTypeScript is the syntax of the cdktf-ts format
+var cdktfTypeScript = &cdktfLanguage{
+	format:       "cdktf-ts",
+	extension:    ".ts",
+	indent:       "  ",
+	null:         "null",
+	true:         "true",
+	false:        "false",
+	mapOpen:      "{",
+	mapClose:     "}",
+	listOpen:     "[",
+	listClose:    "]",
+	keySeparator: ": ",
+	key: func(k string) string {
+		if tsIdentifier.MatchString(k) {
+			return k
+		}
+		return quoteJSString(k)
+	},
+	quote: quoteJSString,
+	rawString: func(quoted string) string {
+		return "Fn.rawString(" + quoted + ")"
+	},
+	resource: func(r resource, manifest string) string {
+		var buf strings.Builder
+		fmt.Fprintf(&buf, "new kubernetes.manifest.Manifest(this, %s, {\n", quoteJSString(r.name))
+		if r.provider != "" {
+			buf.WriteString("  provider,\n")
+		}
+		fmt.Fprintf(&buf, "  manifest: %s,\n", manifest)
+		buf.WriteString("});\n")
+		return buf.String()
+	},
+	file: func(statements []string, rawStrings, provider bool) (string, error) {
+		var buf strings.Builder
+		buf.WriteString("import { Construct } from \"constructs\";\n")
+		if rawStrings {
+			buf.WriteString("import { Fn } from \"cdktf\";\n")
+		}
+		buf.WriteString("import * as kubernetes from \"@cdktf/provider-kubernetes\";\n\n")
+		fmt.Fprintf(&buf, "export class %s extends Construct {\n", cdktfConstruct)
+		if provider {
+			buf.WriteString("  constructor(scope: Construct, id: string, provider: kubernetes.provider.KubernetesProvider) {\n")
+		} else {
+			buf.WriteString("  constructor(scope: Construct, id: string) {\n")
+		}
+		buf.WriteString("    super(scope, id);\n")
+		for _, s := range statements {
+			fmt.Fprintf(&buf, "\n    %s\n", indentLines(s, "    "))
+		}
+		buf.WriteString("  }\n}\n")
+		return buf.String(), nil
+	},
+}
+
+// cdktfPython is the syntax of the cdktf-python format
+var cdktfPython = &cdktfLanguage{
+	format:       "cdktf-python",
+	extension:    ".py",
+	indent:       "    ",
+	null:         "None",
+	true:         "True",
+	false:        "False",
+	mapOpen:      "{",
+	mapClose:     "}",
+	listOpen:     "[",
+	listClose:    "]",
+	keySeparator: ": ",
+	key:          quoteJSString,
+	quote:        quoteJSString,
+	rawString: func(quoted string) string {
+		return "Fn.raw_string(" + quoted + ")"
+	},
+	resource: func(r resource, manifest string) string {
+		var buf strings.Builder
+		fmt.Fprintf(&buf, "Manifest(\n    self,\n    %s,\n", quoteJSString(r.name))
+		if r.provider != "" {
+			buf.WriteString("    provider=provider,\n")
+		}
+		fmt.Fprintf(&buf, "    manifest=%s,\n", manifest)
+		buf.WriteString(")\n")
+		return buf.String()
+	},
+	file: func(statements []string, rawStrings, provider bool) (string, error) {
+		var buf strings.Builder
+		buf.WriteString("from constructs import Construct\n")
+		if rawStrings {
+			buf.WriteString("from cdktf import Fn\n")
+		}
+		buf.WriteString("from cdktf_cdktf_provider_kubernetes.manifest import Manifest\n")
+		if provider {
+			buf.WriteString("from cdktf_cdktf_provider_kubernetes.provider import KubernetesProvider\n")
+		}
+		fmt.Fprintf(&buf, "\n\nclass %s(Construct):\n", cdktfConstruct)
+		if provider {
+			buf.WriteString("    def __init__(self, scope: Construct, id: str, provider: KubernetesProvider):\n")
+		} else {
+			buf.WriteString("    def __init__(self, scope: Construct, id: str):\n")
+		}
+		buf.WriteString("        super().__init__(scope, id)\n")
+		for _, s := range statements {
+			fmt.Fprintf(&buf, "\n        %s\n", indentLines(s, "        "))
+		}
+		return buf.String(), nil
+	},
+}
+
+// cdktfGo is the syntax of the cdktf-go format, the file is run through
+// gofmt so the literals are aligned the way Go code is
+var cdktfGo = &cdktfLanguage{
+	format:       "cdktf-go",
+	extension:    ".go",
+	indent:       "\t",
+	null:         "nil",
+	true:         "true",
+	false:        "false",
+	mapOpen:      "map[string]interface{}{",
+	mapClose:     "}",
+	listOpen:     "[]interface{}{",
+	listClose:    "}",
+	keySeparator: ": ",
+	key:          strconv.Quote,
+	quote:        strconv.Quote,
+	rawString: func(quoted string) string {
+		return "cdktf.Fn_RawString(jsii.String(" + quoted + "))"
+	},
+	resource: func(r resource, manifest string) string {
+		var buf strings.Builder
+		fmt.Fprintf(&buf, "manifest.NewManifest(c, jsii.String(%s), &manifest.ManifestConfig{\n", strconv.Quote(r.name))
+		if r.provider != "" {
+			buf.WriteString("\tProvider: provider,\n")
+		}
+		fmt.Fprintf(&buf, "\tManifest: &%s,\n", manifest)
+		buf.WriteString("})\n")
+		return buf.String()
+	},
+	file: func(statements []string, rawStrings, provider bool) (string, error) {
+		var buf strings.Builder
+		buf.WriteString("package main\n\nimport (\n")
+		buf.WriteString("\t\"github.com/aws/constructs-go/constructs/v10\"\n")
+		buf.WriteString("\t\"github.com/aws/jsii-runtime-go\"\n")
+		buf.WriteString("\t\"github.com/cdktf/cdktf-provider-kubernetes-go/kubernetes/v11/manifest\"\n")
+		if rawStrings || provider {
+			buf.WriteString("\t\"github.com/hashicorp/terraform-cdk-go/cdktf\"\n")
+		}
+		buf.WriteString(")\n\n")
+		fmt.Fprintf(&buf, "// New%s creates the Kubernetes resources converted by tfk8s\n", cdktfConstruct)
+		if provider {
+			fmt.Fprintf(&buf, "func New%s(scope constructs.Construct, id string, provider cdktf.TerraformProvider) constructs.Construct {\n", cdktfConstruct)
+		} else {
+			fmt.Fprintf(&buf, "func New%s(scope constructs.Construct, id string) constructs.Construct {\n", cdktfConstruct)
+		}
+		buf.WriteString("\tc := constructs.NewConstruct(scope, jsii.String(id))\n")
+		for _, s := range statements {
+			fmt.Fprintf(&buf, "\n\t%s\n", indentLines(s, "\t"))
+		}
+		buf.WriteString("\n\treturn c\n}\n")
+
+		src, err := format.Source([]byte(buf.String()))
+		if err != nil {
+			return "", err
+		}
+		return string(src), nil
+	},
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestYAMLToTerraformResourcesCDKTFTypeScript(t *testing.T) {
+	yaml := `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: test
+  labels:
+    app.kubernetes.io/name: test
+data:
+  TEMPLATE: "${HOME}"
+  EMPTY: ""
+  replicas: 3
+  ports: []
+`
+
+	output, err := YAMLToTerraformResources(strings.NewReader(yaml), WithFormat("cdktf-ts"))
+	if err != nil {
+		t.Fatal("Converting to TypeScript failed:", err)
+	}
+
+	expected := `import { Construct } from "constructs";
+import { Fn } from "cdktf";
+import * as kubernetes from "@cdktf/provider-kubernetes";
+
+export class KubernetesManifests extends Construct {
+  constructor(scope: Construct, id: string) {
+    super(scope, id);
+
+    new kubernetes.manifest.Manifest(this, "configmap_test", {
+      manifest: {
+        apiVersion: "v1",
+        data: {
+          EMPTY: "",
+          TEMPLATE: Fn.rawString("${HOME}"),
+          ports: [],
+          replicas: 3,
+        },
+        kind: "ConfigMap",
+        metadata: {
+          labels: {
+            "app.kubernetes.io/name": "test",
+          },
+          name: "test",
+        },
+      },
+    });
+  }
+}
+`
+	assert.Equal(t, expected, output)
+}
+
+func TestCDKTFTypeScriptProvider(t *testing.T) {
+	output, err := YAMLToTerraformResources(strings.NewReader(`apiVersion: v1
+kind: Namespace
+metadata:
+  name: test
+`), WithFormat("cdktf-ts"), WithProviderAlias("kubernetes.other"))
+	if err != nil {
+		t.Fatal("Converting to TypeScript failed:", err)
+	}
+
+	assert.NotContains(t, output, "Fn")
+	assert.Contains(t, output, "constructor(scope: Construct, id: string, provider: kubernetes.provider.KubernetesProvider) {")
+	assert.Contains(t, output, "new kubernetes.manifest.Manifest(this, \"namespace_test\", {\n      provider,\n")
+}
+
+func TestYAMLToTerraformResourcesCDKTFPython(t *testing.T) {
+	output, err := YAMLToTerraformResources(strings.NewReader(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: test
+data:
+  TEMPLATE: "${HOME}"
+  enabled: true
+  missing: null
+`), WithFormat("cdktf-python"))
+	if err != nil {
+		t.Fatal("Converting to Python failed:", err)
+	}
+
+	expected := `from constructs import Construct
+from cdktf import Fn
+from cdktf_cdktf_provider_kubernetes.manifest import Manifest
+
+
+class KubernetesManifests(Construct):
+    def __init__(self, scope: Construct, id: str):
+        super().__init__(scope, id)
+
+        Manifest(
+            self,
+            "configmap_test",
+            manifest={
+                "apiVersion": "v1",
+                "data": {
+                    "TEMPLATE": Fn.raw_string("${HOME}"),
+                    "enabled": True,
+                    "missing": None,
+                },
+                "kind": "ConfigMap",
+                "metadata": {
+                    "name": "test",
+                },
+            },
+        )
+`
+	assert.Equal(t, expected, output)
+}
+
+func TestYAMLToTerraformResourcesCDKTFGo(t *testing.T) {
+	output, err := YAMLToTerraformResources(strings.NewReader(`apiVersion: v1
+kind: Service
+metadata:
+  name: test
+spec:
+  ports:
+  - port: 80
+    name: http
+`), WithFormat("cdktf-go"))
+	if err != nil {
+		t.Fatal("Converting to Go failed:", err)
+	}
+
+	expected := `package main
+
+import (
+	"github.com/aws/constructs-go/constructs/v10"
+	"github.com/aws/jsii-runtime-go"
+	"github.com/cdktf/cdktf-provider-kubernetes-go/kubernetes/v11/manifest"
+)
+
+// NewKubernetesManifests creates the Kubernetes resources converted by tfk8s
+func NewKubernetesManifests(scope constructs.Construct, id string) constructs.Construct {
+	c := constructs.NewConstruct(scope, jsii.String(id))
+
+	manifest.NewManifest(c, jsii.String("service_test"), &manifest.ManifestConfig{
+		Manifest: &map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "Service",
+			"metadata": map[string]interface{}{
+				"name": "test",
+			},
+			"spec": map[string]interface{}{
+				"ports": []interface{}{
+					map[string]interface{}{
+						"name": "http",
+						"port": 80,
+					},
+				},
+			},
+		},
+	})
+
+	return c
+}
+`
+	assert.Equal(t, expected, output)
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+// kubectlCommand is the command used to read resources from the cluster
+var kubectlCommand = "kubectl"
+
+// runKubectl runs kubectl with the supplied arguments and returns its output
+var runKubectl = func(args ...string) ([]byte, error) {
+	return runCommand(kubectlCommand, args...)
+}
+
+// excludeKinds is the list of kinds that are skipped when exporting
+// all the resources from a namespace with --all, these are objects
+// that are created and managed by controllers
+var excludeKinds = []string{
+	"Event",
+	"Endpoints",
+	"EndpointSlice",
+	"Pod",
+	"ReplicaSet",
+	"ControllerRevision",
+	"Lease",
+	"PodMetrics",
+}
+
+// clusterOptions configures which resources are read from the cluster
+type clusterOptions struct {
+	kubeconfig    string
+	context       string
+	namespace     string
+	labelSelector string
+	all           bool
+	resources     []string
+	excludeKinds  []string
+}
+
+// kubectlArgs adds the flags that select the cluster to the kubectl
+// arguments. When they are not set kubectl falls back to KUBECONFIG
+// and the current context.
+func (o clusterOptions) kubectlArgs(args ...string) []string {
+	if o.kubeconfig != "" {
+		args = append(args, "--kubeconfig", o.kubeconfig)
+	}
+	if o.context != "" {
+		args = append(args, "--context", o.context)
+	}
+	return args
+}
+
+// parseAPIResources parses the output of kubectl api-resources --no-headers
+// and returns the fully qualified resource names, skipping excluded kinds
+func parseAPIResources(output []byte, exclude []string) []string {
+	skip := map[string]bool{}
+	for _, k := range exclude {
+		skip[strings.ToLower(k)] = true
+	}
+
+	resources := []string{}
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		// the SHORTNAMES column can be empty so we count the
+		// columns from the end: NAME [SHORTNAMES] APIVERSION NAMESPACED KIND
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 {
+			continue
+		}
+		name := fields[0]
+		apiVersion := fields[len(fields)-3]
+		kind := fields[len(fields)-1]
+		if skip[strings.ToLower(kind)] {
+			continue
+		}
+		if i := strings.Index(apiVersion, "/"); i != -1 {
+			name = name + "." + apiVersion[:i]
+		}
+		resources = append(resources, name)
+	}
+	return resources
+}
+
+// listNamespacedResources returns every namespaced resource type in
+// the cluster that can be listed
+func listNamespacedResources(opts clusterOptions) ([]string, error) {
+	out, err := runKubectl(opts.kubectlArgs("api-resources", "--namespaced=true", "--verbs=list", "--no-headers")...)
+	if err != nil {
+		return nil, err
+	}
+	return parseAPIResources(out, opts.excludeKinds), nil
+}
+
+// getClusterManifests reads resources from the cluster and returns them as
+// JSON that can be passed to YAMLToTerraformResources. The resources are
+// passed to kubectl get as they are, so they can be anything kubectl get
+// accepts such as "deployments,services" or "deployment/nginx".
+func getClusterManifests(opts clusterOptions) ([]byte, error) {
+	resources := opts.resources
+	if opts.all {
+		all, err := listNamespacedResources(opts)
+		if err != nil {
+			return nil, err
+		}
+		resources = []string{strings.Join(all, ",")}
+	}
+	if len(resources) == 0 {
+		return nil, fmt.Errorf("specify the resources to export or use --all")
+	}
+
+	args := append([]string{"get"}, resources...)
+	args = append(args, "-o", "json")
+	if opts.namespace != "" {
+		args = append(args, "--namespace", opts.namespace)
+	}
+	if opts.labelSelector != "" {
+		args = append(args, "--selector", opts.labelSelector)
+	}
+	return runKubectl(opts.kubectlArgs(args...)...)
+}
+
+// clusterSource returns a source that reads resources from the cluster
+func clusterSource(opts clusterOptions) source {
+	return source{
+		name: "cluster",
+		open: func() (io.ReadCloser, error) {
+			b, err := getClusterManifests(opts)
+			if err != nil {
+				return nil, err
+			}
+			return ioutil.NopCloser(bytes.NewReader(b)), nil
+		},
+	}
+}
+
+// isKubectlPlugin returns true if tfk8s was installed and run as the
+// kubectl-tfk8s plugin
+func isKubectlPlugin(arg0 string) bool {
+	name := strings.TrimSuffix(filepath.Base(arg0), ".exe")
+	return name == "kubectl-tfk8s"
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseAPIResources(t *testing.T) {
+	output := `configmaps                        cm           v1                                true         ConfigMap
+endpoints                         ep           v1                                true         Endpoints
+events                            ev           v1                                true         Event
+pods                              po           v1                                true         Pod
+secrets                                        v1                                true         Secret
+deployments                       deploy       apps/v1                           true         Deployment
+replicasets                       rs           apps/v1                           true         ReplicaSet
+events                            ev           events.k8s.io/v1                  true         Event
+ingresses                         ing          networking.k8s.io/v1              true         Ingress
+certificates                      cert,certs   cert-manager.io/v1                true         Certificate
+`
+
+	resources := parseAPIResources([]byte(output), excludeKinds)
+
+	expected := []string{
+		"configmaps",
+		"secrets",
+		"deployments.apps",
+		"ingresses.networking.k8s.io",
+		"certificates.cert-manager.io",
+	}
+	assert.Equal(t, expected, resources)
+}
+
+func TestGetClusterManifests(t *testing.T) {
+	defer func(f func(...string) ([]byte, error)) { runKubectl = f }(runKubectl)
+
+	calls := [][]string{}
+	runKubectl = func(args ...string) ([]byte, error) {
+		calls = append(calls, args)
+		if args[0] == "api-resources" {
+			return []byte("configmaps   cm   v1   true   ConfigMap\npods   po   v1   true   Pod\n"), nil
+		}
+		return []byte(`{"apiVersion": "v1", "kind": "List", "items": []}`), nil
+	}
+
+	_, err := getClusterManifests(clusterOptions{
+		namespace:    "prod",
+		all:          true,
+		excludeKinds: excludeKinds,
+	})
+	if err != nil {
+		t.Fatal("Getting cluster manifests failed:", err)
+	}
+
+	expected := [][]string{
+		{"api-resources", "--namespaced=true", "--verbs=list", "--no-headers"},
+		{"get", "configmaps", "-o", "json", "--namespace", "prod"},
+	}
+	assert.Equal(t, expected, calls)
+
+	_, err = getClusterManifests(clusterOptions{namespace: "prod"})
+	assert.Error(t, err)
+}
+
+func TestGetClusterManifestsLabelSelector(t *testing.T) {
+	defer func(f func(...string) ([]byte, error)) { runKubectl = f }(runKubectl)
+
+	var args []string
+	runKubectl = func(a ...string) ([]byte, error) {
+		args = a
+		return []byte(`{"apiVersion": "v1", "kind": "List", "items": []}`), nil
+	}
+
+	_, err := getClusterManifests(clusterOptions{
+		namespace:     "web",
+		labelSelector: "app.kubernetes.io/instance=myapp",
+		resources:     []string{"deployments,services"},
+	})
+	if err != nil {
+		t.Fatal("Getting cluster manifests failed:", err)
+	}
+
+	expected := []string{
+		"get", "deployments,services", "-o", "json",
+		"--namespace", "web",
+		"--selector", "app.kubernetes.io/instance=myapp",
+	}
+	assert.Equal(t, expected, args)
+}
+
+func TestGetClusterManifestsKubeconfig(t *testing.T) {
+	defer func(f func(...string) ([]byte, error)) { runKubectl = f }(runKubectl)
+
+	calls := [][]string{}
+	runKubectl = func(args ...string) ([]byte, error) {
+		calls = append(calls, args)
+		if args[0] == "api-resources" {
+			return []byte("configmaps   cm   v1   true   ConfigMap\nsecrets      v1   true   Secret\n"), nil
+		}
+		return []byte(`{"apiVersion": "v1", "kind": "List", "items": []}`), nil
+	}
+
+	opts := clusterOptions{
+		kubeconfig: "/tmp/kubeconfig",
+		context:    "staging",
+		namespace:  "web",
+		resources:  []string{"deployment", "nginx"},
+	}
+	if _, err := getClusterManifests(opts); err != nil {
+		t.Fatal("Getting cluster manifests failed:", err)
+	}
+	opts.all = true
+	if _, err := getClusterManifests(opts); err != nil {
+		t.Fatal("Getting cluster manifests failed:", err)
+	}
+
+	expected := [][]string{
+		{"get", "deployment", "nginx", "-o", "json", "--namespace", "web", "--kubeconfig", "/tmp/kubeconfig", "--context", "staging"},
+		{"api-resources", "--namespaced=true", "--verbs=list", "--no-headers", "--kubeconfig", "/tmp/kubeconfig", "--context", "staging"},
+		{"get", "configmaps,secrets", "-o", "json", "--namespace", "web", "--kubeconfig", "/tmp/kubeconfig", "--context", "staging"},
+	}
+	assert.Equal(t, expected, calls)
+}
+
+func TestIsKubectlPlugin(t *testing.T) {
+	assert.True(t, isKubectlPlugin("/usr/local/bin/kubectl-tfk8s"))
+	assert.True(t, isKubectlPlugin("kubectl-tfk8s.exe"))
+	assert.False(t, isKubectlPlugin("/usr/local/bin/tfk8s"))
+}
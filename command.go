@@ -0,0 +1,34 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// runCommand runs an external command and returns its output. If the
+// command fails the error includes what it printed to stderr.
+func runCommand(name string, args ...string) ([]byte, error) {
+	return runCommandInput(name, nil, args...)
+}
+
+// runCommandInput runs an external command like runCommand, with input on
+// its stdin
+func runCommandInput(name string, input []byte, args ...string) ([]byte, error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command(name, args...)
+	if input != nil {
+		cmd.Stdin = bytes.NewReader(input)
+	}
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = err.Error()
+		}
+		return nil, fmt.Errorf("%s %s: %s", name, strings.Join(args, " "), msg)
+	}
+	return out, nil
+}
@@ -0,0 +1,408 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/terraform-exec/tfexec"
+	flag "github.com/spf13/pflag"
+	cty "github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/function"
+	"github.com/zclconf/go-cty/cty/function/stdlib"
+)
+
+// terraformCommand is the command used by tfk8s compare
+var terraformCommand = "terraform"
+
+// terraformRunner runs the terraform commands tfk8s compare needs, it is
+// implemented by tfexec.Terraform
+type terraformRunner interface {
+	Init(ctx context.Context, opts ...tfexec.InitOption) error
+	Plan(ctx context.Context, opts ...tfexec.PlanOption) (bool, error)
+}
+
+// newTerraform returns a terraformRunner that runs terraform in dir
+var newTerraform = func(dir string) (terraformRunner, error) {
+	execPath, err := exec.LookPath(terraformCommand)
+	if err != nil {
+		return nil, err
+	}
+	return tfexec.NewTerraform(dir, execPath)
+}
+
+// compareCommand is the subcommand that compares the config tfk8s writes
+// with the config Terraform generates for the same objects
+const compareCommand = "compare"
+
+// generatedConfigFile is the file terraform plan writes the generated
+// config to
+const generatedConfigFile = "generated.tf"
+
+// defaultProviderConfig configures the provider from the environment, like
+// KUBE_CONFIG_PATH, when --provider-config isn't set
+const defaultProviderConfig = "provider \"kubernetes\" {}\n"
+
+// generateTerraformConfig writes an import block for each resource to dir
+// and runs terraform plan to generate the config for them, which is
+// returned. The objects have to exist in the cluster the provider is
+// configured for.
+func generateTerraformConfig(dir string, resources []resource, providerConfig string) (string, error) {
+	imports := []string{}
+	for _, r := range resources {
+		r.importID = importID(r)
+		if r.importID == "" {
+			return "", fmt.Errorf("%s has no metadata.name so it can't be imported", r.name)
+		}
+		imports = append(imports, strings.TrimPrefix(importBlock(r), "\n"))
+	}
+
+	if err := writeFileAtomic(filepath.Join(dir, "provider.tf"), []byte(providerConfig), 0644); err != nil {
+		return "", err
+	}
+	if err := writeFileAtomic(filepath.Join(dir, "imports.tf"), []byte(strings.Join(imports, "\n")), 0644); err != nil {
+		return "", err
+	}
+	generated := filepath.Join(dir, generatedConfigFile)
+	if err := os.Remove(generated); err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
+
+	tf, err := newTerraform(dir)
+	if err != nil {
+		return "", err
+	}
+	ctx := context.Background()
+	if err := tf.Init(ctx); err != nil {
+		return "", err
+	}
+	_, planErr := tf.Plan(ctx, tfexec.GenerateConfigOut(generatedConfigFile))
+
+	// plan fails when the generated config isn't valid, which is worth
+	// comparing too, so the error only matters if nothing was generated
+	b, err := ioutil.ReadFile(generated)
+	if err != nil {
+		if planErr != nil {
+			return "", planErr
+		}
+		return "", err
+	}
+	return string(b), nil
+}
+
+// attributeDiff is an attribute of a resource that tfk8s and Terraform
+// write differently. The values are empty when the attribute is missing.
+type attributeDiff struct {
+	address   string
+	path      string
+	tfk8s     string
+	terraform string
+}
+
+// hclFunctions are the functions flattenHCL evaluates, so attributes
+// written with them are compared by their value
+var hclFunctions = map[string]function.Function{
+	"jsonencode": stdlib.JSONEncodeFunc,
+	"jsondecode": stdlib.JSONDecodeFunc,
+	"base64encode": function.New(&function.Spec{
+		Params: []function.Parameter{{Name: "str", Type: cty.String}},
+		Type:   function.StaticReturnType(cty.String),
+		Impl: func(args []cty.Value, _ cty.Type) (cty.Value, error) {
+			return cty.StringVal(base64.StdEncoding.EncodeToString([]byte(args[0].AsString()))), nil
+		},
+	}),
+}
+
+// flattenHCL returns the attributes in the body of the first resource
+// block in src keyed by their path, like manifest.spec.replicas, so two
+// configs can be compared without caring how they are quoted, wrapped or
+// written. Repeated blocks like container are numbered after the first,
+// like container[1]. Values are written as HCL, and expressions that
+// can't be evaluated, like references to variables, are kept as they are
+// written.
+func flattenHCL(src string) (map[string]string, error) {
+	f, diags := hclsyntax.ParseConfig([]byte(src), "", hcl.InitialPos)
+	if diags.HasErrors() {
+		return nil, diags
+	}
+	attrs := map[string]string{}
+	for _, b := range f.Body.(*hclsyntax.Body).Blocks {
+		if b.Type == "resource" {
+			flattenBody(attrs, []byte(src), "", b.Body)
+			break
+		}
+	}
+	return attrs, nil
+}
+
+// joinPath returns the path of key in the object or block at path
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// flattenBody adds the attributes and blocks of body to attrs
+func flattenBody(attrs map[string]string, src []byte, path string, body *hclsyntax.Body) {
+	for name, attr := range body.Attributes {
+		flattenExpression(attrs, src, joinPath(path, name), attr.Expr)
+	}
+	seen := map[string]int{}
+	for _, b := range body.Blocks {
+		key := b.Type
+		if n := seen[b.Type]; n > 0 {
+			key = fmt.Sprintf("%s[%d]", key, n)
+		}
+		seen[b.Type]++
+		flattenBody(attrs, src, joinPath(path, key), b.Body)
+	}
+}
+
+// flattenExpression adds the value of expr to attrs. Objects and lists are
+// flattened item by item, so an item that can't be evaluated only keeps
+// that item as it is written.
+func flattenExpression(attrs map[string]string, src []byte, path string, expr hclsyntax.Expression) {
+	ctx := &hcl.EvalContext{Functions: hclFunctions}
+	switch e := expr.(type) {
+	case *hclsyntax.ObjectConsExpr:
+		if len(e.Items) == 0 {
+			attrs[path] = "{}"
+		}
+		for _, item := range e.Items {
+			key := strings.TrimSpace(string(item.KeyExpr.Range().SliceBytes(src)))
+			if k, diags := item.KeyExpr.Value(ctx); !diags.HasErrors() && k.Type() == cty.String && k.IsKnown() && !k.IsNull() {
+				key = k.AsString()
+			}
+			flattenExpression(attrs, src, joinPath(path, key), item.ValueExpr)
+		}
+		return
+	case *hclsyntax.TupleConsExpr:
+		if len(e.Exprs) == 0 {
+			attrs[path] = "[]"
+		}
+		for i, item := range e.Exprs {
+			flattenExpression(attrs, src, fmt.Sprintf("%s[%d]", path, i), item)
+		}
+		return
+	}
+	v, diags := expr.Value(ctx)
+	if diags.HasErrors() || !v.IsWhollyKnown() {
+		attrs[path] = strings.TrimSpace(string(expr.Range().SliceBytes(src)))
+		return
+	}
+	flattenValue(attrs, path, v)
+}
+
+// flattenValue adds v to attrs, with the attributes of objects and the
+// items of lists under their own path
+func flattenValue(attrs map[string]string, path string, v cty.Value) {
+	ty := v.Type()
+	switch {
+	case v.IsNull():
+		attrs[path] = "null"
+	case ty.IsObjectType() || ty.IsMapType():
+		if v.LengthInt() == 0 {
+			attrs[path] = "{}"
+		}
+		for it := v.ElementIterator(); it.Next(); {
+			k, v := it.Element()
+			flattenValue(attrs, joinPath(path, k.AsString()), v)
+		}
+	case ty.IsTupleType() || ty.IsListType() || ty.IsSetType():
+		if v.LengthInt() == 0 {
+			attrs[path] = "[]"
+		}
+		i := 0
+		for it := v.ElementIterator(); it.Next(); i++ {
+			_, v := it.Element()
+			flattenValue(attrs, fmt.Sprintf("%s[%d]", path, i), v)
+		}
+	case ty == cty.String:
+		attrs[path] = strconv.Quote(v.AsString())
+	case ty == cty.Number:
+		attrs[path] = v.AsBigFloat().Text('f', -1)
+	case ty == cty.Bool:
+		attrs[path] = strconv.FormatBool(v.True())
+	}
+}
+
+// compareResources compares each resource with the resource block at the
+// same address in the config Terraform generated
+func compareResources(resources []resource, generated string) ([]attributeDiff, error) {
+	blocks, err := findResourceBlocks(generated)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", generatedConfigFile, err)
+	}
+	found := map[string]string{}
+	for _, b := range blocks {
+		found[b.address] = b.text
+	}
+
+	diffs := []attributeDiff{}
+	for _, r := range resources {
+		address := r.resourceType + "." + r.name
+		block, ok := found[address]
+		if !ok {
+			diffs = append(diffs, attributeDiff{address: address})
+			continue
+		}
+
+		ours, err := flattenHCL(r.text)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", address, err)
+		}
+		theirs, err := flattenHCL(block)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s: %s", generatedConfigFile, address, err)
+		}
+		paths := []string{}
+		for p := range ours {
+			paths = append(paths, p)
+		}
+		for p := range theirs {
+			if _, ok := ours[p]; !ok {
+				paths = append(paths, p)
+			}
+		}
+		sort.Strings(paths)
+		for _, p := range paths {
+			if ours[p] != theirs[p] {
+				diffs = append(diffs, attributeDiff{address: address, path: p, tfk8s: ours[p], terraform: theirs[p]})
+			}
+		}
+	}
+	return diffs, nil
+}
+
+// writeComparison writes the differences grouped by resource. Attributes
+// only tfk8s writes are marked with -, the ones only Terraform writes
+// with + and the ones they write differently with ~.
+func writeComparison(w io.Writer, diffs []attributeDiff) {
+	address := ""
+	for _, d := range diffs {
+		if d.address != address {
+			if address != "" {
+				fmt.Fprintln(w)
+			}
+			address = d.address
+			fmt.Fprintln(w, address)
+		}
+		switch {
+		case d.path == "":
+			fmt.Fprintln(w, "  Terraform didn't generate config for this resource")
+		case d.terraform == "":
+			fmt.Fprintf(w, "  - %s = %s\n", d.path, d.tfk8s)
+		case d.tfk8s == "":
+			fmt.Fprintf(w, "  + %s = %s\n", d.path, d.terraform)
+		default:
+			fmt.Fprintf(w, "  ~ %s = %s => %s\n", d.path, d.tfk8s, d.terraform)
+		}
+	}
+}
+
+// runCompare runs tfk8s compare with args, the arguments after the
+// subcommand, and returns the exit status. It is 1 if the configs are
+// different, like diff.
+func runCompare(args []string) int {
+	flags := flag.NewFlagSet(compareCommand, flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage of tfk8s %s:\n\n", compareCommand)
+		fmt.Fprintf(os.Stderr, "Imports the objects in the manifests with terraform plan -generate-config-out and compares\n")
+		fmt.Fprintf(os.Stderr, "the config Terraform generates with the config tfk8s writes for them.\n\n")
+		flags.PrintDefaults()
+	}
+	infiles := flags.StringArrayP("file", "f", []string{"-"}, "Input file, directory or URL containing the Kubernetes manifests of objects in the cluster, can be repeated")
+	workDir := flags.String("work-dir", "", "Directory to run terraform in, it is kept so the generated config can be inspected, the default is a temporary directory")
+	providerConfig := flags.String("provider-config", "", "Terraform file configuring the kubernetes provider, the default configures it from the environment like KUBE_CONFIG_PATH")
+	stripServerSide := flags.BoolP("strip", "s", false, "Strip out server side fields - use if you are piping from kubectl get")
+	typed := flags.Bool("typed", false, "Compare the Kubernetes provider's native resources instead of kubernetes_manifest")
+	if err := flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return 0
+		}
+		return 2
+	}
+
+	provider := defaultProviderConfig
+	if *providerConfig != "" {
+		b, err := ioutil.ReadFile(*providerConfig)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\r\n", err.Error())
+			return 2
+		}
+		provider = string(b)
+	}
+
+	paths, err := expandInputPaths(*infiles)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\r\n", err.Error())
+		return 2
+	}
+	sources, err := fileSources(paths, newHTTPClient(30*time.Second, false))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\r\n", err.Error())
+		return 2
+	}
+	resources := []resource{}
+	for _, s := range sources {
+		r, err := s.open()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\r\n", err.Error())
+			return 2
+		}
+		converted, err := convertResources(r,
+			WithSourceName(s.name),
+			WithStripServerSide(*stripServerSide),
+			WithTyped(*typed),
+			WithWarnings(os.Stderr),
+		)
+		r.Close()
+		if err != nil {
+			fmt.Println("error:", err)
+			return 2
+		}
+		resources = append(resources, converted...)
+	}
+
+	dir := *workDir
+	if dir == "" {
+		dir, err = ioutil.TempDir("", "tfk8s-compare")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\r\n", err.Error())
+			return 2
+		}
+		defer os.RemoveAll(dir)
+	} else if err := os.MkdirAll(dir, 0755); err != nil {
+		fmt.Fprintf(os.Stderr, "%s\r\n", err.Error())
+		return 2
+	}
+
+	generated, err := generateTerraformConfig(dir, resources, provider)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\r\n", err.Error())
+		return 2
+	}
+	diffs, err := compareResources(resources, generated)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\r\n", err.Error())
+		return 2
+	}
+	if len(diffs) == 0 {
+		fmt.Println("Terraform generates the same config as tfk8s")
+		return 0
+	}
+	writeComparison(os.Stdout, diffs)
+	return 1
+}
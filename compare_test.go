@@ -0,0 +1,236 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-exec/tfexec"
+	"github.com/stretchr/testify/assert"
+)
+
+var generatedConfig = `# __generated__ by Terraform
+# Please review these resources and move them into your main configuration files.
+
+# __generated__ by Terraform from "apiVersion=apps/v1,kind=Deployment,namespace=web,name=nginx"
+resource "kubernetes_manifest" "deployment_web_nginx" {
+  manifest = {
+    apiVersion = "apps/v1"
+    kind       = "Deployment"
+    metadata = {
+      name      = "nginx"
+      namespace = "web"
+    }
+    spec = {
+      replicas = 1
+      template = {
+        spec = {
+          containers = [{
+            args  = ["--port", "80"]
+            image = "nginx"
+            name  = "nginx"
+          }, {
+            image = "envoy"
+            name  = "envoy"
+          }]
+        }
+      }
+    }
+  }
+}
+`
+
+// fakeTerraform records the terraform commands tfk8s compare runs, and
+// writes the generated config when it plans
+type fakeTerraform struct {
+	dir   string
+	calls []string
+}
+
+func (f *fakeTerraform) Init(ctx context.Context, opts ...tfexec.InitOption) error {
+	f.calls = append(f.calls, "init")
+	return nil
+}
+
+func (f *fakeTerraform) Plan(ctx context.Context, opts ...tfexec.PlanOption) (bool, error) {
+	f.calls = append(f.calls, "plan")
+	if !assert.Equal(nil, []tfexec.PlanOption{tfexec.GenerateConfigOut(generatedConfigFile)}, opts) {
+		return false, errors.New("unexpected plan options")
+	}
+	return true, ioutil.WriteFile(filepath.Join(f.dir, generatedConfigFile), []byte(generatedConfig), 0644)
+}
+
+func TestGenerateTerraformConfig(t *testing.T) {
+	defer func(f func(string) (terraformRunner, error)) { newTerraform = f }(newTerraform)
+
+	dir, err := ioutil.TempDir("", "tfk8s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tf := &fakeTerraform{}
+	newTerraform = func(d string) (terraformRunner, error) {
+		tf.dir = d
+		return tf, nil
+	}
+
+	resources, err := convertResources(strings.NewReader(`apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: nginx
+  namespace: web
+`))
+	if err != nil {
+		t.Fatal("Converting to HCL failed:", err)
+	}
+	generated, err := generateTerraformConfig(dir, resources, defaultProviderConfig)
+	if err != nil {
+		t.Fatal("Generating config failed:", err)
+	}
+	assert.Equal(t, generatedConfig, generated)
+
+	assert.Equal(t, dir, tf.dir)
+	assert.Equal(t, []string{"init", "plan"}, tf.calls)
+
+	imports, err := ioutil.ReadFile(filepath.Join(dir, "imports.tf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, `import {
+  to = kubernetes_manifest.deployment_web_nginx
+  id = "apiVersion=apps/v1,kind=Deployment,namespace=web,name=nginx"
+}
+`, string(imports))
+}
+
+func TestCompareResources(t *testing.T) {
+	resources, err := convertResources(strings.NewReader(`apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: nginx
+  namespace: web
+spec:
+  replicas: 3
+  template:
+    spec:
+      containers:
+      - name: nginx
+        image: nginx
+        args: ["--port", "80"]
+      - name: envoy
+        image: envoy
+        ports:
+        - containerPort: 8080
+---
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: web
+`))
+	if err != nil {
+		t.Fatal("Converting to HCL failed:", err)
+	}
+
+	diffs, err := compareResources(resources, generatedConfig)
+	if err != nil {
+		t.Fatal("Comparing failed:", err)
+	}
+
+	var buf bytes.Buffer
+	writeComparison(&buf, diffs)
+	expected := `kubernetes_manifest.deployment_web_nginx
+  ~ manifest.spec.replicas = 3 => 1
+  - manifest.spec.template.spec.containers[1].ports[0].containerPort = 8080
+
+kubernetes_manifest.namespace_web
+  Terraform didn't generate config for this resource
+`
+	assert.Equal(t, expected, buf.String())
+}
+
+func TestFlattenHCL(t *testing.T) {
+	attrs, err := flattenHCL(`resource "kubernetes_deployment_v1" "nginx" {
+  metadata {
+    name = "nginx"
+  }
+
+  spec {
+    container {
+      name = "a"
+    }
+    container {
+      name = "b"
+    }
+    selector = {}
+  }
+  data = {
+    "config.yaml" = <<-EOT
+      a: 1
+    EOT
+  }
+}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, map[string]string{
+		"metadata.name":          `"nginx"`,
+		"spec.container.name":    `"a"`,
+		"spec.container[1].name": `"b"`,
+		"spec.selector":          "{}",
+		"data.config.yaml":       `"a: 1\n"`,
+	}, attrs)
+}
+
+func TestFlattenHCLEvaluatesValues(t *testing.T) {
+	ours, err := flattenHCL(`resource "kubernetes_manifest" "config" {
+  manifest = {
+    "data" = {
+      "config.json" = jsonencode({ "a" = 1 })
+      "replicas"    = 3
+    }
+    "args" = ["--port", "80"]
+    "name" = var.name
+  }
+}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	theirs, err := flattenHCL(`resource "kubernetes_manifest" "config" {
+  manifest = {
+    args = [
+      "--port",
+      "80",
+    ]
+    data = {
+      "config.json" = "{\"a\":1}"
+      replicas      = 3.0
+    }
+    name = var.name
+  }
+}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, map[string]string{
+		"manifest.args[0]":          `"--port"`,
+		"manifest.args[1]":          `"80"`,
+		"manifest.data.config.json": `"{\"a\":1}"`,
+		"manifest.data.replicas":    "3",
+		"manifest.name":             "var.name",
+	}, ours)
+	assert.Equal(t, ours, theirs)
+}
+
+func TestFlattenHCLInvalid(t *testing.T) {
+	_, err := flattenHCL(`resource "kubernetes_manifest" "config" {`)
+	assert.Error(t, err)
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	cty "github.com/zclconf/go-cty/cty"
+)
+
+// defaultComputedFields are the fields kubernetes_manifest treats as
+// computed when computed_fields isn't set, they are kept when it is
+var defaultComputedFields = []string{"metadata.annotations", "metadata.labels"}
+
+// computedFieldPaths are the fields controllers and admission plugins set
+// after an object is created, by kind. [*] matches every item of a list.
+// They are only added when the manifest sets them.
+var computedFieldPaths = map[string][]string{
+	"Service":                        {"spec.clusterIP", "spec.clusterIPs"},
+	"MutatingWebhookConfiguration":   {"webhooks[*].clientConfig.caBundle"},
+	"ValidatingWebhookConfiguration": {"webhooks[*].clientConfig.caBundle"},
+	"CustomResourceDefinition":       {"spec.conversion.webhook.clientConfig.caBundle"},
+	"APIService":                     {"spec.caBundle"},
+	"Pod":                            {"spec.tolerations"},
+}
+
+// computedField is a field --extra-computed-fields adds to computed_fields,
+// on the resources of kind or every resource if kind is empty
+type computedField struct {
+	kind string
+	path string
+}
+
+// parseComputedField parses a field like spec.replicas or
+// Deployment:spec.replicas
+func parseComputedField(s string) (computedField, error) {
+	f := computedField{path: s}
+	if i := strings.Index(s, ":"); i >= 0 {
+		f.kind, f.path = s[:i], s[i+1:]
+	}
+	if f.path == "" {
+		return computedField{}, fmt.Errorf("invalid --extra-computed-fields %q, must be like spec.replicas or Deployment:spec.replicas", s)
+	}
+	return f, nil
+}
+
+// expandPath returns the paths in v that match pattern, a path like
+// webhooks[*].clientConfig.caBundle where [*] matches every item of a
+// list, in the syntax computed_fields uses
+func expandPath(v cty.Value, pattern string) []string {
+	var expand func(v cty.Value, prefix string, steps []string) []string
+	expand = func(v cty.Value, prefix string, steps []string) []string {
+		if len(steps) == 0 {
+			return []string{prefix}
+		}
+		step, all := strings.TrimSuffix(steps[0], "[*]"), strings.HasSuffix(steps[0], "[*]")
+		attr, ok := getPath(v, step)
+		if !ok {
+			return nil
+		}
+		if prefix != "" {
+			step = prefix + "." + step
+		}
+		if !all {
+			return expand(attr, step, steps[1:])
+		}
+		if attr.IsMarked() || !(attr.Type().IsTupleType() || attr.Type().IsListType()) {
+			return nil
+		}
+		paths := []string{}
+		for i, item := range attr.AsValueSlice() {
+			paths = append(paths, expand(item, fmt.Sprintf("%s[%d]", step, i), steps[1:])...)
+		}
+		return paths
+	}
+	return expand(v, "", strings.Split(pattern, "."))
+}
+
+// resourceComputedFields returns the computed_fields of a manifest of kind,
+// or nil if it only needs the fields kubernetes_manifest already treats as
+// computed
+func resourceComputedFields(manifest cty.Value, kind string, extra []computedField) []string {
+	fields := []string{}
+	for _, pattern := range computedFieldPaths[kind] {
+		fields = append(fields, expandPath(manifest, pattern)...)
+	}
+	for _, f := range extra {
+		if f.kind != "" && f.kind != kind {
+			continue
+		}
+		if strings.Contains(f.path, "[*]") {
+			fields = append(fields, expandPath(manifest, f.path)...)
+		} else {
+			fields = append(fields, f.path)
+		}
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+	return addComputedFields(nil, fields...)
+}
+
+// addComputedFields adds fields to the computed_fields in computed, with
+// the fields kubernetes_manifest treats as computed by default, and
+// returns them sorted without duplicates
+func addComputedFields(computed []string, fields ...string) []string {
+	seen := map[string]bool{}
+	result := []string{}
+	for _, f := range append(append(append([]string{}, defaultComputedFields...), computed...), fields...) {
+		if !seen[f] {
+			seen[f] = true
+			result = append(result, f)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+// computedFieldsAttribute returns the computed_fields argument of r, or ""
+// if it doesn't have one
+func computedFieldsAttribute(r resource) string {
+	if len(r.computedFields) == 0 {
+		return ""
+	}
+	var buf strings.Builder
+	buf.WriteString("\n  computed_fields = [\n")
+	for _, f := range r.computedFields {
+		fmt.Fprintf(&buf, "    %q,\n", f)
+	}
+	buf.WriteString("  ]\n")
+	return buf.String()
+}
+
+// scaleTargets returns the objects the HorizontalPodAutoscalers in
+// resources scale, by module, kind, namespace and name
+func scaleTargets(resources []resource, o options) map[string]bool {
+	targets := map[string]bool{}
+	for _, r := range resources {
+		if r.kind != "HorizontalPodAutoscaler" || !r.forEach.IsNull() {
+			continue
+		}
+		kind, _ := getString(r.manifest, "spec", "scaleTargetRef", "kind")
+		name, ok := getString(r.manifest, "spec", "scaleTargetRef", "name")
+		if ok {
+			targets[scaleTargetKey(r.module, kind, o.namespaceOrDefault(r.namespace), name)] = true
+		}
+	}
+	return targets
+}
+
+// scaleTargetKey is the key of an object in scaleTargets
+func scaleTargetKey(module, kind, namespace, name string) string {
+	return strings.Join([]string{module, kind, namespace, name}, "\x00")
+}
+
+// isScaled returns true if r is scaled by one of the targets and sets its
+// replicas
+func isScaled(r resource, targets map[string]bool, o options) bool {
+	if !targets[scaleTargetKey(r.module, r.kind, o.namespaceOrDefault(r.namespace), r.objectName)] {
+		return false
+	}
+	_, ok := getPath(r.manifest, "spec", "replicas")
+	return ok
+}
+
+// computeScaledReplicas adds spec.replicas to the computed_fields of the
+// resources a HorizontalPodAutoscaler converted in the same run scales, as
+// the autoscaler changes it. The resources are converted again with opts.
+func computeScaledReplicas(resources []resource, opts ...Option) ([]resource, error) {
+	o := newOptions(opts)
+	format, err := lookupFormat(o.format)
+	if err != nil {
+		return nil, err
+	}
+
+	targets := scaleTargets(resources, o)
+	for i, r := range resources {
+		if r.resourceType != resourceType || r.typed || !r.forEach.IsNull() || !isScaled(r, targets, o) {
+			continue
+		}
+		r.computedFields = addComputedFields(r.computedFields, "spec.replicas")
+		if r.text, err = format.resource(r, o); err != nil {
+			return nil, err
+		}
+		resources[i] = r
+	}
+	return resources, nil
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestComputedFields(t *testing.T) {
+	yaml := `apiVersion: v1
+kind: Service
+metadata:
+  name: web
+spec:
+  clusterIP: 10.0.0.1
+---
+apiVersion: admissionregistration.k8s.io/v1
+kind: ValidatingWebhookConfiguration
+metadata:
+  name: check
+webhooks:
+- name: a.example.com
+  clientConfig:
+    caBundle: Cg==
+- name: b.example.com
+  clientConfig:
+    url: https://example.com
+- name: c.example.com
+  clientConfig:
+    caBundle: Cg==
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: web
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  replicas: 2
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: other
+spec:
+  replicas: 2
+---
+apiVersion: autoscaling/v2
+kind: HorizontalPodAutoscaler
+metadata:
+  name: web
+spec:
+  scaleTargetRef:
+    apiVersion: apps/v1
+    kind: Deployment
+    name: web
+`
+	resources, err := convertResources(strings.NewReader(yaml), WithComputedFields(computedField{kind: "ConfigMap", path: "data"}))
+	if err != nil {
+		t.Fatal("Converting to HCL failed:", err)
+	}
+	resources, err = computeScaledReplicas(resources, WithComputedFields())
+	assert.NoError(t, err)
+
+	assert.Equal(t, []string{"metadata.annotations", "metadata.labels", "spec.clusterIP"}, resources[0].computedFields)
+	assert.Contains(t, resources[0].text, `
+  computed_fields = [
+    "metadata.annotations",
+    "metadata.labels",
+    "spec.clusterIP",
+  ]
+}
+`)
+	assert.Equal(t, []string{
+		"metadata.annotations",
+		"metadata.labels",
+		"webhooks[0].clientConfig.caBundle",
+		"webhooks[2].clientConfig.caBundle",
+	}, resources[1].computedFields)
+	assert.Equal(t, []string{"data", "metadata.annotations", "metadata.labels"}, resources[2].computedFields)
+	assert.Equal(t, []string{"metadata.annotations", "metadata.labels", "spec.replicas"}, resources[3].computedFields)
+	assert.Contains(t, resources[3].text, `"spec.replicas",`)
+	assert.Empty(t, resources[4].computedFields)
+	assert.NotContains(t, resources[4].text, "computed_fields")
+}
+
+func TestParseComputedField(t *testing.T) {
+	f, err := parseComputedField("Deployment:spec.replicas")
+	assert.NoError(t, err)
+	assert.Equal(t, computedField{kind: "Deployment", path: "spec.replicas"}, f)
+
+	_, err = parseComputedField("Deployment:")
+	assert.Error(t, err)
+}
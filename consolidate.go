@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jrhouston/tfk8s/contrib/hashicorp/terraform"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// withMetadata returns manifest with the metadata fields in values set to
+// them. Fields that aren't in the manifest are left out.
+func withMetadata(manifest cty.Value, values map[string]cty.Value) cty.Value {
+	mm := manifest.AsValueMap()
+	metadata := mm["metadata"].AsValueMap()
+	for k, v := range values {
+		if _, ok := metadata[k]; ok {
+			metadata[k] = v
+		}
+	}
+	mm["metadata"] = cty.ObjectVal(metadata)
+	return cty.ObjectVal(mm)
+}
+
+// eachValue returns the expression for the attribute of each.value
+func eachValue(attr string) cty.Value {
+	return cty.StringVal("each.value." + attr).Mark(terraform.Expression)
+}
+
+// consolidateResources replaces resources that only differ by the name and
+// namespace of the object with a single resource using for_each over a map
+// of the names and namespaces. The resources are converted again with opts.
+func consolidateResources(resources []resource, opts ...Option) ([]resource, error) {
+	o := newOptions(opts)
+	format, err := lookupFormat(o.format)
+	if err != nil {
+		return nil, err
+	}
+
+	// group the resources by their manifest with the name and namespace
+	// taken out
+	generic := make([]cty.Value, len(resources))
+	groups := [][]int{}
+	for i, r := range resources {
+		generic[i] = withMetadata(r.manifest, map[string]cty.Value{
+			"name":      eachValue("name"),
+			"namespace": eachValue("namespace"),
+		})
+		grouped := false
+		for j, group := range groups {
+			first := resources[group[0]]
+			if first.resourceType == r.resourceType && first.typed == r.typed && generic[group[0]].RawEquals(generic[i]) {
+				groups[j] = append(group, i)
+				grouped = true
+				break
+			}
+		}
+		if !grouped {
+			groups = append(groups, []int{i})
+		}
+	}
+
+	names := map[string]bool{}
+	for _, r := range resources {
+		names[r.name] = true
+	}
+	consolidated := []resource{}
+	for _, group := range groups {
+		if len(group) == 1 {
+			consolidated = append(consolidated, resources[group[0]])
+			continue
+		}
+
+		// only the fields that differ come from each.value
+		r := resources[group[0]]
+		sameName, sameNamespace := true, true
+		for _, i := range group {
+			sameName = sameName && resources[i].objectName == r.objectName
+			sameNamespace = sameNamespace && resources[i].namespace == r.namespace
+		}
+		each := map[string]cty.Value{}
+		if !sameName {
+			each["name"] = eachValue("name")
+		}
+		if !sameNamespace {
+			each["namespace"] = eachValue("namespace")
+		}
+
+		forEach := map[string]cty.Value{}
+		for _, i := range group {
+			object, key := map[string]cty.Value{}, resources[i].objectName
+			if resources[i].namespace != "" {
+				key = resources[i].namespace + "/" + key
+			}
+			if !sameName {
+				object["name"] = cty.StringVal(resources[i].objectName)
+			}
+			if !sameNamespace {
+				object["namespace"] = cty.StringVal(resources[i].namespace)
+			}
+			forEach[key] = cty.ObjectVal(object)
+		}
+
+		name := r.kind
+		if sameName {
+			name += "_" + r.objectName
+		}
+		name = snakify(name)
+		for n, base := 2, name; names[name]; n++ {
+			name = fmt.Sprintf("%s_%d", base, n)
+		}
+		names[name] = true
+
+		r.name = name
+		r.manifest = withMetadata(r.manifest, each)
+		r.forEach = cty.ObjectVal(forEach)
+		r.source, r.importID = "", ""
+		if !sameNamespace {
+			r.namespace = ""
+		}
+		if r.text, err = format.resource(r, o); err != nil {
+			return nil, err
+		}
+		keys := []string{}
+		for k := range forEach {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		o.note("consolidated %d %s resources into %s.%s for %s", len(group), r.kind, r.resourceType, r.name, strings.Join(keys, ", "))
+		consolidated = append(consolidated, r)
+	}
+	return consolidated, nil
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var consolidateYAML = `apiVersion: rbac.authorization.k8s.io/v1
+kind: Role
+metadata:
+  name: reader
+  namespace: dev
+rules:
+- verbs: ["get"]
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: Role
+metadata:
+  name: reader
+  namespace: prod
+rules:
+- verbs: ["get"]
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: Role
+metadata:
+  name: writer
+  namespace: prod
+rules:
+- verbs: ["create"]
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: a
+data:
+  x: "1"
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: b
+data:
+  x: "1"
+`
+
+func TestConsolidateResources(t *testing.T) {
+	resources, err := convertResources(strings.NewReader(consolidateYAML))
+	if err != nil {
+		t.Fatal("Converting to HCL failed:", err)
+	}
+	resources, err = consolidateResources(resources)
+	assert.NoError(t, err)
+	assert.Len(t, resources, 3)
+
+	expected := `resource "kubernetes_manifest" "role_reader" {
+  for_each = {
+    "dev/reader" = {
+      "namespace" = "dev"
+    }
+    "prod/reader" = {
+      "namespace" = "prod"
+    }
+  }
+
+  manifest = {
+    "apiVersion" = "rbac.authorization.k8s.io/v1"
+    "kind" = "Role"
+    "metadata" = {
+      "name" = "reader"
+      "namespace" = each.value.namespace
+    }
+    "rules" = [
+      {
+        "verbs" = [
+          "get",
+        ]
+      },
+    ]
+  }
+}
+`
+	assert.Equal(t, expected, resources[0].text)
+	// the writer Role has different rules, so it is left alone
+	assert.Equal(t, "role_prod_writer", resources[1].name)
+	assert.True(t, resources[1].forEach.IsNull())
+
+	assert.Equal(t, "configmap", resources[2].name)
+	assert.Contains(t, resources[2].text, "      \"name\" = each.value.name\n")
+	assert.Contains(t, resources[2].text, "    \"b\" = {\n      \"name\" = \"b\"\n    }\n")
+}
+
+func TestConsolidateResourcesTFJSON(t *testing.T) {
+	resources, err := convertResources(strings.NewReader(consolidateYAML), WithFormat("tfjson"))
+	if err != nil {
+		t.Fatal("Converting to JSON failed:", err)
+	}
+	resources, err = consolidateResources(resources, WithFormat("tfjson"))
+	assert.NoError(t, err)
+	text, err := joinResources(tfjsonFormat{}, resources)
+	assert.NoError(t, err)
+	assert.Contains(t, text, `"namespace": "${each.value.namespace}"`)
+	assert.Contains(t, text, `"for_each": {`)
+}
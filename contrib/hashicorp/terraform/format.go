@@ -15,6 +15,12 @@ import (
 	"github.com/zclconf/go-cty/cty"
 )
 
+// Expression marks a string value that holds an HCL expression, such as a
+// function call, so FormatValue writes it out as it is instead of quoting it
+const Expression = expressionMark("expression")
+
+type expressionMark string
+
 // FormatValue formats a value in a way that resembles Terraform language syntax
 // and uses the type conversion functions where necessary to indicate exactly
 // what type it is given, so that equality test failures can be quickly
@@ -24,6 +30,10 @@ func FormatValue(v cty.Value, indent int, stripKeyQuotes bool) string {
 		return "(known after apply)"
 	}
 	if v.IsMarked() {
+		if v.HasMark(Expression) {
+			expr, _ := v.Unmark()
+			return expr.AsString()
+		}
 		return "(sensitive)"
 	}
 	if v.IsNull() {
@@ -56,7 +66,7 @@ func FormatValue(v cty.Value, indent int, stripKeyQuotes bool) string {
 			if formatted, isMultiline := formatMultilineString(v, indent); isMultiline {
 				return formatted
 			}
-			return strconv.Quote(v.AsString())
+			return strconv.Quote(escapeTemplateSequences(v.AsString()))
 		case cty.Number:
 			bf := v.AsBigFloat()
 			return bf.Text('f', -1)
@@ -83,8 +93,16 @@ func FormatValue(v cty.Value, indent int, stripKeyQuotes bool) string {
 	return fmt.Sprintf("%#v", v)
 }
 
+// escapeTemplateSequences escapes the ${ and %{ sequences that Terraform
+// would otherwise treat as the start of an interpolation or a directive,
+// so strings like shell scripts in a ConfigMap come out unchanged
+func escapeTemplateSequences(s string) string {
+	s = strings.ReplaceAll(s, "${", "$${")
+	return strings.ReplaceAll(s, "%{", "%%{")
+}
+
 func formatMultilineString(v cty.Value, indent int) (string, bool) {
-	str := v.AsString()
+	str := escapeTemplateSequences(v.AsString())
 	lines := strings.Split(str, "\n")
 	if len(lines) < 2 {
 		return "", false
@@ -74,6 +74,23 @@ EOS
 EOT
 EOU
 EOT_`,
+		},
+		{
+			cty.StringVal("echo ${HOME} %{if true}yes%{endif} $${x}"),
+			`"echo $${HOME} %%{if true}yes%%{endif} $$${x}"`,
+		},
+		{
+			cty.StringVal("#!/bin/sh\necho ${USER}"),
+			`<<EOT
+#!/bin/sh
+echo $${USER}
+EOT`,
+		},
+		{
+			cty.ObjectVal(map[string]cty.Value{"${key}": cty.StringVal("value")}),
+			`{
+  "$${key}" = "value"
+}`,
 		},
 		{
 			cty.ObjectVal(map[string]cty.Value{"foo": cty.StringVal("boop\nbeep")}),
@@ -176,6 +193,14 @@ EOT_`,
 			cty.StringVal("sensitive value").Mark("sensitive"),
 			"(sensitive)",
 		},
+		{
+			cty.ObjectVal(map[string]cty.Value{
+				"a": cty.StringVal(`filebase64("${path.module}/a.bin")`).Mark(Expression),
+			}),
+			`{
+  "a" = filebase64("${path.module}/a.bin")
+}`,
+		},
 	}
 
 	for _, test := range tests {
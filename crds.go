@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+)
+
+// crdKind is the group and kind of the objects a CustomResourceDefinition
+// defines
+type crdKind struct {
+	group, kind string
+}
+
+// definedKind returns the group and kind the CustomResourceDefinition r
+// defines, and false if r isn't a CustomResourceDefinition
+func definedKind(r resource) (crdKind, bool) {
+	if r.kind != "CustomResourceDefinition" || !r.forEach.IsNull() {
+		return crdKind{}, false
+	}
+	group, _ := getString(r.manifest, "spec", "group")
+	kind, ok := getString(r.manifest, "spec", "names", "kind")
+	return crdKind{group, kind}, ok
+}
+
+// separateCRDs splits the CustomResourceDefinitions out of resources, so
+// they can be written to their own file and applied before the custom
+// resources that need them
+func separateCRDs(resources []resource) (crds, rest []resource) {
+	for _, r := range resources {
+		if r.kind == "CustomResourceDefinition" {
+			crds = append(crds, r)
+		} else {
+			rest = append(rest, r)
+		}
+	}
+	return crds, rest
+}
+
+// crdWarnings returns a warning for each kubernetes_manifest for a custom
+// resource whose CustomResourceDefinition is converted in the same run.
+// kubernetes_manifest needs the CRD to be in the cluster to plan the
+// custom resource, so a fresh terraform apply of both fails.
+func crdWarnings(resources []resource) []string {
+	crds := map[crdKind]string{}
+	for _, r := range resources {
+		if k, ok := definedKind(r); ok {
+			crds[k] = r.objectName
+		}
+	}
+	if len(crds) == 0 {
+		return nil
+	}
+
+	warnings := []string{}
+	warned := map[crdKind]bool{}
+	for _, r := range resources {
+		if r.resourceType != resourceType || r.typed {
+			continue
+		}
+		apiVersion, ok := getString(r.manifest, "apiVersion")
+		if !ok {
+			continue
+		}
+		k := crdKind{apiGroup(apiVersion), r.kind}
+		crd, ok := crds[k]
+		if !ok || warned[k] {
+			continue
+		}
+		warned[k] = true
+		warnings = append(warnings, fmt.Sprintf("%s can't plan the %s resources until the CustomResourceDefinition %s is in the cluster, apply it first with -target or use --crds-output to write it to its own configuration", resourceType, r.kind, crd))
+	}
+	return warnings
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSeparateCRDs(t *testing.T) {
+	resources, err := convertResources(strings.NewReader(dependsYAML))
+	if err != nil {
+		t.Fatal("Converting to HCL failed:", err)
+	}
+
+	crds, rest := separateCRDs(resources)
+	if assert.Len(t, crds, 1) {
+		assert.Equal(t, "customresourcedefinition_widgets_example_com", crds[0].name)
+	}
+	assert.Len(t, rest, len(resources)-1)
+	assert.Empty(t, crdWarnings(rest))
+}
+
+func TestCRDWarnings(t *testing.T) {
+	resources, err := convertResources(strings.NewReader(dependsYAML + `---
+apiVersion: example.com/v1
+kind: Widget
+metadata:
+  name: other
+`))
+	if err != nil {
+		t.Fatal("Converting to HCL failed:", err)
+	}
+
+	// one warning for each kind
+	assert.Equal(t, []string{
+		"kubernetes_manifest can't plan the Widget resources until the CustomResourceDefinition widgets.example.com is in the cluster, apply it first with -target or use --crds-output to write it to its own configuration",
+	}, crdWarnings(resources))
+
+	resources, err = convertResources(strings.NewReader(dependsYAML), WithTarget(kubectlResourceType))
+	if err != nil {
+		t.Fatal("Converting to HCL failed:", err)
+	}
+	assert.Empty(t, crdWarnings(resources))
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"strings"
+
+	cty "github.com/zclconf/go-cty/cty"
+)
+
+// crossplaneObjectAPIVersion is the apiVersion of the Object resource of
+// Crossplane's provider-kubernetes
+const crossplaneObjectAPIVersion = "kubernetes.crossplane.io/v1alpha2"
+
+// crossplaneObject wraps a manifest in a provider-kubernetes Object, so
+// Crossplane manages the object in the cluster set by providerConfig.
+// Objects are cluster scoped, so the name is made from the resource name,
+// which already includes the namespace.
+func crossplaneObject(doc cty.Value, resourceName, providerConfig string) cty.Value {
+	return cty.ObjectVal(map[string]cty.Value{
+		"apiVersion": cty.StringVal(crossplaneObjectAPIVersion),
+		"kind":       cty.StringVal("Object"),
+		"metadata": cty.ObjectVal(map[string]cty.Value{
+			"name": cty.StringVal(strings.ReplaceAll(resourceName, "_", "-")),
+		}),
+		"spec": cty.ObjectVal(map[string]cty.Value{
+			"forProvider": cty.ObjectVal(map[string]cty.Value{
+				"manifest": doc,
+			}),
+			"providerConfigRef": cty.ObjectVal(map[string]cty.Value{
+				"name": cty.StringVal(providerConfig),
+			}),
+		}),
+	})
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestYAMLToTerraformResourcesCrossplaneObject(t *testing.T) {
+	yaml := `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: test
+  namespace: web
+data:
+  TEST: test
+`
+
+	output, err := YAMLToTerraformResources(strings.NewReader(yaml), WithCrossplaneObject("production"), WithFormat("yaml"))
+	if err != nil {
+		t.Fatal("Converting to YAML failed:", err)
+	}
+
+	expected := `apiVersion: kubernetes.crossplane.io/v1alpha2
+kind: Object
+metadata:
+  name: configmap-web-test
+spec:
+  forProvider:
+    manifest:
+      apiVersion: v1
+      data:
+        TEST: test
+      kind: ConfigMap
+      metadata:
+        name: test
+        namespace: web
+  providerConfigRef:
+    name: production
+`
+	assert.Equal(t, expected, output)
+
+	output, err = YAMLToTerraformResources(strings.NewReader(yaml), WithCrossplaneObject("production"))
+	if err != nil {
+		t.Fatal("Converting to HCL failed:", err)
+	}
+	assert.Contains(t, output, `resource "kubernetes_manifest" "configmap_web_test" {`)
+	assert.Contains(t, output, `"kind" = "Object"`)
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// cueCommand is the command used to evaluate CUE files
+var cueCommand = "cue"
+
+// runCue runs cue with the supplied arguments and returns its output
+var runCue = func(args ...string) ([]byte, error) {
+	return runCommand(cueCommand, args...)
+}
+
+// isCUEFile returns true if the file needs to be evaluated with cue
+func isCUEFile(path string) bool {
+	return strings.ToLower(filepath.Ext(path)) == ".cue"
+}
+
+// isKubernetesObject returns true if the value has an apiVersion and a kind
+func isKubernetesObject(v map[string]interface{}) bool {
+	_, hasAPIVersion := v["apiVersion"]
+	_, hasKind := v["kind"]
+	return hasAPIVersion && hasKind
+}
+
+// collectObjects walks the value and returns every Kubernetes object in it.
+// Map keys are visited in sorted order so the output is deterministic.
+func collectObjects(v interface{}) []interface{} {
+	switch vv := v.(type) {
+	case map[string]interface{}:
+		if isKubernetesObject(vv) {
+			return []interface{}{vv}
+		}
+		keys := []string{}
+		for k := range vv {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		objects := []interface{}{}
+		for _, k := range keys {
+			objects = append(objects, collectObjects(vv[k])...)
+		}
+		return objects
+	case []interface{}:
+		objects := []interface{}{}
+		for _, e := range vv {
+			objects = append(objects, collectObjects(e)...)
+		}
+		return objects
+	}
+	return nil
+}
+
+// evaluateCUE exports a CUE file and returns a JSON list of every
+// Kubernetes object it contains. CUE configurations usually export
+// a struct of objects rather than the objects themselves.
+func evaluateCUE(path string) ([]byte, error) {
+	out, err := runCue("export", path, "--out", "json")
+	if err != nil {
+		return nil, err
+	}
+
+	var v interface{}
+	dec := json.NewDecoder(bytes.NewReader(out))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return json.Marshal(collectObjects(v))
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOpenInputCUE(t *testing.T) {
+	defer func(f func(...string) ([]byte, error)) { runCue = f }(runCue)
+
+	var args []string
+	runCue = func(a ...string) ([]byte, error) {
+		args = a
+		return []byte(`{
+  "service": {
+    "web": {"apiVersion": "v1", "kind": "Service", "metadata": {"name": "web"}, "spec": {"ports": [{"port": 8080}]}}
+  },
+  "configMap": {
+    "web": {"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "web"}}
+  },
+  "replicas": 3
+}`), nil
+	}
+
+	r, err := openInput("kube.cue", nil)
+	if err != nil {
+		t.Fatal("Evaluating CUE failed:", err)
+	}
+	assert.Equal(t, []string{"export", "kube.cue", "--out", "json"}, args)
+
+	output, err := YAMLToTerraformResources(r)
+	if err != nil {
+		t.Fatal("Converting to HCL failed:", err)
+	}
+
+	expected := `
+resource "kubernetes_manifest" "configmap_web" {
+  manifest = {
+    "apiVersion" = "v1"
+    "kind" = "ConfigMap"
+    "metadata" = {
+      "name" = "web"
+    }
+  }
+}
+
+resource "kubernetes_manifest" "service_web" {
+  manifest = {
+    "apiVersion" = "v1"
+    "kind" = "Service"
+    "metadata" = {
+      "name" = "web"
+    }
+    "spec" = {
+      "ports" = [
+        {
+          "port" = 8080
+        },
+      ]
+    }
+  }
+}`
+	assert.Equal(t, strings.TrimSpace(expected), strings.TrimSpace(output))
+}
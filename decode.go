@@ -0,0 +1,413 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	cty "github.com/zclconf/go-cty/cty"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+
+	yamlv3 "gopkg.in/yaml.v3"
+	yaml "sigs.k8s.io/yaml"
+)
+
+// document is a single manifest read from a stream
+type document struct {
+	// raw is the text of the document as it appeared in the stream
+	raw string
+	// json is the document converted to JSON
+	json []byte
+	// index is the position of the document in the stream, starting at 1
+	index int
+	// line is the line the document starts on, or 0 if it isn't known
+	line int
+}
+
+// documentError is an error reading or converting a document, with the
+// position of the document so the user can find it
+type documentError struct {
+	source string
+	index  int
+	line   int
+	err    error
+}
+
+func (e *documentError) Error() string {
+	msg := fmt.Sprintf("document %d", e.index)
+	if e.line > 0 {
+		msg += fmt.Sprintf(", line %d", e.line)
+	}
+	if e.source != "" && e.source != "-" {
+		msg = e.source + ": " + msg
+	}
+	return fmt.Sprintf("%s: %s", msg, e.err)
+}
+
+// documentReader reads a stream of YAML or JSON manifests one document
+// at a time, so documents can be converted as soon as they arrive
+type documentReader struct {
+	r *bufio.Reader
+
+	// json is set once we know the stream is JSON
+	json *json.Decoder
+	// yaml is set once we know the stream is YAML
+	yaml bool
+	// pending is the start of the next YAML document
+	pending string
+	// explicit is set when the current YAML document was started with "---"
+	explicit bool
+	// lines is the number of lines read so far
+	lines int
+	// count is the number of documents returned so far
+	count int
+	// done is set when the rest of the stream can't be read
+	done bool
+
+	// queue holds documents that have been decoded but not returned yet
+	queue []document
+}
+
+// newDocumentReader returns a documentReader that reads from r
+func newDocumentReader(r io.Reader) *documentReader {
+	return &documentReader{r: bufio.NewReader(r)}
+}
+
+// isDocumentMarker returns true if the line is a YAML document marker,
+// either the "---" that starts a document or the "..." that ends one.
+// The YAML spec doesn't allow these at the start of a line anywhere inside
+// a document, so unlike looking for "\n---" this can't split a document
+// in the middle of a block scalar.
+func isDocumentMarker(line, marker string) bool {
+	if !strings.HasPrefix(line, marker) {
+		return false
+	}
+	rest := line[len(marker):]
+	return rest == "" || strings.ContainsRune(" \t\r\n", rune(rest[0]))
+}
+
+// isBlankDocument returns true if the document only contains
+// whitespace, comments and directives
+func isBlankDocument(doc string) bool {
+	for _, line := range strings.Split(doc, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") && !strings.HasPrefix(line, "%") {
+			return false
+		}
+	}
+	return true
+}
+
+// utf8BOM is the byte order mark some Windows editors add to UTF-8 files
+var utf8BOM = []byte("\xef\xbb\xbf")
+
+// detect peeks at the first non-whitespace character of the stream
+// to work out if it is JSON or YAML
+func (d *documentReader) detect() error {
+	if b, err := d.r.Peek(len(utf8BOM)); err == nil && bytes.Equal(b, utf8BOM) {
+		d.r.Discard(len(utf8BOM))
+	}
+	for {
+		b, err := d.r.Peek(1)
+		if err != nil {
+			return err
+		}
+		switch b[0] {
+		case '\n':
+			d.lines++
+			fallthrough
+		case ' ', '\t', '\r':
+			d.r.ReadByte()
+			continue
+		case '{', '[':
+			d.json = json.NewDecoder(d.r)
+		default:
+			d.yaml = true
+		}
+		return nil
+	}
+}
+
+// readJSON reads the next JSON document into the queue. Top-level arrays
+// are treated as a list of documents.
+func (d *documentReader) readJSON() error {
+	var raw json.RawMessage
+	if err := d.json.Decode(&raw); err != nil {
+		if err == io.EOF {
+			return err
+		}
+		// the decoder can't carry on after a syntax error
+		d.done = true
+		// failed documents still count towards the position of the rest
+		d.count++
+		return &documentError{index: d.count, err: err}
+	}
+	if !bytes.HasPrefix(bytes.TrimSpace(raw), []byte("[")) {
+		d.queue = append(d.queue, document{raw: string(raw), json: raw})
+		return nil
+	}
+
+	var items []json.RawMessage
+	if err := json.Unmarshal(raw, &items); err != nil {
+		d.count++
+		return &documentError{index: d.count, err: err}
+	}
+	for _, item := range items {
+		d.queue = append(d.queue, document{raw: string(item), json: item})
+	}
+	return nil
+}
+
+// nextYAMLChunk reads lines up to the next document marker and returns
+// them. Documents are returned as soon as their terminating marker has
+// been read, rather than waiting for the start of the next document.
+// Documents that only contain comments are returned if they were started
+// with "---", so they can be reported as empty. The line the chunk starts
+// on is returned with it.
+func (d *documentReader) nextYAMLChunk() (string, int, error) {
+	var doc strings.Builder
+	start := d.chunkStart()
+	doc.WriteString(d.pending)
+	d.pending = ""
+	for {
+		line, err := d.r.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return "", 0, err
+		}
+		if line != "" {
+			d.lines++
+		}
+		// files saved on Windows should convert the same as everywhere else
+		if strings.HasSuffix(line, "\r\n") {
+			line = line[:len(line)-2] + "\n"
+		} else if err == io.EOF {
+			line = strings.TrimSuffix(line, "\r")
+		}
+
+		marker := isDocumentMarker(line, "---")
+		if marker || isDocumentMarker(line, "...") {
+			chunk := doc.String()
+			explicit := d.explicit
+			d.explicit = marker
+			if marker {
+				// keep anything that follows the marker for the next document
+				d.pending = line[3:]
+			}
+			if explicit || !isBlankDocument(chunk) {
+				return chunk, start, nil
+			}
+			doc.Reset()
+			start = d.chunkStart()
+			doc.WriteString(d.pending)
+			d.pending = ""
+			line = ""
+		}
+		doc.WriteString(line)
+
+		if err == io.EOF {
+			if d.explicit || !isBlankDocument(doc.String()) {
+				d.explicit = false
+				return doc.String(), start, nil
+			}
+			return "", 0, io.EOF
+		}
+	}
+}
+
+// chunkStart returns the line the next YAML chunk starts on, which is
+// the line of the "---" marker if there is something pending from it
+func (d *documentReader) chunkStart() int {
+	if strings.TrimSpace(d.pending) == "" {
+		// the YAML parser miscounts lines when the document starts with
+		// an empty line, so drop the newline left over from the marker
+		d.pending = ""
+		return d.lines + 1
+	}
+	return d.lines
+}
+
+// yamlErrorLine matches the line number at the start of a YAML syntax error
+var yamlErrorLine = regexp.MustCompile(`^yaml: line (\d+): `)
+
+// yamlErrorPosition returns the line a YAML syntax error refers to and
+// the error without its position. Lines in the error are counted from
+// start, the line the document started on.
+func yamlErrorPosition(err error, start int) (int, error) {
+	msg := err.Error()
+	m := yamlErrorLine.FindStringSubmatch(msg)
+	if m == nil {
+		return start, fmt.Errorf("%s", strings.TrimPrefix(msg, "yaml: "))
+	}
+	n, _ := strconv.Atoi(m[1])
+	return start + n - 1, fmt.Errorf("%s", msg[len(m[0]):])
+}
+
+// integerPattern matches decimal integers that are valid in JSON, apart
+// from the sign
+var integerPattern = regexp.MustCompile(`^[-+]?(0|[1-9][0-9]*)$`)
+
+// isBigInt returns true if s is an integer that doesn't fit in 64 bits
+func isBigInt(s string) bool {
+	if !integerPattern.MatchString(s) {
+		return false
+	}
+	if _, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return false
+	}
+	if _, err := strconv.ParseUint(strings.TrimPrefix(s, "+"), 10, 64); err == nil {
+		return false
+	}
+	return true
+}
+
+// bigIntPrefix returns a prefix for big integer placeholders that doesn't
+// appear anywhere in the document
+func bigIntPrefix(doc string) string {
+	prefix := "tfk8s-bigint-"
+	for strings.Contains(doc, prefix) {
+		prefix = "_" + prefix
+	}
+	return prefix
+}
+
+// protectBigInts replaces integers that don't fit in 64 bits with
+// placeholder strings, because converting YAML to JSON turns them into
+// floats which lose precision. The placeholders are added to placeholders
+// with the digits they should be swapped back for once the document is JSON.
+func protectBigInts(node *yamlv3.Node, prefix string, placeholders map[string]string) {
+	switch node.Kind {
+	case yamlv3.ScalarNode:
+		tag := node.ShortTag()
+		if node.Style != 0 || (tag != "!!int" && tag != "!!float") || !isBigInt(node.Value) {
+			return
+		}
+		p := fmt.Sprintf("%s%d", prefix, len(placeholders))
+		placeholders[p] = strings.TrimPrefix(node.Value, "+")
+		node.Value = p
+		node.Tag = "!!str"
+		node.Style = yamlv3.DoubleQuotedStyle
+	case yamlv3.MappingNode:
+		// keys are left alone as they become strings anyway
+		for i := 1; i < len(node.Content); i += 2 {
+			protectBigInts(node.Content[i], prefix, placeholders)
+		}
+	default:
+		for _, n := range node.Content {
+			protectBigInts(n, prefix, placeholders)
+		}
+	}
+}
+
+// decodeYAMLDocuments decodes the YAML documents in s and converts them to JSON
+func decodeYAMLDocuments(s string) ([]document, error) {
+	docs := []document{}
+	dec := yamlv3.NewDecoder(strings.NewReader(s))
+	for {
+		var node yamlv3.Node
+		err := dec.Decode(&node)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		placeholders := map[string]string{}
+		protectBigInts(&node, bigIntPrefix(s), placeholders)
+
+		// the node is encoded again so the conversion to JSON uses the same
+		// YAML rules as Kubernetes, quoted values stay quoted when encoding
+		var buf bytes.Buffer
+		enc := yamlv3.NewEncoder(&buf)
+		enc.SetIndent(2)
+		if err := enc.Encode(&node); err != nil {
+			return nil, err
+		}
+		enc.Close()
+
+		b, err := yaml.YAMLToJSON(buf.Bytes())
+		if err != nil {
+			return nil, err
+		}
+		raw := buf.Bytes()
+		for p, digits := range placeholders {
+			quoted := []byte(`"` + p + `"`)
+			b = bytes.ReplaceAll(b, quoted, []byte(digits))
+			raw = bytes.ReplaceAll(raw, quoted, []byte(digits))
+		}
+		docs = append(docs, document{raw: string(raw), json: b})
+	}
+
+	switch len(docs) {
+	case 0:
+		// the document was empty or only contained comments
+		docs = append(docs, document{raw: s, json: []byte("null")})
+	case 1:
+		docs[0].raw = s
+	}
+	return docs, nil
+}
+
+// readYAML reads the next YAML document into the queue
+func (d *documentReader) readYAML() error {
+	chunk, start, err := d.nextYAMLChunk()
+	if err != nil {
+		return err
+	}
+	docs, err := decodeYAMLDocuments(chunk)
+	if err != nil {
+		line, err := yamlErrorPosition(err, start)
+		d.count++
+		return &documentError{index: d.count, line: line, err: err}
+	}
+	for _, doc := range docs {
+		doc.line = start
+		d.queue = append(d.queue, doc)
+	}
+	return nil
+}
+
+// next returns the next document in the stream, or io.EOF when there
+// are no more documents
+func (d *documentReader) next() (document, error) {
+	for len(d.queue) == 0 {
+		if d.done {
+			return document{}, io.EOF
+		}
+		if d.json == nil && !d.yaml {
+			if err := d.detect(); err != nil {
+				return document{}, err
+			}
+		}
+
+		var err error
+		if d.json != nil {
+			err = d.readJSON()
+		} else {
+			err = d.readYAML()
+		}
+		if err != nil {
+			return document{}, err
+		}
+	}
+
+	doc := d.queue[0]
+	d.queue = d.queue[1:]
+	d.count++
+	doc.index = d.count
+	return doc, nil
+}
+
+// parseDocument converts a single JSON document into a cty value
+func parseDocument(b []byte) (cty.Value, error) {
+	t, err := ctyjson.ImpliedType(b)
+	if err != nil {
+		return cty.NilVal, err
+	}
+	return ctyjson.Unmarshal(b, t)
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// decodeMultiFile is the file --decode-multi writes the manifests to
+	decodeMultiFile = "manifests.yaml"
+	// decodeMultiResource is the name of the resource --decode-multi writes
+	decodeMultiResource = "manifests"
+)
+
+// decodeMultiKey is the key of each manifest in the for_each map, like
+// Deployment/web/nginx, or Namespace/web for objects without a namespace
+const decodeMultiKey = `join("/", compact([m.kind, try(m.metadata.namespace, ""), m.metadata.name]))`
+
+// decodeMulti writes the manifests of resources to a single YAML file in
+// the --manifest-dir, and returns a resource that creates an object for
+// each of them with for_each over manifest_decode_multi(), so the YAML is
+// the source of truth and the config stays the same size however many
+// manifests there are
+func decodeMulti(resources []resource, opts ...Option) (resource, error) {
+	o := newOptions(opts)
+	dir, ref := o.manifestDir, o.manifestRef
+	if dir == "" {
+		dir, ref = defaultManifestDir, defaultManifestDir
+	}
+
+	docs := []string{}
+	for _, r := range resources {
+		body, err := manifestYAML(r, "--decode-multi")
+		if err != nil {
+			return resource{}, err
+		}
+		body = strings.TrimPrefix(body, "---\n")
+		if !strings.HasSuffix(body, "\n") {
+			body += "\n"
+		}
+		docs = append(docs, body)
+	}
+	f := pendingFile{filename: filepath.Join(dir, decodeMultiFile), data: []byte(strings.Join(docs, "---\n"))}
+	if err := o.writeFile(f); err != nil {
+		return resource{}, err
+	}
+
+	r := resource{
+		resourceType:      resourceType,
+		name:              decodeMultiResource,
+		provider:          o.providerAlias,
+		providerFunctions: true,
+	}
+	hcl := fmt.Sprintf("resource %q %q {\n", r.resourceType, r.name)
+	if o.providerAlias != "" {
+		hcl += fmt.Sprintf("  provider = %v\n\n", o.providerAlias)
+	}
+	hcl += "  for_each = {\n"
+	hcl += fmt.Sprintf("    for m in provider::kubernetes::manifest_decode_multi(file(%q)) :\n", modulePath(ref, decodeMultiFile))
+	hcl += fmt.Sprintf("    %s => m\n", decodeMultiKey)
+	hcl += "  }\n\n"
+	hcl += "  manifest = each.value\n"
+	hcl += "}\n"
+	r.text = hcl
+	return r, nil
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zclconf/go-cty/cty"
+
+	"github.com/jrhouston/tfk8s/contrib/hashicorp/terraform"
+)
+
+func TestDecodeMulti(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tfk8s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	yaml := `# the app config
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: test
+data: {x: "1"}
+---
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: web
+`
+	opts := []Option{WithManifestDir(filepath.Join(dir, "manifests"), "manifests"), WithProviderAlias("kubernetes.foo")}
+	resources, err := convertResources(strings.NewReader(yaml), opts...)
+	if err != nil {
+		t.Fatal("Converting to HCL failed:", err)
+	}
+	r, err := decodeMulti(resources, opts...)
+	assert.NoError(t, err)
+
+	expected := `resource "kubernetes_manifest" "manifests" {
+  provider = kubernetes.foo
+
+  for_each = {
+    for m in provider::kubernetes::manifest_decode_multi(file("${path.module}/manifests/manifests.yaml")) :
+    join("/", compact([m.kind, try(m.metadata.namespace, ""), m.metadata.name])) => m
+  }
+
+  manifest = each.value
+}
+`
+	assert.Equal(t, expected, r.text)
+
+	// the documents are written as they were, comments and all
+	written, err := ioutil.ReadFile(filepath.Join(dir, "manifests", "manifests.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, yaml, string(written))
+
+	s := newScaffold([]resource{r})
+	assert.Equal(t, terraformFunctionsVersion, s.requiredVersion)
+	assert.Equal(t, []requiredProvider{kubernetesFunctionsProvider}, s.providers)
+}
+
+func TestDecodeMultiExpression(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tfk8s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	r := resource{
+		resourceType: resourceType,
+		name:         "test",
+		manifest: cty.ObjectVal(map[string]cty.Value{
+			"replicas": cty.StringVal("var.replicas").Mark(terraform.Expression),
+		}),
+	}
+	_, err = decodeMulti([]resource{r}, WithManifestDir(dir, "manifests"))
+	assert.EqualError(t, err, "var.replicas is a Terraform expression, it can't be used with --decode-multi")
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	cty "github.com/zclconf/go-cty/cty"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+)
+
+// dryRunKubectl runs kubectl with manifest on its stdin and returns its
+// output
+var dryRunKubectl = func(manifest []byte, args ...string) ([]byte, error) {
+	return runCommandInput(kubectlCommand, manifest, args...)
+}
+
+// serverDefaults finds the fields of objects the API server would set to
+// the same value if they were left out, with server side dry runs
+type serverDefaults struct {
+	cluster clusterOptions
+}
+
+// dryRun creates a copy of the object doc with a server side dry run, and
+// returns the object the server would have created. The copy has a
+// generated name so it doesn't clash with the object when it exists.
+func (d serverDefaults) dryRun(doc cty.Value) (cty.Value, error) {
+	m := doc.AsValueMap()
+	metadata := m["metadata"].AsValueMap()
+	copyMetadata := map[string]cty.Value{}
+	for _, attr := range []string{"namespace", "labels", "annotations"} {
+		if v, ok := metadata[attr]; ok {
+			copyMetadata[attr] = v
+		}
+	}
+	name, ok := stringAttr(metadata, "name")
+	if !ok {
+		name, _ = stringAttr(metadata, "generateName")
+	}
+	copyMetadata["generateName"] = cty.StringVal(strings.TrimSuffix(name, "-") + "-")
+	m["metadata"] = cty.ObjectVal(copyMetadata)
+	delete(m, "status")
+
+	object := cty.ObjectVal(m)
+	b, err := ctyjson.Marshal(object, object.Type())
+	if err != nil {
+		return cty.NilVal, err
+	}
+	out, err := dryRunKubectl(b, d.cluster.kubectlArgs("create", "--dry-run=server", "-o", "json", "-f", "-")...)
+	if err != nil {
+		return cty.NilVal, err
+	}
+	return parseDocument(out)
+}
+
+// strip removes the fields of doc the server sets to the same value when
+// they are left out. The fields the object can't be created without are
+// found by leaving out fewer fields at a time, so it takes a few dry runs
+// for each object.
+func (d serverDefaults) strip(doc cty.Value) (cty.Value, error) {
+	if _, err := d.dryRun(doc); err != nil {
+		return doc, err
+	}
+	var paths []fieldPath
+	for k, v := range doc.AsValueMap() {
+		if k != "apiVersion" && k != "kind" && k != "metadata" && k != "status" {
+			paths = append(paths, fieldPaths(v, fieldPath{{name: k}})...)
+		}
+	}
+	sort.Slice(paths, func(i, j int) bool {
+		return paths[i].String() < paths[j].String()
+	})
+	for _, path := range d.defaulted(doc, paths) {
+		doc = removeField(doc, path)
+	}
+	return doc, nil
+}
+
+// defaulted returns the paths the server sets to the same value in doc
+// when all of them are left out. If the object can't be created without
+// them, they are split in two and tried again.
+func (d serverDefaults) defaulted(doc cty.Value, paths []fieldPath) []fieldPath {
+	if len(paths) == 0 {
+		return nil
+	}
+	without := doc
+	for _, path := range paths {
+		without = removeField(without, path)
+	}
+	created, err := d.dryRun(without)
+	if err != nil {
+		if len(paths) == 1 {
+			return nil
+		}
+		half := len(paths) / 2
+		return append(d.defaulted(doc, paths[:half]), d.defaulted(doc, paths[half:])...)
+	}
+
+	defaulted := []fieldPath{}
+	for _, path := range paths {
+		want, _ := fieldAt(doc, path)
+		got, ok := fieldAt(created, path)
+		if ok && sameJSON(want, got) {
+			defaulted = append(defaulted, path)
+		}
+	}
+	return defaulted
+}
+
+// fieldPaths returns the paths of the fields in v that can be defaulted,
+// the scalars, lists of scalars and empty objects, below path
+func fieldPaths(v cty.Value, path fieldPath) []fieldPath {
+	if v.IsNull() || v.IsMarked() || !v.IsKnown() {
+		return nil
+	}
+	child := func(step fieldStep) fieldPath {
+		return append(append(fieldPath{}, path...), step)
+	}
+	ty := v.Type()
+	switch {
+	case (ty.IsObjectType() || ty.IsMapType()) && v.LengthInt() > 0:
+		paths := []fieldPath{}
+		for k, item := range v.AsValueMap() {
+			paths = append(paths, fieldPaths(item, child(fieldStep{name: k}))...)
+		}
+		return paths
+	case (ty.IsTupleType() || ty.IsListType()) && hasObjects(v):
+		paths := []fieldPath{}
+		for i, item := range v.AsValueSlice() {
+			paths = append(paths, fieldPaths(item, child(fieldStep{name: strconv.Itoa(i), index: true}))...)
+		}
+		return paths
+	}
+	return []fieldPath{path}
+}
+
+// String returns the path like metadata.labels.app or spec.ports[0].port
+func (p fieldPath) String() string {
+	var buf strings.Builder
+	for i, step := range p {
+		switch {
+		case step.index:
+			fmt.Fprintf(&buf, "[%s]", step.name)
+		case i > 0:
+			buf.WriteString("." + step.name)
+		default:
+			buf.WriteString(step.name)
+		}
+	}
+	return buf.String()
+}
+
+// hasObjects returns true if the list v has an object in it
+func hasObjects(v cty.Value) bool {
+	for _, item := range v.AsValueSlice() {
+		if ty := item.Type(); ty.IsObjectType() || ty.IsMapType() {
+			return true
+		}
+	}
+	return false
+}
+
+// fieldAt returns the field of v at a path without wildcards, and false if
+// it isn't set
+func fieldAt(v cty.Value, path fieldPath) (cty.Value, bool) {
+	for _, step := range path {
+		if v.IsNull() || v.IsMarked() || !v.IsKnown() {
+			return cty.NilVal, false
+		}
+		ty := v.Type()
+		switch {
+		case step.index && (ty.IsTupleType() || ty.IsListType()):
+			i, _ := strconv.Atoi(step.name)
+			items := v.AsValueSlice()
+			if i >= len(items) {
+				return cty.NilVal, false
+			}
+			v = items[i]
+		case !step.index && (ty.IsObjectType() || ty.IsMapType()):
+			item, ok := v.AsValueMap()[step.name]
+			if !ok {
+				return cty.NilVal, false
+			}
+			v = item
+		default:
+			return cty.NilVal, false
+		}
+	}
+	return v, true
+}
+
+// sameJSON returns true if a and b are the same in JSON, the types of the
+// lists and objects the server returns can be different
+func sameJSON(a, b cty.Value) bool {
+	ja, err := ctyjson.Marshal(a, a.Type())
+	if err != nil {
+		return false
+	}
+	jb, err := ctyjson.Marshal(b, b.Type())
+	return err == nil && bytes.Equal(ja, jb)
+}
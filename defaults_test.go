@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeDryRun is an API server that defaults a few fields of Pods and
+// needs the image of each container
+func fakeDryRun(manifest []byte, args ...string) ([]byte, error) {
+	if strings.Join(args, " ") != "create --dry-run=server -o json -f - --context test" {
+		return nil, fmt.Errorf("unexpected arguments %v", args)
+	}
+	var pod map[string]interface{}
+	if err := json.Unmarshal(manifest, &pod); err != nil {
+		return nil, err
+	}
+	metadata := pod["metadata"].(map[string]interface{})
+	metadata["name"] = metadata["generateName"].(string) + "x7k2p"
+	spec := pod["spec"].(map[string]interface{})
+	setDefault := func(m map[string]interface{}, k string, v interface{}) {
+		if _, ok := m[k]; !ok {
+			m[k] = v
+		}
+	}
+	setDefault(spec, "restartPolicy", "Always")
+	setDefault(spec, "dnsPolicy", "ClusterFirst")
+	containers, _ := spec["containers"].([]interface{})
+	for i, c := range containers {
+		container := c.(map[string]interface{})
+		if _, ok := container["image"]; !ok {
+			return nil, fmt.Errorf("spec.containers[%d].image: Required value", i)
+		}
+		setDefault(container, "imagePullPolicy", "IfNotPresent")
+		setDefault(container, "terminationMessagePath", "/dev/termination-log")
+	}
+	return json.Marshal(pod)
+}
+
+func TestStripDefaults(t *testing.T) {
+	defer func(f func([]byte, ...string) ([]byte, error)) { dryRunKubectl = f }(dryRunKubectl)
+	dryRunKubectl = fakeDryRun
+
+	yaml := `apiVersion: v1
+kind: Pod
+metadata:
+  name: web
+  namespace: prod
+spec:
+  restartPolicy: Always
+  dnsPolicy: None
+  containers:
+  - name: web
+    image: nginx
+    imagePullPolicy: Always
+    terminationMessagePath: /dev/termination-log
+`
+	hcl, err := YAMLToTerraformResources(strings.NewReader(yaml), WithStripDefaults(clusterOptions{context: "test"}), WithMapOnly(true))
+	assert.NoError(t, err)
+	assert.Equal(t, `{
+  "apiVersion" = "v1"
+  "kind" = "Pod"
+  "metadata" = {
+    "name" = "web"
+    "namespace" = "prod"
+  }
+  "spec" = {
+    "containers" = [
+      {
+        "image" = "nginx"
+        "imagePullPolicy" = "Always"
+        "name" = "web"
+      },
+    ]
+    "dnsPolicy" = "None"
+  }
+}
+`, hcl)
+}
+
+func TestStripDefaultsFails(t *testing.T) {
+	defer func(f func([]byte, ...string) ([]byte, error)) { dryRunKubectl = f }(dryRunKubectl)
+	dryRunKubectl = func(manifest []byte, args ...string) ([]byte, error) {
+		return nil, fmt.Errorf("the server could not find the requested resource")
+	}
+
+	var warnings strings.Builder
+	yaml := `apiVersion: example.com/v1
+kind: Widget
+metadata:
+  name: web
+spec:
+  size: 1
+`
+	hcl, err := YAMLToTerraformResources(strings.NewReader(yaml), WithStripDefaults(clusterOptions{}), WithWarnings(&warnings), WithMapOnly(true))
+	assert.NoError(t, err)
+	assert.Contains(t, hcl, `"size" = 1`)
+	assert.Contains(t, warnings.String(), "the defaults of Widget web can't be found")
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/zclconf/go-cty/cty"
+)
+
+// podSpecPaths are where the pod spec is in the manifests of the workload
+// kinds, the ConfigMaps and Secrets a workload refers to are found in them
+var podSpecPaths = map[string][]string{
+	"Pod":                   {"spec"},
+	"Deployment":            {"spec", "template", "spec"},
+	"StatefulSet":           {"spec", "template", "spec"},
+	"DaemonSet":             {"spec", "template", "spec"},
+	"ReplicaSet":            {"spec", "template", "spec"},
+	"ReplicationController": {"spec", "template", "spec"},
+	"Job":                   {"spec", "template", "spec"},
+	"CronJob":               {"spec", "jobTemplate", "spec", "template", "spec"},
+}
+
+// dependsOnAttribute returns the depends_on argument of r, or "" if it
+// doesn't depend on anything
+func dependsOnAttribute(r resource) string {
+	if len(r.dependsOn) == 0 {
+		return ""
+	}
+	var buf strings.Builder
+	buf.WriteString("\n  depends_on = [\n")
+	for _, address := range r.dependsOn {
+		fmt.Fprintf(&buf, "    %s,\n", address)
+	}
+	buf.WriteString("  ]\n")
+	return buf.String()
+}
+
+// getPath returns the value at path in v, and false if it isn't there
+func getPath(v cty.Value, path ...string) (cty.Value, bool) {
+	for _, attr := range path {
+		if v.IsNull() || v.IsMarked() || !v.Type().IsObjectType() || !v.Type().HasAttribute(attr) {
+			return cty.NilVal, false
+		}
+		v = v.GetAttr(attr)
+	}
+	return v, !v.IsNull()
+}
+
+// getString returns the string at path in v
+func getString(v cty.Value, path ...string) (string, bool) {
+	v, ok := getPath(v, path...)
+	if !ok || v.IsMarked() || v.Type() != cty.String {
+		return "", false
+	}
+	return v.AsString(), true
+}
+
+// podReferenceFields are the fields in a pod spec that refer to other
+// objects by name, by the key of the object they are in, and the kind of
+// object they refer to
+var podReferenceFields = map[string]struct {
+	kind  string
+	field string
+}{
+	"configMap":        {"ConfigMap", "name"},
+	"configMapRef":     {"ConfigMap", "name"},
+	"configMapKeyRef":  {"ConfigMap", "name"},
+	"secretRef":        {"Secret", "name"},
+	"secretKeyRef":     {"Secret", "name"},
+	"imagePullSecrets": {"Secret", "name"},
+}
+
+// rewritePodReferences calls visit with the kind and name of each object
+// the pod spec refers to in volumes, env, envFrom, imagePullSecrets and
+// serviceAccountName, and returns the spec with each name replaced by the
+// value visit returns
+func rewritePodReferences(spec cty.Value, visit func(kind, name string) cty.Value) cty.Value {
+	var walk func(key string, v cty.Value) cty.Value
+	walk = func(key string, v cty.Value) cty.Value {
+		if v.IsNull() || v.IsMarked() || !v.IsKnown() {
+			return v
+		}
+		ty := v.Type()
+		switch {
+		case ty.IsObjectType() || ty.IsMapType():
+			m := v.AsValueMap()
+			if len(m) == 0 {
+				return v
+			}
+			kind, field := "", ""
+			if ref, ok := podReferenceFields[key]; ok {
+				kind, field = ref.kind, ref.field
+			} else if key == "secret" {
+				// volumes have a secretName, projected sources a name
+				kind, field = "Secret", "name"
+				if _, ok := m["secretName"]; ok {
+					field = "secretName"
+				}
+			} else if key == "" {
+				kind, field = "ServiceAccount", "serviceAccountName"
+			}
+			for k, item := range m {
+				if k == field && !item.IsNull() && !item.IsMarked() && item.Type() == cty.String {
+					m[k] = visit(kind, item.AsString())
+					continue
+				}
+				m[k] = walk(k, item)
+			}
+			if ty.IsMapType() {
+				return cty.MapVal(m)
+			}
+			return cty.ObjectVal(m)
+		case ty.IsTupleType() || ty.IsListType():
+			items := v.AsValueSlice()
+			if len(items) == 0 {
+				return v
+			}
+			for i, item := range items {
+				items[i] = walk(key, item)
+			}
+			if ty.IsListType() {
+				return cty.ListVal(items)
+			}
+			return cty.TupleVal(items)
+		}
+		return v
+	}
+	return walk("", spec)
+}
+
+// podReferences returns the names of the ConfigMaps and Secrets the pod
+// spec refers to in volumes, env, envFrom and imagePullSecrets
+func podReferences(spec cty.Value) (configMaps, secrets []string) {
+	rewritePodReferences(spec, func(kind, name string) cty.Value {
+		switch kind {
+		case "ConfigMap":
+			configMaps = append(configMaps, name)
+		case "Secret":
+			secrets = append(secrets, name)
+		}
+		return cty.StringVal(name)
+	})
+	sort.Strings(configMaps)
+	sort.Strings(secrets)
+	return configMaps, secrets
+}
+
+// apiGroup returns the group of an apiVersion, "" for the core group
+func apiGroup(apiVersion string) string {
+	if i := strings.Index(apiVersion, "/"); i >= 0 {
+		return apiVersion[:i]
+	}
+	return ""
+}
+
+// inferDependencies sets depends_on for each resource to the resources
+// converted in the same run that have to be created first: the Namespace
+// it is in, the CustomResourceDefinition of its kind, the ConfigMaps and
+// Secrets a workload refers to, and the resources in the stage before its
+// own, set with Helm hook and Argo CD sync wave annotations. Resources
+// only depend on resources in the same module. The resources are
+// converted again with opts.
+func inferDependencies(resources []resource, opts ...Option) ([]resource, error) {
+	o := newOptions(opts)
+	format, err := lookupFormat(o.format)
+	if err != nil {
+		return nil, err
+	}
+
+	// index the resources others can depend on by what refers to them
+	addresses := map[string]string{}
+	key := func(module string, parts ...string) string {
+		return module + "\x00" + strings.Join(parts, "\x00")
+	}
+	for _, r := range resources {
+		if !r.forEach.IsNull() {
+			continue
+		}
+		address := r.resourceType + "." + r.name
+		switch r.kind {
+		case "Namespace":
+			addresses[key(r.module, "Namespace", r.objectName)] = address
+		case "CustomResourceDefinition":
+			if k, ok := definedKind(r); ok {
+				addresses[key(r.module, "CustomResourceDefinition", k.group, k.kind)] = address
+			}
+		case "ConfigMap", "Secret":
+			addresses[key(r.module, r.kind, o.namespaceOrDefault(r.namespace), r.objectName)] = address
+		}
+	}
+
+	// the Namespace, CustomResourceDefinition, ConfigMaps and Secrets each
+	// resource needs
+	inferred := make([][]string, len(resources))
+	for i, r := range resources {
+		seen := map[string]bool{}
+		add := func(k string) {
+			if address, ok := addresses[k]; ok && address != r.resourceType+"."+r.name && !seen[address] {
+				seen[address] = true
+				inferred[i] = append(inferred[i], address)
+			}
+		}
+
+		if r.namespace != "" {
+			add(key(r.module, "Namespace", r.namespace))
+		}
+		if apiVersion, ok := getString(r.manifest, "apiVersion"); ok {
+			add(key(r.module, "CustomResourceDefinition", apiGroup(apiVersion), r.kind))
+		}
+		if path, ok := podSpecPaths[r.kind]; ok {
+			if spec, ok := getPath(r.manifest, path...); ok {
+				configMaps, secrets := podReferences(spec)
+				for _, name := range configMaps {
+					add(key(r.module, "ConfigMap", o.namespaceOrDefault(r.namespace), name))
+				}
+				for _, name := range secrets {
+					add(key(r.module, "Secret", o.namespaceOrDefault(r.namespace), name))
+				}
+			}
+		}
+	}
+
+	// resources in a later stage depend on the resources in the stage
+	// before theirs. Depending on a resource waits for the ones it depends
+	// on too, so only the resources nothing else in their stage depends on
+	// are needed.
+	type stageKey struct {
+		module string
+		stage  stage
+	}
+	stages := map[stageKey][]string{}
+	dependedOn := map[stageKey]map[string]bool{}
+	for i, r := range resources {
+		k := stageKey{r.module, applyStage(r)}
+		stages[k] = append(stages[k], r.resourceType+"."+r.name)
+		if dependedOn[k] == nil {
+			dependedOn[k] = map[string]bool{}
+		}
+		for _, address := range inferred[i] {
+			dependedOn[k][address] = true
+		}
+	}
+	previousStage := func(module string, s stage) []string {
+		var previous *stageKey
+		for k := range stages {
+			if k.module == module && k.stage.before(s) && (previous == nil || previous.stage.before(k.stage)) {
+				p := k
+				previous = &p
+			}
+		}
+		if previous == nil {
+			return nil
+		}
+		last := []string{}
+		for _, address := range stages[*previous] {
+			if !dependedOn[*previous][address] {
+				last = append(last, address)
+			}
+		}
+		return last
+	}
+
+	for i, r := range resources {
+		dependsOn := inferred[i]
+		for _, address := range previousStage(r.module, applyStage(r)) {
+			if !containsString(dependsOn, address) {
+				dependsOn = append(dependsOn, address)
+			}
+		}
+		if len(dependsOn) == 0 {
+			continue
+		}
+
+		r.dependsOn = dependsOn
+		if r.text, err = format.resource(r, o); err != nil {
+			return nil, err
+		}
+		resources[i] = r
+	}
+	return resources, nil
+}
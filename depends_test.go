@@ -0,0 +1,238 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var dependsYAML = `apiVersion: v1
+kind: Namespace
+metadata:
+  name: web
+---
+apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: widgets.example.com
+spec:
+  group: example.com
+  names:
+    kind: Widget
+---
+apiVersion: example.com/v1
+kind: Widget
+metadata:
+  name: test
+  namespace: web
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+  namespace: web
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: creds
+  namespace: web
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: creds
+---
+apiVersion: batch/v1
+kind: CronJob
+metadata:
+  name: backup
+  namespace: web
+spec:
+  jobTemplate:
+    spec:
+      template:
+        spec:
+          containers:
+          - name: backup
+            env:
+            - name: PASSWORD
+              valueFrom:
+                secretKeyRef:
+                  name: creds
+                  key: password
+          volumes:
+          - name: config
+            configMap:
+              name: config
+          - name: missing
+            secret:
+              secretName: missing
+`
+
+func TestInferDependencies(t *testing.T) {
+	resources, err := convertResources(strings.NewReader(dependsYAML))
+	if err != nil {
+		t.Fatal("Converting to HCL failed:", err)
+	}
+	resources, err = inferDependencies(resources)
+	assert.NoError(t, err)
+
+	assert.Empty(t, resources[0].dependsOn)
+	assert.Equal(t, []string{
+		"kubernetes_manifest.namespace_web",
+		"kubernetes_manifest.customresourcedefinition_widgets_example_com",
+	}, resources[2].dependsOn)
+	assert.Empty(t, resources[5].dependsOn)
+
+	// the Secret in the default namespace and ones that aren't converted
+	// aren't depended on
+	assert.Equal(t, []string{
+		"kubernetes_manifest.namespace_web",
+		"kubernetes_manifest.configmap_web_config",
+		"kubernetes_manifest.secret_web_creds",
+	}, resources[6].dependsOn)
+	assert.Contains(t, resources[6].text, `  depends_on = [
+    kubernetes_manifest.namespace_web,
+    kubernetes_manifest.configmap_web_config,
+    kubernetes_manifest.secret_web_creds,
+  ]
+}
+`)
+}
+
+func TestInferDependenciesDefaultNamespace(t *testing.T) {
+	yaml := `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+  namespace: apps
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  template:
+    spec:
+      containers:
+      - name: web
+        envFrom:
+        - configMapRef:
+            name: config
+`
+	// objects without a namespace are in the --default-namespace
+	opts := []Option{WithDefaultNamespace("apps")}
+	resources, err := convertResources(strings.NewReader(yaml), opts...)
+	if err != nil {
+		t.Fatal("Converting to HCL failed:", err)
+	}
+	resources, err = inferDependencies(resources, opts...)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"kubernetes_manifest.configmap_config"}, resources[1].dependsOn)
+}
+
+func TestInferDependenciesTFJSON(t *testing.T) {
+	resources, err := convertResources(strings.NewReader(dependsYAML), WithFormat("tfjson"))
+	if err != nil {
+		t.Fatal("Converting to JSON failed:", err)
+	}
+	resources, err = inferDependencies(resources, WithFormat("tfjson"))
+	assert.NoError(t, err)
+	assert.Contains(t, resources[3].text, "\"depends_on\": [\n    \"kubernetes_manifest.namespace_web\"\n  ]")
+}
+
+func TestInferDependenciesStages(t *testing.T) {
+	yaml := `apiVersion: batch/v1
+kind: Job
+metadata:
+  name: migrate
+  annotations:
+    argocd.argoproj.io/hook: PreSync
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  annotations:
+    argocd.argoproj.io/sync-wave: "2"
+---
+apiVersion: batch/v1
+kind: Job
+metadata:
+  name: smoke
+  annotations:
+    argocd.argoproj.io/hook: PostSync
+`
+	resources, err := convertResources(strings.NewReader(yaml))
+	if err != nil {
+		t.Fatal("Converting to HCL failed:", err)
+	}
+	resources, err = inferDependencies(resources)
+	assert.NoError(t, err)
+
+	assert.Empty(t, resources[0].dependsOn)
+	assert.Equal(t, []string{"kubernetes_manifest.job_migrate"}, resources[1].dependsOn)
+	assert.Equal(t, []string{"kubernetes_manifest.service_web"}, resources[2].dependsOn)
+	assert.Equal(t, []string{"kubernetes_manifest.deployment_web"}, resources[3].dependsOn)
+}
+
+func TestInferDependenciesStagesLast(t *testing.T) {
+	yaml := `apiVersion: v1
+kind: Namespace
+metadata:
+  name: web
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+  namespace: web
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+  namespace: web
+spec:
+  template:
+    spec:
+      volumes:
+      - name: config
+        configMap:
+          name: config
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: app
+  namespace: web
+---
+apiVersion: batch/v1
+kind: Job
+metadata:
+  name: smoke
+  namespace: web
+  annotations:
+    argocd.argoproj.io/sync-wave: "1"
+`
+	resources, err := convertResources(strings.NewReader(yaml))
+	if err != nil {
+		t.Fatal("Converting to HCL failed:", err)
+	}
+	resources, err = inferDependencies(resources)
+	assert.NoError(t, err)
+
+	// the Namespace and ConfigMap are waited for through the Deployment
+	assert.Equal(t, []string{
+		"kubernetes_manifest.namespace_web",
+		"kubernetes_manifest.deployment_web_app",
+		"kubernetes_manifest.service_web_app",
+	}, resources[4].dependsOn)
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+
+	cty "github.com/zclconf/go-cty/cty"
+)
+
+// secretFinding is a value --fail-on-secrets found in the output that
+// looks like a credential
+type secretFinding struct {
+	kind   string
+	name   string
+	path   string
+	reason string
+}
+
+func (f secretFinding) String() string {
+	return fmt.Sprintf("%s %s: %s %s", f.kind, f.name, f.path, f.reason)
+}
+
+// tokenPattern matches strings that could be a token or a key, without
+// spaces. They must also have a digit, so long names aren't reported.
+var tokenPattern = regexp.MustCompile(`^[A-Za-z0-9+/=_\-.]{20,}$`)
+
+// hexPattern matches hex strings, which need a lower entropy to be a key.
+// They must have a digit and a letter, so runs of one kind aren't reported.
+var hexPattern = regexp.MustCompile(`^[0-9a-fA-F]{32,}$`)
+
+// checksumPrefix starts the annotations charts add with the checksum of a
+// ConfigMap or Secret, like checksum/config, to restart pods when it
+// changes. The digests look like keys but aren't secret.
+const checksumPrefix = "checksum/"
+
+// privateKeyPattern matches PEM encoded private keys
+var privateKeyPattern = regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----`)
+
+// publicFields are the fields that hold high entropy values which aren't
+// secret, like the CA certificates of webhooks, or long names like the
+// apiVersion of a Crossplane Object
+var publicFields = []string{"apiVersion", "caBundle", "image", "kind"}
+
+// entropy returns the Shannon entropy of s in bits per character
+func entropy(s string) float64 {
+	counts := map[rune]int{}
+	n := 0
+	for _, r := range s {
+		counts[r]++
+		n++
+	}
+	e := 0.0
+	for _, c := range counts {
+		p := float64(c) / float64(n)
+		e -= p * math.Log2(p)
+	}
+	return e
+}
+
+// looksLikeSecret returns why s looks like a credential, or "" if it
+// doesn't
+func looksLikeSecret(s string) string {
+	switch {
+	case privateKeyPattern.MatchString(s):
+		return "is a private key"
+	case hexPattern.MatchString(s):
+		if entropy(s) >= 3.5 && strings.ContainsAny(s, "0123456789") && strings.ContainsAny(s, "abcdefABCDEF") {
+			return "looks like a key, it is a high entropy string"
+		}
+	case tokenPattern.MatchString(s) && strings.ContainsAny(s, "0123456789"):
+		if entropy(s) >= 4 {
+			return "looks like a token, it is a high entropy string"
+		}
+	}
+	return ""
+}
+
+// findSecrets returns the values in the manifests of resources that look
+// like credentials: the values of Secrets that are written as they are,
+// private keys and high entropy strings. Values that are references, like
+// variables, and the placeholder of --redact-secrets aren't reported,
+// unless they read one of the files that are pending.
+func findSecrets(resources []resource, files *pendingFiles) []secretFinding {
+	findings := []secretFinding{}
+	for _, r := range resources {
+		if r.manifest == cty.NilVal || r.manifest.IsNull() {
+			continue
+		}
+		object := objectPath(r)
+		cty.Walk(r.manifest, func(path cty.Path, v cty.Value) (bool, error) {
+			if v.IsMarked() || v.IsNull() || !v.IsKnown() || v.Type() != cty.String || len(path) == 0 {
+				return !v.IsMarked(), nil
+			}
+			s := v.AsString()
+			if rel := relativePath(path, object); r.kind == "Secret" && len(rel) == 2 && isSecretField(rel[0]) {
+				if s != redactedValue {
+					findings = append(findings, secretFinding{r.kind, r.objectName, formatPath(path), "is the value of the Secret"})
+				}
+				return true, nil
+			}
+			if step, ok := path[len(path)-1].(cty.GetAttrStep); ok && (containsString(publicFields, step.Name) || strings.HasPrefix(step.Name, checksumPrefix)) {
+				return true, nil
+			}
+			if reason := looksLikeSecret(s); reason != "" {
+				findings = append(findings, secretFinding{r.kind, r.objectName, formatPath(path), reason})
+			}
+			return true, nil
+		})
+	}
+	if files != nil {
+		for _, f := range files.files {
+			if f.secret != nil {
+				findings = append(findings, *f.secret)
+			}
+		}
+	}
+	return findings
+}
+
+// objectPath returns the path of the Kubernetes object in the manifest of
+// r, which is wrapped in spec.forProvider.manifest by --crossplane-object
+func objectPath(r resource) cty.Path {
+	if kind, _ := getString(r.manifest, "kind"); kind == r.kind {
+		return nil
+	}
+	if _, ok := getPath(r.manifest, "spec", "forProvider", "manifest"); ok {
+		return cty.GetAttrPath("spec").GetAttr("forProvider").GetAttr("manifest")
+	}
+	return nil
+}
+
+// relativePath returns path relative to prefix, or nil if path isn't in
+// prefix
+func relativePath(path, prefix cty.Path) cty.Path {
+	if len(path) < len(prefix) || !path[:len(prefix)].Equals(prefix) {
+		return nil
+	}
+	return path[len(prefix):]
+}
+
+// isSecretField returns true if step is one of the fields of a Secret that
+// hold its values
+func isSecretField(step cty.PathStep) bool {
+	s, ok := step.(cty.GetAttrStep)
+	return ok && containsString(secretFields, s.Name)
+}
+
+// secretsReport returns the report --fail-on-secrets writes for findings
+func secretsReport(findings []secretFinding) string {
+	var buf strings.Builder
+	buf.WriteString("found values that look like secrets in the output, nothing was written:\n")
+	for _, f := range findings {
+		fmt.Fprintf(&buf, "  %s\n", f)
+	}
+	buf.WriteString("use --redact-secrets, --secrets-to-vars, --secrets-to-vault or --secrets-to-external-secrets to keep the values of Secrets out of the output\n")
+	return buf.String()
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"text/template"
+
+	cty "github.com/zclconf/go-cty/cty"
+)
+
+// externalSecretAPIVersion is the apiVersion of the ExternalSecrets
+// --secrets-to-external-secrets writes
+const externalSecretAPIVersion = "external-secrets.io/v1"
+
+// externalSecrets replaces Secrets with External Secrets Operator
+// ExternalSecrets that read their values from a secret store
+type externalSecrets struct {
+	// store is the name of the SecretStore or ClusterSecretStore
+	store string
+	// storeKind is SecretStore or ClusterSecretStore
+	storeKind string
+	// key is executed with the Secret's filenameData to get the key of
+	// the secret in the store
+	key *template.Template
+}
+
+// newExternalSecrets returns the externalSecrets for a store and a key
+// template like {{.Namespace}}/{{.Name}}
+func newExternalSecrets(store, storeKind, key string) (*externalSecrets, error) {
+	if store == "" {
+		return nil, fmt.Errorf("--secrets-to-external-secrets requires --secret-store")
+	}
+	if storeKind != "SecretStore" && storeKind != "ClusterSecretStore" {
+		return nil, fmt.Errorf("unknown --secret-store-kind %q, must be SecretStore or ClusterSecretStore", storeKind)
+	}
+	t, err := parseSecretPath("--external-secret-key", key)
+	if err != nil {
+		return nil, err
+	}
+	return &externalSecrets{store: store, storeKind: storeKind, key: t}, nil
+}
+
+// externalSecret returns an ExternalSecret that creates the Secret doc
+// with the values of its keys read from the properties of the same name of
+// a secret in the store. The type, labels and annotations of the Secret are
+// kept in the target's template, its values are left out.
+func (e *externalSecrets) externalSecret(doc cty.Value, namespace, name, resourceName string) (cty.Value, error) {
+	key, err := secretPath(e.key, "--external-secret-key", namespace, name, resourceName)
+	if err != nil {
+		return cty.NilVal, err
+	}
+	doc = removeFields(doc, "Secret", lastAppliedConfiguration)
+	mm := doc.AsValueMap()
+	metadata := mm["metadata"].AsValueMap()
+
+	keys := []string{}
+	for _, field := range secretFields {
+		data, ok := mm[field]
+		if !ok || data.IsNull() || data.IsMarked() || !(data.Type().IsObjectType() || data.Type().IsMapType()) {
+			continue
+		}
+		for k := range data.AsValueMap() {
+			if !containsString(keys, k) {
+				keys = append(keys, k)
+			}
+		}
+	}
+	sort.Strings(keys)
+	data := []cty.Value{}
+	for _, k := range keys {
+		data = append(data, cty.ObjectVal(map[string]cty.Value{
+			"secretKey": cty.StringVal(k),
+			"remoteRef": cty.ObjectVal(map[string]cty.Value{
+				"key":      cty.StringVal(key),
+				"property": cty.StringVal(k),
+			}),
+		}))
+	}
+
+	templateMetadata := map[string]cty.Value{}
+	for _, field := range []string{"labels", "annotations"} {
+		if v, ok := metadata[field]; ok && !v.IsNull() && v.LengthInt() > 0 {
+			templateMetadata[field] = v
+		}
+	}
+	target := map[string]cty.Value{
+		"name":           cty.StringVal(name),
+		"creationPolicy": cty.StringVal("Owner"),
+	}
+	tmpl := map[string]cty.Value{}
+	if v, ok := mm["type"]; ok && !v.IsNull() {
+		tmpl["type"] = v
+	}
+	if len(templateMetadata) > 0 {
+		tmpl["metadata"] = cty.ObjectVal(templateMetadata)
+	}
+	if len(tmpl) > 0 {
+		target["template"] = cty.ObjectVal(tmpl)
+	}
+
+	spec := map[string]cty.Value{
+		"secretStoreRef": cty.ObjectVal(map[string]cty.Value{
+			"name": cty.StringVal(e.store),
+			"kind": cty.StringVal(e.storeKind),
+		}),
+		"target": cty.ObjectVal(target),
+	}
+	if len(data) > 0 {
+		spec["data"] = cty.TupleVal(data)
+	}
+
+	objectMetadata := map[string]cty.Value{"name": cty.StringVal(name)}
+	if v, ok := metadata["namespace"]; ok && !v.IsNull() {
+		objectMetadata["namespace"] = v
+	}
+	return cty.ObjectVal(map[string]cty.Value{
+		"apiVersion": cty.StringVal(externalSecretAPIVersion),
+		"kind":       cty.StringVal("ExternalSecret"),
+		"metadata":   cty.ObjectVal(objectMetadata),
+		"spec":       cty.ObjectVal(spec),
+	}), nil
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExternalSecrets(t *testing.T) {
+	yaml := `apiVersion: v1
+kind: Secret
+type: kubernetes.io/basic-auth
+metadata:
+  name: db
+  labels:
+    app: db
+  annotations:
+    kubectl.kubernetes.io/last-applied-configuration: '{"stringData":{"password":"hunter2"}}'
+data:
+  password: aHVudGVyMg==
+stringData:
+  username: admin
+`
+	e, err := newExternalSecrets("vault", "ClusterSecretStore", "k8s/{{.Namespace}}/{{.Name}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	output, err := YAMLToTerraformResources(strings.NewReader(yaml), WithExternalSecrets(e))
+	if err != nil {
+		t.Fatal("Converting to HCL failed:", err)
+	}
+
+	expected := `
+resource "kubernetes_manifest" "externalsecret_db" {
+  manifest = {
+    "apiVersion" = "external-secrets.io/v1"
+    "kind" = "ExternalSecret"
+    "metadata" = {
+      "name" = "db"
+    }
+    "spec" = {
+      "data" = [
+        {
+          "remoteRef" = {
+            "key" = "k8s/default/db"
+            "property" = "password"
+          }
+          "secretKey" = "password"
+        },
+        {
+          "remoteRef" = {
+            "key" = "k8s/default/db"
+            "property" = "username"
+          }
+          "secretKey" = "username"
+        },
+      ]
+      "secretStoreRef" = {
+        "kind" = "ClusterSecretStore"
+        "name" = "vault"
+      }
+      "target" = {
+        "creationPolicy" = "Owner"
+        "name" = "db"
+        "template" = {
+          "metadata" = {
+            "labels" = {
+              "app" = "db"
+            }
+          }
+          "type" = "kubernetes.io/basic-auth"
+        }
+      }
+    }
+  }
+}`
+
+	assert.Equal(t, strings.TrimSpace(expected), strings.TrimSpace(output))
+	assert.NotContains(t, output, "hunter2")
+}
+
+func TestNewExternalSecretsInvalid(t *testing.T) {
+	_, err := newExternalSecrets("", "SecretStore", defaultSecretPath)
+	assert.Error(t, err)
+	_, err = newExternalSecrets("vault", "Store", defaultSecretPath)
+	assert.Error(t, err)
+	_, err = newExternalSecrets("vault", "SecretStore", "{{.Name")
+	assert.Error(t, err)
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// fieldManagerBlock returns the field_manager block of r, or "" if
+// --field-manager isn't set or r isn't a kubernetes_manifest
+func fieldManagerBlock(r resource, o options) string {
+	if o.fieldManager == "" || r.resourceType != resourceType || r.typed {
+		return ""
+	}
+	var buf strings.Builder
+	buf.WriteString("\n  field_manager {\n")
+	if o.forceConflicts {
+		fmt.Fprintf(&buf, "    name            = %q\n", o.fieldManager)
+		buf.WriteString("    force_conflicts = true\n")
+	} else {
+		fmt.Fprintf(&buf, "    name = %q\n", o.fieldManager)
+	}
+	buf.WriteString("  }\n")
+	return buf.String()
+}
+
+// fieldManagerJSON returns the field_manager block for Terraform JSON, or
+// nil if there isn't one
+func fieldManagerJSON(r resource, o options) map[string]interface{} {
+	if o.fieldManager == "" || r.resourceType != resourceType || r.typed {
+		return nil
+	}
+	block := map[string]interface{}{"name": o.fieldManager}
+	if o.forceConflicts {
+		block["force_conflicts"] = true
+	}
+	return block
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFieldManager(t *testing.T) {
+	yaml := `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: test
+`
+	hcl, err := YAMLToTerraformResources(strings.NewReader(yaml), WithFieldManager("terraform", false))
+	assert.NoError(t, err)
+	assert.Contains(t, hcl, `
+  field_manager {
+    name = "terraform"
+  }
+}
+`)
+
+	hcl, err = YAMLToTerraformResources(strings.NewReader(yaml), WithFieldManager("terraform", true), WithFormat("heredoc"))
+	assert.NoError(t, err)
+	assert.Contains(t, hcl, `
+  field_manager {
+    name            = "terraform"
+    force_conflicts = true
+  }
+`)
+
+	hcl, err = YAMLToTerraformResources(strings.NewReader(yaml), WithFieldManager("terraform", true), WithFormat("tfjson"))
+	assert.NoError(t, err)
+	assert.Contains(t, hcl, `"field_manager": {
+          "force_conflicts": true,
+          "name": "terraform"
+        }`)
+
+	hcl, err = YAMLToTerraformResources(strings.NewReader(yaml), WithFieldManager("terraform", false), WithTyped(true))
+	assert.NoError(t, err)
+	assert.NotContains(t, hcl, "field_manager")
+}
@@ -0,0 +1,296 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	cty "github.com/zclconf/go-cty/cty"
+
+	"github.com/jrhouston/tfk8s/contrib/hashicorp/terraform"
+)
+
+// outputFormat is a syntax the resources can be written in
+type outputFormat interface {
+	// resource returns the config for a single resource
+	resource(r resource, o options) (string, error)
+	// join returns the config for resources as a single file
+	join(resources []resource) (string, error)
+	// extension is the file extension used for files in this format
+	extension() string
+}
+
+// outputFormats are the formats that can be used with --format
+var outputFormats = map[string]outputFormat{
+	"hcl":          hclFormat{},
+	"tfjson":       tfjsonFormat{},
+	"cdktf-ts":     cdktfFormat{cdktfTypeScript},
+	"cdktf-python": cdktfFormat{cdktfPython},
+	"cdktf-go":     cdktfFormat{cdktfGo},
+	"pulumi-yaml":  pulumiYAMLFormat{},
+	"yaml":         yamlFormat{},
+	"yamlref":      yamlRefFormat{},
+	"heredoc":      heredocFormat{},
+	"jsondecode":   jsonDecodeFormat{},
+}
+
+// formatNames returns the names of the output formats in order
+func formatNames() []string {
+	names := []string{}
+	for name := range outputFormats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// lookupFormat returns the output format called name, the default is hcl
+func lookupFormat(name string) (outputFormat, error) {
+	if name == "" {
+		name = "hcl"
+	}
+	f, ok := outputFormats[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown --format %q, must be one of %s", name, strings.Join(formatNames(), ", "))
+	}
+	return f, nil
+}
+
+// hclFormat writes resources in the Terraform language
+type hclFormat struct{}
+
+func (hclFormat) resource(r resource, o options) (string, error) {
+	s := terraform.FormatValue(r.manifest, 0, o.stripKeyQuotes)
+	if o.mapOnly {
+		return fmt.Sprintf("%v\n", s), nil
+	}
+
+	hcl := fmt.Sprintf("resource %q %q {\n", r.resourceType, r.name)
+	if o.providerAlias != "" {
+		hcl += fmt.Sprintf("  provider = %v\n\n", o.providerAlias)
+	}
+	if !r.forEach.IsNull() {
+		hcl += fmt.Sprintf("  for_each = %v\n\n", terraform.FormatValue(r.forEach, 2, o.stripKeyQuotes))
+	}
+	switch {
+	case r.typed:
+		hcl += typedResource(r.manifest, r.kind)
+	case r.resourceType == kubectlResourceType:
+		body, err := kubectlYAMLBody(r.manifest)
+		if err != nil {
+			return "", err
+		}
+		hcl += fmt.Sprintf("  yaml_body = %v\n", heredoc(body, "  ", "YAML"))
+	default:
+		hcl += fmt.Sprintf("  manifest = %v\n", strings.ReplaceAll(s, "\n", "\n  "))
+	}
+	hcl += waitBlock(r)
+	hcl += fieldManagerBlock(r, o)
+	hcl += computedFieldsAttribute(r)
+	hcl += dependsOnAttribute(r)
+	hcl += lifecycleBlock(r)
+	hcl += fmt.Sprintf("}\n")
+	hcl += importBlock(r)
+	return hcl, nil
+}
+
+func (hclFormat) join(resources []resource) (string, error) {
+	resources = withSecretBlocks(resources)
+	hcl := make([]string, len(resources))
+	for i, r := range resources {
+		hcl[i] = r.text
+	}
+	return strings.Join(hcl, "\n"), nil
+}
+
+func (hclFormat) extension() string {
+	return ".tf"
+}
+
+// withSecretBlocks returns resources with the data source and the
+// random_passwords each of them reads the values of its Secret from before
+// it, for the Terraform language
+func withSecretBlocks(resources []resource) []resource {
+	all := []resource{}
+	for _, r := range resources {
+		all = append(all, vaultDataBlock(r)...)
+		all = append(all, randomPasswordBlocks(r)...)
+		all = append(all, r)
+	}
+	return all
+}
+
+// tfjsonFormat writes resources in Terraform's JSON configuration syntax.
+// The text of each resource is the JSON object for its body, join puts
+// them under the resource type and label.
+type tfjsonFormat struct{}
+
+func (tfjsonFormat) resource(r resource, o options) (string, error) {
+	if o.mapOnly {
+		return "", fmt.Errorf("--map-only can't be used with --format tfjson")
+	}
+	if r.typed {
+		return "", fmt.Errorf("--typed can't be used with --format tfjson")
+	}
+	body := map[string]interface{}{}
+	if r.resourceType == kubectlResourceType {
+		yamlBody, err := kubectlYAMLBody(r.manifest)
+		if err != nil {
+			return "", err
+		}
+		body["yaml_body"] = yamlBody
+	} else {
+		body["manifest"] = jsonValue(r.manifest)
+	}
+	if o.providerAlias != "" {
+		body["provider"] = o.providerAlias
+	}
+	if !r.forEach.IsNull() {
+		body["for_each"] = jsonValue(r.forEach)
+	}
+	if r.wait != nil {
+		body["wait"] = waitJSON(r.wait)
+	}
+	if fm := fieldManagerJSON(r, o); fm != nil {
+		body["field_manager"] = fm
+	}
+	if len(r.computedFields) > 0 {
+		body["computed_fields"] = r.computedFields
+	}
+	if len(r.dependsOn) > 0 {
+		body["depends_on"] = r.dependsOn
+	}
+	if lifecycle := lifecycleJSON(r); lifecycle != nil {
+		body["lifecycle"] = lifecycle
+	}
+	return marshalJSON(body)
+}
+
+func (tfjsonFormat) join(resources []resource) (string, error) {
+	resources, err := withRandomPasswords(resources)
+	if err != nil {
+		return "", err
+	}
+	types := []string{}
+	byType := map[string][]resource{}
+	for _, r := range resources {
+		if _, ok := byType[r.resourceType]; !ok {
+			types = append(types, r.resourceType)
+		}
+		byType[r.resourceType] = append(byType[r.resourceType], r)
+	}
+
+	var buf strings.Builder
+	buf.WriteString("{\n  \"resource\": {\n")
+	for i, typ := range types {
+		fmt.Fprintf(&buf, "    %q: {\n", typ)
+		for j, r := range byType[typ] {
+			name, err := marshalJSON(r.name)
+			if err != nil {
+				return "", err
+			}
+			body := strings.ReplaceAll(strings.TrimSuffix(r.text, "\n"), "\n", "\n      ")
+			fmt.Fprintf(&buf, "      %s: %s", strings.TrimSuffix(name, "\n"), body)
+			if j < len(byType[typ])-1 {
+				buf.WriteString(",")
+			}
+			buf.WriteString("\n")
+		}
+		buf.WriteString("    }")
+		if i < len(types)-1 {
+			buf.WriteString(",")
+		}
+		buf.WriteString("\n")
+	}
+	buf.WriteString("  }")
+
+	if sources := vaultDataJSON(resources); sources != nil {
+		data, err := marshalJSON(map[string]interface{}{vaultDataSource: sources})
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&buf, ",\n  \"data\": %s", strings.ReplaceAll(strings.TrimSuffix(data, "\n"), "\n", "\n  "))
+	}
+
+	imports := []string{}
+	for _, r := range resources {
+		if r.importID == "" {
+			continue
+		}
+		block := map[string]interface{}{
+			"to": r.resourceType + "." + r.name,
+			"id": r.importID,
+		}
+		if r.provider != "" {
+			block["provider"] = r.provider
+		}
+		b, err := marshalJSON(block)
+		if err != nil {
+			return "", err
+		}
+		imports = append(imports, "    "+strings.ReplaceAll(strings.TrimSuffix(b, "\n"), "\n", "\n    "))
+	}
+	if len(imports) > 0 {
+		fmt.Fprintf(&buf, ",\n  \"import\": [\n%s\n  ]", strings.Join(imports, ",\n"))
+	}
+	buf.WriteString("\n}\n")
+	return buf.String(), nil
+}
+
+func (tfjsonFormat) extension() string {
+	return ".tf.json"
+}
+
+// marshalJSON encodes v as indented JSON without escaping HTML characters
+func marshalJSON(v interface{}) (string, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// templateEscaper escapes the template sequences Terraform would otherwise
+// interpolate in JSON string values and heredocs
+var templateEscaper = strings.NewReplacer("${", "$${", "%{", "%%{")
+
+// jsonValue converts a manifest value to something encoding/json writes
+// the way Terraform reads it. Strings in Terraform JSON are templates, so
+// expressions are wrapped in ${} and literal template sequences escaped.
+func jsonValue(v cty.Value) interface{} {
+	if v.HasMark(terraform.Expression) {
+		expr, _ := v.Unmark()
+		return "${" + expr.AsString() + "}"
+	}
+	if v.IsNull() {
+		return nil
+	}
+
+	ty := v.Type()
+	switch {
+	case ty == cty.String:
+		return templateEscaper.Replace(v.AsString())
+	case ty == cty.Number:
+		return json.Number(v.AsBigFloat().Text('f', -1))
+	case ty == cty.Bool:
+		return v.True()
+	case ty.IsObjectType() || ty.IsMapType():
+		m := map[string]interface{}{}
+		for k, v := range v.AsValueMap() {
+			m[k] = jsonValue(v)
+		}
+		return m
+	case ty.IsTupleType() || ty.IsListType() || ty.IsSetType():
+		l := []interface{}{}
+		for _, v := range v.AsValueSlice() {
+			l = append(l, jsonValue(v))
+		}
+		return l
+	}
+	return nil
+}
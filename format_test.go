@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestYAMLToTerraformResourcesTFJSON(t *testing.T) {
+	yaml := `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: test
+data:
+  TEMPLATE: "${HOME} and %{if}"
+  HTML: "<b>"
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: test
+spec:
+  ports:
+  - port: 80
+`
+
+	output, err := YAMLToTerraformResources(strings.NewReader(yaml), WithFormat("tfjson"), WithProviderAlias("kubernetes.foo"))
+	if err != nil {
+		t.Fatal("Converting to JSON failed:", err)
+	}
+
+	expected := `{
+  "resource": {
+    "kubernetes_manifest": {
+      "configmap_test": {
+        "manifest": {
+          "apiVersion": "v1",
+          "data": {
+            "HTML": "<b>",
+            "TEMPLATE": "$${HOME} and %%{if}"
+          },
+          "kind": "ConfigMap",
+          "metadata": {
+            "name": "test"
+          }
+        },
+        "provider": "kubernetes.foo"
+      },
+      "service_test": {
+        "manifest": {
+          "apiVersion": "v1",
+          "kind": "Service",
+          "metadata": {
+            "name": "test"
+          },
+          "spec": {
+            "ports": [
+              {
+                "port": 80
+              }
+            ]
+          }
+        },
+        "provider": "kubernetes.foo"
+      }
+    }
+  }
+}
+`
+	assert.Equal(t, expected, output)
+	assert.True(t, json.Valid([]byte(output)))
+}
+
+func TestTFJSONBinaryData(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tfk8s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	output, err := YAMLToTerraformResources(strings.NewReader(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: test
+binaryData:
+  key: c2VjcmV0
+`), WithFormat("tfjson"), WithExtractBinaryData(dir, "files"))
+	if err != nil {
+		t.Fatal("Converting to JSON failed:", err)
+	}
+	assert.Contains(t, output, `"key": "${filebase64(\"${path.module}/files/configmap_test/key\")}"`)
+}
+
+func TestLookupFormat(t *testing.T) {
+	f, err := lookupFormat("")
+	assert.NoError(t, err)
+	assert.Equal(t, ".tf", f.extension())
+
+	f, err = lookupFormat("tfjson")
+	assert.NoError(t, err)
+	assert.Equal(t, ".tf.json", f.extension())
+
+	_, err = lookupFormat("xml")
+	assert.EqualError(t, err, `unknown --format "xml", must be one of cdktf-go, cdktf-python, cdktf-ts, hcl, heredoc, jsondecode, pulumi-yaml, tfjson, yaml, yamlref`)
+
+	_, err = YAMLToTerraformResources(strings.NewReader("kind: ConfigMap"), WithFormat("xml"))
+	assert.Error(t, err)
+
+	_, err = YAMLToTerraformResources(strings.NewReader(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: test
+`), WithFormat("tfjson"), WithMapOnly(true))
+	assert.Error(t, err)
+}
+
+func TestLayoutFilesTFJSON(t *testing.T) {
+	resources, err := convertResources(strings.NewReader(splitManifests), WithFormat("tfjson"))
+	if err != nil {
+		t.Fatal("Converting to JSON failed:", err)
+	}
+
+	filenames, files, err := layoutFiles(outputLayout{groupBy: "kind", format: tfjsonFormat{}}, resources)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"namespaces.tf.json", "deployments.tf.json", "services.tf.json", "configmaps.tf.json"}, filenames)
+	for _, filename := range filenames {
+		assert.True(t, json.Valid([]byte(files[filename])), filename)
+	}
+
+	filenames, _, err = layoutFiles(outputLayout{file: "main.tf.json", maxPerFile: 3, format: tfjsonFormat{}}, resources)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"main_1.tf.json", "main_2.tf.json"}, filenames)
+}
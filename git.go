@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	pathpkg "path"
+	"path/filepath"
+	"strings"
+)
+
+// gitPrefix marks a --file path as a git repository, using the same
+// syntax as Terraform module sources
+const gitPrefix = "git::"
+
+// gitCommand is the command used to clone repositories
+var gitCommand = "git"
+
+// runGit runs git with the supplied arguments and returns its output
+var runGit = func(args ...string) ([]byte, error) {
+	return runCommand(gitCommand, args...)
+}
+
+// isGit returns true if the path references a git repository
+func isGit(path string) bool {
+	return strings.HasPrefix(path, gitPrefix)
+}
+
+// gitSource is a parsed git repository reference
+type gitSource struct {
+	repository string
+	subpath    string
+	ref        string
+}
+
+// parseGitSource parses references like
+// git::https://github.com/org/repo//manifests?ref=v1.4.0
+func parseGitSource(path string) (gitSource, error) {
+	s := strings.TrimPrefix(path, gitPrefix)
+
+	var ref string
+	if i := strings.Index(s, "?"); i != -1 {
+		query, err := url.ParseQuery(s[i+1:])
+		if err != nil {
+			return gitSource{}, fmt.Errorf("invalid git source %q: %s", path, err)
+		}
+		ref = query.Get("ref")
+		s = s[:i]
+	}
+
+	// the subpath is separated from the repository by a double slash,
+	// skipping the one that follows the URL scheme
+	offset := 0
+	if i := strings.Index(s, "://"); i != -1 {
+		offset = i + 3
+	}
+	var subpath string
+	if i := strings.Index(s[offset:], "//"); i != -1 {
+		subpath = s[offset+i+2:]
+		s = s[:offset+i]
+	}
+
+	if s == "" {
+		return gitSource{}, fmt.Errorf("invalid git source %q: missing repository", path)
+	}
+	// git would read values starting with a dash as options
+	if strings.HasPrefix(s, "-") || strings.HasPrefix(ref, "-") {
+		return gitSource{}, fmt.Errorf("invalid git source %q: the repository and ref can't start with -", path)
+	}
+	if p := pathpkg.Clean(subpath); p == ".." || strings.HasPrefix(p, "../") {
+		return gitSource{}, fmt.Errorf("invalid git source %q: the subpath is outside of the repository", path)
+	}
+	return gitSource{repository: s, subpath: subpath, ref: ref}, nil
+}
+
+// cloneGitSource clones the repository into dir and checks out the ref
+func cloneGitSource(src gitSource, dir string) error {
+	if src.ref == "" {
+		_, err := runGit("clone", "--depth", "1", "--", src.repository, dir)
+		return err
+	}
+
+	// shallow clones only work for branches and tags, so fall
+	// back to a full clone in case the ref is a commit
+	_, err := runGit("clone", "--depth", "1", "--branch", src.ref, "--", src.repository, dir)
+	if err == nil {
+		return nil
+	}
+	os.RemoveAll(dir)
+	if _, err := runGit("clone", "--", src.repository, dir); err != nil {
+		return err
+	}
+	// the -- after the ref stops git reading it as a path
+	_, err = runGit("-C", dir, "checkout", src.ref, "--")
+	return err
+}
+
+// gitSources clones a git repository and returns a source for each
+// manifest found under the subpath
+func gitSources(path string, client *http.Client) ([]source, error) {
+	src, err := parseGitSource(path)
+	if err != nil {
+		return nil, err
+	}
+
+	dir, err := ioutil.TempDir("", "tfk8s-git")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	repoDir := filepath.Join(dir, "repo")
+	if err := cloneGitSource(src, repoDir); err != nil {
+		return nil, err
+	}
+
+	prefix := src.repository + "//"
+	if subpath := strings.Trim(src.subpath, "/"); subpath != "" {
+		prefix += subpath + "/"
+	}
+	return dirSources(filepath.Join(repoDir, filepath.FromSlash(src.subpath)), prefix, client)
+}
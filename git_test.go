@@ -0,0 +1,99 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseGitSource(t *testing.T) {
+	tests := []struct {
+		path     string
+		expected gitSource
+	}{
+		{
+			"git::https://github.com/org/repo//manifests?ref=v1.4.0",
+			gitSource{"https://github.com/org/repo", "manifests", "v1.4.0"},
+		},
+		{
+			"git::https://github.com/org/repo.git",
+			gitSource{"https://github.com/org/repo.git", "", ""},
+		},
+		{
+			"git::git@github.com:org/repo.git//deploy/prod?ref=main",
+			gitSource{"git@github.com:org/repo.git", "deploy/prod", "main"},
+		},
+	}
+
+	for _, tt := range tests {
+		src, err := parseGitSource(tt.path)
+		if err != nil {
+			t.Fatal("Parsing git source failed:", err)
+		}
+		assert.Equal(t, tt.expected, src)
+	}
+}
+
+func TestParseGitSourceInvalid(t *testing.T) {
+	for _, path := range []string{
+		"git::",
+		"git::--upload-pack=touch /tmp/x",
+		"git::https://github.com/org/repo?ref=--upload-pack=touch",
+		"git::https://github.com/org/repo//../outside",
+		"git::https://github.com/org/repo//manifests/../../outside",
+	} {
+		_, err := parseGitSource(path)
+		assert.Error(t, err, path)
+	}
+}
+
+func TestGitSources(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tfk8s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	git := func(args ...string) {
+		args = append([]string{"-C", dir, "-c", "user.name=tfk8s", "-c", "user.email=tfk8s@example.com"}, args...)
+		if _, err := runGit(args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write := func(name, content string) {
+		p := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(p), 0755)
+		if err := ioutil.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	git("init", "-q")
+	write("manifests/configmap.yaml", "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: tagged\n")
+	write("other/namespace.yaml", "apiVersion: v1\nkind: Namespace\nmetadata:\n  name: other\n")
+	git("add", ".")
+	git("commit", "-q", "-m", "first")
+	git("tag", "v1.0.0")
+	write("manifests/configmap.yaml", "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: latest\n")
+	git("commit", "-q", "-am", "second")
+
+	sources, err := gitSources("git::file://"+filepath.ToSlash(dir)+"//manifests?ref=v1.0.0", nil)
+	if err != nil {
+		t.Fatal("Cloning git source failed:", err)
+	}
+	if assert.Len(t, sources, 1) {
+		assert.Equal(t, "file://"+filepath.ToSlash(dir)+"//manifests/configmap.yaml", sources[0].name)
+		r, err := sources[0].open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		output, err := YAMLToTerraformResources(r)
+		if err != nil {
+			t.Fatal("Converting to HCL failed:", err)
+		}
+		assert.Contains(t, output, `resource "kubernetes_manifest" "configmap_tagged"`)
+	}
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/cli"
+	"helm.sh/helm/v3/pkg/cli/values"
+	"helm.sh/helm/v3/pkg/getter"
+)
+
+// helmReleaseName is the name charts are rendered with, the same one
+// helm template uses
+const helmReleaseName = "release-name"
+
+// helmConfiguration returns the configuration used to read installed
+// releases from the cluster, with the releases stored where Helm stores
+// them, in Secrets unless HELM_DRIVER says otherwise
+var helmConfiguration = func(opts helmOptions) (*action.Configuration, error) {
+	settings := cli.New()
+	settings.KubeConfig = opts.kubeconfig
+	settings.KubeContext = opts.kubeContext
+	if opts.namespace != "" {
+		settings.SetNamespace(opts.namespace)
+	}
+	cfg := &action.Configuration{}
+	err := cfg.Init(settings.RESTClientGetter(), settings.Namespace(), os.Getenv("HELM_DRIVER"), helmLog)
+	if err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// helmLog discards the debug messages of the Helm SDK
+func helmLog(string, ...interface{}) {}
+
+// helmSourcePrefix is the comment helm template adds to each document
+// to say which template it was rendered from
+const helmSourcePrefix = "# Source: "
+
+// helmOptions configures how a Helm chart is rendered, or which
+// installed release is read from the cluster
+type helmOptions struct {
+	chart     string
+	values    []string
+	namespace string
+
+	release     string
+	kubeconfig  string
+	kubeContext string
+}
+
+// renderHelmChart loads the chart from a directory or packaged chart and
+// renders it
+func renderHelmChart(opts helmOptions) ([]byte, error) {
+	chrt, err := loader.Load(opts.chart)
+	if err != nil {
+		return nil, err
+	}
+	return renderChart(chrt, opts)
+}
+
+// renderChart renders the chart the same as helm template, with the hooks
+// after the manifests, without installing anything
+func renderChart(chrt *chart.Chart, opts helmOptions) ([]byte, error) {
+	vals, err := (&values.Options{ValueFiles: opts.values}).MergeValues(getter.Providers{})
+	if err != nil {
+		return nil, err
+	}
+
+	install := action.NewInstall(&action.Configuration{Log: helmLog})
+	install.DryRun = true
+	install.ClientOnly = true
+	install.Replace = true
+	install.ReleaseName = helmReleaseName
+	install.Namespace = opts.namespace
+	if install.Namespace == "" {
+		install.Namespace = "default"
+	}
+	rel, err := install.Run(chrt, vals)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintln(&buf, strings.TrimSpace(rel.Manifest))
+	for _, h := range rel.Hooks {
+		fmt.Fprintf(&buf, "---\n%s%s\n%s\n", helmSourcePrefix, h.Path, h.Manifest)
+	}
+	return buf.Bytes(), nil
+}
+
+// getHelmRelease returns the manifest of an installed release, the same
+// as running helm get manifest
+func getHelmRelease(opts helmOptions) ([]byte, error) {
+	cfg, err := helmConfiguration(opts)
+	if err != nil {
+		return nil, err
+	}
+	rel, err := action.NewGet(cfg).Run(opts.release)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", opts.release, err)
+	}
+	return []byte(rel.Manifest), nil
+}
+
+// helmTemplateSource returns the template path from the "# Source:"
+// comment in a rendered document
+func helmTemplateSource(doc string) string {
+	for _, line := range strings.Split(doc, "\n") {
+		if strings.HasPrefix(line, helmSourcePrefix) {
+			return strings.TrimSpace(strings.TrimPrefix(line, helmSourcePrefix))
+		}
+	}
+	return ""
+}
+
+// splitHelmSources groups the documents rendered by helm template by the
+// template they came from, in the order the templates first appear
+func splitHelmSources(rendered []byte) ([]source, error) {
+	order := []string{}
+	docs := map[string][]string{}
+	r := newDocumentReader(bytes.NewReader(rendered))
+	for {
+		doc, err := r.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if isBlankDocument(doc.raw) {
+			// templates that render nothing still get a "# Source:" comment
+			continue
+		}
+		name := helmTemplateSource(doc.raw)
+		if _, ok := docs[name]; !ok {
+			order = append(order, name)
+		}
+		docs[name] = append(docs[name], doc.raw)
+	}
+
+	sources := []source{}
+	for _, name := range order {
+		manifest := strings.Join(docs[name], "\n---\n")
+		sources = append(sources, bytesSource(name, []byte(manifest)))
+	}
+	return sources, nil
+}
+
+// helmSources renders the chart, or gets the manifest of the release, and
+// returns the rendered manifests. When groupBySource is true there is one
+// source per template.
+func helmSources(opts helmOptions, groupBySource bool) ([]source, error) {
+	name := opts.chart
+	render := renderHelmChart
+	if opts.release != "" {
+		name = opts.release
+		render = getHelmRelease
+	}
+
+	rendered, err := render(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if groupBySource {
+		return splitHelmSources(rendered)
+	}
+	return []source{bytesSource(name, rendered)}, nil
+}
+
+// helmSourceFilename returns the file to write the resources rendered from
+// a template to, mirroring the layout of the chart's templates. ext is the
+// extension of the output format, like .tf
+func helmSourceFilename(dir, template, ext string) string {
+	if template == "" {
+		return filepath.Join(dir, "main"+ext)
+	}
+	template = strings.TrimSuffix(template, filepath.Ext(template))
+	return filepath.Join(dir, filepath.FromSlash(template)+ext)
+}
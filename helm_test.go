@@ -0,0 +1,206 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"helm.sh/helm/v3/pkg/action"
+	kubefake "helm.sh/helm/v3/pkg/kube/fake"
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/storage"
+	"helm.sh/helm/v3/pkg/storage/driver"
+)
+
+var renderedChart = `---
+# Source: mychart/templates/serviceaccount.yaml
+apiVersion: v1
+kind: ServiceAccount
+metadata:
+  name: release-name-mychart
+---
+# Source: mychart/templates/service.yaml
+apiVersion: v1
+kind: Service
+metadata:
+  name: release-name-mychart
+spec:
+  ports:
+  - port: 80
+---
+# Source: mychart/templates/serviceaccount.yaml
+apiVersion: v1
+kind: ServiceAccount
+metadata:
+  name: release-name-mychart-extra
+`
+
+// writeHelmChart writes a chart with a Service, a hook and a template
+// that renders nothing to dir
+func writeHelmChart(t *testing.T, dir string) string {
+	chart := filepath.Join(dir, "mychart")
+	files := map[string]string{
+		"Chart.yaml":  "apiVersion: v2\nname: mychart\nversion: 0.1.0\n",
+		"values.yaml": "port: 80\nmigrate: false\n",
+		"templates/service.yaml": `apiVersion: v1
+kind: Service
+metadata:
+  name: {{ .Release.Name }}-{{ .Chart.Name }}
+  namespace: {{ .Release.Namespace }}
+spec:
+  ports:
+  - port: {{ .Values.port }}
+`,
+		"templates/job.yaml": `{{- if .Values.migrate }}
+apiVersion: batch/v1
+kind: Job
+metadata:
+  name: {{ .Release.Name }}-migrate
+  annotations:
+    helm.sh/hook: pre-install
+{{- end }}
+`,
+		"templates/NOTES.txt": "Installed {{ .Release.Name }}\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(chart, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return chart
+}
+
+func TestRenderHelmChart(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tfk8s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	chart := writeHelmChart(t, dir)
+	values := filepath.Join(dir, "values.yaml")
+	if err := ioutil.WriteFile(values, []byte("port: 8080\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	prod := filepath.Join(dir, "values-prod.yaml")
+	if err := ioutil.WriteFile(prod, []byte("migrate: true\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	sources, err := helmSources(helmOptions{
+		chart:     chart,
+		values:    []string{values, prod},
+		namespace: "web",
+	}, true)
+	if err != nil {
+		t.Fatal("Rendering chart failed:", err)
+	}
+
+	names, output := convertSources(t, sources)
+	assert.Equal(t, []string{
+		"mychart/templates/service.yaml",
+		"mychart/templates/job.yaml",
+	}, names)
+	assert.Contains(t, output, `resource "kubernetes_manifest" "service_web_release_name_mychart"`)
+	assert.Contains(t, output, `"port" = 8080`)
+	assert.Contains(t, output, `resource "kubernetes_manifest" "job_release_name_migrate"`)
+}
+
+func TestRenderHelmChartDefaultValues(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tfk8s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	sources, err := helmSources(helmOptions{chart: writeHelmChart(t, dir)}, false)
+	if err != nil {
+		t.Fatal("Rendering chart failed:", err)
+	}
+
+	names, output := convertSources(t, sources)
+	assert.Equal(t, []string{filepath.Join(dir, "mychart")}, names)
+	assert.Contains(t, output, `resource "kubernetes_manifest" "service_release_name_mychart"`)
+	assert.Contains(t, output, `"namespace" = "default"`)
+	assert.Contains(t, output, `"port" = 80`)
+	assert.NotContains(t, output, "Job")
+}
+
+func TestGetHelmRelease(t *testing.T) {
+	defer func(f func(helmOptions) (*action.Configuration, error)) { helmConfiguration = f }(helmConfiguration)
+
+	var opts helmOptions
+	helmConfiguration = func(o helmOptions) (*action.Configuration, error) {
+		opts = o
+		cfg := &action.Configuration{
+			Releases:   storage.Init(driver.NewMemory()),
+			KubeClient: &kubefake.PrintingKubeClient{Out: ioutil.Discard},
+			Log:        helmLog,
+		}
+		err := cfg.Releases.Create(&release.Release{
+			Name:      "myapp",
+			Namespace: "web",
+			Version:   1,
+			Info:      &release.Info{Status: release.StatusDeployed},
+			Manifest:  renderedChart,
+		})
+		return cfg, err
+	}
+
+	sources, err := helmSources(helmOptions{
+		release:     "myapp",
+		namespace:   "web",
+		kubeContext: "staging",
+	}, true)
+	if err != nil {
+		t.Fatal("Getting release failed:", err)
+	}
+
+	assert.Equal(t, "staging", opts.kubeContext)
+	assert.Len(t, sources, 2)
+
+	_, err = helmSources(helmOptions{release: "missing"}, false)
+	assert.Error(t, err)
+}
+
+func TestSplitHelmSources(t *testing.T) {
+	sources, err := splitHelmSources([]byte(renderedChart))
+	if err != nil {
+		t.Fatal("Splitting rendered chart failed:", err)
+	}
+
+	names := []string{}
+	resources := []string{}
+	for _, s := range sources {
+		r, err := s.open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		output, err := YAMLToTerraformResources(r)
+		if err != nil {
+			t.Fatal("Converting to HCL failed:", err)
+		}
+		names = append(names, s.name)
+		resources = append(resources, output)
+	}
+
+	assert.Equal(t, []string{
+		"mychart/templates/serviceaccount.yaml",
+		"mychart/templates/service.yaml",
+	}, names)
+	assert.Contains(t, resources[0], `resource "kubernetes_manifest" "serviceaccount_release_name_mychart"`)
+	assert.Contains(t, resources[0], `resource "kubernetes_manifest" "serviceaccount_release_name_mychart_extra"`)
+	assert.Contains(t, resources[1], `resource "kubernetes_manifest" "service_release_name_mychart"`)
+}
+
+func TestHelmSourceFilename(t *testing.T) {
+	assert.Equal(t, filepath.Join("out", "mychart", "templates", "service.tf"), helmSourceFilename("out", "mychart/templates/service.yaml", ".tf"))
+	assert.Equal(t, filepath.Join("out", "main.tf"), helmSourceFilename("out", "", ".tf"))
+	assert.Equal(t, filepath.Join("out", "mychart", "templates", "service.tf.json"), helmSourceFilename("out", "mychart/templates/service.yaml", ".tf.json"))
+}
@@ -0,0 +1,86 @@
+package main
+
+import "fmt"
+
+// heredocFormat writes the manifest of each resource as YAML in a heredoc
+// passed to yamldecode(), so the formatting and comments of the document
+// are kept in the config
+type heredocFormat struct {
+	hclFormat
+}
+
+// heredocYAML returns the YAML of a manifest for a heredoc. Heredocs are
+// templates, so template sequences in the document are escaped, and
+// expressions are interpolated when the manifest had to be re-encoded.
+func heredocYAML(r resource) (string, error) {
+	if r.source != "" {
+		return templateEscaper.Replace(r.source), nil
+	}
+	return kubectlYAMLBody(r.manifest)
+}
+
+func (heredocFormat) resource(r resource, o options) (string, error) {
+	if o.mapOnly || r.typed || r.resourceType != resourceType {
+		return "", fmt.Errorf("--format heredoc can't be used with --map-only, --typed or --target")
+	}
+
+	body, err := heredocYAML(r)
+	if err != nil {
+		return "", err
+	}
+
+	hcl := fmt.Sprintf("resource %q %q {\n", r.resourceType, r.name)
+	if o.providerAlias != "" {
+		hcl += fmt.Sprintf("  provider = %v\n\n", o.providerAlias)
+	}
+	// the closing delimiter has to be on a line of its own
+	hcl += fmt.Sprintf("  manifest = yamldecode(%v\n  )\n", heredoc(body, "  ", "EOT"))
+	hcl += waitBlock(r)
+	hcl += fieldManagerBlock(r, o)
+	hcl += computedFieldsAttribute(r)
+	hcl += dependsOnAttribute(r)
+	hcl += lifecycleBlock(r)
+	hcl += "}\n"
+	hcl += importBlock(r)
+	return hcl, nil
+}
+
+// jsonDecodeFormat writes the manifest of each resource as JSON in a
+// heredoc passed to jsondecode(). The keys are sorted and on lines of their
+// own, so changes to the manifests make small diffs, and the JSON doesn't
+// need any of the quoting HCL does. With --target kubectl_manifest the JSON
+// is used as the yaml_body, as JSON is valid YAML.
+type jsonDecodeFormat struct {
+	hclFormat
+}
+
+func (jsonDecodeFormat) resource(r resource, o options) (string, error) {
+	if o.mapOnly || r.typed {
+		return "", fmt.Errorf("--format jsondecode can't be used with --map-only or --typed")
+	}
+
+	// jsonValue escapes template sequences and interpolates expressions,
+	// which is what a heredoc needs too
+	body, err := marshalJSON(jsonValue(r.manifest))
+	if err != nil {
+		return "", err
+	}
+
+	hcl := fmt.Sprintf("resource %q %q {\n", r.resourceType, r.name)
+	if o.providerAlias != "" {
+		hcl += fmt.Sprintf("  provider = %v\n\n", o.providerAlias)
+	}
+	if r.resourceType == kubectlResourceType {
+		hcl += fmt.Sprintf("  yaml_body = %v\n", heredoc(body, "  ", "EOT"))
+	} else {
+		hcl += fmt.Sprintf("  manifest = jsondecode(%v\n  )\n", heredoc(body, "  ", "EOT"))
+	}
+	hcl += waitBlock(r)
+	hcl += fieldManagerBlock(r, o)
+	hcl += computedFieldsAttribute(r)
+	hcl += dependsOnAttribute(r)
+	hcl += lifecycleBlock(r)
+	hcl += "}\n"
+	hcl += importBlock(r)
+	return hcl, nil
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestYAMLToTerraformResourcesHeredoc(t *testing.T) {
+	yaml := `# the config for the app
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: test # inline
+
+data:
+  TEMPLATE: "${HOME}"
+`
+
+	output, err := YAMLToTerraformResources(strings.NewReader(yaml), WithFormat("heredoc"))
+	if err != nil {
+		t.Fatal("Converting to HCL failed:", err)
+	}
+
+	expected := `resource "kubernetes_manifest" "configmap_test" {
+  manifest = yamldecode(<<-EOT
+    # the config for the app
+    apiVersion: v1
+    kind: ConfigMap
+    metadata:
+      name: test # inline
+
+    data:
+      TEMPLATE: "$${HOME}"
+  EOT
+  )
+}
+`
+	assert.Equal(t, expected, output)
+}
+
+func TestHeredocExtractBinaryData(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tfk8s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	output, err := YAMLToTerraformResources(strings.NewReader(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: test
+binaryData:
+  logo.png: iVBORw0KGgo=
+`), WithFormat("heredoc"), WithExtractBinaryData(dir, "files"))
+	if err != nil {
+		t.Fatal("Converting to HCL failed:", err)
+	}
+
+	// the document is re-encoded with the expressions interpolated
+	assert.Contains(t, output, "      logo.png: ${filebase64(\"${path.module}/files/configmap_test/logo.png\")}\n")
+}
+
+func TestYAMLToTerraformResourcesJSONDecode(t *testing.T) {
+	yaml := `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: test
+data:
+  TEMPLATE: "${HOME}"
+  replicas: "3"
+`
+
+	output, err := YAMLToTerraformResources(strings.NewReader(yaml), WithFormat("jsondecode"))
+	if err != nil {
+		t.Fatal("Converting to HCL failed:", err)
+	}
+
+	expected := `resource "kubernetes_manifest" "configmap_test" {
+  manifest = jsondecode(<<-EOT
+    {
+      "apiVersion": "v1",
+      "data": {
+        "TEMPLATE": "$${HOME}",
+        "replicas": "3"
+      },
+      "kind": "ConfigMap",
+      "metadata": {
+        "name": "test"
+      }
+    }
+  EOT
+  )
+}
+`
+	assert.Equal(t, expected, output)
+
+	output, err = YAMLToTerraformResources(strings.NewReader(yaml), WithFormat("jsondecode"), WithTarget(kubectlResourceType))
+	if err != nil {
+		t.Fatal("Converting to HCL failed:", err)
+	}
+	assert.Contains(t, output, "resource \"kubectl_manifest\" \"configmap_test\" {\n  yaml_body = <<-EOT\n    {\n")
+	assert.Contains(t, output, "\n  EOT\n}\n")
+}
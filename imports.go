@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// typedClusterScoped are the typed resources for objects that aren't in a
+// namespace, they are imported by name alone
+var typedClusterScoped = map[string]bool{
+	"kubernetes_namespace_v1":                        true,
+	"kubernetes_cluster_role_v1":                     true,
+	"kubernetes_cluster_role_binding_v1":             true,
+	"kubernetes_storage_class_v1":                    true,
+	"kubernetes_priority_class_v1":                   true,
+	"kubernetes_validating_webhook_configuration_v1": true,
+}
+
+// importID returns the ID each provider's resource is imported with, or
+// an empty string if the object has no name to import it by.
+//
+// kubernetes_manifest takes apiVersion=v1,kind=ConfigMap,name=test with the
+// namespace when it is set, kubectl_manifest takes v1//ConfigMap//test and
+// the namespace, and the typed resources take the namespace and name.
+func importID(r resource) string {
+	m := r.manifest.AsValueMap()
+	metadata := m["metadata"].AsValueMap()
+	name, ok := stringAttr(metadata, "name")
+	if !ok {
+		return ""
+	}
+	namespace, _ := stringAttr(metadata, "namespace")
+	if v, ok := metadata["namespace"]; ok && v.IsMarked() {
+		// the namespace is a variable, the object is imported from the
+		// namespace it was read from
+		namespace = r.namespace
+	}
+	apiVersion := m["apiVersion"].AsString()
+	kind := m["kind"].AsString()
+
+	switch {
+	case r.typed:
+		if typedClusterScoped[r.resourceType] {
+			return name
+		}
+		if namespace == "" {
+			namespace = "default"
+		}
+		return namespace + "/" + name
+	case r.resourceType == kubectlResourceType:
+		parts := []string{apiVersion, kind, name}
+		if namespace != "" {
+			parts = append(parts, namespace)
+		}
+		return strings.Join(parts, "//")
+	}
+	parts := []string{"apiVersion=" + apiVersion, "kind=" + kind}
+	if namespace != "" {
+		parts = append(parts, "namespace="+namespace)
+	}
+	parts = append(parts, "name="+name)
+	return strings.Join(parts, ",")
+}
+
+// importBlock returns the import block for a resource, which follows its
+// resource block, or an empty string if it isn't imported
+func importBlock(r resource) string {
+	if r.importID == "" {
+		return ""
+	}
+	hcl := "\nimport {\n"
+	if r.provider != "" {
+		hcl += fmt.Sprintf("  provider = %v\n", r.provider)
+	}
+	hcl += fmt.Sprintf("  to = %s.%s\n", r.resourceType, r.name)
+	hcl += fmt.Sprintf("  id = %q\n", r.importID)
+	hcl += "}\n"
+	return hcl
+}
+
+// shellQuote quotes s for a POSIX shell
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// importScript returns a shell script that runs terraform import for each
+// resource, for versions of Terraform without import blocks
+func importScript(resources []resource) string {
+	var buf strings.Builder
+	buf.WriteString("#!/bin/sh\n")
+	buf.WriteString("# Imports the objects that are already in the cluster, generated by tfk8s\n")
+	buf.WriteString("set -e\n\n")
+	for _, r := range resources {
+		id := importID(r)
+		if id == "" {
+			fmt.Fprintf(&buf, "# %s has no metadata.name so it can't be imported\n", r.name)
+			continue
+		}
+		fmt.Fprintf(&buf, "terraform import %s %s\n", shellQuote(r.resourceType+"."+r.name), shellQuote(id))
+	}
+	return buf.String()
+}
+
+// writeImportScript writes the import script for resources to path
+func writeImportScript(path string, resources []resource) error {
+	return writeFileAtomic(path, []byte(importScript(resources)), 0755)
+}
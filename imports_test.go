@@ -0,0 +1,137 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestYAMLToTerraformResourcesGenerateImports(t *testing.T) {
+	yaml := `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: test
+  namespace: web
+`
+
+	output, err := YAMLToTerraformResources(strings.NewReader(yaml), WithGenerateImports(true), WithProviderAlias("kubernetes.other"))
+	if err != nil {
+		t.Fatal("Converting to HCL failed:", err)
+	}
+
+	expected := `resource "kubernetes_manifest" "configmap_web_test" {
+  provider = kubernetes.other
+
+  manifest = {
+    "apiVersion" = "v1"
+    "kind" = "ConfigMap"
+    "metadata" = {
+      "name" = "test"
+      "namespace" = "web"
+    }
+  }
+}
+
+import {
+  provider = kubernetes.other
+  to = kubernetes_manifest.configmap_web_test
+  id = "apiVersion=v1,kind=ConfigMap,namespace=web,name=test"
+}
+`
+	assert.Equal(t, expected, output)
+
+	output, err = YAMLToTerraformResources(strings.NewReader(yaml), WithGenerateImports(true), WithFormat("tfjson"))
+	if err != nil {
+		t.Fatal("Converting to JSON failed:", err)
+	}
+	assert.Contains(t, output, `
+  },
+  "import": [
+    {
+      "id": "apiVersion=v1,kind=ConfigMap,namespace=web,name=test",
+      "to": "kubernetes_manifest.configmap_web_test"
+    }
+  ]
+}
+`)
+}
+
+func TestImportID(t *testing.T) {
+	importID := func(yaml string, opts ...Option) string {
+		t.Helper()
+		opts = append(opts, WithGenerateImports(true))
+		output, err := YAMLToTerraformResources(strings.NewReader(yaml), opts...)
+		if err != nil {
+			t.Fatal("Converting to HCL failed:", err)
+		}
+		i := strings.Index(output, "  id = ")
+		if i < 0 {
+			return ""
+		}
+		return strings.TrimSpace(strings.SplitN(output[i:], "\n", 2)[0])
+	}
+
+	configMap := `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: test
+  namespace: web
+`
+	namespace := `apiVersion: v1
+kind: Namespace
+metadata:
+  name: web
+`
+	assert.Equal(t, `id = "apiVersion=v1,kind=Namespace,name=web"`, importID(namespace))
+	assert.Equal(t, `id = "v1//ConfigMap//test//web"`, importID(configMap, WithTarget(kubectlResourceType)))
+	assert.Equal(t, `id = "v1//Namespace//web"`, importID(namespace, WithTarget(kubectlResourceType)))
+	assert.Equal(t, `id = "web/test"`, importID(configMap, WithTyped(true)))
+	assert.Equal(t, `id = "web"`, importID(namespace, WithTyped(true)))
+
+	// namespaced objects are in the default namespace unless it is set
+	assert.Equal(t, `id = "default/test"`, importID(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: test
+`, WithTyped(true)))
+
+	// objects named by the server can't be imported
+	assert.Equal(t, "", importID(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  generateName: test-
+`))
+}
+
+func TestImportScript(t *testing.T) {
+	resources, err := convertResources(strings.NewReader(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: test
+  namespace: web
+---
+apiVersion: example.com/v1
+kind: Widget
+metadata:
+  name: it's
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  generateName: test-
+`))
+	if err != nil {
+		t.Fatal("Converting to HCL failed:", err)
+	}
+
+	expected := `#!/bin/sh
+# Imports the objects that are already in the cluster, generated by tfk8s
+set -e
+
+terraform import 'kubernetes_manifest.configmap_web_test' 'apiVersion=v1,kind=ConfigMap,namespace=web,name=test'
+terraform import 'kubernetes_manifest.widget_it_s' 'apiVersion=example.com/v1,kind=Widget,name=it'\''s'
+# configmap_test has no metadata.name so it can't be imported
+`
+	assert.Equal(t, expected, importScript(resources))
+}
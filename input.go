@@ -0,0 +1,316 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// source is a named stream of manifests to convert
+type source struct {
+	// name is used to report which input an error came from
+	name string
+	open func() (io.ReadCloser, error)
+}
+
+// bytesSource returns a source that reads from b
+func bytesSource(name string, b []byte) source {
+	return source{
+		name: name,
+		open: func() (io.ReadCloser, error) {
+			return ioutil.NopCloser(bytes.NewReader(b)), nil
+		},
+	}
+}
+
+// dirSources reads every manifest in dir and returns a source for each,
+// named by prefix followed by the path of the file relative to dir. The files are
+// read straight away so dir can be removed afterwards.
+func dirSources(dir, prefix string, client *http.Client) ([]source, error) {
+	files, err := expandInputPath(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	sources := []source{}
+	for _, f := range files {
+		r, err := openInput(f, client)
+		if err != nil {
+			return nil, err
+		}
+		b, err := ioutil.ReadAll(r)
+		r.Close()
+		if err != nil {
+			return nil, err
+		}
+		rel, err := filepath.Rel(dir, f)
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, bytesSource(prefix+filepath.ToSlash(rel), b))
+	}
+	return sources, nil
+}
+
+// fileSources returns the sources for the paths returned by expandInputPaths.
+// OCI artifacts, git repositories and archives are read straight away as
+// they can contain many manifests.
+func fileSources(paths []string, client *http.Client) ([]source, error) {
+	sources := []source{}
+	for _, p := range paths {
+		path := p
+		if isOCI(path) {
+			s, err := ociSources(path, client)
+			if err != nil {
+				return nil, err
+			}
+			sources = append(sources, s...)
+			continue
+		}
+		if isGit(path) {
+			s, err := gitSources(path, client)
+			if err != nil {
+				return nil, err
+			}
+			sources = append(sources, s...)
+			continue
+		}
+		if isArchive(path) {
+			s, err := archiveSources(path, client)
+			if err != nil {
+				return nil, err
+			}
+			sources = append(sources, s...)
+			continue
+		}
+		sources = append(sources, source{
+			name: path,
+			open: func() (io.ReadCloser, error) {
+				return openInput(path, client)
+			},
+		})
+	}
+	return sources, nil
+}
+
+// manifestExtensions is the list of file extensions that are read
+// when a directory is passed to --file
+var manifestExtensions = []string{
+	".yaml",
+	".yml",
+	".json",
+	".jsonnet",
+}
+
+// isManifestFile returns true if the file has one of the manifestExtensions
+func isManifestFile(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	for _, e := range manifestExtensions {
+		if ext == e {
+			return true
+		}
+	}
+	return false
+}
+
+// hasGlobMeta returns true if the path contains any glob pattern characters
+func hasGlobMeta(path string) bool {
+	return strings.ContainsAny(path, "*?[")
+}
+
+// matchGlob reports whether the path segments match the pattern segments.
+// The pattern segment ** matches zero or more path segments, all other
+// segments are matched using path.Match.
+func matchGlob(pattern, name []string) (bool, error) {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				ok, err := matchGlob(pattern[1:], name[i:])
+				if ok || err != nil {
+					return ok, err
+				}
+			}
+			return false, nil
+		}
+		if len(name) == 0 {
+			return false, nil
+		}
+		ok, err := path.Match(pattern[0], name[0])
+		if !ok || err != nil {
+			return false, err
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0, nil
+}
+
+// expandGlob returns the files matching the glob pattern in lexical order.
+// Patterns always use forward slashes so they behave the same on every OS.
+func expandGlob(pattern string) ([]string, error) {
+	pattern = filepath.ToSlash(filepath.Clean(pattern))
+	segments := strings.Split(pattern, "/")
+
+	// walk from the longest prefix of the pattern without any glob characters
+	base := []string{}
+	for _, s := range segments {
+		if hasGlobMeta(s) {
+			break
+		}
+		base = append(base, s)
+	}
+	root := strings.Join(base, "/")
+	if root == "" && len(base) > 0 {
+		root = "/"
+	} else if root == "" {
+		root = "."
+	}
+
+	files := []string{}
+	err := filepath.Walk(filepath.FromSlash(root), func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		ok, err := matchGlob(segments, strings.Split(filepath.ToSlash(p), "/"))
+		if err != nil {
+			return err
+		}
+		if ok {
+			files = append(files, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no files match the pattern %q", pattern)
+	}
+	return files, nil
+}
+
+// expandInputPath returns the list of files to read for the path supplied
+// to --file. URLs, OCI references and git sources are returned as is. If
+// the path is a directory it is walked recursively and every manifest file
+// is returned in lexical order. If the path is a glob pattern the matching
+// files are returned in lexical order.
+func expandInputPath(path string) ([]string, error) {
+	if path == "-" || isURL(path) || isOCI(path) || isGit(path) {
+		return []string{path}, nil
+	}
+
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) && hasGlobMeta(path) {
+		return expandGlob(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+
+	files := []string{}
+	err = filepath.Walk(path, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && isManifestFile(p) {
+			files = append(files, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// expandInputPaths expands every path supplied to --file, preserving the
+// order the paths were given in
+func expandInputPaths(paths []string) ([]string, error) {
+	files := []string{}
+	for _, p := range paths {
+		f, err := expandInputPath(p)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, f...)
+	}
+	return files, nil
+}
+
+// isURL returns true if the path is an HTTP or HTTPS URL
+func isURL(path string) bool {
+	return strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://")
+}
+
+// newHTTPClient returns the client used to fetch manifests from URLs
+func newHTTPClient(timeout time.Duration, insecureSkipVerify bool) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{
+		InsecureSkipVerify: insecureSkipVerify,
+	}
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+	}
+}
+
+// openInput opens a path returned by expandInputPaths for reading.
+// "-" is stdin, URLs are fetched using the client, jsonnet and CUE
+// files are evaluated and files encrypted with SOPS are decrypted.
+func openInput(path string, client *http.Client) (io.ReadCloser, error) {
+	if path == "-" {
+		return os.Stdin, nil
+	}
+
+	if isURL(path) {
+		res, err := client.Get(path)
+		if err != nil {
+			return nil, err
+		}
+		if res.StatusCode != http.StatusOK {
+			res.Body.Close()
+			return nil, fmt.Errorf("GET %s: %s", path, res.Status)
+		}
+		return res.Body, nil
+	}
+
+	if isJsonnetFile(path) {
+		b, err := evaluateJsonnet(path)
+		if err != nil {
+			return nil, err
+		}
+		return ioutil.NopCloser(bytes.NewReader(b)), nil
+	}
+
+	if isCUEFile(path) {
+		b, err := evaluateCUE(path)
+		if err != nil {
+			return nil, err
+		}
+		return ioutil.NopCloser(bytes.NewReader(b)), nil
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if isSOPSEncrypted(b) {
+		if b, err = decryptSOPS(path); err != nil {
+			return nil, err
+		}
+	}
+	return ioutil.NopCloser(bytes.NewReader(b)), nil
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpandInputPathDirectory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tfk8s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := []string{
+		"b.yaml",
+		"a.json",
+		"nested/c.yml",
+		"nested/deeper/d.YAML",
+		"nested/e.jsonnet",
+		"README.md",
+		"nested/script.sh",
+	}
+	for _, f := range files {
+		p := filepath.Join(dir, f)
+		os.MkdirAll(filepath.Dir(p), 0755)
+		if err := ioutil.WriteFile(p, []byte{}, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	paths, err := expandInputPath(dir)
+	if err != nil {
+		t.Fatal("Expanding input path failed:", err)
+	}
+
+	expected := []string{
+		filepath.Join(dir, "a.json"),
+		filepath.Join(dir, "b.yaml"),
+		filepath.Join(dir, "nested/c.yml"),
+		filepath.Join(dir, "nested/deeper/d.YAML"),
+		filepath.Join(dir, "nested/e.jsonnet"),
+	}
+	assert.Equal(t, expected, paths)
+}
+
+func TestExpandInputPathFile(t *testing.T) {
+	paths, err := expandInputPath("tfk8s.go")
+	if err != nil {
+		t.Fatal("Expanding input path failed:", err)
+	}
+	assert.Equal(t, []string{"tfk8s.go"}, paths)
+
+	paths, err = expandInputPath("-")
+	if err != nil {
+		t.Fatal("Expanding input path failed:", err)
+	}
+	assert.Equal(t, []string{"-"}, paths)
+
+	_, err = expandInputPath("does-not-exist.yaml")
+	assert.Error(t, err)
+}
+
+func TestExpandInputPathGlob(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tfk8s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := []string{
+		"deploy/ns.yaml",
+		"deploy/app/deployment.yaml",
+		"deploy/app/service.yaml",
+		"deploy/app/values.json",
+		"deploy/app/nested/config.yaml",
+		"other/secret.yaml",
+	}
+	for _, f := range files {
+		p := filepath.Join(dir, f)
+		os.MkdirAll(filepath.Dir(p), 0755)
+		if err := ioutil.WriteFile(p, []byte{}, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		pattern  string
+		expected []string
+	}{
+		{
+			"deploy/**/*.yaml",
+			[]string{
+				"deploy/app/deployment.yaml",
+				"deploy/app/nested/config.yaml",
+				"deploy/app/service.yaml",
+				"deploy/ns.yaml",
+			},
+		},
+		{
+			"deploy/*/*.yaml",
+			[]string{
+				"deploy/app/deployment.yaml",
+				"deploy/app/service.yaml",
+			},
+		},
+		{
+			"**/s*.yaml",
+			[]string{
+				"deploy/app/service.yaml",
+				"other/secret.yaml",
+			},
+		},
+		{
+			"deploy/app/*.json",
+			[]string{
+				"deploy/app/values.json",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			paths, err := expandInputPath(filepath.ToSlash(dir) + "/" + tt.pattern)
+			if err != nil {
+				t.Fatal("Expanding input path failed:", err)
+			}
+
+			expected := []string{}
+			for _, e := range tt.expected {
+				expected = append(expected, filepath.Join(dir, e))
+			}
+			assert.Equal(t, expected, paths)
+		})
+	}
+
+	_, err = expandInputPath(filepath.Join(dir, "*.txt"))
+	assert.Error(t, err)
+}
+
+func TestExpandInputPaths(t *testing.T) {
+	paths, err := expandInputPaths([]string{"tfk8s.go", "input.go", "-"})
+	if err != nil {
+		t.Fatal("Expanding input paths failed:", err)
+	}
+	assert.Equal(t, []string{"tfk8s.go", "input.go", "-"}, paths)
+
+	_, err = expandInputPaths([]string{"tfk8s.go", "does-not-exist.yaml"})
+	assert.Error(t, err)
+}
+
+func TestOpenInputURL(t *testing.T) {
+	manifest := `---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: test
+`
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/manifest.yaml" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(manifest))
+	})
+	server := httptest.NewTLSServer(handler)
+	defer server.Close()
+
+	url := server.URL + "/manifest.yaml"
+	paths, err := expandInputPath(url)
+	if err != nil {
+		t.Fatal("Expanding input path failed:", err)
+	}
+	assert.Equal(t, []string{url}, paths)
+
+	// the test server uses a self signed certificate
+	_, err = openInput(url, newHTTPClient(time.Second, false))
+	assert.Error(t, err)
+
+	r, err := openInput(url, newHTTPClient(time.Second, true))
+	if err != nil {
+		t.Fatal("Opening URL failed:", err)
+	}
+	defer r.Close()
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal("Reading URL failed:", err)
+	}
+	assert.Equal(t, manifest, string(b))
+
+	_, err = openInput(server.URL+"/missing.yaml", newHTTPClient(time.Second, true))
+	assert.EqualError(t, err, "GET "+server.URL+"/missing.yaml: 404 Not Found")
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	jsonnet "github.com/google/go-jsonnet"
+)
+
+// isJsonnetFile returns true if the file needs to be evaluated with jsonnet
+func isJsonnetFile(path string) bool {
+	return strings.ToLower(filepath.Ext(path)) == ".jsonnet"
+}
+
+// evaluateJsonnet evaluates a jsonnet file and returns the resulting JSON,
+// which can be a single object or a list of objects. Library paths can be
+// supplied with the JSONNET_PATH environment variable.
+func evaluateJsonnet(path string) ([]byte, error) {
+	// the importer searches the last path first, and the paths that come
+	// first in JSONNET_PATH win with the jsonnet command
+	paths := filepath.SplitList(os.Getenv("JSONNET_PATH"))
+	jpaths := make([]string, len(paths))
+	for i, p := range paths {
+		jpaths[len(paths)-1-i] = p
+	}
+
+	vm := jsonnet.MakeVM()
+	vm.Importer(&jsonnet.FileImporter{JPaths: jpaths})
+	json, err := vm.EvaluateFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(json), nil
+}
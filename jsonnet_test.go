@@ -0,0 +1,88 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOpenInputJsonnet(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tfk8s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"lib/configmap.libsonnet": `{
+  configMap(name):: {apiVersion: "v1", kind: "ConfigMap", metadata: {name: name}},
+}`,
+		"app/main.jsonnet": `local k = import "configmap.libsonnet";
+[k.configMap(name) for name in ["one", "two"]]`,
+	}
+	for name, text := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(text), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	defer os.Setenv("JSONNET_PATH", os.Getenv("JSONNET_PATH"))
+	os.Setenv("JSONNET_PATH", filepath.Join(dir, "lib"))
+
+	r, err := openInput(filepath.Join(dir, "app", "main.jsonnet"), nil)
+	if err != nil {
+		t.Fatal("Evaluating jsonnet failed:", err)
+	}
+
+	output, err := YAMLToTerraformResources(r)
+	if err != nil {
+		t.Fatal("Converting to HCL failed:", err)
+	}
+
+	expected := `
+resource "kubernetes_manifest" "configmap_one" {
+  manifest = {
+    "apiVersion" = "v1"
+    "kind" = "ConfigMap"
+    "metadata" = {
+      "name" = "one"
+    }
+  }
+}
+
+resource "kubernetes_manifest" "configmap_two" {
+  manifest = {
+    "apiVersion" = "v1"
+    "kind" = "ConfigMap"
+    "metadata" = {
+      "name" = "two"
+    }
+  }
+}`
+	assert.Equal(t, strings.TrimSpace(expected), strings.TrimSpace(output))
+}
+
+func TestEvaluateJsonnetError(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tfk8s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "main.jsonnet")
+	if err := ioutil.WriteFile(path, []byte(`error "no manifests"`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, err = evaluateJsonnet(path)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "no manifests")
+	}
+}
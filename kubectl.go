@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	cty "github.com/zclconf/go-cty/cty"
+)
+
+// kubectlResourceType is the resource of the gavinbunney/kubectl provider,
+// which takes the manifest as a YAML string
+const kubectlResourceType = "kubectl_manifest"
+
+// targets are the resource types that can be used with --target
+var targets = []string{resourceType, kubectlResourceType}
+
+// lookupTarget checks target is a supported resource type, the default is
+// kubernetes_manifest
+func lookupTarget(target string) (string, error) {
+	if target == "" {
+		return resourceType, nil
+	}
+	for _, t := range targets {
+		if t == target {
+			return target, nil
+		}
+	}
+	return "", fmt.Errorf("unknown --target %q, must be one of %s", target, strings.Join(targets, ", "))
+}
+
+// kubectlYAMLBody returns the yaml_body of a kubectl_manifest. It is a
+// template in both HCL and JSON, so template sequences are escaped and
+// expressions are interpolated.
+func kubectlYAMLBody(manifest cty.Value) (string, error) {
+	n, err := yamlNode(manifest, yamlEncoding{
+		flag:   "--target " + kubectlResourceType,
+		escape: templateEscaper.Replace,
+		expression: func(expr string) string {
+			return "${" + expr + "}"
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+	return encodeYAML(n)
+}
+
+// heredoc returns s as an indented heredoc that starts on the current line.
+// delimiter is prefixed with _ until it doesn't appear on a line of its own
+// in s.
+func heredoc(s, indent, delimiter string) string {
+	lines := map[string]bool{}
+	for _, line := range strings.Split(s, "\n") {
+		lines[strings.TrimSpace(line)] = true
+	}
+	for lines[delimiter] {
+		delimiter = "_" + delimiter
+	}
+
+	var buf strings.Builder
+	buf.WriteString("<<-" + delimiter + "\n")
+	for _, line := range strings.SplitAfter(strings.TrimSuffix(s, "\n"), "\n") {
+		if strings.TrimSpace(line) != "" {
+			// blank lines are left out when the indentation is removed
+			buf.WriteString(indent + "  ")
+		}
+		buf.WriteString(line)
+	}
+	buf.WriteString("\n" + indent + delimiter)
+	return buf.String()
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/yaml"
+)
+
+func TestYAMLToTerraformResourcesKubectlManifest(t *testing.T) {
+	yaml := `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: test
+data:
+  TEMPLATE: "${HOME}"
+  SCRIPT: |
+    echo hello
+
+    echo world
+`
+
+	output, err := YAMLToTerraformResources(strings.NewReader(yaml), WithTarget("kubectl_manifest"), WithProviderAlias("kubectl.other"))
+	if err != nil {
+		t.Fatal("Converting to HCL failed:", err)
+	}
+
+	expected := `resource "kubectl_manifest" "configmap_test" {
+  provider = kubectl.other
+
+  yaml_body = <<-YAML
+    apiVersion: v1
+    data:
+      SCRIPT: |
+        echo hello
+
+        echo world
+      TEMPLATE: $${HOME}
+    kind: ConfigMap
+    metadata:
+      name: test
+  YAML
+}
+`
+	assert.Equal(t, expected, output)
+
+	output, err = YAMLToTerraformResources(strings.NewReader(yaml), WithTarget("kubectl_manifest"), WithFormat("tfjson"))
+	if err != nil {
+		t.Fatal("Converting to JSON failed:", err)
+	}
+	var config map[string]map[string]map[string]map[string]string
+	if err := json.Unmarshal([]byte(output), &config); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "apiVersion: v1\ndata:\n  SCRIPT: |\n    echo hello\n\n    echo world\n  TEMPLATE: $${HOME}\nkind: ConfigMap\nmetadata:\n  name: test\n", config["resource"]["kubectl_manifest"]["configmap_test"]["yaml_body"])
+
+	_, err = YAMLToTerraformResources(strings.NewReader(yaml), WithTarget("kubectl_manifest"), WithFormat("cdktf-ts"))
+	assert.Error(t, err)
+
+	_, err = YAMLToTerraformResources(strings.NewReader(yaml), WithTarget("helm_release"))
+	assert.Error(t, err)
+}
+
+func TestKubectlYAMLBodyRoundTrip(t *testing.T) {
+	data := map[string]string{
+		"y":       "y",
+		"country": "NO",
+		"on":      "0755",
+		"1.10":    "null",
+		"name":    "nginx",
+	}
+	manifest := "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: test\ndata:\n"
+	for k, v := range data {
+		manifest += "  \"" + k + "\": \"" + v + "\"\n"
+	}
+	output, err := YAMLToTerraformResources(strings.NewReader(manifest), WithTarget("kubectl_manifest"), WithFormat("tfjson"))
+	if err != nil {
+		t.Fatal("Converting to JSON failed:", err)
+	}
+	var config map[string]map[string]map[string]map[string]string
+	if err := json.Unmarshal([]byte(output), &config); err != nil {
+		t.Fatal(err)
+	}
+
+	// read the yaml_body the way kubectl does
+	body, err := yaml.YAMLToJSON([]byte(config["resource"]["kubectl_manifest"]["configmap_test"]["yaml_body"]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var configMap struct {
+		Data map[string]string
+	}
+	if err := json.Unmarshal(body, &configMap); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, data, configMap.Data)
+}
+
+func TestHeredoc(t *testing.T) {
+	assert.Equal(t, "<<-YAML\n    a: 1\n\n    b: 2\n  YAML", heredoc("a: 1\n\nb: 2\n", "  ", "YAML"))
+	assert.Equal(t, "<<-_YAML\n  a: |\n    YAML\n_YAML", heredoc("a: |\n  YAML\n", "", "YAML"))
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ignoreChange is an attribute --ignore-changes adds to ignore_changes, on
+// the resources of kind or every resource if kind is empty
+type ignoreChange struct {
+	kind string
+	path string
+}
+
+// parseIgnoreChange parses an attribute like manifest.spec.replicas or
+// Deployment:manifest.spec.replicas
+func parseIgnoreChange(s string) (ignoreChange, error) {
+	c := ignoreChange{path: s}
+	if i := strings.Index(s, ":"); i >= 0 {
+		c.kind, c.path = s[:i], s[i+1:]
+	}
+	if c.path == "" {
+		return ignoreChange{}, fmt.Errorf("invalid --ignore-changes %q, must be like manifest.spec.replicas or Deployment:manifest.spec.replicas", s)
+	}
+	return c, nil
+}
+
+// resourceIgnoreChanges returns the attributes in changes a resource of
+// kind ignores the changes to
+func resourceIgnoreChanges(kind string, changes []ignoreChange) []string {
+	var paths []string
+	for _, c := range changes {
+		if (c.kind == "" || c.kind == kind) && !containsString(paths, c.path) {
+			paths = append(paths, c.path)
+		}
+	}
+	return paths
+}
+
+// replicasAttribute returns the attribute of the replicas of the object r
+// creates, and false if the resource doesn't have one
+func replicasAttribute(r resource) (string, bool) {
+	switch {
+	case r.typed:
+		return "spec[0].replicas", true
+	case r.resourceType == resourceType:
+		return "manifest.spec.replicas", true
+	}
+	return "", false
+}
+
+// ignoreScaledReplicas adds the replicas to the ignore_changes of the
+// resources a HorizontalPodAutoscaler converted in the same run scales, so
+// Terraform doesn't undo what the autoscaler did. The resources are
+// converted again with opts.
+func ignoreScaledReplicas(resources []resource, opts ...Option) ([]resource, error) {
+	o := newOptions(opts)
+	format, err := lookupFormat(o.format)
+	if err != nil {
+		return nil, err
+	}
+
+	targets := scaleTargets(resources, o)
+	for i, r := range resources {
+		if !r.forEach.IsNull() || !isScaled(r, targets, o) {
+			continue
+		}
+		replicas, ok := replicasAttribute(r)
+		if !ok {
+			o.note("%s %s is scaled by a HorizontalPodAutoscaler, but %s can't ignore changes to its replicas", r.kind, r.objectName, r.resourceType)
+			continue
+		}
+		if containsString(r.ignoreChanges, replicas) {
+			continue
+		}
+		r.ignoreChanges = append(r.ignoreChanges, replicas)
+		if r.text, err = format.resource(r, o); err != nil {
+			return nil, err
+		}
+		resources[i] = r
+	}
+	return resources, nil
+}
+
+// preventDestroyKinds parses the kinds --prevent-destroy protects, each
+// can be written as kind=PersistentVolumeClaim
+func preventDestroyKinds(kinds []string) map[string]bool {
+	protected := map[string]bool{}
+	for _, kind := range kinds {
+		protected[strings.TrimPrefix(strings.TrimSpace(kind), "kind=")] = true
+	}
+	return protected
+}
+
+// lifecycleBlock returns the lifecycle block of r, or "" if it doesn't
+// need one
+func lifecycleBlock(r resource) string {
+	if len(r.ignoreChanges) == 0 && !r.preventDestroy {
+		return ""
+	}
+	var buf strings.Builder
+	buf.WriteString("\n  lifecycle {\n")
+	if r.preventDestroy {
+		buf.WriteString("    prevent_destroy = true\n")
+	}
+	if len(r.ignoreChanges) > 0 {
+		buf.WriteString("    ignore_changes = [\n")
+		for _, path := range r.ignoreChanges {
+			fmt.Fprintf(&buf, "      %s,\n", path)
+		}
+		buf.WriteString("    ]\n")
+	}
+	buf.WriteString("  }\n")
+	return buf.String()
+}
+
+// lifecycleJSON returns the lifecycle block of r for Terraform JSON, or nil
+// if it doesn't need one
+func lifecycleJSON(r resource) map[string]interface{} {
+	if len(r.ignoreChanges) == 0 && !r.preventDestroy {
+		return nil
+	}
+	lifecycle := map[string]interface{}{}
+	if r.preventDestroy {
+		lifecycle["prevent_destroy"] = true
+	}
+	if len(r.ignoreChanges) > 0 {
+		lifecycle["ignore_changes"] = r.ignoreChanges
+	}
+	return lifecycle
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var scaledYAML = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  replicas: 2
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: other
+spec:
+  replicas: 2
+---
+apiVersion: autoscaling/v2
+kind: HorizontalPodAutoscaler
+metadata:
+  name: web
+spec:
+  scaleTargetRef:
+    apiVersion: apps/v1
+    kind: Deployment
+    name: web
+`
+
+func TestIgnoreChanges(t *testing.T) {
+	c, err := parseIgnoreChange("Deployment:manifest.spec.replicas")
+	assert.NoError(t, err)
+	assert.Equal(t, ignoreChange{kind: "Deployment", path: "manifest.spec.replicas"}, c)
+
+	resources, err := convertResources(strings.NewReader(scaledYAML), WithIgnoreChanges(
+		ignoreChange{path: "manifest.metadata.annotations"},
+		ignoreChange{kind: "HorizontalPodAutoscaler", path: "manifest.spec.minReplicas"},
+	))
+	if err != nil {
+		t.Fatal("Converting to HCL failed:", err)
+	}
+	assert.Equal(t, []string{"manifest.metadata.annotations"}, resources[0].ignoreChanges)
+	assert.Equal(t, []string{"manifest.metadata.annotations", "manifest.spec.minReplicas"}, resources[2].ignoreChanges)
+
+	resources, err = ignoreScaledReplicas(resources)
+	assert.NoError(t, err)
+	assert.Contains(t, resources[0].text, `
+  lifecycle {
+    ignore_changes = [
+      manifest.metadata.annotations,
+      manifest.spec.replicas,
+    ]
+  }
+}
+`)
+	assert.Equal(t, []string{"manifest.metadata.annotations"}, resources[1].ignoreChanges)
+}
+
+func TestIgnoreScaledReplicasTyped(t *testing.T) {
+	resources, err := convertResources(strings.NewReader(scaledYAML), WithTyped(true))
+	if err != nil {
+		t.Fatal("Converting to HCL failed:", err)
+	}
+	resources, err = ignoreScaledReplicas(resources, WithTyped(true))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"spec[0].replicas"}, resources[0].ignoreChanges)
+	assert.Contains(t, resources[0].text, "      spec[0].replicas,\n")
+	assert.Empty(t, resources[1].ignoreChanges)
+}
+
+func TestPreventDestroy(t *testing.T) {
+	yaml := `apiVersion: v1
+kind: PersistentVolumeClaim
+metadata:
+  name: data
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+`
+	resources, err := convertResources(strings.NewReader(yaml), WithPreventDestroy("kind=PersistentVolumeClaim", "Namespace"), WithIgnoreChanges(ignoreChange{path: "manifest.metadata.labels"}))
+	if err != nil {
+		t.Fatal("Converting to HCL failed:", err)
+	}
+	assert.Contains(t, resources[0].text, `
+  lifecycle {
+    prevent_destroy = true
+    ignore_changes = [
+      manifest.metadata.labels,
+    ]
+  }
+`)
+	assert.False(t, resources[1].preventDestroy)
+	assert.NotContains(t, resources[1].text, "prevent_destroy")
+
+	resources, err = convertResources(strings.NewReader(yaml), WithPreventDestroy("PersistentVolumeClaim"), WithFormat("tfjson"))
+	if err != nil {
+		t.Fatal("Converting to JSON failed:", err)
+	}
+	assert.Contains(t, resources[0].text, "\"lifecycle\": {\n    \"prevent_destroy\": true\n  }")
+}
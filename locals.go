@@ -0,0 +1,173 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/jrhouston/tfk8s/contrib/hashicorp/terraform"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// localsFile is the name of the file --hoist-common-labels writes the
+// locals block to
+const localsFile = "locals.tf"
+
+// commonMetadata is the metadata fields --hoist-common-labels looks for
+// values shared by the resources in, and the local each is hoisted to
+var commonMetadata = []struct {
+	field string
+	local string
+}{
+	{"labels", "common_labels"},
+	{"annotations", "common_annotations"},
+}
+
+// stringMap returns the string values of the map or object v
+func stringMap(v cty.Value) map[string]string {
+	if v.IsNull() || v.IsMarked() || !(v.Type().IsObjectType() || v.Type().IsMapType()) {
+		return nil
+	}
+	m := map[string]string{}
+	for k, v := range v.AsValueMap() {
+		if v.IsNull() || v.IsMarked() || v.Type() != cty.String {
+			continue
+		}
+		m[k] = v.AsString()
+	}
+	return m
+}
+
+// commonValues returns the keys and values in field of the metadata of
+// every resource that has it, when at least two resources have it
+func commonValues(resources []resource, field string) map[string]string {
+	var common map[string]string
+	count := 0
+	for _, r := range resources {
+		values := stringMap(metadataField(r.manifest, field))
+		if len(values) == 0 {
+			continue
+		}
+		count++
+		if common == nil {
+			common = values
+			continue
+		}
+		for k, v := range common {
+			if values[k] != v {
+				delete(common, k)
+			}
+		}
+	}
+	if count < 2 {
+		return nil
+	}
+	return common
+}
+
+// metadataField returns the field of the metadata of manifest, or a null
+// value when it isn't set
+func metadataField(manifest cty.Value, field string) cty.Value {
+	if !manifest.Type().IsObjectType() || !manifest.Type().HasAttribute("metadata") {
+		return cty.NilVal
+	}
+	metadata := manifest.GetAttr("metadata")
+	if metadata.IsNull() || !metadata.Type().IsObjectType() || !metadata.Type().HasAttribute(field) {
+		return cty.NilVal
+	}
+	return metadata.GetAttr(field)
+}
+
+// inlineObject returns values as an object on a single line, so it can be
+// used in an expression whatever it's nested in
+func inlineObject(values map[string]string) string {
+	keys := []string{}
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	attributes := []string{}
+	for _, k := range keys {
+		attributes = append(attributes, fmt.Sprintf("%s = %s",
+			terraform.FormatValue(cty.StringVal(k), 0, false),
+			terraform.FormatValue(cty.StringVal(values[k]), 0, false)))
+	}
+	return "{ " + strings.Join(attributes, ", ") + " }"
+}
+
+// hoistedExpression returns the expression that replaces values once common
+// is hoisted to local, with the values that are left merged in
+func hoistedExpression(local string, values, common map[string]string) string {
+	rest := map[string]string{}
+	for k, v := range values {
+		if _, ok := common[k]; !ok {
+			rest[k] = v
+		}
+	}
+	if len(rest) == 0 {
+		return "local." + local
+	}
+	return fmt.Sprintf("merge(local.%s, %s)", local, inlineObject(rest))
+}
+
+// hoistCommonMetadata moves the labels and annotations every resource that
+// has them shares to locals, and refers to the locals in the manifests
+// instead. The resources are converted again with opts, and the locals
+// block is returned, or "" if nothing is shared.
+func hoistCommonMetadata(resources []resource, opts ...Option) ([]resource, string, error) {
+	o := newOptions(opts)
+	format, err := lookupFormat(o.format)
+	if err != nil {
+		return nil, "", err
+	}
+
+	locals := []string{}
+	for _, m := range commonMetadata {
+		common := commonValues(resources, m.field)
+		if len(common) == 0 {
+			continue
+		}
+		values := map[string]cty.Value{}
+		for k, v := range common {
+			values[k] = cty.StringVal(v)
+		}
+		locals = append(locals, fmt.Sprintf("  %s = %s\n", m.local, terraform.FormatValue(cty.ObjectVal(values), 2, false)))
+
+		for i, r := range resources {
+			values := stringMap(metadataField(r.manifest, m.field))
+			if len(values) == 0 {
+				continue
+			}
+			expr := cty.StringVal(hoistedExpression(m.local, values, common)).Mark(terraform.Expression)
+			r.manifest, err = cty.Transform(r.manifest, func(path cty.Path, v cty.Value) (cty.Value, error) {
+				if len(path) == 2 && path.Equals(cty.GetAttrPath("metadata").GetAttr(m.field)) {
+					return expr, nil
+				}
+				return v, nil
+			})
+			if err != nil {
+				return nil, "", err
+			}
+			resources[i] = r
+		}
+	}
+	if len(locals) == 0 {
+		return resources, "", nil
+	}
+
+	for i, r := range resources {
+		// the source no longer matches the manifest
+		r.source = ""
+		if r.text, err = format.resource(r, o); err != nil {
+			return nil, "", err
+		}
+		resources[i] = r
+	}
+	return resources, "locals {\n" + strings.Join(locals, "\n") + "}\n", nil
+}
+
+// writeLocals writes the locals block to locals.tf in dir
+func writeLocals(dir, locals string) error {
+	return writeFileAtomic(filepath.Join(dir, localsFile), []byte(locals), 0644)
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var commonLabelsYAML = `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: a
+  labels:
+    app: web
+    team: core
+    tier: front
+  annotations:
+    owner: me
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+  labels:
+    app: web
+    team: core
+---
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: web
+`
+
+func TestHoistCommonMetadata(t *testing.T) {
+	resources, err := convertResources(strings.NewReader(commonLabelsYAML))
+	if err != nil {
+		t.Fatal("Converting to HCL failed:", err)
+	}
+	resources, locals, err := hoistCommonMetadata(resources)
+	assert.NoError(t, err)
+
+	// only the ConfigMap has annotations, so they aren't hoisted
+	expected := `locals {
+  common_labels = {
+    "app" = "web"
+    "team" = "core"
+  }
+}
+`
+	assert.Equal(t, expected, locals)
+	assert.Contains(t, resources[0].text, `"labels" = merge(local.common_labels, { "tier" = "front" })`)
+	assert.Contains(t, resources[0].text, `"owner" = "me"`)
+	assert.Contains(t, resources[1].text, `"labels" = local.common_labels`)
+	assert.NotContains(t, resources[2].text, "labels")
+}
+
+func TestHoistCommonMetadataNothingShared(t *testing.T) {
+	resources, err := convertResources(strings.NewReader(commonLabelsYAML+`---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: other
+  labels:
+    app: other
+`), WithFormat("tfjson"))
+	if err != nil {
+		t.Fatal("Converting to JSON failed:", err)
+	}
+	text := resources[0].text
+	resources, locals, err := hoistCommonMetadata(resources, WithFormat("tfjson"))
+	assert.NoError(t, err)
+	assert.Equal(t, "", locals)
+	assert.Equal(t, text, resources[0].text)
+}
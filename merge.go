@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+)
+
+// hclBlock is a resource block in a Terraform file
+type hclBlock struct {
+	// address is the address of the resource, like kubernetes_manifest.configmap_test
+	address string
+	// text is the block as it is written in the file
+	text string
+}
+
+// parseHCL parses a Terraform file so its top level blocks can be found
+// and replaced without changing the rest of it
+func parseHCL(src string) (*hclwrite.File, error) {
+	f, diags := hclwrite.ParseConfig([]byte(src), "", hcl.InitialPos)
+	if diags.HasErrors() {
+		return nil, diags
+	}
+	return f, nil
+}
+
+// blockAddress returns the address a top level block is found by, like
+// kubernetes_manifest.configmap_test for a resource block or
+// data.vault_kv_secret_v2.secret_test for a data block. Import blocks are
+// found by the resource they import to, like
+// import.kubernetes_manifest.configmap_test. Other blocks have no address.
+func blockAddress(b *hclwrite.Block) string {
+	labels := b.Labels()
+	switch {
+	case b.Type() == "resource" && len(labels) == 2:
+		return labels[0] + "." + labels[1]
+	case b.Type() == "data" && len(labels) == 2:
+		return "data." + labels[0] + "." + labels[1]
+	case b.Type() == "import":
+		if to := b.Body().GetAttribute("to"); to != nil {
+			return "import." + strings.TrimSpace(string(to.Expr().BuildTokens(nil).Bytes()))
+		}
+	}
+	return ""
+}
+
+// findResourceBlocks returns the resource blocks in a Terraform file
+func findResourceBlocks(src string) ([]hclBlock, error) {
+	f, err := parseHCL(src)
+	if err != nil {
+		return nil, err
+	}
+	blocks := []hclBlock{}
+	for _, b := range f.Body().Blocks() {
+		if b.Type() != "resource" {
+			continue
+		}
+		if address := blockAddress(b); address != "" {
+			blocks = append(blocks, hclBlock{address: address, text: string(b.BuildTokens(nil).Bytes())})
+		}
+	}
+	return blocks, nil
+}
+
+// mergeResources adds resources to an existing Terraform file. Resources
+// that are already in the file are replaced where they are if replace is
+// set, otherwise they are left alone and their addresses are returned.
+// The blocks written with a resource, like its import block, are merged
+// the same way by their own address.
+func mergeResources(existing string, resources []resource, replace bool) (string, []string, error) {
+	f, err := parseHCL(existing)
+	if err != nil {
+		return "", nil, err
+	}
+	found := map[string]*hclwrite.Block{}
+	for _, b := range f.Body().Blocks() {
+		if address := blockAddress(b); address != "" {
+			found[address] = b
+		}
+	}
+
+	skipped := []string{}
+	added := []string{}
+	for _, r := range resources {
+		address := r.resourceType + "." + r.name
+		if _, ok := found[address]; ok && !replace {
+			skipped = append(skipped, address)
+			continue
+		}
+		blocks, err := parseHCL(r.text)
+		if err != nil {
+			return "", nil, fmt.Errorf("%s: %s", address, err)
+		}
+		text := []string{}
+		for _, b := range blocks.Body().Blocks() {
+			existing, ok := found[blockAddress(b)]
+			if !ok {
+				text = append(text, string(b.BuildTokens(nil).Bytes()))
+				continue
+			}
+			existing.Body().Clear()
+			existing.Body().AppendUnstructuredTokens(b.Body().BuildTokens(nil))
+		}
+		if len(text) == len(blocks.Body().Blocks()) {
+			// none of it is in the file yet, so it is added as it is
+			added = append(added, r.text)
+		} else if len(text) > 0 {
+			added = append(added, strings.Join(text, "\n"))
+		}
+	}
+
+	var merged strings.Builder
+	// the tokens are written as they are, File.Bytes would reformat the file
+	merged.Write(f.BuildTokens(nil).Bytes())
+	if len(added) > 0 {
+		if merged.Len() > 0 && !strings.HasSuffix(merged.String(), "\n") {
+			merged.WriteString("\n")
+		}
+		if merged.Len() > 0 {
+			merged.WriteString("\n")
+		}
+		merged.WriteString(strings.Join(added, "\n"))
+	}
+	return merged.String(), skipped, nil
+}
+
+// appendResources adds resources to the Terraform file at path, creating
+// it if it doesn't exist
+func appendResources(path string, resources []resource, replace bool) ([]string, error) {
+	existing, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	merged, skipped, err := mergeResources(string(existing), resources, replace)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	return skipped, writeFileAtomic(path, []byte(merged), 0644)
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const existingConfig = `# managed by hand
+provider "kubernetes" {
+  config_path = "~/.kube/config"
+}
+
+resource "kubernetes_manifest" "configmap_test" {
+  manifest = {
+    "data" = {
+      "brace" = "}"
+      "script" = <<-EOT
+      if true; then {
+      EOT
+      "template" = "${var.x["}"]}"
+    }
+    "kind" = "ConfigMap"
+  }
+}
+
+/* resource "kubernetes_manifest" "commented_out" { */
+resource "kubernetes_manifest" "namespace_test" {
+  manifest = {}
+}
+`
+
+func TestFindResourceBlocks(t *testing.T) {
+	blocks, err := findResourceBlocks(existingConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	addresses := []string{}
+	for _, b := range blocks {
+		addresses = append(addresses, b.address)
+	}
+	assert.Equal(t, []string{
+		"kubernetes_manifest.configmap_test",
+		"kubernetes_manifest.namespace_test",
+	}, addresses)
+	assert.True(t, strings.HasPrefix(blocks[0].text, `resource "kubernetes_manifest" "configmap_test" {`))
+	assert.True(t, strings.HasSuffix(blocks[0].text, "  }\n}\n"))
+	assert.Equal(t, "resource \"kubernetes_manifest\" \"namespace_test\" {\n  manifest = {}\n}\n", blocks[1].text)
+
+	_, err = findResourceBlocks(`resource "kubernetes_manifest" "broken" {`)
+	assert.Error(t, err)
+}
+
+func TestMergeResources(t *testing.T) {
+	resources, err := convertResources(strings.NewReader(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: test
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: new
+`))
+	if err != nil {
+		t.Fatal("Converting to HCL failed:", err)
+	}
+
+	merged, skipped, err := mergeResources(existingConfig, resources, false)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"kubernetes_manifest.configmap_test"}, skipped)
+	assert.Equal(t, existingConfig+"\n"+resources[1].text, merged)
+
+	merged, skipped, err = mergeResources(existingConfig, resources, true)
+	assert.NoError(t, err)
+	assert.Empty(t, skipped)
+	assert.NotContains(t, merged, `"brace" = "}"`)
+	assert.Contains(t, merged, "provider \"kubernetes\" {\n  config_path = \"~/.kube/config\"\n}\n\n"+resources[0].text+"\n/* resource")
+	assert.True(t, strings.HasSuffix(merged, "  manifest = {}\n}\n\n"+resources[1].text))
+}
+
+func TestAppendResources(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tfk8s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	resources, err := convertResources(strings.NewReader(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: test
+`))
+	if err != nil {
+		t.Fatal("Converting to HCL failed:", err)
+	}
+
+	path := filepath.Join(dir, "main.tf")
+	_, err = appendResources(path, resources, false)
+	assert.NoError(t, err)
+	skipped, err := appendResources(path, resources, false)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"kubernetes_manifest.configmap_test"}, skipped)
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, resources[0].text, string(b))
+}
+
+func TestMergeResourcesImports(t *testing.T) {
+	resources, err := convertResources(strings.NewReader(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: test
+`), WithGenerateImports(true))
+	if err != nil {
+		t.Fatal("Converting to HCL failed:", err)
+	}
+
+	merged, _, err := mergeResources("", resources, false)
+	assert.NoError(t, err)
+	merged, skipped, err := mergeResources(merged, resources, true)
+	assert.NoError(t, err)
+	assert.Empty(t, skipped)
+	assert.Equal(t, 1, strings.Count(merged, "import {"))
+	assert.Equal(t, 1, strings.Count(merged, `resource "kubernetes_manifest" "configmap_test"`))
+}
@@ -0,0 +1,642 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+
+	cty "github.com/zclconf/go-cty/cty"
+
+	"github.com/jrhouston/tfk8s/contrib/hashicorp/terraform"
+)
+
+// moduleVariable is an input variable of the module --as-module writes
+type moduleVariable struct {
+	name        string
+	description string
+	typ         string
+	value       cty.Value
+	// sensitive variables hold the values of Secrets, they have no default
+	// and their values are only written to the secrets tfvars
+	sensitive bool
+}
+
+// moduleVariables collects the values of the manifests that are made into
+// module variables, in the order they are found
+type moduleVariables struct {
+	variables []moduleVariable
+	// names are the variable names already used
+	names map[string]bool
+	// namespaces and images map a namespace and an image repository to
+	// the name of its variable
+	namespaces map[string]string
+	images     map[string]string
+	// containerImages maps a container and its image to the name of the
+	// variable --extract images makes for it
+	containerImages map[string]string
+	// extract is what --extract makes into variables, nil is the
+	// defaultExtractions
+	extract map[string]bool
+	// sharedNamespace is set by --parameterize-namespace to make every
+	// namespace into the one namespace variable
+	sharedNamespace bool
+	// substitutions are the rules of the --substitutions file, and
+	// substituted maps the variable of a rule to the text it matched
+	substitutions []substitution
+	substituted   map[string]substitutedText
+}
+
+// extractions are the values --extract can make into variables
+var extractions = []string{"images", "replicas", "resources"}
+
+// defaultExtractions are what is made into variables without --extract
+var defaultExtractions = map[string]bool{"namespace": true, "image-tags": true, "replicas": true}
+
+// parseExtract parses the values given to --extract
+func parseExtract(names []string) (map[string]bool, error) {
+	extract := map[string]bool{}
+	for _, name := range names {
+		if !containsString(extractions, name) {
+			return nil, fmt.Errorf("unknown --extract %q, must be one of %s", name, strings.Join(extractions, ", "))
+		}
+		extract[name] = true
+	}
+	return extract, nil
+}
+
+// extracts returns true if the values called name are made into variables
+func (m *moduleVariables) extracts(name string) bool {
+	if m.extract == nil {
+		return defaultExtractions[name]
+	}
+	return m.extract[name]
+}
+
+// newModuleVariables returns an empty set of module variables
+func newModuleVariables() *moduleVariables {
+	return &moduleVariables{
+		names:           map[string]bool{},
+		namespaces:      map[string]string{},
+		images:          map[string]string{},
+		containerImages: map[string]string{},
+		substituted:     map[string]substitutedText{},
+	}
+}
+
+// variableName returns name as a valid variable name that isn't used yet
+func (m *moduleVariables) variableName(name string) string {
+	name = snakify(name)
+	if r := []rune(name); len(r) == 0 || !unicode.IsLetter(r[0]) {
+		name = "v_" + name
+	}
+	unique := name
+	for i := 2; m.names[unique]; i++ {
+		unique = fmt.Sprintf("%s_%d", name, i)
+	}
+	m.names[unique] = true
+	return unique
+}
+
+// formatPath returns a path in a manifest like spec.containers[0].image
+func formatPath(path cty.Path) string {
+	var buf strings.Builder
+	for _, step := range path {
+		switch s := step.(type) {
+		case cty.GetAttrStep:
+			if buf.Len() > 0 {
+				buf.WriteString(".")
+			}
+			buf.WriteString(s.Name)
+		case cty.IndexStep:
+			if s.Key.Type() == cty.Number {
+				fmt.Fprintf(&buf, "[%s]", s.Key.AsBigFloat().Text('f', -1))
+			} else {
+				fmt.Fprintf(&buf, "[%q]", s.Key.AsString())
+			}
+		}
+	}
+	return buf.String()
+}
+
+// add adds a variable for the value at path in the manifest of resource,
+// and returns the expression that references it. The description says
+// where the default came from.
+func (m *moduleVariables) add(name, description, typ string, value cty.Value, resourceName string, path cty.Path) string {
+	name = m.variableName(name)
+	m.variables = append(m.variables, moduleVariable{
+		name:        name,
+		description: fmt.Sprintf("%s, from %s in %s", description, formatPath(path), resourceName),
+		typ:         typ,
+		value:       value,
+	})
+	return "var." + name
+}
+
+// namespace returns the variable for a namespace, the first namespace is
+// called namespace. With sharedNamespace every namespace is the namespace
+// variable, which defaults to the first one.
+func (m *moduleVariables) namespace(ns, resourceName string, path cty.Path) string {
+	key, description := ns, fmt.Sprintf("Namespace the %s resources are created in", ns)
+	if m.sharedNamespace {
+		key, description = "", "Namespace the resources are created in"
+	}
+	if name, ok := m.namespaces[key]; ok {
+		return name
+	}
+	name := "namespace"
+	if len(m.namespaces) > 0 {
+		name = "namespace_" + ns
+	}
+	expr := m.add(name, description, "string", cty.StringVal(ns), resourceName, path)
+	m.namespaces[key] = expr
+	return expr
+}
+
+// splitImage splits an image into its repository and tag, ok is false if
+// it has no tag or is pinned to a digest
+func splitImage(image string) (repository, tag string, ok bool) {
+	if strings.Contains(image, "@") {
+		return "", "", false
+	}
+	i := strings.LastIndex(image, ":")
+	if i < 0 || strings.Contains(image[i:], "/") {
+		return "", "", false
+	}
+	return image[:i], image[i+1:], true
+}
+
+// image returns the image with its tag replaced by a variable. Every use
+// of a repository shares the variable of the first, uses with another tag
+// are left alone.
+func (m *moduleVariables) image(image, resourceName string, path cty.Path) (string, bool) {
+	repository, tag, ok := splitImage(image)
+	if !ok {
+		return "", false
+	}
+	key := repository + ":" + tag
+	if expr, ok := m.images[key]; ok {
+		return expr, true
+	}
+	for k := range m.images {
+		if strings.HasPrefix(k, repository+":") {
+			return "", false
+		}
+	}
+	name := repository[strings.LastIndex(repository, "/")+1:]
+	variable := m.add(name+"_image_tag", fmt.Sprintf("Tag of the %s image", repository), "string", cty.StringVal(tag), resourceName, path)
+	expr := fmt.Sprintf("\"%s:${%s}\"", repository, variable)
+	m.images[key] = expr
+	return expr, true
+}
+
+// containerImage returns the variable for the image of a container, every
+// container with the same name and image shares it
+func (m *moduleVariables) containerImage(container, image, resourceName string, path cty.Path) string {
+	key := container + "\x00" + image
+	if expr, ok := m.containerImages[key]; ok {
+		return expr
+	}
+	expr := m.add("image_"+container, fmt.Sprintf("Image of the %s container", container), "string", cty.StringVal(image), resourceName, path)
+	m.containerImages[key] = expr
+	return expr
+}
+
+// containerResources replaces the requests and limits in the resources of
+// a container with a variable each, so the resources block of the typed
+// resources still works
+func (m *moduleVariables) containerResources(resources cty.Value, container, kind, name, resourceName string, path cty.Path) cty.Value {
+	if resources.IsMarked() || resources.IsNull() || !resources.Type().IsObjectType() {
+		return resources
+	}
+	attrs := resources.AsValueMap()
+	for _, field := range []string{"limits", "requests"} {
+		v, ok := attrs[field]
+		if !ok || v.IsMarked() || v.IsNull() || !v.Type().IsObjectType() || v.LengthInt() == 0 {
+			continue
+		}
+		description := fmt.Sprintf("Resource %s of the %s container of the %s %s", field, container, kind, name)
+		expr := m.add(resourceName+"_"+container+"_"+field, description, "map(string)", v, resourceName, path.GetAttr(field))
+		attrs[field] = cty.StringVal(expr).Mark(terraform.Expression)
+	}
+	return cty.ObjectVal(attrs)
+}
+
+// isContainer returns true if path is a container, like
+// spec.template.spec.containers[0]
+func isContainer(path cty.Path) bool {
+	if len(path) < 2 {
+		return false
+	}
+	if _, ok := path[len(path)-1].(cty.IndexStep); !ok {
+		return false
+	}
+	containers, ok := path[len(path)-2].(cty.GetAttrStep)
+	return ok && (containers.Name == "containers" || containers.Name == "initContainers")
+}
+
+// isContainerImage returns true if path is the image of a container, like
+// spec.template.spec.containers[0].image
+func isContainerImage(path cty.Path) bool {
+	if len(path) < 3 {
+		return false
+	}
+	image, ok := path[len(path)-1].(cty.GetAttrStep)
+	return ok && image.Name == "image" && isContainer(path[:len(path)-1])
+}
+
+// clusterScopedKinds are the kinds of objects that aren't in a namespace.
+// Custom resources are assumed to be namespaced unless their kind starts
+// with Cluster, like ClusterIssuer.
+var clusterScopedKinds = map[string]bool{
+	"Namespace":                        true,
+	"Node":                             true,
+	"PersistentVolume":                 true,
+	"StorageClass":                     true,
+	"CSIDriver":                        true,
+	"CSINode":                          true,
+	"VolumeAttachment":                 true,
+	"ClusterRole":                      true,
+	"ClusterRoleBinding":               true,
+	"CustomResourceDefinition":         true,
+	"APIService":                       true,
+	"MutatingWebhookConfiguration":     true,
+	"ValidatingWebhookConfiguration":   true,
+	"ValidatingAdmissionPolicy":        true,
+	"ValidatingAdmissionPolicyBinding": true,
+	"PriorityClass":                    true,
+	"RuntimeClass":                     true,
+	"IngressClass":                     true,
+	"PodSecurityPolicy":                true,
+	"CertificateSigningRequest":        true,
+	"FlowSchema":                       true,
+	"PriorityLevelConfiguration":       true,
+	"ComponentStatus":                  true,
+}
+
+// isNamespaced returns true if objects of kind are in a namespace
+func isNamespaced(kind string) bool {
+	return !clusterScopedKinds[kind] && !strings.HasPrefix(kind, "Cluster")
+}
+
+// namespaceReferences are the fields that refer to a namespace, by kind.
+// * matches every item of a list.
+var namespaceReferences = map[string][][]string{
+	"RoleBinding":                    {{"subjects", "*", "namespace"}},
+	"ClusterRoleBinding":             {{"subjects", "*", "namespace"}},
+	"MutatingWebhookConfiguration":   {{"webhooks", "*", "clientConfig", "service", "namespace"}},
+	"ValidatingWebhookConfiguration": {{"webhooks", "*", "clientConfig", "service", "namespace"}},
+	"APIService":                     {{"spec", "service", "namespace"}},
+	"CustomResourceDefinition":       {{"spec", "conversion", "webhook", "clientConfig", "service", "namespace"}},
+}
+
+// isNamespaceReference returns true if path is a field of an object of
+// kind that refers to a namespace
+func isNamespaceReference(kind string, path cty.Path) bool {
+	for _, pattern := range namespaceReferences[kind] {
+		if matchPath(path, pattern...) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchPath returns true if path is the attributes names, where * matches
+// an index
+func matchPath(path cty.Path, names ...string) bool {
+	if len(path) != len(names) {
+		return false
+	}
+	for i, step := range path {
+		if names[i] == "*" {
+			if _, ok := step.(cty.IndexStep); !ok {
+				return false
+			}
+			continue
+		}
+		attr, ok := step.(cty.GetAttrStep)
+		if !ok || attr.Name != names[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// isPath returns true if path is the attributes names
+func isPath(path cty.Path, names ...string) bool {
+	if len(path) != len(names) {
+		return false
+	}
+	for i, step := range path {
+		attr, ok := step.(cty.GetAttrStep)
+		if !ok || attr.Name != names[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// parameterizeNamespace makes the namespace of a manifest, and the
+// namespaces it refers to, into the namespace variable for
+// --parameterize-namespace, and sets namespaced objects that don't have a
+// namespace to it. namespace is the namespace the object is in. It is
+// done before --strip, which would remove the default namespace.
+func (m *moduleVariables) parameterizeNamespace(doc cty.Value, kind, namespace, resourceName string) cty.Value {
+	doc, _ = cty.Transform(doc, func(path cty.Path, v cty.Value) (cty.Value, error) {
+		if v.IsMarked() || v.IsNull() || !v.IsKnown() || v.Type() != cty.String {
+			return v, nil
+		}
+		if isPath(path, "metadata", "namespace") || (kind == "Namespace" && isPath(path, "metadata", "name")) || isNamespaceReference(kind, path) {
+			return cty.StringVal(m.namespace(v.AsString(), resourceName, path)).Mark(terraform.Expression), nil
+		}
+		return v, nil
+	})
+
+	attrs := doc.AsValueMap()
+	metadata := attrs["metadata"].AsValueMap()
+	if _, ok := metadata["namespace"]; ok || !isNamespaced(kind) {
+		return doc
+	}
+	path := cty.GetAttrPath("metadata").GetAttr("namespace")
+	metadata["namespace"] = cty.StringVal(m.namespace(namespace, resourceName, path)).Mark(terraform.Expression)
+	attrs["metadata"] = cty.ObjectVal(metadata)
+	return cty.ObjectVal(attrs)
+}
+
+// parameterize replaces the namespace, the image tags and the replica
+// count of a manifest with module variables, with the values in the
+// manifest as their defaults
+func (m *moduleVariables) parameterize(doc cty.Value, kind, name, resourceName string) (cty.Value, error) {
+	return m.parameterizeValue(nil, doc, kind, name, resourceName)
+}
+
+// parameterizeValue parameterizes the value at path. Keys are walked in
+// order, so the variables are always added in the same order.
+func (m *moduleVariables) parameterizeValue(path cty.Path, v cty.Value, kind, name, resourceName string) (cty.Value, error) {
+	if v.IsMarked() || v.IsNull() || !v.IsKnown() {
+		return v, nil
+	}
+	ty := v.Type()
+	switch {
+	case ty == cty.String && m.extracts("namespace") && (isPath(path, "metadata", "namespace") || (kind == "Namespace" && isPath(path, "metadata", "name")) || isNamespaceReference(kind, path)):
+		return cty.StringVal(m.namespace(v.AsString(), resourceName, path)).Mark(terraform.Expression), nil
+	case ty == cty.String && m.extracts("image-tags") && isContainerImage(path):
+		if expr, ok := m.image(v.AsString(), resourceName, path); ok {
+			return cty.StringVal(expr).Mark(terraform.Expression), nil
+		}
+	case ty == cty.Number && m.extracts("replicas") && isPath(path, "spec", "replicas"):
+		expr := m.add(resourceName+"_replicas", fmt.Sprintf("Number of replicas of the %s %s", kind, name), "number", v, resourceName, path)
+		return cty.StringVal(expr).Mark(terraform.Expression), nil
+	case ty == cty.String && len(m.substitutions) > 0 && !isPath(path, "apiVersion") && !isPath(path, "kind"):
+		expr, ok, err := m.substitute(v.AsString(), resourceName, path)
+		if err != nil {
+			return v, err
+		}
+		if ok {
+			return cty.StringVal(expr).Mark(terraform.Expression), nil
+		}
+	case ty.IsObjectType():
+		attrs := v.AsValueMap()
+		if m.extracts("images") && isContainer(path) && !attrs["image"].IsMarked() && !attrs["name"].IsMarked() {
+			image, hasImage := stringAttr(attrs, "image")
+			container, hasName := stringAttr(attrs, "name")
+			if hasImage && hasName {
+				expr := m.containerImage(container, image, resourceName, path.GetAttr("image"))
+				attrs["image"] = cty.StringVal(expr).Mark(terraform.Expression)
+			}
+		}
+		if resources, ok := attrs["resources"]; ok && m.extracts("resources") && isContainer(path) && !attrs["name"].IsMarked() {
+			if container, ok := stringAttr(attrs, "name"); ok {
+				attrs["resources"] = m.containerResources(resources, container, kind, name, resourceName, path.GetAttr("resources"))
+			}
+		}
+		keys := []string{}
+		for k := range attrs {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			var err error
+			if attrs[k], err = m.parameterizeValue(path.GetAttr(k), attrs[k], kind, name, resourceName); err != nil {
+				return v, err
+			}
+		}
+		return cty.ObjectVal(attrs), nil
+	case ty.IsTupleType():
+		items := v.AsValueSlice()
+		for i, item := range items {
+			var err error
+			if items[i], err = m.parameterizeValue(path.Index(cty.NumberIntVal(int64(i))), item, kind, name, resourceName); err != nil {
+				return v, err
+			}
+		}
+		return cty.TupleVal(items), nil
+	}
+	return v, nil
+}
+
+// hcl returns the variables.tf of the module
+func (m *moduleVariables) hcl() string {
+	blocks := []string{}
+	for _, v := range m.variables {
+		var buf strings.Builder
+		fmt.Fprintf(&buf, "variable %q {\n", v.name)
+		fmt.Fprintf(&buf, "  description = %q\n", v.description)
+		fmt.Fprintf(&buf, "  type        = %s\n", v.typ)
+		if v.sensitive {
+			buf.WriteString("  sensitive   = true\n")
+		} else {
+			fmt.Fprintf(&buf, "  default     = %s\n", terraform.FormatValue(v.value, 2, false))
+		}
+		buf.WriteString("}\n")
+		blocks = append(blocks, buf.String())
+	}
+	return strings.Join(blocks, "\n")
+}
+
+// moduleCall returns an example module block that calls the module in
+// source, with each variable set to its default so it is easy to change
+func moduleCall(name, source string, variables *moduleVariables) string {
+	var buf strings.Builder
+	fmt.Fprintf(&buf, "module %q {\n", snakify(name))
+	fmt.Fprintf(&buf, "  source = %q\n", source)
+	if len(variables.variables) > 0 {
+		buf.WriteString("\n")
+		buf.WriteString(variables.assignments("  ", func(v moduleVariable) (string, bool) {
+			if v.sensitive {
+				return "var." + v.name, true
+			}
+			return terraform.FormatValue(v.value, 2, false), true
+		}))
+	}
+	buf.WriteString("}\n")
+	return buf.String()
+}
+
+// assignments returns an assignment to each variable value returns true
+// for, with the values aligned like terraform fmt does
+func (m *moduleVariables) assignments(indent string, value func(v moduleVariable) (string, bool)) string {
+	type assignment struct{ name, value string }
+	assignments := []assignment{}
+	width := 0
+	for _, v := range m.variables {
+		expr, ok := value(v)
+		if !ok {
+			continue
+		}
+		assignments = append(assignments, assignment{v.name, expr})
+		if len(v.name) > width {
+			width = len(v.name)
+		}
+	}
+	var buf strings.Builder
+	for _, a := range assignments {
+		fmt.Fprintf(&buf, "%s%-*s = %s\n", indent, width, a.name, a.value)
+	}
+	return buf.String()
+}
+
+// tfvars returns a tfvars file that sets the variables to the values in the
+// manifests, the sensitive variables are left out
+func (m *moduleVariables) tfvars() string {
+	return m.assignments("", func(v moduleVariable) (string, bool) {
+		return terraform.FormatValue(v.value, 0, false), !v.sensitive
+	})
+}
+
+// secretTFVars returns a tfvars file that sets the sensitive variables to
+// the values of the Secrets
+func (m *moduleVariables) secretTFVars() string {
+	return m.assignments("", func(v moduleVariable) (string, bool) {
+		return terraform.FormatValue(v.value, 0, false), v.sensitive
+	})
+}
+
+// moduleSource returns the source of the module in dir for a module block
+// in the directory above it, like ./app
+func moduleSource(dir string) string {
+	return "./" + filepath.ToSlash(filepath.Base(filepath.Clean(dir)))
+}
+
+// moduleOutputs returns the outputs.tf of the module, an output for each
+// resource with the metadata of the object it creates
+func moduleOutputs(resources []resource) string {
+	blocks := []string{}
+	for _, r := range resources {
+		address := r.resourceType + "." + r.name
+		value := address + ".object.metadata"
+		switch {
+		case r.typed:
+			value = address + ".metadata[0]"
+		case r.resourceType == kubectlResourceType:
+			// kubectl_manifest doesn't have the object, only its uid
+			value = fmt.Sprintf("{\n    name      = %s.name\n    namespace = %s.namespace\n    uid       = %s.uid\n  }", address, address, address)
+		}
+		var buf strings.Builder
+		fmt.Fprintf(&buf, "output %q {\n", r.name)
+		fmt.Fprintf(&buf, "  description = %q\n", fmt.Sprintf("Metadata of the %s %s", r.kind, r.objectName))
+		fmt.Fprintf(&buf, "  value       = %s\n", value)
+		buf.WriteString("}\n")
+		blocks = append(blocks, buf.String())
+	}
+	return strings.Join(blocks, "\n")
+}
+
+// writeVariables writes the variables to variables.tf in dir
+func writeVariables(dir string, variables *moduleVariables) error {
+	return writeFileAtomic(filepath.Join(dir, "variables.tf"), []byte(variables.hcl()), 0644)
+}
+
+// writeTFVars writes a tfvars file that sets the variables to filename
+func writeTFVars(filename string, variables *moduleVariables) error {
+	return writeFileAtomic(filename, []byte(variables.tfvars()), 0644)
+}
+
+// writeModule writes resources to dir as a module, with main.tf for the
+// resources, variables.tf for the variables and outputs.tf for the outputs
+func writeModule(dir string, resources []resource, variables *moduleVariables) error {
+	main, err := joinResources(hclFormat{}, resources)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	files := []struct {
+		name string
+		text string
+	}{
+		{"main.tf", main},
+		{"variables.tf", variables.hcl()},
+		{"outputs.tf", moduleOutputs(resources)},
+	}
+	for _, f := range files {
+		if err := writeFileAtomic(filepath.Join(dir, f.name), []byte(f.text), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// unlabeledModule is the module --module-per puts the resources without
+// the label in
+const unlabeledModule = "unlabeled"
+
+// moduleSet is the modules --module-per groups resources into, one for
+// each value of a label
+type moduleSet struct {
+	label     string
+	variables map[string]*moduleVariables
+	// extract is what --extract makes into variables in each module
+	extract map[string]bool
+	// sharedNamespace is set by --parameterize-namespace
+	sharedNamespace bool
+	// substitutions are the rules of the --substitutions file
+	substitutions []substitution
+}
+
+// newModuleSet returns an empty set of modules grouped by label
+func newModuleSet(label string) *moduleSet {
+	return &moduleSet{label: label, variables: map[string]*moduleVariables{}}
+}
+
+// module returns the module for an object with metadata, and its variables
+func (s *moduleSet) module(metadata map[string]cty.Value) (string, *moduleVariables) {
+	name := unlabeledModule
+	if labels, ok := metadata["labels"]; ok && !labels.IsNull() && (labels.Type().IsObjectType() || labels.Type().IsMapType()) {
+		if v, ok := stringAttr(labels.AsValueMap(), s.label); ok {
+			name = v
+		}
+	}
+	if _, ok := s.variables[name]; !ok {
+		s.variables[name] = newModuleVariables()
+		s.variables[name].extract = s.extract
+		s.variables[name].sharedNamespace = s.sharedNamespace
+		s.variables[name].substitutions = s.substitutions
+	}
+	return name, s.variables[name]
+}
+
+// writeModules writes the resources of each module to its own directory
+// in dir, and a main.tf in dir that calls the modules
+func writeModules(dir string, resources []resource, s *moduleSet) error {
+	names := []string{}
+	grouped := map[string][]resource{}
+	for _, r := range resources {
+		if _, ok := grouped[r.module]; !ok {
+			names = append(names, r.module)
+		}
+		grouped[r.module] = append(grouped[r.module], r)
+	}
+
+	calls := []string{}
+	for _, name := range names {
+		if err := writeModule(filepath.Join(dir, name), grouped[name], s.variables[name]); err != nil {
+			return err
+		}
+		calls = append(calls, moduleCall(name, moduleSource(name), s.variables[name]))
+	}
+	return writeFileAtomic(filepath.Join(dir, "main.tf"), []byte(strings.Join(calls, "\n")), 0644)
+}
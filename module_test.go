@@ -0,0 +1,463 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zclconf/go-cty/cty"
+)
+
+var moduleYAML = `apiVersion: v1
+kind: Namespace
+metadata:
+  name: web
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: nginx
+  namespace: web
+spec:
+  replicas: 3
+  template:
+    spec:
+      containers:
+      - name: nginx
+        image: registry.example.com:5000/nginx:1.25
+      - name: sidecar
+        image: envoyproxy/envoy@sha256:4f3d
+      - name: other
+        image: registry.example.com:5000/nginx:1.24
+`
+
+func TestYAMLToTerraformResourcesModuleVariables(t *testing.T) {
+	variables := newModuleVariables()
+	output, err := YAMLToTerraformResources(strings.NewReader(moduleYAML), WithModuleVariables(variables))
+	if err != nil {
+		t.Fatal("Converting to HCL failed:", err)
+	}
+
+	assert.Contains(t, output, `"name" = var.namespace`)
+	assert.Contains(t, output, `"namespace" = var.namespace`)
+	assert.Contains(t, output, `"replicas" = var.deployment_web_nginx_replicas`)
+	assert.Contains(t, output, `"image" = "registry.example.com:5000/nginx:${var.nginx_image_tag}"`)
+	// digests and other tags of the same image are left alone
+	assert.Contains(t, output, `"image" = "envoyproxy/envoy@sha256:4f3d"`)
+	assert.Contains(t, output, `"image" = "registry.example.com:5000/nginx:1.24"`)
+
+	expected := `variable "namespace" {
+  description = "Namespace the web resources are created in, from metadata.name in namespace_web"
+  type        = string
+  default     = "web"
+}
+
+variable "deployment_web_nginx_replicas" {
+  description = "Number of replicas of the Deployment nginx, from spec.replicas in deployment_web_nginx"
+  type        = number
+  default     = 3
+}
+
+variable "nginx_image_tag" {
+  description = "Tag of the registry.example.com:5000/nginx image, from spec.template.spec.containers[0].image in deployment_web_nginx"
+  type        = string
+  default     = "1.25"
+}
+`
+	assert.Equal(t, expected, variables.hcl())
+}
+
+func TestFormatPath(t *testing.T) {
+	path := cty.GetAttrPath("spec").GetAttr("containers").Index(cty.NumberIntVal(0)).GetAttr("image")
+	assert.Equal(t, "spec.containers[0].image", formatPath(path))
+	assert.Equal(t, `data["app.conf"]`, formatPath(cty.GetAttrPath("data").Index(cty.StringVal("app.conf"))))
+}
+
+func TestExtractVariablesTFJSON(t *testing.T) {
+	variables := newModuleVariables()
+	output, err := YAMLToTerraformResources(strings.NewReader(moduleYAML), WithModuleVariables(variables), WithFormat("tfjson"))
+	if err != nil {
+		t.Fatal("Converting to JSON failed:", err)
+	}
+	assert.Contains(t, output, `"replicas": "${var.deployment_web_nginx_replicas}"`)
+	assert.Contains(t, output, `"image": "${\"registry.example.com:5000/nginx:${var.nginx_image_tag}\"}"`)
+	assert.Len(t, variables.variables, 3)
+}
+
+func TestExtractImages(t *testing.T) {
+	variables := newModuleVariables()
+	extract, err := parseExtract([]string{"images"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	variables.extract = extract
+	output, err := YAMLToTerraformResources(strings.NewReader(moduleYAML), WithModuleVariables(variables))
+	if err != nil {
+		t.Fatal("Converting to HCL failed:", err)
+	}
+	assert.Contains(t, output, `"image" = var.image_nginx`)
+	assert.Contains(t, output, `"image" = var.image_sidecar`)
+	assert.Contains(t, output, `"image" = var.image_other`)
+	assert.Contains(t, output, `"namespace" = "web"`)
+	assert.Contains(t, output, `"replicas" = 3`)
+
+	names := []string{}
+	for _, v := range variables.variables {
+		names = append(names, v.name)
+	}
+	assert.Equal(t, []string{"image_nginx", "image_sidecar", "image_other"}, names)
+	assert.Equal(t, cty.StringVal("envoyproxy/envoy@sha256:4f3d"), variables.variables[1].value)
+
+	_, err = parseExtract([]string{"labels"})
+	assert.Error(t, err)
+}
+
+func TestExtractReplicasAndResources(t *testing.T) {
+	yaml := `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: nginx
+  namespace: web
+spec:
+  replicas: 3
+  template:
+    spec:
+      containers:
+      - name: nginx
+        image: nginx:1.25
+        resources:
+          requests:
+            cpu: 100m
+          limits:
+            memory: 256Mi
+`
+	variables := newModuleVariables()
+	extract, err := parseExtract([]string{"replicas", "resources"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	variables.extract = extract
+	output, err := YAMLToTerraformResources(strings.NewReader(yaml), WithModuleVariables(variables))
+	if err != nil {
+		t.Fatal("Converting to HCL failed:", err)
+	}
+	assert.Contains(t, output, `"replicas" = var.deployment_web_nginx_replicas`)
+	assert.Contains(t, output, `"resources" = {
+                "limits" = var.deployment_web_nginx_nginx_limits
+                "requests" = var.deployment_web_nginx_nginx_requests
+              }`)
+	assert.Contains(t, output, `"image" = "nginx:1.25"`)
+	assert.Contains(t, output, `"namespace" = "web"`)
+	assert.Contains(t, variables.hcl(), `variable "deployment_web_nginx_nginx_requests" {
+  description = "Resource requests of the nginx container of the Deployment nginx, from spec.template.spec.containers[0].resources.requests in deployment_web_nginx"
+  type        = map(string)
+  default     = {
+    "cpu" = "100m"
+  }
+}`)
+}
+
+func TestParameterizeNamespace(t *testing.T) {
+	yaml := `apiVersion: v1
+kind: ServiceAccount
+metadata:
+  name: app
+  namespace: team-a
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRoleBinding
+metadata:
+  name: app
+roleRef:
+  apiGroup: rbac.authorization.k8s.io
+  kind: ClusterRole
+  name: view
+subjects:
+- kind: ServiceAccount
+  name: app
+  namespace: team-a
+---
+apiVersion: admissionregistration.k8s.io/v1
+kind: ValidatingWebhookConfiguration
+metadata:
+  name: app
+webhooks:
+- name: app.example.com
+  clientConfig:
+    service:
+      name: app
+      namespace: team-a
+`
+	variables := newModuleVariables()
+	variables.extract = map[string]bool{"namespace": true}
+	variables.sharedNamespace = true
+	output, err := YAMLToTerraformResources(strings.NewReader(yaml), WithModuleVariables(variables))
+	if err != nil {
+		t.Fatal("Converting to HCL failed:", err)
+	}
+	assert.Equal(t, 3, strings.Count(output, `"namespace" = var.namespace`))
+	assert.NotContains(t, output, "team-a")
+	assert.Len(t, variables.variables, 1)
+	assert.Equal(t, "namespace", variables.variables[0].name)
+}
+
+func TestParameterizeNamespaceImports(t *testing.T) {
+	yaml := `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+  namespace: team-a
+`
+	// the object is imported from the namespace it was read from
+	variables := newModuleVariables()
+	variables.extract = map[string]bool{"namespace": true}
+	variables.sharedNamespace = true
+	resources, err := convertResources(strings.NewReader(yaml), WithModuleVariables(variables), WithGenerateImports(true))
+	if err != nil {
+		t.Fatal("Converting to HCL failed:", err)
+	}
+	assert.Equal(t, "apiVersion=v1,kind=ConfigMap,namespace=team-a,name=config", resources[0].importID)
+}
+
+func TestParameterizeNamespaceMissing(t *testing.T) {
+	yaml := `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+  namespace: default
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+---
+apiVersion: cert-manager.io/v1
+kind: ClusterIssuer
+metadata:
+  name: letsencrypt
+---
+apiVersion: storage.k8s.io/v1
+kind: StorageClass
+metadata:
+  name: fast
+`
+	// --strip would remove the default namespace, and objects without one
+	// are in it, so both are set to the variable
+	variables := newModuleVariables()
+	variables.extract = map[string]bool{"namespace": true}
+	variables.sharedNamespace = true
+	resources, err := convertResources(strings.NewReader(yaml), WithModuleVariables(variables), WithStripServerSide(true))
+	if err != nil {
+		t.Fatal("Converting to HCL failed:", err)
+	}
+	assert.Contains(t, resources[0].text, `"namespace" = var.namespace`)
+	assert.Contains(t, resources[1].text, `"namespace" = var.namespace`)
+	assert.NotContains(t, resources[2].text, "namespace")
+	assert.NotContains(t, resources[3].text, "namespace")
+	if assert.Len(t, variables.variables, 1) {
+		assert.Equal(t, "namespace", variables.variables[0].name)
+		assert.Equal(t, cty.StringVal("default"), variables.variables[0].value)
+	}
+}
+
+func TestParameterizeNamespaceSeveral(t *testing.T) {
+	yaml := `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+  namespace: team-a
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+  namespace: team-b
+`
+	// every namespace is the one variable, so the config can be stamped
+	// into any namespace
+	variables := newModuleVariables()
+	variables.extract = map[string]bool{"namespace": true}
+	variables.sharedNamespace = true
+	output, err := YAMLToTerraformResources(strings.NewReader(yaml), WithModuleVariables(variables))
+	if err != nil {
+		t.Fatal("Converting to HCL failed:", err)
+	}
+	assert.Equal(t, 2, strings.Count(output, `"namespace" = var.namespace`))
+	assert.NotContains(t, output, "namespace_team_b")
+	if assert.Len(t, variables.variables, 1) {
+		assert.Equal(t, "namespace", variables.variables[0].name)
+		assert.Equal(t, cty.StringVal("team-a"), variables.variables[0].value)
+	}
+}
+
+func TestMatchPath(t *testing.T) {
+	path := cty.GetAttrPath("subjects").Index(cty.NumberIntVal(0)).GetAttr("namespace")
+	assert.True(t, matchPath(path, "subjects", "*", "namespace"))
+	assert.False(t, matchPath(path, "subjects", "namespace"))
+	assert.False(t, matchPath(cty.GetAttrPath("subjects").GetAttr("x").GetAttr("namespace"), "subjects", "*", "namespace"))
+}
+
+func TestWriteModule(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tfk8s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	variables := newModuleVariables()
+	resources, err := convertResources(strings.NewReader(moduleYAML), WithModuleVariables(variables), WithTyped(true))
+	if err != nil {
+		t.Fatal("Converting to HCL failed:", err)
+	}
+	if err := writeModule(filepath.Join(dir, "app"), resources, variables); err != nil {
+		t.Fatal(err)
+	}
+
+	main, err := ioutil.ReadFile(filepath.Join(dir, "app", "main.tf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Contains(t, string(main), "resource \"kubernetes_deployment_v1\" \"deployment_web_nginx\" {\n  metadata {\n    name = \"nginx\"\n    namespace = var.namespace\n  }\n")
+	assert.Contains(t, string(main), "    replicas = var.deployment_web_nginx_replicas\n")
+
+	outputs, err := ioutil.ReadFile(filepath.Join(dir, "app", "outputs.tf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, `output "namespace_web" {
+  description = "Metadata of the Namespace web"
+  value       = kubernetes_namespace_v1.namespace_web.metadata[0]
+}
+
+output "deployment_web_nginx" {
+  description = "Metadata of the Deployment nginx"
+  value       = kubernetes_deployment_v1.deployment_web_nginx.metadata[0]
+}
+`, string(outputs))
+
+	_, err = os.Stat(filepath.Join(dir, "app", "variables.tf"))
+	assert.NoError(t, err)
+}
+
+func TestSplitImage(t *testing.T) {
+	for image, expected := range map[string][]string{
+		"nginx:1.25":                     {"nginx", "1.25"},
+		"localhost:5000/nginx:1.25":      {"localhost:5000/nginx", "1.25"},
+		"localhost:5000/nginx":           nil,
+		"nginx":                          nil,
+		"nginx:1.25@sha256:4f3d":         nil,
+		"ghcr.io/org/team/app:v1.2.3-rc": {"ghcr.io/org/team/app", "v1.2.3-rc"},
+	} {
+		repository, tag, ok := splitImage(image)
+		if expected == nil {
+			assert.False(t, ok, image)
+			continue
+		}
+		assert.True(t, ok, image)
+		assert.Equal(t, expected, []string{repository, tag}, image)
+	}
+}
+
+func TestModuleCall(t *testing.T) {
+	variables := newModuleVariables()
+	if _, err := convertResources(strings.NewReader(moduleYAML), WithModuleVariables(variables)); err != nil {
+		t.Fatal("Converting to HCL failed:", err)
+	}
+
+	expected := `module "my_app" {
+  source = "./my-app"
+
+  namespace                     = "web"
+  deployment_web_nginx_replicas = 3
+  nginx_image_tag               = "1.25"
+}
+`
+	assert.Equal(t, expected, moduleCall("my-app", moduleSource("modules/my-app/"), variables))
+	assert.Equal(t, "module \"app\" {\n  source = \"./app\"\n}\n", moduleCall("app", "./app", newModuleVariables()))
+}
+
+func TestTFVars(t *testing.T) {
+	variables := newModuleVariables()
+	if _, err := convertResources(strings.NewReader(moduleYAML), WithModuleVariables(variables)); err != nil {
+		t.Fatal("Converting to HCL failed:", err)
+	}
+
+	expected := `namespace                     = "web"
+deployment_web_nginx_replicas = 3
+nginx_image_tag               = "1.25"
+`
+	assert.Equal(t, expected, variables.tfvars())
+	assert.Equal(t, "", newModuleVariables().tfvars())
+}
+
+func TestWriteModules(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tfk8s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	modules := newModuleSet("app.kubernetes.io/name")
+	resources, err := convertResources(strings.NewReader(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: frontend
+  labels:
+    app.kubernetes.io/name: frontend
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: api
+  labels:
+    app.kubernetes.io/name: api
+spec:
+  replicas: 2
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: shared
+`), WithModulePer(modules))
+	if err != nil {
+		t.Fatal("Converting to HCL failed:", err)
+	}
+	if err := writeModules(dir, resources, modules); err != nil {
+		t.Fatal(err)
+	}
+
+	main, err := ioutil.ReadFile(filepath.Join(dir, "main.tf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, `module "frontend" {
+  source = "./frontend"
+}
+
+module "api" {
+  source = "./api"
+
+  deployment_api_replicas = 2
+}
+
+module "unlabeled" {
+  source = "./unlabeled"
+}
+`, string(main))
+
+	api, err := ioutil.ReadFile(filepath.Join(dir, "api", "main.tf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Contains(t, string(api), `resource "kubernetes_manifest" "deployment_api" {`)
+	assert.NotContains(t, string(api), "configmap")
+
+	shared, err := ioutil.ReadFile(filepath.Join(dir, "unlabeled", "main.tf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Contains(t, string(shared), `resource "kubernetes_manifest" "configmap_shared" {`)
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	cty "github.com/zclconf/go-cty/cty"
+)
+
+// builtinNamespaces are the namespaces every cluster has, --create-namespaces
+// doesn't create them
+var builtinNamespaces = map[string]bool{
+	"default":         true,
+	"kube-system":     true,
+	"kube-public":     true,
+	"kube-node-lease": true,
+}
+
+// createNamespaces adds a Namespace resource for each namespace the
+// resources are in that isn't converted in the same run, and makes the
+// resources in it depend on it, so they can be applied to a cluster that
+// doesn't have the namespace yet. The Namespaces are added before the
+// other resources, in the order their namespaces are first used.
+func createNamespaces(resources []resource, opts ...Option) ([]resource, error) {
+	o := newOptions(opts)
+	format, err := lookupFormat(o.format)
+	if err != nil {
+		return nil, err
+	}
+	// the namespaces don't exist yet, so there is nothing to import
+	o.generateImports = false
+
+	converted := map[string]bool{}
+	for _, r := range resources {
+		if r.kind == "Namespace" {
+			converted[r.objectName] = true
+		}
+	}
+
+	namespaces := []resource{}
+	addresses := map[string]string{}
+	for i, r := range resources {
+		ns := r.namespace
+		if ns == "" || builtinNamespaces[ns] || converted[ns] || !r.forEach.IsNull() {
+			continue
+		}
+		address, ok := addresses[ns]
+		if !ok {
+			doc := cty.ObjectVal(map[string]cty.Value{
+				"apiVersion": cty.StringVal("v1"),
+				"kind":       cty.StringVal("Namespace"),
+				"metadata": cty.ObjectVal(map[string]cty.Value{
+					"name": cty.StringVal(ns),
+				}),
+			})
+			created, err := yamlToResources(doc, "", o)
+			if err != nil {
+				return nil, err
+			}
+			o.note("creating Namespace %s for %s %s", ns, r.kind, r.objectName)
+			namespaces = append(namespaces, created...)
+			address = created[0].resourceType + "." + created[0].name
+			addresses[ns] = address
+		}
+
+		if containsString(r.dependsOn, address) {
+			continue
+		}
+		r.dependsOn = append([]string{address}, r.dependsOn...)
+		if r.text, err = format.resource(r, o); err != nil {
+			return nil, err
+		}
+		resources[i] = r
+	}
+	return append(namespaces, resources...), nil
+}
+
+// containsString returns true if s is in list
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
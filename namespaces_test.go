@@ -0,0 +1,59 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateNamespaces(t *testing.T) {
+	yaml := `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+  namespace: web
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: creds
+  namespace: web
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+  namespace: kube-system
+---
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: db
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+  namespace: db
+`
+	resources, err := convertResources(strings.NewReader(yaml), WithGenerateImports(true))
+	if err != nil {
+		t.Fatal("Converting to HCL failed:", err)
+	}
+	resources, err = createNamespaces(resources, WithGenerateImports(true))
+	assert.NoError(t, err)
+
+	// only the web namespace is created, once, before the other resources
+	if assert.Len(t, resources, 6) {
+		assert.Equal(t, "namespace_web", resources[0].name)
+		assert.Contains(t, resources[0].text, `"name" = "web"`)
+		assert.NotContains(t, resources[0].text, "import {")
+		for _, r := range resources[1:3] {
+			assert.Equal(t, []string{"kubernetes_manifest.namespace_web"}, r.dependsOn)
+			assert.Contains(t, r.text, "depends_on = [\n    kubernetes_manifest.namespace_web,\n  ]")
+		}
+		assert.Empty(t, resources[3].dependsOn)
+		assert.Empty(t, resources[5].dependsOn)
+	}
+}
@@ -0,0 +1,396 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// ociPrefix is the scheme used to reference OCI artifacts in --file
+const ociPrefix = "oci://"
+
+const (
+	ociManifestMediaType       = "application/vnd.oci.image.manifest.v1+json"
+	dockerManifestMediaType    = "application/vnd.docker.distribution.manifest.v2+json"
+	helmChartContentMediaType  = "application/vnd.cncf.helm.chart.content.v1.tar+gzip"
+	ociImageTitleAnnotationKey = "org.opencontainers.image.title"
+)
+
+// ociManifestLayerMediaTypes are the media types of the layers that are
+// read as manifests or tarballs of manifests. Other layers, like Helm
+// provenance files, signatures and SBOMs, are skipped.
+var ociManifestLayerMediaTypes = []string{
+	"application/yaml",
+	"application/x-yaml",
+	"text/yaml",
+	"application/json",
+	"application/x-tar",
+	"application/gzip",
+	"application/x-gzip",
+	"application/tar+gzip",
+	"application/vnd.oci.image.layer.v1.tar",
+	"application/vnd.oci.image.layer.v1.tar+gzip",
+	"application/vnd.docker.image.rootfs.diff.tar.gzip",
+	"application/vnd.cncf.flux.content.v1.tar+gzip",
+	"application/vnd.carvel.imgpkg.bundle.v1.tar+gzip",
+}
+
+// isOCI returns true if the path references an OCI artifact
+func isOCI(path string) bool {
+	return strings.HasPrefix(path, ociPrefix)
+}
+
+// ociReference is a parsed reference to an artifact in an OCI registry
+type ociReference struct {
+	registry   string
+	repository string
+	// reference is either a tag or a digest
+	reference string
+}
+
+// parseOCIReference parses references like oci://registry.example.com/charts/app:1.2.3
+func parseOCIReference(ref string) (ociReference, error) {
+	s := strings.TrimPrefix(ref, ociPrefix)
+	i := strings.Index(s, "/")
+	if i == -1 {
+		return ociReference{}, fmt.Errorf("invalid OCI reference %q: missing repository", ref)
+	}
+	r := ociReference{registry: s[:i], repository: s[i+1:], reference: "latest"}
+	if at := strings.Index(r.repository, "@"); at != -1 {
+		r.reference = r.repository[at+1:]
+		r.repository = r.repository[:at]
+	} else if colon := strings.LastIndex(r.repository, ":"); colon != -1 {
+		r.reference = r.repository[colon+1:]
+		r.repository = r.repository[:colon]
+	}
+	if r.repository == "" || r.reference == "" {
+		return ociReference{}, fmt.Errorf("invalid OCI reference %q", ref)
+	}
+	return r, nil
+}
+
+// ociDescriptor describes a blob in an OCI manifest
+type ociDescriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Annotations map[string]string `json:"annotations"`
+}
+
+// ociManifest is an OCI image manifest
+type ociManifest struct {
+	MediaType string          `json:"mediaType"`
+	Config    ociDescriptor   `json:"config"`
+	Layers    []ociDescriptor `json:"layers"`
+}
+
+// registryCredentials are the credentials used to authenticate with a registry
+type registryCredentials struct {
+	username string
+	password string
+}
+
+// dockerConfig is the part of the docker config.json used to
+// find credentials for a registry
+type dockerConfig struct {
+	Auths map[string]struct {
+		Auth string `json:"auth"`
+	} `json:"auths"`
+	CredsStore  string            `json:"credsStore"`
+	CredHelpers map[string]string `json:"credHelpers"`
+}
+
+// runCredentialHelper asks a docker credential helper for the credentials
+// of a registry
+var runCredentialHelper = func(helper, registry string) (registryCredentials, error) {
+	cmd := exec.Command("docker-credential-"+helper, "get")
+	cmd.Stdin = strings.NewReader(registry)
+	out, err := cmd.Output()
+	if err != nil {
+		return registryCredentials{}, fmt.Errorf("docker-credential-%s: %s", helper, err)
+	}
+	var creds struct {
+		Username string
+		Secret   string
+	}
+	if err := json.Unmarshal(out, &creds); err != nil {
+		return registryCredentials{}, err
+	}
+	return registryCredentials{creds.Username, creds.Secret}, nil
+}
+
+// dockerConfigPath returns the path of the docker config.json
+func dockerConfigPath() string {
+	if dir := os.Getenv("DOCKER_CONFIG"); dir != "" {
+		return filepath.Join(dir, "config.json")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".docker", "config.json")
+}
+
+// lookupRegistryCredentials finds the credentials for a registry in the
+// docker config, using the configured credential helpers if there are any
+func lookupRegistryCredentials(registry string) (*registryCredentials, error) {
+	b, err := ioutil.ReadFile(dockerConfigPath())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var config dockerConfig
+	if err := json.Unmarshal(b, &config); err != nil {
+		return nil, fmt.Errorf("reading docker config: %s", err)
+	}
+
+	if helper, ok := config.CredHelpers[registry]; ok {
+		creds, err := runCredentialHelper(helper, registry)
+		return &creds, err
+	}
+	for _, key := range []string{registry, "https://" + registry} {
+		auth, ok := config.Auths[key]
+		if !ok || auth.Auth == "" {
+			continue
+		}
+		decoded, err := base64.StdEncoding.DecodeString(auth.Auth)
+		if err != nil {
+			return nil, fmt.Errorf("reading docker config: %s", err)
+		}
+		parts := strings.SplitN(string(decoded), ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("reading docker config: invalid auth for %s", key)
+		}
+		return &registryCredentials{parts[0], parts[1]}, nil
+	}
+	if config.CredsStore != "" {
+		creds, err := runCredentialHelper(config.CredsStore, registry)
+		return &creds, err
+	}
+	return nil, nil
+}
+
+// parseAuthChallenge parses a WWW-Authenticate header into its scheme and parameters
+func parseAuthChallenge(header string) (string, map[string]string) {
+	params := map[string]string{}
+	parts := strings.SplitN(strings.TrimSpace(header), " ", 2)
+	scheme := strings.ToLower(parts[0])
+	if len(parts) == 1 {
+		return scheme, params
+	}
+	for _, p := range strings.Split(parts[1], ",") {
+		kv := strings.SplitN(strings.TrimSpace(p), "=", 2)
+		if len(kv) == 2 {
+			params[strings.ToLower(kv[0])] = strings.Trim(kv[1], `"`)
+		}
+	}
+	return scheme, params
+}
+
+// ociClient fetches manifests and blobs from a registry
+type ociClient struct {
+	client *http.Client
+	ref    ociReference
+	creds  *registryCredentials
+	// authorization is the Authorization header to send with requests
+	authorization string
+}
+
+// fetchToken exchanges the registry credentials for a bearer token
+func (c *ociClient) fetchToken(params map[string]string) (string, error) {
+	u, err := url.Parse(params["realm"])
+	if err != nil {
+		return "", err
+	}
+	q := u.Query()
+	if s, ok := params["service"]; ok {
+		q.Set("service", s)
+	}
+	if s, ok := params["scope"]; ok {
+		q.Set("scope", s)
+	} else {
+		q.Set("scope", fmt.Sprintf("repository:%s:pull", c.ref.repository))
+	}
+	u.RawQuery = q.Encode()
+
+	req, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	if c.creds != nil {
+		req.SetBasicAuth(c.creds.username, c.creds.password)
+	}
+	res, err := c.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("GET %s: %s", u.String(), res.Status)
+	}
+
+	var token struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&token); err != nil {
+		return "", err
+	}
+	if token.Token != "" {
+		return token.Token, nil
+	}
+	return token.AccessToken, nil
+}
+
+// get fetches a path from the registry API, authenticating if the
+// registry asks us to
+func (c *ociClient) get(path, accept string) ([]byte, error) {
+	u := fmt.Sprintf("https://%s/v2/%s/%s", c.ref.registry, c.ref.repository, path)
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequest("GET", u, nil)
+		if err != nil {
+			return nil, err
+		}
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		if c.authorization != "" {
+			req.Header.Set("Authorization", c.authorization)
+		}
+		res, err := c.client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		b, err := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		if res.StatusCode == http.StatusUnauthorized && attempt == 0 {
+			scheme, params := parseAuthChallenge(res.Header.Get("WWW-Authenticate"))
+			switch scheme {
+			case "bearer":
+				token, err := c.fetchToken(params)
+				if err != nil {
+					return nil, err
+				}
+				c.authorization = "Bearer " + token
+				continue
+			case "basic":
+				if c.creds != nil {
+					auth := c.creds.username + ":" + c.creds.password
+					c.authorization = "Basic " + base64.StdEncoding.EncodeToString([]byte(auth))
+					continue
+				}
+			}
+		}
+		if res.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("GET %s: %s", u, res.Status)
+		}
+		return b, nil
+	}
+}
+
+// pullOCIArtifact fetches the manifest of the artifact and returns it
+// along with a client that can be used to fetch its blobs
+func pullOCIArtifact(ref string, client *http.Client) (*ociClient, ociManifest, error) {
+	r, err := parseOCIReference(ref)
+	if err != nil {
+		return nil, ociManifest{}, err
+	}
+	creds, err := lookupRegistryCredentials(r.registry)
+	if err != nil {
+		return nil, ociManifest{}, err
+	}
+
+	c := &ociClient{client: client, ref: r, creds: creds}
+	b, err := c.get("manifests/"+r.reference, ociManifestMediaType+", "+dockerManifestMediaType)
+	if err != nil {
+		return nil, ociManifest{}, err
+	}
+	var manifest ociManifest
+	if err := json.Unmarshal(b, &manifest); err != nil {
+		return nil, ociManifest{}, fmt.Errorf("reading OCI manifest: %s", err)
+	}
+	return c, manifest, nil
+}
+
+// verifyDigest checks b has the sha256 digest of the descriptor it was
+// fetched for
+func verifyDigest(b []byte, digest string) error {
+	want := strings.TrimPrefix(digest, "sha256:")
+	if want == digest {
+		return fmt.Errorf("unsupported digest %s, only sha256 is supported", digest)
+	}
+	sum := sha256.Sum256(b)
+	if got := hex.EncodeToString(sum[:]); got != want {
+		return fmt.Errorf("blob %s has the digest sha256:%s", digest, got)
+	}
+	return nil
+}
+
+// ociSources pulls an OCI artifact and returns a source for each manifest
+// it contains. Helm charts are rendered like helm template, the layers
+// with one of the ociManifestLayerMediaTypes are either gzipped tarballs
+// of manifests or manifests themselves, and the other layers are skipped.
+func ociSources(ref string, client *http.Client) ([]source, error) {
+	c, manifest, err := pullOCIArtifact(ref, client)
+	if err != nil {
+		return nil, err
+	}
+
+	sources := []source{}
+	skipped := []string{}
+	for _, layer := range manifest.Layers {
+		if layer.MediaType != helmChartContentMediaType && !containsString(ociManifestLayerMediaTypes, layer.MediaType) {
+			skipped = append(skipped, layer.MediaType)
+			continue
+		}
+		b, err := c.get("blobs/"+layer.Digest, "")
+		if err != nil {
+			return nil, err
+		}
+		if err := verifyDigest(b, layer.Digest); err != nil {
+			return nil, fmt.Errorf("%s: %s", ref, err)
+		}
+
+		if !isGzip(b) {
+			name := layer.Annotations[ociImageTitleAnnotationKey]
+			if name == "" {
+				name = layer.Digest
+			}
+			sources = append(sources, bytesSource(ref+"//"+name, b))
+			continue
+		}
+
+		if layer.MediaType == helmChartContentMediaType {
+			rendered, err := renderHelmChartArchive(b)
+			if err != nil {
+				return nil, err
+			}
+			sources = append(sources, bytesSource(ref, rendered))
+			continue
+		}
+
+		s, err := tarSources(b, ref)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", ref, err)
+		}
+		sources = append(sources, s...)
+	}
+	if len(sources) == 0 && len(skipped) > 0 {
+		return nil, fmt.Errorf("%s has no layers with manifests, only %s", ref, strings.Join(skipped, ", "))
+	}
+	return sources, nil
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseOCIReference(t *testing.T) {
+	tests := []struct {
+		ref      string
+		expected ociReference
+	}{
+		{
+			"oci://registry.example.com/charts/app:1.2.3",
+			ociReference{"registry.example.com", "charts/app", "1.2.3"},
+		},
+		{
+			"oci://localhost:5000/manifests",
+			ociReference{"localhost:5000", "manifests", "latest"},
+		},
+		{
+			"oci://ghcr.io/org/app@sha256:abc123",
+			ociReference{"ghcr.io", "org/app", "sha256:abc123"},
+		},
+	}
+
+	for _, tt := range tests {
+		r, err := parseOCIReference(tt.ref)
+		if err != nil {
+			t.Fatal("Parsing OCI reference failed:", err)
+		}
+		assert.Equal(t, tt.expected, r)
+	}
+
+	_, err := parseOCIReference("oci://registry.example.com")
+	assert.Error(t, err)
+}
+
+func TestParseAuthChallenge(t *testing.T) {
+	scheme, params := parseAuthChallenge(`Bearer realm="https://auth.example.com/token",service="registry.example.com",scope="repository:charts/app:pull"`)
+	assert.Equal(t, "bearer", scheme)
+	assert.Equal(t, map[string]string{
+		"realm":   "https://auth.example.com/token",
+		"service": "registry.example.com",
+		"scope":   "repository:charts/app:pull",
+	}, params)
+}
+
+func TestOCISources(t *testing.T) {
+	bundle := tarGz(t, map[string]string{
+		"manifests/configmap.yaml": "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: bundled\n",
+		"manifests/README.md":      "not a manifest",
+	})
+	plain := []byte("apiVersion: v1\nkind: Namespace\nmetadata:\n  name: plain\n")
+	blobs := map[string][]byte{
+		ociDigest(bundle): bundle,
+		ociDigest(plain):  plain,
+	}
+
+	var server *httptest.Server
+	server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			user, pass, ok := r.BasicAuth()
+			if !ok || user != "user" || pass != "secret" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			assert.Equal(t, "repository:manifests/app:pull", r.URL.Query().Get("scope"))
+			w.Write([]byte(`{"token": "t0k3n"}`))
+			return
+		}
+
+		if r.Header.Get("Authorization") != "Bearer t0k3n" {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(
+				`Bearer realm="%s/token",service="registry",scope="repository:manifests/app:pull"`, server.URL))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		switch r.URL.Path {
+		case "/v2/manifests/app/manifests/1.0.0":
+			json.NewEncoder(w).Encode(ociManifest{
+				MediaType: ociManifestMediaType,
+				Layers: []ociDescriptor{
+					{
+						MediaType: "application/vnd.oci.image.layer.v1.tar+gzip",
+						Digest:    ociDigest(bundle),
+					},
+					{
+						MediaType:   "application/yaml",
+						Digest:      ociDigest(plain),
+						Annotations: map[string]string{ociImageTitleAnnotationKey: "namespace.yaml"},
+					},
+					{
+						MediaType: "application/vnd.cncf.helm.chart.provenance.v1.prov",
+						Digest:    "sha256:provenance",
+					},
+				},
+			})
+		case "/v2/manifests/app/manifests/tampered":
+			json.NewEncoder(w).Encode(ociManifest{
+				MediaType: ociManifestMediaType,
+				Layers: []ociDescriptor{
+					{MediaType: "application/yaml", Digest: ociDigest([]byte("other"))},
+				},
+			})
+		case "/v2/manifests/app/blobs/" + ociDigest([]byte("other")):
+			w.Write(plain)
+		default:
+			b, ok := blobs[strings.TrimPrefix(r.URL.Path, "/v2/manifests/app/blobs/")]
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			w.Write(b)
+		}
+	}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "tfk8s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	registry := strings.TrimPrefix(server.URL, "https://")
+	config := fmt.Sprintf(`{"auths": {%q: {"auth": %q}}}`,
+		registry, base64.StdEncoding.EncodeToString([]byte("user:secret")))
+	if err := ioutil.WriteFile(filepath.Join(dir, "config.json"), []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Setenv("DOCKER_CONFIG", os.Getenv("DOCKER_CONFIG"))
+	os.Setenv("DOCKER_CONFIG", dir)
+
+	ref := "oci://" + registry + "/manifests/app:1.0.0"
+	sources, err := ociSources(ref, server.Client())
+	if err != nil {
+		t.Fatal("Pulling OCI artifact failed:", err)
+	}
+
+	names := []string{}
+	output := ""
+	for _, s := range sources {
+		names = append(names, s.name)
+		r, err := s.open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		hcl, err := YAMLToTerraformResources(r)
+		if err != nil {
+			t.Fatal("Converting to HCL failed:", err)
+		}
+		output += hcl
+	}
+
+	assert.Equal(t, []string{
+		ref + "//manifests/configmap.yaml",
+		ref + "//namespace.yaml",
+	}, names)
+	assert.Contains(t, output, `resource "kubernetes_manifest" "configmap_bundled"`)
+	assert.Contains(t, output, `resource "kubernetes_manifest" "namespace_plain"`)
+
+	_, err = ociSources("oci://"+registry+"/manifests/app:tampered", server.Client())
+	assert.Error(t, err)
+}
+
+// ociDigest returns the sha256 digest of a blob
+func ociDigest(b []byte) string {
+	sum := sha256.Sum256(b)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
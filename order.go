@@ -0,0 +1,133 @@
+package main
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// installOrder is the order --sort writes resources in, the same order
+// Helm installs a chart's manifests in, so the objects other objects need
+// come first. Kinds that aren't in the list go after the workloads, before
+// the webhooks that could otherwise reject them.
+var installOrder = []string{
+	"Namespace",
+	"NetworkPolicy",
+	"ResourceQuota",
+	"LimitRange",
+	"PodSecurityPolicy",
+	"PodDisruptionBudget",
+	"ServiceAccount",
+	"Secret",
+	"SecretList",
+	"ConfigMap",
+	"StorageClass",
+	"PersistentVolume",
+	"PersistentVolumeClaim",
+	"CustomResourceDefinition",
+	"ClusterRole",
+	"ClusterRoleList",
+	"ClusterRoleBinding",
+	"ClusterRoleBindingList",
+	"Role",
+	"RoleList",
+	"RoleBinding",
+	"RoleBindingList",
+	"Service",
+	"DaemonSet",
+	"Pod",
+	"ReplicationController",
+	"ReplicaSet",
+	"Deployment",
+	"HorizontalPodAutoscaler",
+	"StatefulSet",
+	"Job",
+	"CronJob",
+	"IngressClass",
+	"Ingress",
+	"APIService",
+	"",
+	"MutatingWebhookConfiguration",
+	"ValidatingWebhookConfiguration",
+}
+
+// installPriority returns the position of kind in installOrder
+func installPriority(kind string) int {
+	other := 0
+	for i, k := range installOrder {
+		if k == kind {
+			return i
+		}
+		if k == "" {
+			other = i
+		}
+	}
+	return other
+}
+
+// stage is when an object is applied: the phase is -1 for Helm
+// pre-install hooks and Argo CD PreSync hooks, 1 for the other hooks and
+// 0 for everything else, the wave is the hook weight or the Argo CD sync
+// wave within the phase
+type stage struct {
+	phase, wave int
+}
+
+// before returns true if s is applied before other
+func (s stage) before(other stage) bool {
+	if s.phase != other.phase {
+		return s.phase < other.phase
+	}
+	return s.wave < other.wave
+}
+
+// annotation returns the value of an annotation of r
+func annotation(r resource, name string) (string, bool) {
+	return getString(r.manifest, "metadata", "annotations", name)
+}
+
+// applyStage returns the stage r is applied in from its helm.sh/hook,
+// helm.sh/hook-weight, argocd.argoproj.io/hook and
+// argocd.argoproj.io/sync-wave annotations
+func applyStage(r resource) stage {
+	s := stage{}
+	if hooks, ok := annotation(r, "helm.sh/hook"); ok {
+		s.phase = 1
+		for _, hook := range strings.Split(hooks, ",") {
+			switch strings.TrimSpace(hook) {
+			case "pre-install", "pre-upgrade":
+				s.phase = -1
+			}
+		}
+		if weight, ok := annotation(r, "helm.sh/hook-weight"); ok {
+			s.wave, _ = strconv.Atoi(strings.TrimSpace(weight))
+		}
+		return s
+	}
+	if hook, ok := annotation(r, "argocd.argoproj.io/hook"); ok {
+		switch hook {
+		case "PreSync":
+			s.phase = -1
+		case "Sync":
+		default:
+			s.phase = 1
+		}
+	}
+	if wave, ok := annotation(r, "argocd.argoproj.io/sync-wave"); ok {
+		s.wave, _ = strconv.Atoi(strings.TrimSpace(wave))
+	}
+	return s
+}
+
+// sortResources sorts resources into the stage they are applied in, then
+// installOrder, keeping the order they were read in for resources of the
+// same kind
+func sortResources(resources []resource) {
+	sort.SliceStable(resources, func(i, j int) bool {
+		si, sj := applyStage(resources[i]), applyStage(resources[j])
+		if si != sj {
+			return si.before(sj)
+		}
+		return installPriority(resources[i].kind) < installPriority(resources[j].kind)
+	})
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSortResources(t *testing.T) {
+	yaml := `apiVersion: admissionregistration.k8s.io/v1
+kind: ValidatingWebhookConfiguration
+metadata:
+  name: check
+---
+apiVersion: example.com/v1
+kind: Widget
+metadata:
+  name: test
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: b
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: a
+---
+apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: widgets.example.com
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: test
+---
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: test
+`
+	resources, err := convertResources(strings.NewReader(yaml))
+	if err != nil {
+		t.Fatal("Converting to HCL failed:", err)
+	}
+	sortResources(resources)
+
+	names := []string{}
+	for _, r := range resources {
+		names = append(names, r.name)
+	}
+	assert.Equal(t, []string{
+		"namespace_test",
+		"configmap_test",
+		"customresourcedefinition_widgets_example_com",
+		"deployment_b",
+		"deployment_a",
+		"widget_test",
+		"validatingwebhookconfiguration_check",
+	}, names)
+}
+
+func TestSortResourcesHooks(t *testing.T) {
+	yaml := `apiVersion: batch/v1
+kind: Job
+metadata:
+  name: test
+  annotations:
+    helm.sh/hook: test
+---
+apiVersion: batch/v1
+kind: Job
+metadata:
+  name: migrate
+  annotations:
+    helm.sh/hook: pre-install,pre-upgrade
+    helm.sh/hook-weight: "5"
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: migrate
+  annotations:
+    helm.sh/hook: pre-install
+    helm.sh/hook-weight: "-5"
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  annotations:
+    argocd.argoproj.io/sync-wave: "1"
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: web
+`
+	resources, err := convertResources(strings.NewReader(yaml))
+	if err != nil {
+		t.Fatal("Converting to HCL failed:", err)
+	}
+	sortResources(resources)
+
+	names := []string{}
+	for _, r := range resources {
+		names = append(names, r.name)
+	}
+	assert.Equal(t, []string{
+		"configmap_migrate",
+		"job_migrate",
+		"configmap_web",
+		"service_web",
+		"deployment_web",
+		"job_test",
+	}, names)
+}
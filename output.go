@@ -0,0 +1,326 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// writeFileAtomic writes data to a temporary file next to filename and
+// renames it into place, so an interrupted run never leaves a truncated
+// file behind. An existing file keeps its permissions.
+func writeFileAtomic(filename string, data []byte, perm os.FileMode) error {
+	if info, err := os.Stat(filename); err == nil {
+		perm = info.Mode().Perm()
+	}
+
+	f, err := ioutil.TempFile(filepath.Dir(filename), "."+filepath.Base(filename)+".tmp")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	defer os.Remove(tmp)
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp, perm); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filename)
+}
+
+// pendingFile is a file the config reads, like a manifest of --format
+// yamlref, written while the resources are converted
+type pendingFile struct {
+	filename string
+	data     []byte
+	// secret is set if the file has the value of a Secret or a value
+	// that looks like a credential, so --fail-on-secrets reports it
+	secret *secretFinding
+}
+
+// pendingFiles holds the files written while the resources are converted
+// until they have all been converted, so nothing is written if
+// --fail-on-secrets finds a secret or a resource fails to convert
+type pendingFiles struct {
+	files []pendingFile
+}
+
+// write writes the files that are held, in the order they were added
+func (p *pendingFiles) write() error {
+	for _, f := range p.files {
+		if err := writeFile(f); err != nil {
+			return err
+		}
+	}
+	p.files = nil
+	return nil
+}
+
+// writeFile writes a file the config reads, creating its directory
+func writeFile(f pendingFile) error {
+	if err := os.MkdirAll(filepath.Dir(f.filename), 0755); err != nil {
+		return err
+	}
+	return writeFileAtomic(f.filename, f.data, 0644)
+}
+
+// outputRef returns the path to dir from the directory the output is
+// written to, so files written next to the config can be read from it
+func outputRef(outfile, dir string, outputIsDir bool) (string, error) {
+	if filepath.IsAbs(dir) {
+		return dir, nil
+	}
+	outdir := "."
+	if outfile != "-" {
+		outdir = filepath.Dir(outfile)
+		if outputIsDir {
+			outdir = outfile
+		}
+	}
+	return filepath.Rel(outdir, dir)
+}
+
+// modulePath returns the path to a file under ref to use in the generated
+// config, it is relative to path.module unless ref is absolute
+func modulePath(ref string, elem ...string) string {
+	p := path.Join(append([]string{filepath.ToSlash(ref)}, elem...)...)
+	if !path.IsAbs(p) {
+		p = "${path.module}/" + p
+	}
+	return p
+}
+
+// joinResources returns the config for resources as a single file in format
+func joinResources(format outputFormat, resources []resource) (string, error) {
+	if len(resources) == 0 {
+		return "", nil
+	}
+	return format.join(resources)
+}
+
+// pluralKind returns the lower case plural of a kind, the same way
+// the API names resources, so Deployment becomes deployments
+func pluralKind(kind string) string {
+	kind = strings.ToLower(kind)
+	switch {
+	case strings.HasSuffix(kind, "ss"), strings.HasSuffix(kind, "x"),
+		strings.HasSuffix(kind, "ch"), strings.HasSuffix(kind, "sh"):
+		return kind + "es"
+	case strings.HasSuffix(kind, "s"):
+		// kinds like Endpoints are already plural
+		return kind
+	case strings.HasSuffix(kind, "y") && len(kind) > 1 && !strings.ContainsRune("aeiou", rune(kind[len(kind)-2])):
+		return kind[:len(kind)-1] + "ies"
+	}
+	return kind + "s"
+}
+
+// outputLayout controls which file each resource is written to
+type outputLayout struct {
+	// file is set to write every resource to the same file, like --output
+	file string
+	// groupBy is what to group resources into files by, by default each
+	// resource gets its own file
+	groupBy string
+	// maxPerFile is the most resources to write to one file, files with
+	// more are split into numbered files. Zero means there is no limit.
+	maxPerFile int
+	// template is used to work out the filename of each resource instead
+	// of groupBy if it is set
+	template *template.Template
+	// format is the syntax the files are written in, the default is hcl
+	format outputFormat
+}
+
+// filenameData is what --filename-template is executed with
+type filenameData struct {
+	Namespace string
+	Kind      string
+	Name      string
+	// Resource is the label of the Terraform resource
+	Resource string
+}
+
+// filenameFuncs are the functions available in --filename-template
+var filenameFuncs = template.FuncMap{
+	"lower":  strings.ToLower,
+	"plural": pluralKind,
+	"snake":  snakify,
+}
+
+// parseFilenameTemplate parses a --filename-template
+func parseFilenameTemplate(text string) (*template.Template, error) {
+	t, err := template.New("filename").Funcs(filenameFuncs).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --filename-template: %s", err)
+	}
+	return t, nil
+}
+
+// validate checks the layout options are supported
+func (l outputLayout) validate() error {
+	if l.maxPerFile < 0 {
+		return fmt.Errorf("--max-resources-per-file must not be negative")
+	}
+	if l.template != nil && l.groupBy != "" {
+		return fmt.Errorf("--filename-template can't be used with --group-by")
+	}
+	switch l.groupBy {
+	case "", "namespace", "kind":
+		return nil
+	}
+	return fmt.Errorf("unknown --group-by %q, must be namespace or kind", l.groupBy)
+}
+
+// outputFormat returns the format the files are written in
+func (l outputLayout) outputFormat() outputFormat {
+	if l.format == nil {
+		return hclFormat{}
+	}
+	return l.format
+}
+
+// filename returns the file a resource is written to, relative to the
+// output directory
+func (l outputLayout) filename(r resource) (string, error) {
+	if l.file != "" {
+		return l.file, nil
+	}
+	if l.template != nil {
+		return l.templateFilename(r)
+	}
+	return l.groupFilename(r), nil
+}
+
+// templateFilename executes the filename template for a resource
+func (l outputLayout) templateFilename(r resource) (string, error) {
+	var buf bytes.Buffer
+	err := l.template.Execute(&buf, filenameData{
+		Namespace: r.namespace,
+		Kind:      r.kind,
+		Name:      r.objectName,
+		Resource:  r.name,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	filename := filepath.Clean(filepath.FromSlash(strings.TrimSpace(buf.String())))
+	if filename == "." || filepath.IsAbs(filename) || strings.HasPrefix(filename, "..") {
+		return "", fmt.Errorf("--filename-template gave %q for %s %s, it must be a path inside the output directory", buf.String(), r.kind, r.objectName)
+	}
+	return filename, nil
+}
+
+// groupFilename returns the file a resource is written to using groupBy
+func (l outputLayout) groupFilename(r resource) string {
+	ext := l.outputFormat().extension()
+	switch l.groupBy {
+	case "namespace":
+		namespace := r.namespace
+		if r.kind == "Namespace" {
+			// keep the namespace with the resources that are in it
+			namespace = r.objectName
+		}
+		if namespace == "" {
+			// cluster scoped resources, and ones without a namespace
+			return "main" + ext
+		}
+		return filepath.Join("namespaces", namespace, "main"+ext)
+	case "kind":
+		return pluralKind(r.kind) + ext
+	}
+	return r.name + ext
+}
+
+// numberedFilename returns the filename for the nth chunk of a file that
+// was split, so main.tf becomes main_1.tf, main_2.tf and so on
+func numberedFilename(filename string, n int) string {
+	ext := filepath.Ext(filename)
+	if strings.HasSuffix(filename, ".tf"+ext) {
+		// keep compound extensions like .tf.json together
+		ext = ".tf" + ext
+	}
+	return fmt.Sprintf("%s_%d%s", strings.TrimSuffix(filename, ext), n, ext)
+}
+
+// layoutFiles returns the contents of each file the resources are written
+// to and the order to write them in. Resources that end up with the same
+// filename are written to the same file, in the order they were converted.
+func layoutFiles(layout outputLayout, resources []resource) ([]string, map[string]string, error) {
+	order := []string{}
+	grouped := map[string][]resource{}
+	for _, r := range resources {
+		filename, err := layout.filename(r)
+		if err != nil {
+			return nil, nil, err
+		}
+		if _, ok := grouped[filename]; !ok {
+			order = append(order, filename)
+		}
+		grouped[filename] = append(grouped[filename], r)
+	}
+
+	format := layout.outputFormat()
+	filenames := []string{}
+	files := map[string]string{}
+	for _, filename := range order {
+		group := grouped[filename]
+		if layout.maxPerFile == 0 || len(group) <= layout.maxPerFile {
+			text, err := format.join(group)
+			if err != nil {
+				return nil, nil, err
+			}
+			filenames = append(filenames, filename)
+			files[filename] = text
+			continue
+		}
+		for i := 0; i < len(group); i += layout.maxPerFile {
+			end := i + layout.maxPerFile
+			if end > len(group) {
+				end = len(group)
+			}
+			text, err := format.join(group[i:end])
+			if err != nil {
+				return nil, nil, err
+			}
+			chunk := numberedFilename(filename, i/layout.maxPerFile+1)
+			filenames = append(filenames, chunk)
+			files[chunk] = text
+		}
+	}
+	return filenames, files, nil
+}
+
+// writeOutputDir writes the resources to files in dir using layout
+func writeOutputDir(dir string, layout outputLayout, resources []resource) error {
+	filenames, files, err := layoutFiles(layout, resources)
+	if err != nil {
+		return err
+	}
+	for _, filename := range filenames {
+		path := filepath.Join(dir, filename)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		if err := writeFileAtomic(path, []byte(files[filename]), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const splitManifests = `apiVersion: v1
+kind: Namespace
+metadata:
+  name: web
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: nginx
+  namespace: web
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: nginx
+  namespace: web
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: settings
+`
+
+// readOutputDir returns the files in dir and their contents
+func readOutputDir(t *testing.T, dir string) map[string]string {
+	files := map[string]string{}
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, path)
+		files[filepath.ToSlash(rel)] = string(b)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+func TestWriteOutputDir(t *testing.T) {
+	resources, err := convertResources(strings.NewReader(splitManifests))
+	if err != nil {
+		t.Fatal("Converting to HCL failed:", err)
+	}
+
+	dir, err := ioutil.TempDir("", "tfk8s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := writeOutputDir(dir, outputLayout{}, resources); err != nil {
+		t.Fatal(err)
+	}
+
+	files := readOutputDir(t, dir)
+	assert.Len(t, files, 4)
+	assert.Contains(t, files["namespace_web.tf"], `resource "kubernetes_manifest" "namespace_web"`)
+	assert.Contains(t, files["deployment_web_nginx.tf"], `resource "kubernetes_manifest" "deployment_web_nginx"`)
+	assert.Contains(t, files["service_web_nginx.tf"], `resource "kubernetes_manifest" "service_web_nginx"`)
+	assert.Contains(t, files["configmap_settings.tf"], `resource "kubernetes_manifest" "configmap_settings"`)
+	assert.Equal(t, resources[3].text, files["configmap_settings.tf"])
+}
+
+func TestWriteOutputDirGroupByNamespace(t *testing.T) {
+	resources, err := convertResources(strings.NewReader(splitManifests))
+	if err != nil {
+		t.Fatal("Converting to HCL failed:", err)
+	}
+
+	dir, err := ioutil.TempDir("", "tfk8s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := writeOutputDir(dir, outputLayout{groupBy: "namespace"}, resources); err != nil {
+		t.Fatal(err)
+	}
+
+	files := readOutputDir(t, dir)
+	assert.Len(t, files, 2)
+	assert.Equal(t, resources[0].text+"\n"+resources[1].text+"\n"+resources[2].text, files["namespaces/web/main.tf"])
+	assert.Equal(t, resources[3].text, files["main.tf"])
+}
+
+func TestWriteOutputDirGroupByKind(t *testing.T) {
+	resources, err := convertResources(strings.NewReader(splitManifests + `---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: more-settings
+`))
+	if err != nil {
+		t.Fatal("Converting to HCL failed:", err)
+	}
+
+	dir, err := ioutil.TempDir("", "tfk8s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := writeOutputDir(dir, outputLayout{groupBy: "kind"}, resources); err != nil {
+		t.Fatal(err)
+	}
+
+	files := readOutputDir(t, dir)
+	assert.Len(t, files, 4)
+	assert.Equal(t, resources[0].text, files["namespaces.tf"])
+	assert.Equal(t, resources[1].text, files["deployments.tf"])
+	assert.Equal(t, resources[2].text, files["services.tf"])
+	assert.Equal(t, resources[3].text+"\n"+resources[4].text, files["configmaps.tf"])
+}
+
+func TestLayoutFilesMaxPerFile(t *testing.T) {
+	resources, err := convertResources(strings.NewReader(splitManifests))
+	if err != nil {
+		t.Fatal("Converting to HCL failed:", err)
+	}
+
+	filenames, files, err := layoutFiles(outputLayout{file: "main.tf", maxPerFile: 3}, resources)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"main_1.tf", "main_2.tf"}, filenames)
+	assert.Equal(t, resources[0].text+"\n"+resources[1].text+"\n"+resources[2].text, files["main_1.tf"])
+	assert.Equal(t, resources[3].text, files["main_2.tf"])
+
+	// files that are under the limit keep their name
+	filenames, _, err = layoutFiles(outputLayout{groupBy: "namespace", maxPerFile: 2}, resources)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		filepath.Join("namespaces", "web", "main_1.tf"),
+		filepath.Join("namespaces", "web", "main_2.tf"),
+		"main.tf",
+	}, filenames)
+}
+
+func TestLayoutFilesTemplate(t *testing.T) {
+	resources, err := convertResources(strings.NewReader(splitManifests))
+	if err != nil {
+		t.Fatal("Converting to HCL failed:", err)
+	}
+
+	tmpl, err := parseFilenameTemplate(`{{with .Namespace}}{{.}}/{{end}}{{.Kind | plural}}_{{.Name | snake}}.tf`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	filenames, _, err := layoutFiles(outputLayout{template: tmpl}, resources)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"namespaces_web.tf",
+		filepath.Join("web", "deployments_nginx.tf"),
+		filepath.Join("web", "services_nginx.tf"),
+		"configmaps_settings.tf",
+	}, filenames)
+
+	tmpl, err = parseFilenameTemplate(`../{{.Name}}.tf`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = layoutFiles(outputLayout{template: tmpl}, resources)
+	assert.Error(t, err)
+
+	_, err = parseFilenameTemplate(`{{.Name`)
+	assert.Error(t, err)
+}
+
+func TestPluralKind(t *testing.T) {
+	tests := map[string]string{
+		"Deployment":    "deployments",
+		"Ingress":       "ingresses",
+		"NetworkPolicy": "networkpolicies",
+		"Gateway":       "gateways",
+		"Endpoints":     "endpoints",
+		"Mailbox":       "mailboxes",
+	}
+	for kind, expected := range tests {
+		assert.Equal(t, expected, pluralKind(kind))
+	}
+}
+
+func TestOutputLayoutValidate(t *testing.T) {
+	assert.NoError(t, outputLayout{}.validate())
+	assert.NoError(t, outputLayout{groupBy: "namespace"}.validate())
+	assert.NoError(t, outputLayout{groupBy: "kind"}.validate())
+	assert.Error(t, outputLayout{groupBy: "colour"}.validate())
+	assert.Error(t, outputLayout{maxPerFile: -1}.validate())
+	tmpl, _ := parseFilenameTemplate(`{{.Name}}.tf`)
+	assert.Error(t, outputLayout{groupBy: "kind", template: tmpl}.validate())
+}
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tfk8s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "main.tf")
+	if err := ioutil.WriteFile(path, []byte("old"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	assert.NoError(t, writeFileAtomic(path, []byte("new"), 0644))
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "new", string(b))
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	// the temporary file is cleaned up
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, files, 1)
+
+	assert.Error(t, writeFileAtomic(filepath.Join(dir, "missing", "main.tf"), []byte("new"), 0644))
+}
+
+func TestOutputRef(t *testing.T) {
+	ref, err := outputRef("-", "files", false)
+	assert.NoError(t, err)
+	assert.Equal(t, "files", ref)
+
+	ref, err = outputRef("terraform/main.tf", "terraform/files", false)
+	assert.NoError(t, err)
+	assert.Equal(t, "files", ref)
+
+	ref, err = outputRef("terraform", "files", true)
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join("..", "files"), ref)
+}
+
+func TestModulePath(t *testing.T) {
+	assert.Equal(t, "${path.module}/files/configmap_test/key", modulePath("files", "configmap_test", "key"))
+	assert.Equal(t, "${path.module}/../manifests/a.yaml", modulePath(filepath.Join("..", "manifests"), "a.yaml"))
+	assert.Equal(t, "/srv/files/key", modulePath("/srv/files", "key"))
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// outputsFile is the name of the file --with-outputs writes
+const outputsFile = "outputs.tf"
+
+// outputAttribute is an attribute of the value of a resource's output
+type outputAttribute struct {
+	name        string
+	description string
+	value       string
+}
+
+// outputAttributes returns the attributes other configurations need to
+// refer to the object r creates: its name and namespace, and the cluster IP
+// of a Service
+func outputAttributes(r resource) []outputAttribute {
+	address := r.resourceType + "." + r.name
+	// the namespace is read from the object, as --strip leaves the default
+	// namespace out of the manifest
+	name, namespace, clusterIP := address+".manifest.metadata.name", address+".object.metadata.namespace", address+".object.spec.clusterIP"
+	hasNamespace := r.namespace != ""
+	switch {
+	case r.typed:
+		name, namespace, clusterIP = address+".metadata[0].name", address+".metadata[0].namespace", address+".spec[0].cluster_ip"
+	case r.resourceType == kubectlResourceType:
+		// kubectl_manifest doesn't have the object, so there's no cluster IP,
+		// and the namespace is only known if the manifest sets it
+		name, namespace, clusterIP = address+".name", address+".namespace", ""
+		_, hasNamespace = getPath(r.manifest, "metadata", "namespace")
+	}
+
+	attributes := []outputAttribute{{"name", "name", name}}
+	if hasNamespace {
+		attributes = append(attributes, outputAttribute{"namespace", "namespace", namespace})
+	}
+	if r.kind == "Service" && clusterIP != "" {
+		attributes = append(attributes, outputAttribute{"cluster_ip", "cluster IP", clusterIP})
+	}
+	return attributes
+}
+
+// resourceOutputs returns an output for each resource with the attributes
+// from outputAttributes
+func resourceOutputs(resources []resource) string {
+	blocks := []string{}
+	for _, r := range resources {
+		attributes := outputAttributes(r)
+		width := 0
+		names := []string{}
+		for _, a := range attributes {
+			if len(a.name) > width {
+				width = len(a.name)
+			}
+			names = append(names, a.description)
+		}
+		description := names[0]
+		if len(names) > 1 {
+			description = strings.Join(names[:len(names)-1], ", ") + " and " + names[len(names)-1]
+		}
+		description = strings.ToUpper(description[:1]) + description[1:]
+
+		var buf strings.Builder
+		fmt.Fprintf(&buf, "output %q {\n", r.name)
+		fmt.Fprintf(&buf, "  description = %q\n", fmt.Sprintf("%s of the %s %s", description, r.kind, r.objectName))
+		buf.WriteString("  value       = {\n")
+		for _, a := range attributes {
+			fmt.Fprintf(&buf, "    %-*s = %s\n", width, a.name, a.value)
+		}
+		buf.WriteString("  }\n")
+		buf.WriteString("}\n")
+		blocks = append(blocks, buf.String())
+	}
+	return strings.Join(blocks, "\n")
+}
+
+// writeOutputs writes the outputs for resources to outputs.tf in dir
+func writeOutputs(dir string, resources []resource) error {
+	return writeFileAtomic(filepath.Join(dir, outputsFile), []byte(resourceOutputs(resources)), 0644)
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var outputsYAML = `apiVersion: v1
+kind: Service
+metadata:
+  name: web
+  namespace: prod
+spec:
+  ports:
+  - port: 80
+---
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: prod
+`
+
+func TestResourceOutputs(t *testing.T) {
+	resources, err := convertResources(strings.NewReader(outputsYAML))
+	if err != nil {
+		t.Fatal("Converting to HCL failed:", err)
+	}
+
+	expected := `output "service_prod_web" {
+  description = "Name, namespace and cluster IP of the Service web"
+  value       = {
+    name       = kubernetes_manifest.service_prod_web.manifest.metadata.name
+    namespace  = kubernetes_manifest.service_prod_web.object.metadata.namespace
+    cluster_ip = kubernetes_manifest.service_prod_web.object.spec.clusterIP
+  }
+}
+
+output "namespace_prod" {
+  description = "Name of the Namespace prod"
+  value       = {
+    name = kubernetes_manifest.namespace_prod.manifest.metadata.name
+  }
+}
+`
+	assert.Equal(t, expected, resourceOutputs(resources))
+}
+
+func TestResourceOutputsTypedAndKubectl(t *testing.T) {
+	resources, err := convertResources(strings.NewReader(outputsYAML), WithTyped(true))
+	if err != nil {
+		t.Fatal("Converting to HCL failed:", err)
+	}
+	output := resourceOutputs(resources)
+	assert.Contains(t, output, "    namespace  = kubernetes_service_v1.service_prod_web.metadata[0].namespace\n")
+	assert.Contains(t, output, "    cluster_ip = kubernetes_service_v1.service_prod_web.spec[0].cluster_ip\n")
+
+	resources, err = convertResources(strings.NewReader(outputsYAML), WithTarget(kubectlResourceType))
+	if err != nil {
+		t.Fatal("Converting to HCL failed:", err)
+	}
+	output = resourceOutputs(resources)
+	assert.Contains(t, output, "  description = \"Name and namespace of the Service web\"\n")
+	assert.Contains(t, output, "    name      = kubectl_manifest.service_prod_web.name\n")
+	assert.NotContains(t, output, "cluster_ip")
+}
+
+func TestResourceOutputsStripped(t *testing.T) {
+	yaml := `apiVersion: v1
+kind: Service
+metadata:
+  name: web
+  namespace: default
+`
+	resources, err := convertResources(strings.NewReader(yaml), WithStripServerSide(true))
+	if err != nil {
+		t.Fatal("Converting to HCL failed:", err)
+	}
+	// --strip leaves the default namespace out of the manifest
+	assert.Contains(t, resourceOutputs(resources), "    namespace  = kubernetes_manifest.service_web.object.metadata.namespace\n")
+
+	resources, err = convertResources(strings.NewReader(yaml), WithStripServerSide(true), WithTarget(kubectlResourceType))
+	if err != nil {
+		t.Fatal("Converting to HCL failed:", err)
+	}
+	assert.NotContains(t, resourceOutputs(resources), "namespace")
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+
+	cty "github.com/zclconf/go-cty/cty"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+)
+
+// fieldSet is the FieldsV1 tree of the fields a manager owns in
+// managedFields. Attributes are f:name, list items k:{"name":"web"} for
+// their keys or v:"value" for their value, and "." the field itself.
+type fieldSet map[string]interface{}
+
+// ownedFields returns the fields the managers own in the managedFields of
+// doc, and false if none of them manage any
+func ownedFields(doc cty.Value, managers []string) (fieldSet, bool) {
+	entries, ok := getPath(doc, "metadata", "managedFields")
+	if !ok || entries.IsNull() || !(entries.Type().IsTupleType() || entries.Type().IsListType()) {
+		return nil, false
+	}
+	owned := fieldSet{}
+	found := false
+	for _, entry := range entries.AsValueSlice() {
+		manager, _ := getString(entry, "manager")
+		fields, ok := getPath(entry, "fieldsV1")
+		if !ok || !containsString(managers, manager) {
+			continue
+		}
+		b, err := ctyjson.Marshal(fields, fields.Type())
+		if err != nil {
+			continue
+		}
+		set := fieldSet{}
+		if err := json.Unmarshal(b, &set); err != nil {
+			continue
+		}
+		owned.merge(set)
+		found = true
+	}
+	return owned, found
+}
+
+// merge adds the fields in other to s
+func (s fieldSet) merge(other fieldSet) {
+	for k, v := range other {
+		child, _ := v.(map[string]interface{})
+		if existing, ok := s[k].(map[string]interface{}); ok {
+			fieldSet(existing).merge(child)
+			continue
+		}
+		merged := fieldSet{}
+		merged.merge(child)
+		s[k] = map[string]interface{}(merged)
+	}
+}
+
+// child returns the fields owned below the step k
+func (s fieldSet) child(k string) (fieldSet, bool) {
+	v, ok := s[k]
+	if !ok {
+		return nil, false
+	}
+	child, _ := v.(map[string]interface{})
+	return fieldSet(child), true
+}
+
+// isLeaf returns true if the whole field is owned, not just some of the
+// fields in it
+func (s fieldSet) isLeaf() bool {
+	for k := range s {
+		if k != "." {
+			return false
+		}
+	}
+	return true
+}
+
+// keepOwnedFields returns doc with only the fields the managers own, so
+// the fields controllers set are removed. The apiVersion, kind, name and
+// namespace are always kept, and false is returned if none of the
+// managers manage doc.
+func keepOwnedFields(doc cty.Value, managers []string) (cty.Value, bool) {
+	owned, ok := ownedFields(doc, managers)
+	if !ok {
+		return doc, false
+	}
+	owned.merge(fieldSet{
+		"f:apiVersion": map[string]interface{}{},
+		"f:kind":       map[string]interface{}{},
+		"f:metadata": map[string]interface{}{
+			"f:name":          map[string]interface{}{},
+			"f:generateName":  map[string]interface{}{},
+			"f:namespace":     map[string]interface{}{},
+			"f:managedFields": map[string]interface{}{},
+		},
+	})
+	return filterOwned(doc, owned), true
+}
+
+// filterOwned returns v with only the fields in owned
+func filterOwned(v cty.Value, owned fieldSet) cty.Value {
+	if owned.isLeaf() || v.IsNull() || v.IsMarked() || !v.IsKnown() {
+		return v
+	}
+	ty := v.Type()
+	switch {
+	case ty.IsObjectType() || ty.IsMapType():
+		m := map[string]cty.Value{}
+		for k, item := range v.AsValueMap() {
+			if child, ok := owned.child("f:" + k); ok {
+				m[k] = filterOwned(item, child)
+			}
+		}
+		if ty.IsMapType() {
+			if len(m) == 0 {
+				return cty.MapValEmpty(ty.ElementType())
+			}
+			return cty.MapVal(m)
+		}
+		return cty.ObjectVal(m)
+	case ty.IsTupleType() || ty.IsListType():
+		items := []cty.Value{}
+		for _, item := range v.AsValueSlice() {
+			if child, ok := owned.item(item); ok {
+				items = append(items, filterOwned(item, child))
+			}
+		}
+		return cty.TupleVal(items)
+	}
+	return v
+}
+
+// item returns the fields owned in a list item, found by its keys or its
+// value
+func (s fieldSet) item(item cty.Value) (fieldSet, bool) {
+	for k := range s {
+		switch {
+		case strings.HasPrefix(k, "v:"):
+			if jsonEqual(item, k[2:]) {
+				return s.child(k)
+			}
+		case strings.HasPrefix(k, "k:"):
+			keys := map[string]json.RawMessage{}
+			if err := json.Unmarshal([]byte(k[2:]), &keys); err != nil {
+				continue
+			}
+			matches := true
+			for attr, value := range keys {
+				v, ok := getPath(item, attr)
+				matches = matches && ok && jsonEqual(v, string(value))
+			}
+			if matches {
+				return s.child(k)
+			}
+		}
+	}
+	return nil, false
+}
+
+// jsonEqual returns true if v is the JSON value s
+func jsonEqual(v cty.Value, s string) bool {
+	var want interface{}
+	if err := json.Unmarshal([]byte(s), &want); err != nil {
+		return false
+	}
+	b, err := ctyjson.Marshal(v, v.Type())
+	if err != nil {
+		return false
+	}
+	var got interface{}
+	if err := json.Unmarshal(b, &got); err != nil {
+		return false
+	}
+	wantJSON, _ := json.Marshal(want)
+	gotJSON, _ := json.Marshal(got)
+	return string(wantJSON) == string(gotJSON)
+}
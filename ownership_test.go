@@ -0,0 +1,105 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var managedYAML = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: prod
+  labels:
+    app: web
+  annotations:
+    deployment.kubernetes.io/revision: "2"
+  managedFields:
+  - manager: kubectl-client-side-apply
+    operation: Update
+    apiVersion: apps/v1
+    fieldsType: FieldsV1
+    fieldsV1:
+      f:metadata:
+        f:labels:
+          .: {}
+          f:app: {}
+      f:spec:
+        f:replicas: {}
+        f:template:
+          f:spec:
+            f:containers:
+              k:{"name":"web"}:
+                .: {}
+                f:name: {}
+                f:image: {}
+                f:args: {}
+  - manager: kube-controller-manager
+    operation: Update
+    apiVersion: apps/v1
+    fieldsType: FieldsV1
+    fieldsV1:
+      f:metadata:
+        f:annotations:
+          f:deployment.kubernetes.io/revision: {}
+      f:status:
+        f:replicas: {}
+spec:
+  replicas: 2
+  revisionHistoryLimit: 10
+  template:
+    spec:
+      containers:
+      - name: web
+        image: nginx
+        args: ["--port", "80"]
+        imagePullPolicy: IfNotPresent
+      - name: injected
+        image: envoy
+status:
+  replicas: 2
+`
+
+func TestKeepOwnedFields(t *testing.T) {
+	hcl, err := YAMLToTerraformResources(strings.NewReader(managedYAML), WithOwnedBy("kubectl-client-side-apply"), WithStripServerSide(true), WithMapOnly(true))
+	assert.NoError(t, err)
+	assert.Equal(t, `{
+  "apiVersion" = "apps/v1"
+  "kind" = "Deployment"
+  "metadata" = {
+    "labels" = {
+      "app" = "web"
+    }
+    "name" = "web"
+    "namespace" = "prod"
+  }
+  "spec" = {
+    "replicas" = 2
+    "template" = {
+      "spec" = {
+        "containers" = [
+          {
+            "args" = [
+              "--port",
+              "80",
+            ]
+            "image" = "nginx"
+            "name" = "web"
+          },
+        ]
+      }
+    }
+  }
+}
+`, hcl)
+}
+
+func TestKeepOwnedFieldsUnmanaged(t *testing.T) {
+	var warnings strings.Builder
+	hcl, err := YAMLToTerraformResources(strings.NewReader(managedYAML), WithOwnedBy("helm"), WithWarnings(&warnings), WithMapOnly(true))
+	assert.NoError(t, err)
+	assert.Contains(t, hcl, `"revisionHistoryLimit" = 10`)
+	assert.Contains(t, warnings.String(), "Deployment web has no fields managed by helm")
+}
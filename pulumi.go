@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	cty "github.com/zclconf/go-cty/cty"
+)
+
+// pulumiCustomResource is the Pulumi type used for kinds that aren't built
+// into Kubernetes, like the ones that come from CRDs
+const pulumiCustomResource = "kubernetes:apiextensions.k8s.io:CustomResource"
+
+// pulumiType returns the Pulumi Kubernetes type token for a kind, like
+// kubernetes:apps/v1:Deployment or kubernetes:networking.k8s.io/v1:Ingress
+func pulumiType(apiVersion, kind string) string {
+	group, version := "core", apiVersion
+	if i := strings.Index(apiVersion, "/"); i >= 0 {
+		group, version = apiVersion[:i], apiVersion[i+1:]
+	}
+	if strings.Contains(group, ".") && !strings.HasSuffix(group, ".k8s.io") {
+		return pulumiCustomResource
+	}
+	return fmt.Sprintf("kubernetes:%s/%s:%s", group, version, kind)
+}
+
+// pulumiAlias returns the name of the Pulumi provider resource for a
+// --provider alias, so kubernetes.staging becomes staging
+func pulumiAlias(alias string) string {
+	if i := strings.LastIndex(alias, "."); i >= 0 {
+		return alias[i+1:]
+	}
+	return alias
+}
+
+// pulumiEscaper escapes the interpolations Pulumi YAML would otherwise
+// evaluate in strings
+var pulumiEscaper = strings.NewReplacer("${", "$${")
+
+// pulumiYAMLFormat writes the resources of a Pulumi YAML program, using
+// the typed Pulumi Kubernetes resource for each kind. The text of each
+// resource is its entry in the resources section.
+type pulumiYAMLFormat struct{}
+
+func (pulumiYAMLFormat) resource(r resource, o options) (string, error) {
+	if o.mapOnly {
+		return "", fmt.Errorf("--map-only can't be used with --format pulumi-yaml")
+	}
+	if r.resourceType != resourceType {
+		return "", fmt.Errorf("--target %s can't be used with --format pulumi-yaml", r.resourceType)
+	}
+
+	m := r.manifest.AsValueMap()
+	apiVersion := m["apiVersion"].AsString()
+	typ := pulumiType(apiVersion, r.kind)
+	if typ != pulumiCustomResource {
+		// the typed resources set these themselves
+		delete(m, "apiVersion")
+		delete(m, "kind")
+	}
+	properties, err := yamlNode(cty.ObjectVal(m), yamlEncoding{
+		flag:   "--format pulumi-yaml",
+		escape: pulumiEscaper.Replace,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	body := mappingNode(
+		scalarNode("!!str", "type"), scalarNode("!!str", typ),
+		scalarNode("!!str", "properties"), properties,
+	)
+	if o.providerAlias != "" {
+		body.Content = append(body.Content,
+			scalarNode("!!str", "options"), mappingNode(
+				scalarNode("!!str", "provider"), scalarNode("!!str", "${"+pulumiAlias(o.providerAlias)+"}"),
+			),
+		)
+	}
+	return encodeYAML(mappingNode(scalarNode("!!str", r.name), body))
+}
+
+func (pulumiYAMLFormat) join(resources []resource) (string, error) {
+	var buf strings.Builder
+	buf.WriteString("resources:\n")
+	for _, r := range resources {
+		for _, line := range strings.SplitAfter(r.text, "\n") {
+			if strings.TrimSpace(line) != "" {
+				// blank lines in block scalars are left without indentation
+				buf.WriteString("  ")
+			}
+			buf.WriteString(line)
+		}
+	}
+	return buf.String(), nil
+}
+
+func (pulumiYAMLFormat) extension() string {
+	return ".yaml"
+}
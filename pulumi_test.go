@@ -0,0 +1,78 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestYAMLToTerraformResourcesPulumiYAML(t *testing.T) {
+	yaml := `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: test
+data:
+  TEMPLATE: "${HOME}"
+  ENABLED: "true"
+  SCRIPT: |
+    echo hello
+
+    echo world
+---
+apiVersion: cert-manager.io/v1
+kind: Certificate
+metadata:
+  name: web
+spec:
+  dnsNames:
+  - example.com
+`
+
+	output, err := YAMLToTerraformResources(strings.NewReader(yaml), WithFormat("pulumi-yaml"), WithProviderAlias("kubernetes.staging"))
+	if err != nil {
+		t.Fatal("Converting to Pulumi YAML failed:", err)
+	}
+
+	expected := `resources:
+  configmap_test:
+    type: kubernetes:core/v1:ConfigMap
+    properties:
+      data:
+        ENABLED: "true"
+        SCRIPT: |
+          echo hello
+
+          echo world
+        TEMPLATE: $${HOME}
+      metadata:
+        name: test
+    options:
+      provider: ${staging}
+  certificate_web:
+    type: kubernetes:apiextensions.k8s.io:CustomResource
+    properties:
+      apiVersion: cert-manager.io/v1
+      kind: Certificate
+      metadata:
+        name: web
+      spec:
+        dnsNames:
+          - example.com
+    options:
+      provider: ${staging}
+`
+	assert.Equal(t, expected, output)
+}
+
+func TestPulumiType(t *testing.T) {
+	tests := map[[2]string]string{
+		{"v1", "Service"}:                       "kubernetes:core/v1:Service",
+		{"apps/v1", "Deployment"}:               "kubernetes:apps/v1:Deployment",
+		{"networking.k8s.io/v1", "Ingress"}:     "kubernetes:networking.k8s.io/v1:Ingress",
+		{"monitoring.coreos.com/v1", "Monitor"}: pulumiCustomResource,
+	}
+	for in, expected := range tests {
+		assert.Equal(t, expected, pulumiType(in[0], in[1]))
+	}
+}
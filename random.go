@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	cty "github.com/zclconf/go-cty/cty"
+
+	"github.com/jrhouston/tfk8s/contrib/hashicorp/terraform"
+)
+
+// randomPasswordType is the resource --secrets-random generates the values
+// of Secrets with
+const randomPasswordType = "random_password"
+
+// randomPasswordLength is the length of the passwords --secrets-random
+// generates
+const randomPasswordLength = 32
+
+// randomKey is a key of a Secret --secrets-random generates the value of,
+// in the Secret called secret or every Secret if secret is empty
+type randomKey struct {
+	secret string
+	key    string
+}
+
+// parseRandomKey parses a key like password or db:password
+func parseRandomKey(s string) (randomKey, error) {
+	k := randomKey{key: s}
+	if i := strings.Index(s, ":"); i >= 0 {
+		k.secret, k.key = s[:i], s[i+1:]
+	}
+	if k.key == "" {
+		return randomKey{}, fmt.Errorf("invalid --secrets-random %q, must be like password or db:password", s)
+	}
+	return k, nil
+}
+
+// isRandomKey returns true if the value of key in the Secret name is one of
+// the keys to generate
+func isRandomKey(keys []randomKey, name, key string) bool {
+	for _, k := range keys {
+		if k.key == key && (k.secret == "" || k.secret == name) {
+			return true
+		}
+	}
+	return false
+}
+
+// randomPasswords replaces the values of keys in the data and stringData of
+// the Secret doc with the result of a random_password, and returns the
+// labels of the random_passwords it needs. The data values are encoded with
+// base64encode().
+func randomPasswords(doc cty.Value, name, resourceName string, keys []randomKey) (cty.Value, []string) {
+	passwords := []string{}
+	doc = replaceSecretValues(doc, func(field, k string, _ cty.Value, _ bool) (cty.Value, bool) {
+		if !isRandomKey(keys, name, k) {
+			return cty.NilVal, false
+		}
+		label := snakify(resourceName + "_" + k)
+		if !containsString(passwords, label) {
+			passwords = append(passwords, label)
+		}
+		ref := fmt.Sprintf("%s.%s.result", randomPasswordType, label)
+		if field == "data" {
+			ref = fmt.Sprintf("base64encode(%s)", ref)
+		}
+		return cty.StringVal(ref).Mark(terraform.Expression), true
+	})
+	return doc, passwords
+}
+
+// randomPasswordBlocks returns the random_passwords r uses as resources of
+// their own, so --append finds them by their address like any other resource
+func randomPasswordBlocks(r resource) []resource {
+	blocks := []resource{}
+	for _, label := range r.randomPasswords {
+		var buf strings.Builder
+		fmt.Fprintf(&buf, "resource %q %q {\n", randomPasswordType, label)
+		fmt.Fprintf(&buf, "  length  = %d\n", randomPasswordLength)
+		buf.WriteString("  special = false\n")
+		buf.WriteString("}\n")
+		blocks = append(blocks, resource{resourceType: randomPasswordType, name: label, text: buf.String()})
+	}
+	return blocks
+}
+
+// withRandomPasswords returns resources with the random_passwords each of
+// them uses before it, for Terraform JSON
+func withRandomPasswords(resources []resource) ([]resource, error) {
+	all := []resource{}
+	for _, r := range resources {
+		for _, label := range r.randomPasswords {
+			body, err := marshalJSON(map[string]interface{}{
+				"length":  randomPasswordLength,
+				"special": false,
+			})
+			if err != nil {
+				return nil, err
+			}
+			all = append(all, resource{resourceType: randomPasswordType, name: label, text: body})
+		}
+		all = append(all, r)
+	}
+	return all, nil
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRandomPasswords(t *testing.T) {
+	yaml := `apiVersion: v1
+kind: Secret
+metadata:
+  name: db
+data:
+  password: aHVudGVyMg==
+  user: YWRtaW4=
+stringData:
+  token: abc
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: api
+stringData:
+  token: def
+`
+	output, err := YAMLToTerraformResources(strings.NewReader(yaml), WithRandomPasswords(
+		randomKey{key: "password"},
+		randomKey{secret: "api", key: "token"},
+	))
+	if err != nil {
+		t.Fatal("Converting to HCL failed:", err)
+	}
+
+	expected := `
+resource "random_password" "secret_db_password" {
+  length  = 32
+  special = false
+}
+
+resource "kubernetes_manifest" "secret_db" {
+  manifest = {
+    "apiVersion" = "v1"
+    "data" = {
+      "password" = base64encode(random_password.secret_db_password.result)
+      "user" = "YWRtaW4="
+    }
+    "kind" = "Secret"
+    "metadata" = {
+      "name" = "db"
+    }
+    "stringData" = {
+      "token" = "abc"
+    }
+  }
+}
+
+resource "random_password" "secret_api_token" {
+  length  = 32
+  special = false
+}
+
+resource "kubernetes_manifest" "secret_api" {
+  manifest = {
+    "apiVersion" = "v1"
+    "kind" = "Secret"
+    "metadata" = {
+      "name" = "api"
+    }
+    "stringData" = {
+      "token" = random_password.secret_api_token.result
+    }
+  }
+}`
+
+	assert.Equal(t, strings.TrimSpace(expected), strings.TrimSpace(output))
+}
+
+func TestRandomPasswordsJSON(t *testing.T) {
+	output, err := YAMLToTerraformResources(strings.NewReader(`apiVersion: v1
+kind: Secret
+metadata:
+  name: db
+stringData:
+  password: hunter2
+`), WithRandomPasswords(randomKey{key: "password"}), WithFormat("tfjson"))
+	if err != nil {
+		t.Fatal("Converting to JSON failed:", err)
+	}
+
+	assert.Contains(t, output, `"random_password": {
+      "secret_db_password": {
+        "length": 32,
+        "special": false
+      }
+    }`)
+	assert.Contains(t, output, `"password": "${random_password.secret_db_password.result}"`)
+}
+
+func TestRandomPasswordsAppend(t *testing.T) {
+	resources, err := convertResources(strings.NewReader(`apiVersion: v1
+kind: Secret
+metadata:
+  name: db
+stringData:
+  password: hunter2
+`), WithRandomPasswords(randomKey{key: "password"}))
+	if err != nil {
+		t.Fatal("Converting to HCL failed:", err)
+	}
+
+	merged, _, err := mergeResources("", withSecretBlocks(resources), false)
+	assert.NoError(t, err)
+	merged, skipped, err := mergeResources(merged, withSecretBlocks(resources), false)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"random_password.secret_db_password", "kubernetes_manifest.secret_db"}, skipped)
+	merged, _, err = mergeResources(merged, withSecretBlocks(resources), true)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, strings.Count(merged, `resource "random_password" "secret_db_password"`))
+	assert.Equal(t, 1, strings.Count(merged, `resource "kubernetes_manifest" "secret_db"`))
+}
+
+func TestParseRandomKey(t *testing.T) {
+	k, err := parseRandomKey("db:password")
+	assert.NoError(t, err)
+	assert.Equal(t, randomKey{secret: "db", key: "password"}, k)
+	k, err = parseRandomKey("password")
+	assert.NoError(t, err)
+	assert.Equal(t, randomKey{key: "password"}, k)
+	_, err = parseRandomKey("db:")
+	assert.Error(t, err)
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"github.com/jrhouston/tfk8s/contrib/hashicorp/terraform"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// nameReference returns the expression for the name of the object r
+// creates
+func nameReference(r resource) string {
+	address := r.resourceType + "." + r.name
+	switch {
+	case r.typed:
+		return address + ".metadata[0].name"
+	case r.resourceType == kubectlResourceType:
+		return address + ".name"
+	}
+	return address + ".manifest.metadata.name"
+}
+
+// referenceNames replaces the names of the ConfigMaps, Secrets and
+// ServiceAccounts workloads refer to with references to the resources for
+// them, when they are converted in the same run, so Terraform knows to
+// create them first. Resources are only referred to in the same module.
+// The resources are converted again with opts.
+func referenceNames(resources []resource, opts ...Option) ([]resource, error) {
+	o := newOptions(opts)
+	format, err := lookupFormat(o.format)
+	if err != nil {
+		return nil, err
+	}
+
+	type object struct {
+		module, kind, namespace, name string
+	}
+	references := map[object]string{}
+	for _, r := range resources {
+		switch r.kind {
+		case "ConfigMap", "Secret", "ServiceAccount":
+			if r.forEach.IsNull() {
+				references[object{r.module, r.kind, o.namespaceOrDefault(r.namespace), r.objectName}] = nameReference(r)
+			}
+		}
+	}
+
+	for i, r := range resources {
+		path, ok := podSpecPaths[r.kind]
+		if !ok {
+			continue
+		}
+		spec, ok := getPath(r.manifest, path...)
+		if !ok {
+			continue
+		}
+		changed := false
+		spec = rewritePodReferences(spec, func(kind, name string) cty.Value {
+			if ref, ok := references[object{r.module, kind, o.namespaceOrDefault(r.namespace), name}]; ok {
+				changed = true
+				return cty.StringVal(ref).Mark(terraform.Expression)
+			}
+			return cty.StringVal(name)
+		})
+		if !changed {
+			continue
+		}
+
+		r.manifest, err = cty.Transform(r.manifest, func(p cty.Path, v cty.Value) (cty.Value, error) {
+			if len(p) == len(path) && p.Equals(attrPath(path)) {
+				return spec, nil
+			}
+			return v, nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		// the source no longer matches the manifest
+		r.source = ""
+		if r.text, err = format.resource(r, o); err != nil {
+			return nil, err
+		}
+		resources[i] = r
+	}
+	return resources, nil
+}
+
+// attrPath returns the path of the attributes in path
+func attrPath(path []string) cty.Path {
+	p := cty.Path{}
+	for _, attr := range path {
+		p = p.GetAttr(attr)
+	}
+	return p
+}
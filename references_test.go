@@ -0,0 +1,50 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReferenceNames(t *testing.T) {
+	yaml := dependsYAML + `---
+apiVersion: v1
+kind: ServiceAccount
+metadata:
+  name: backup
+  namespace: web
+---
+apiVersion: v1
+kind: Pod
+metadata:
+  name: test
+spec:
+  serviceAccountName: backup
+  imagePullSecrets:
+  - name: creds
+`
+	resources, err := convertResources(strings.NewReader(yaml))
+	if err != nil {
+		t.Fatal("Converting to HCL failed:", err)
+	}
+	resources, err = referenceNames(resources)
+	assert.NoError(t, err)
+
+	cronJob := resources[6].text
+	assert.Contains(t, cronJob, `"name" = kubernetes_manifest.secret_web_creds.manifest.metadata.name`)
+	assert.Contains(t, cronJob, `"name" = kubernetes_manifest.configmap_web_config.manifest.metadata.name`)
+	// the Secret isn't converted, and the ServiceAccount is only referred
+	// to in the same namespace
+	assert.Contains(t, cronJob, `"secretName" = "missing"`)
+	assert.Contains(t, resources[8].text, `"name" = kubernetes_manifest.secret_creds.manifest.metadata.name`)
+	assert.Contains(t, resources[8].text, `"serviceAccountName" = "backup"`)
+
+	resources, err = convertResources(strings.NewReader(yaml), WithTyped(true))
+	if err != nil {
+		t.Fatal("Converting to HCL failed:", err)
+	}
+	resources, err = referenceNames(resources)
+	assert.NoError(t, err)
+	assert.Contains(t, resources[6].text, "name = kubernetes_secret_v1.secret_web_creds.metadata[0].name\n")
+}
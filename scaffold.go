@@ -0,0 +1,227 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// requiredProvider is an entry in the required_providers block
+type requiredProvider struct {
+	name    string
+	source  string
+	version string
+}
+
+var (
+	// kubernetesProvider is the first version of the Kubernetes provider
+	// where kubernetes_manifest is no longer experimental
+	kubernetesProvider = requiredProvider{"kubernetes", "hashicorp/kubernetes", ">= 2.7.0"}
+	// kubernetesTypedProvider is the first version of the Kubernetes
+	// provider with all the typed resources --typed generates
+	kubernetesTypedProvider = requiredProvider{"kubernetes", "hashicorp/kubernetes", ">= 2.16.0"}
+	// kubernetesFunctionsProvider is the first version of the Kubernetes
+	// provider with the manifest_decode_multi function --decode-multi uses
+	kubernetesFunctionsProvider = requiredProvider{"kubernetes", "hashicorp/kubernetes", ">= 2.28.0"}
+	// kubectlProvider is the provider for kubectl_manifest
+	kubectlProvider = requiredProvider{"kubectl", "gavinbunney/kubectl", ">= 1.7.0"}
+)
+
+const (
+	// terraformVersion is the first version of Terraform that supports
+	// kubernetes_manifest
+	terraformVersion = ">= 0.14.8"
+	// terraformImportVersion is the first version of Terraform with
+	// import blocks
+	terraformImportVersion = ">= 1.5.0"
+	// terraformFunctionsVersion is the first version of Terraform that
+	// can call provider functions
+	terraformFunctionsVersion = ">= 1.8.0"
+)
+
+// versionsFile is the name of the file --init-scaffold writes
+const versionsFile = "versions"
+
+// backends are the backends --backend supports
+var backends = []string{"gcs", "local", "s3"}
+
+// backendSetting is an argument of a backend block
+type backendSetting struct {
+	name  string
+	value string
+}
+
+// backendConfig is the backend block of the scaffold
+type backendConfig struct {
+	name     string
+	settings []backendSetting
+}
+
+// newBackend returns the backend block for --backend. key is the key of
+// the state in the s3 bucket, the prefix in the gcs bucket, or the path of
+// the local state file.
+func newBackend(name, bucket, key, region string) (*backendConfig, error) {
+	b := &backendConfig{name: name}
+	switch name {
+	case "s3":
+		if bucket == "" || region == "" {
+			return nil, fmt.Errorf("--backend s3 requires --backend-bucket and --backend-region")
+		}
+		if key == "" {
+			key = "terraform.tfstate"
+		}
+		b.settings = []backendSetting{{"bucket", bucket}, {"key", key}, {"region", region}}
+	case "gcs":
+		if bucket == "" {
+			return nil, fmt.Errorf("--backend gcs requires --backend-bucket")
+		}
+		if region != "" {
+			return nil, fmt.Errorf("--backend-region can only be used with --backend s3")
+		}
+		b.settings = []backendSetting{{"bucket", bucket}}
+		if key != "" {
+			b.settings = append(b.settings, backendSetting{"prefix", key})
+		}
+	case "local":
+		if bucket != "" || region != "" {
+			return nil, fmt.Errorf("--backend-bucket and --backend-region can't be used with --backend local")
+		}
+		if key != "" {
+			b.settings = []backendSetting{{"path", key}}
+		}
+	default:
+		return nil, fmt.Errorf("unknown --backend %q, must be one of %s", name, strings.Join(backends, ", "))
+	}
+	return b, nil
+}
+
+// scaffold is the terraform block --init-scaffold writes next to the
+// resources, so the directory can be initialized straight away
+type scaffold struct {
+	requiredVersion string
+	providers       []requiredProvider
+	backend         *backendConfig
+}
+
+// newScaffold returns the terraform block for the Terraform and provider
+// versions resources need
+func newScaffold(resources []resource) scaffold {
+	s := scaffold{requiredVersion: terraformVersion}
+	kubernetes, kubectl := false, false
+	typed, functions := false, false
+	for _, r := range resources {
+		if r.importID != "" && s.requiredVersion == terraformVersion {
+			s.requiredVersion = terraformImportVersion
+		}
+		if r.providerFunctions {
+			s.requiredVersion, functions = terraformFunctionsVersion, true
+		}
+		switch {
+		case r.resourceType == kubectlResourceType:
+			kubectl = true
+		case r.typed:
+			kubernetes, typed = true, true
+		default:
+			kubernetes = true
+		}
+	}
+	if kubernetes || !kubectl {
+		if functions {
+			s.providers = append(s.providers, kubernetesFunctionsProvider)
+		} else if typed {
+			s.providers = append(s.providers, kubernetesTypedProvider)
+		} else {
+			s.providers = append(s.providers, kubernetesProvider)
+		}
+	}
+	if kubectl {
+		s.providers = append(s.providers, kubectlProvider)
+	}
+	return s
+}
+
+// hcl returns the terraform block in the Terraform language
+func (s scaffold) hcl() string {
+	var buf strings.Builder
+	buf.WriteString("terraform {\n")
+	fmt.Fprintf(&buf, "  required_version = %q\n\n", s.requiredVersion)
+	buf.WriteString("  required_providers {\n")
+	for _, p := range s.providers {
+		fmt.Fprintf(&buf, "    %s = {\n", p.name)
+		fmt.Fprintf(&buf, "      source  = %q\n", p.source)
+		fmt.Fprintf(&buf, "      version = %q\n", p.version)
+		buf.WriteString("    }\n")
+	}
+	buf.WriteString("  }\n")
+	if s.backend != nil {
+		// the values are aligned like terraform fmt does
+		width := 0
+		for _, setting := range s.backend.settings {
+			if len(setting.name) > width {
+				width = len(setting.name)
+			}
+		}
+		fmt.Fprintf(&buf, "\n  backend %q {", s.backend.name)
+		if len(s.backend.settings) > 0 {
+			buf.WriteString("\n")
+			for _, setting := range s.backend.settings {
+				fmt.Fprintf(&buf, "    %-*s = %q\n", width, setting.name, setting.value)
+			}
+			buf.WriteString("  ")
+		}
+		buf.WriteString("}\n")
+	}
+	buf.WriteString("}\n")
+	return buf.String()
+}
+
+// json returns the terraform block in Terraform's JSON syntax
+func (s scaffold) json() (string, error) {
+	providers := map[string]interface{}{}
+	for _, p := range s.providers {
+		providers[p.name] = map[string]string{
+			"source":  p.source,
+			"version": p.version,
+		}
+	}
+	terraform := map[string]interface{}{
+		"required_version":   s.requiredVersion,
+		"required_providers": providers,
+	}
+	if s.backend != nil {
+		settings := map[string]string{}
+		for _, setting := range s.backend.settings {
+			settings[setting.name] = setting.value
+		}
+		terraform["backend"] = map[string]interface{}{s.backend.name: settings}
+	}
+	return marshalJSON(map[string]interface{}{"terraform": terraform})
+}
+
+// writeScaffold writes the terraform block for resources to dir, with
+// backend if it isn't nil. A file that is already there is left alone, and
+// false is returned.
+func writeScaffold(dir string, resources []resource, backend *backendConfig, json bool) (string, bool, error) {
+	s := newScaffold(resources)
+	s.backend = backend
+	filename := filepath.Join(dir, versionsFile+".tf")
+	text := s.hcl()
+	if json {
+		filename += ".json"
+		var err error
+		if text, err = s.json(); err != nil {
+			return "", false, err
+		}
+	}
+
+	if _, err := os.Stat(filename); err == nil {
+		return filename, false, nil
+	} else if !os.IsNotExist(err) {
+		return "", false, err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", false, err
+	}
+	return filename, true, writeFileAtomic(filename, []byte(text), 0644)
+}
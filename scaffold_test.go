@@ -0,0 +1,152 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScaffold(t *testing.T) {
+	yaml := `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: test
+---
+apiVersion: example.com/v1
+kind: Widget
+metadata:
+  name: test
+`
+	resources, err := convertResources(strings.NewReader(yaml))
+	if err != nil {
+		t.Fatal("Converting to HCL failed:", err)
+	}
+	expected := `terraform {
+  required_version = ">= 0.14.8"
+
+  required_providers {
+    kubernetes = {
+      source  = "hashicorp/kubernetes"
+      version = ">= 2.7.0"
+    }
+  }
+}
+`
+	assert.Equal(t, expected, newScaffold(resources).hcl())
+
+	// import blocks need a newer Terraform, and the typed resources a
+	// newer provider
+	resources, err = convertResources(strings.NewReader(yaml), WithTyped(true), WithGenerateImports(true))
+	if err != nil {
+		t.Fatal("Converting to HCL failed:", err)
+	}
+	s := newScaffold(resources)
+	assert.Equal(t, terraformImportVersion, s.requiredVersion)
+	assert.Equal(t, []requiredProvider{kubernetesTypedProvider}, s.providers)
+
+	resources, err = convertResources(strings.NewReader(yaml), WithTarget(kubectlResourceType))
+	if err != nil {
+		t.Fatal("Converting to HCL failed:", err)
+	}
+	assert.Equal(t, []requiredProvider{kubectlProvider}, newScaffold(resources).providers)
+}
+
+func TestWriteScaffold(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tfk8s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	filename, written, err := writeScaffold(dir, nil, nil, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, written)
+	assert.Equal(t, filepath.Join(dir, "versions.tf.json"), filename)
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, `{
+  "terraform": {
+    "required_providers": {
+      "kubernetes": {
+        "source": "hashicorp/kubernetes",
+        "version": ">= 2.7.0"
+      }
+    },
+    "required_version": ">= 0.14.8"
+  }
+}
+`, string(b))
+
+	// a file that is already there is left alone
+	if err := ioutil.WriteFile(filepath.Join(dir, "versions.tf"), []byte("# mine\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, written, err = writeScaffold(dir, nil, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.False(t, written)
+	b, _ = ioutil.ReadFile(filepath.Join(dir, "versions.tf"))
+	assert.Equal(t, "# mine\n", string(b))
+}
+
+func TestScaffoldBackend(t *testing.T) {
+	b, err := newBackend("s3", "state", "", "eu-west-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := newScaffold(nil)
+	s.backend = b
+	expected := `terraform {
+  required_version = ">= 0.14.8"
+
+  required_providers {
+    kubernetes = {
+      source  = "hashicorp/kubernetes"
+      version = ">= 2.7.0"
+    }
+  }
+
+  backend "s3" {
+    bucket = "state"
+    key    = "terraform.tfstate"
+    region = "eu-west-1"
+  }
+}
+`
+	assert.Equal(t, expected, s.hcl())
+
+	s.backend, err = newBackend("local", "", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Contains(t, s.hcl(), "\n  backend \"local\" {}\n}\n")
+
+	s.backend, err = newBackend("gcs", "state", "k8s", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	text, err := s.json()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Contains(t, text, `"backend": {
+      "gcs": {
+        "bucket": "state",
+        "prefix": "k8s"
+      }
+    },`)
+
+	_, err = newBackend("s3", "state", "", "")
+	assert.EqualError(t, err, "--backend s3 requires --backend-bucket and --backend-region")
+	_, err = newBackend("azurerm", "", "", "")
+	assert.EqualError(t, err, `unknown --backend "azurerm", must be one of gcs, local, s3`)
+}
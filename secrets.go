@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+	"unicode/utf8"
+
+	cty "github.com/zclconf/go-cty/cty"
+
+	"github.com/jrhouston/tfk8s/contrib/hashicorp/terraform"
+)
+
+// defaultSecretPath is the path the values of a Secret are kept at in an
+// external secret store by default
+const defaultSecretPath = "{{.Namespace}}/{{.Name}}"
+
+// redactedValue is what --redact-secrets replaces the values of Secrets with
+const redactedValue = "REDACTED"
+
+// secretFields are the fields of a Secret that hold its values
+var secretFields = []string{"data", "stringData"}
+
+// lastAppliedConfiguration is the annotation kubectl apply keeps a copy of
+// the manifest in, values and all
+var lastAppliedConfiguration = mustParseStripFields([]string{
+	`metadata.annotations."kubectl.kubernetes.io/last-applied-configuration"`,
+})
+
+// redactSecret replaces the values in the data and stringData of the Secret
+// doc with a placeholder, so the config can be committed without them. The
+// copy kubectl apply keeps in the annotations is removed.
+func redactSecret(doc cty.Value) cty.Value {
+	doc = removeFields(doc, "Secret", lastAppliedConfiguration)
+	m := doc.AsValueMap()
+	for _, field := range secretFields {
+		data, ok := m[field]
+		if !ok || data.IsNull() || data.IsMarked() || !(data.Type().IsObjectType() || data.Type().IsMapType()) || data.LengthInt() == 0 {
+			continue
+		}
+		values := data.AsValueMap()
+		for k := range values {
+			values[k] = cty.StringVal(redactedValue)
+		}
+		m[field] = cty.ObjectVal(values)
+	}
+	return cty.ObjectVal(m)
+}
+
+// decodeSecret moves the values in the data of the Secret doc that are
+// text to its stringData, decoded. Values that are already in stringData
+// are left alone, as stringData takes precedence over data.
+func decodeSecret(doc cty.Value) cty.Value {
+	m := doc.AsValueMap()
+	data, ok := m["data"]
+	if !ok || data.IsNull() || data.IsMarked() || !(data.Type().IsObjectType() || data.Type().IsMapType()) || data.LengthInt() == 0 {
+		return doc
+	}
+	var stringData map[string]cty.Value
+	if v, ok := m["stringData"]; ok && !v.IsNull() && !v.IsMarked() && (v.Type().IsObjectType() || v.Type().IsMapType()) {
+		stringData = v.AsValueMap()
+	}
+	if stringData == nil {
+		stringData = map[string]cty.Value{}
+	}
+
+	values := data.AsValueMap()
+	for k, v := range values {
+		if _, ok := stringData[k]; ok || v.IsNull() || v.IsMarked() || v.Type() != cty.String {
+			continue
+		}
+		decoded, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(v.AsString()), ""))
+		if err != nil || !utf8.Valid(decoded) {
+			continue
+		}
+		stringData[k] = cty.StringVal(string(decoded))
+		delete(values, k)
+	}
+	if len(stringData) == 0 {
+		return doc
+	}
+	if len(values) == 0 {
+		delete(m, "data")
+	} else {
+		m["data"] = cty.ObjectVal(values)
+	}
+	m["stringData"] = cty.ObjectVal(stringData)
+	return cty.ObjectVal(m)
+}
+
+// replaceSecretValues replaces each string value in the data and
+// stringData of the Secret doc with what replace returns, in order, unless
+// it returns false. The data values are decoded when they are text, binary
+// is set when they aren't and value is still base64 encoded. If any value
+// is replaced the copy kubectl apply keeps in the annotations is removed,
+// otherwise doc is returned as it is.
+func replaceSecretValues(doc cty.Value, replace func(field, key string, value cty.Value, binary bool) (cty.Value, bool)) cty.Value {
+	manifest := doc.AsValueMap()
+	replaced := false
+	for _, field := range secretFields {
+		data, ok := manifest[field]
+		if !ok || data.IsNull() || data.IsMarked() || !(data.Type().IsObjectType() || data.Type().IsMapType()) || data.LengthInt() == 0 {
+			continue
+		}
+		values := data.AsValueMap()
+		keys := []string{}
+		for k := range values {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			v := values[k]
+			if v.IsNull() || v.IsMarked() || v.Type() != cty.String {
+				continue
+			}
+			value, binary := v, false
+			if field == "data" {
+				decoded, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(v.AsString()), ""))
+				if binary = err != nil || !utf8.Valid(decoded); !binary {
+					value = cty.StringVal(string(decoded))
+				}
+			}
+			if r, ok := replace(field, k, value, binary); ok {
+				values[k] = r
+				replaced = true
+			}
+		}
+		manifest[field] = cty.ObjectVal(values)
+	}
+	if !replaced {
+		return doc
+	}
+	return removeFields(cty.ObjectVal(manifest), "Secret", lastAppliedConfiguration)
+}
+
+// secretVariables replaces the values in the data and stringData of the
+// Secret doc with sensitive variables. The data values are decoded when
+// they are text, and encoded again with base64encode().
+func (m *moduleVariables) secretVariables(doc cty.Value, name, resourceName string) cty.Value {
+	return replaceSecretValues(doc, func(field, k string, v cty.Value, binary bool) (cty.Value, bool) {
+		path := cty.GetAttrPath(field).Index(cty.StringVal(k))
+		description := fmt.Sprintf("Value of %s in the Secret %s", k, name)
+		if binary {
+			description += ", base64 encoded"
+		}
+		variable := m.addSensitive("secret_"+name+"_"+k, description, v, resourceName, path)
+		if field == "data" && !binary {
+			variable = fmt.Sprintf("base64encode(%s)", variable)
+		}
+		return cty.StringVal(variable).Mark(terraform.Expression), true
+	})
+}
+
+// parseSecretPath parses the template flag gives for the path of a Secret
+// in an external secret store, it is executed with the same data as
+// --filename-template
+func parseSecretPath(flag, text string) (*template.Template, error) {
+	t, err := template.New("path").Funcs(filenameFuncs).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %s", flag, err)
+	}
+	return t, nil
+}
+
+// secretPath executes the path template t for the Secret name, the path
+// must not be empty
+func secretPath(t *template.Template, flag, namespace, name, resourceName string) (string, error) {
+	var buf bytes.Buffer
+	err := t.Execute(&buf, filenameData{
+		Namespace: namespace,
+		Kind:      "Secret",
+		Name:      name,
+		Resource:  resourceName,
+	})
+	if err != nil {
+		return "", err
+	}
+	path := strings.Trim(strings.TrimSpace(buf.String()), "/")
+	if path == "" {
+		return "", fmt.Errorf("%s gave %q for the Secret %s", flag, buf.String(), name)
+	}
+	return path, nil
+}
+
+// addSensitive adds a sensitive string variable like add
+func (m *moduleVariables) addSensitive(name, description string, value cty.Value, resourceName string, path cty.Path) string {
+	expr := m.add(name, description, "string", value, resourceName, path)
+	m.variables[len(m.variables)-1].sensitive = true
+	return expr
+}
+
+// writeSecretTFVars writes a tfvars file that sets the sensitive variables
+// to filename, and adds it to the .gitignore next to it so the values
+// aren't committed
+func writeSecretTFVars(filename string, variables *moduleVariables) error {
+	if err := writeFileAtomic(filename, []byte(variables.secretTFVars()), 0600); err != nil {
+		return err
+	}
+	return gitignore(filename)
+}
+
+// gitignore adds filename to the .gitignore in its directory, unless it is
+// already there
+func gitignore(filename string) error {
+	ignore := filepath.Join(filepath.Dir(filename), ".gitignore")
+	pattern := filepath.Base(filename)
+	b, err := ioutil.ReadFile(ignore)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	text := string(b)
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.TrimSpace(line); line == pattern || line == "/"+pattern {
+			return nil
+		}
+	}
+	if text != "" && !strings.HasSuffix(text, "\n") {
+		text += "\n"
+	}
+	return writeFileAtomic(ignore, []byte(text+pattern+"\n"), 0644)
+}
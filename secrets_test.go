@@ -0,0 +1,232 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var secretYAML = `apiVersion: v1
+kind: Secret
+metadata:
+  name: db
+  annotations:
+    kubectl.kubernetes.io/last-applied-configuration: |
+      {"apiVersion":"v1","kind":"Secret","data":{"password":"aHVudGVyMg=="}}
+    team: data
+type: Opaque
+data:
+  password: aHVudGVyMg==
+stringData:
+  user: admin
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+data:
+  user: admin
+`
+
+func TestRedactSecrets(t *testing.T) {
+	hcl, err := YAMLToTerraformResources(strings.NewReader(secretYAML), WithRedactSecrets(true), WithMapOnly(true))
+	assert.NoError(t, err)
+	assert.Equal(t, `{
+  "apiVersion" = "v1"
+  "data" = {
+    "password" = "REDACTED"
+  }
+  "kind" = "Secret"
+  "metadata" = {
+    "annotations" = {
+      "team" = "data"
+    }
+    "name" = "db"
+  }
+  "stringData" = {
+    "user" = "REDACTED"
+  }
+  "type" = "Opaque"
+}
+
+{
+  "apiVersion" = "v1"
+  "data" = {
+    "user" = "admin"
+  }
+  "kind" = "ConfigMap"
+  "metadata" = {
+    "name" = "config"
+  }
+}
+`, hcl)
+
+	// the source isn't kept, so the values aren't written with --format yaml
+	yaml, err := YAMLToTerraformResources(strings.NewReader(secretYAML), WithRedactSecrets(true), WithFormat("yaml"))
+	assert.NoError(t, err)
+	assert.NotContains(t, yaml, "aHVudGVyMg==")
+}
+
+func TestSecretVariables(t *testing.T) {
+	variables := newModuleVariables()
+	hcl, err := YAMLToTerraformResources(strings.NewReader(secretYAML+`---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: tls
+data:
+  tls.key: /w==
+`), WithSecretVariables(variables))
+	assert.NoError(t, err)
+	assert.Equal(t, `resource "kubernetes_manifest" "secret_db" {
+  manifest = {
+    "apiVersion" = "v1"
+    "data" = {
+      "password" = base64encode(var.secret_db_password)
+    }
+    "kind" = "Secret"
+    "metadata" = {
+      "annotations" = {
+        "team" = "data"
+      }
+      "name" = "db"
+    }
+    "stringData" = {
+      "user" = var.secret_db_user
+    }
+    "type" = "Opaque"
+  }
+}
+
+resource "kubernetes_manifest" "configmap_config" {
+  manifest = {
+    "apiVersion" = "v1"
+    "data" = {
+      "user" = "admin"
+    }
+    "kind" = "ConfigMap"
+    "metadata" = {
+      "name" = "config"
+    }
+  }
+}
+
+resource "kubernetes_manifest" "secret_tls" {
+  manifest = {
+    "apiVersion" = "v1"
+    "data" = {
+      "tls.key" = var.secret_tls_tls_key
+    }
+    "kind" = "Secret"
+    "metadata" = {
+      "name" = "tls"
+    }
+  }
+}
+`, hcl)
+
+	assert.Equal(t, `variable "secret_db_password" {
+  description = "Value of password in the Secret db, from data[\"password\"] in secret_db"
+  type        = string
+  sensitive   = true
+}
+
+variable "secret_db_user" {
+  description = "Value of user in the Secret db, from stringData[\"user\"] in secret_db"
+  type        = string
+  sensitive   = true
+}
+
+variable "secret_tls_tls_key" {
+  description = "Value of tls.key in the Secret tls, base64 encoded, from data[\"tls.key\"] in secret_tls"
+  type        = string
+  sensitive   = true
+}
+`, variables.hcl())
+	assert.Equal(t, `secret_db_password = "hunter2"
+secret_db_user     = "admin"
+secret_tls_tls_key = "/w=="
+`, variables.secretTFVars())
+	assert.Equal(t, "", variables.tfvars())
+}
+
+func TestWriteSecretTFVars(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tfk8s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	variables := newModuleVariables()
+	if _, err := convertResources(strings.NewReader(secretYAML), WithSecretVariables(variables)); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, ".gitignore"), []byte(".terraform"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	filename := filepath.Join(dir, "secrets.auto.tfvars")
+	for i := 0; i < 2; i++ {
+		assert.NoError(t, writeSecretTFVars(filename, variables))
+	}
+
+	b, err := ioutil.ReadFile(filename)
+	assert.NoError(t, err)
+	assert.Contains(t, string(b), `secret_db_password = "hunter2"`)
+	b, err = ioutil.ReadFile(filepath.Join(dir, ".gitignore"))
+	assert.NoError(t, err)
+	assert.Equal(t, ".terraform\nsecrets.auto.tfvars\n", string(b))
+}
+
+func TestDecodeSecrets(t *testing.T) {
+	yaml := `apiVersion: v1
+kind: Secret
+metadata:
+  name: db
+data:
+  password: aHVudGVyMg==
+  user: cm9vdA==
+  tls.key: /w==
+stringData:
+  user: admin
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: token
+data:
+  token: c2VjcmV0
+`
+	hcl, err := YAMLToTerraformResources(strings.NewReader(yaml), WithDecodeSecrets(true), WithMapOnly(true))
+	assert.NoError(t, err)
+	assert.Equal(t, `{
+  "apiVersion" = "v1"
+  "data" = {
+    "tls.key" = "/w=="
+    "user" = "cm9vdA=="
+  }
+  "kind" = "Secret"
+  "metadata" = {
+    "name" = "db"
+  }
+  "stringData" = {
+    "password" = "hunter2"
+    "user" = "admin"
+  }
+}
+
+{
+  "apiVersion" = "v1"
+  "kind" = "Secret"
+  "metadata" = {
+    "name" = "token"
+  }
+  "stringData" = {
+    "token" = "secret"
+  }
+}
+`, hcl)
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"regexp"
+
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+)
+
+// sopsCommand is the command used to decrypt and encrypt SOPS files
+var sopsCommand = "sops"
+
+// runSOPS runs sops with the supplied arguments and input on its stdin,
+// and returns its output
+var runSOPS = func(input []byte, args ...string) ([]byte, error) {
+	return runCommandInput(sopsCommand, input, args...)
+}
+
+// sopsMetadata matches the sops key SOPS adds to the files it encrypts, in
+// YAML or JSON
+var sopsMetadata = regexp.MustCompile(`(?m)^sops:|"sops"\s*:\s*\{`)
+
+// isSOPSEncrypted returns true if b is a file encrypted with SOPS
+func isSOPSEncrypted(b []byte) bool {
+	return bytes.Contains(b, []byte("ENC[AES256_GCM,")) && sopsMetadata.Match(b)
+}
+
+// decryptSOPS decrypts the SOPS file at path, the plain text is only kept
+// in memory
+func decryptSOPS(path string) ([]byte, error) {
+	return runSOPS(nil, "--decrypt", path)
+}
+
+// secretTFVarsJSON returns a tfvars.json file that sets the sensitive
+// variables to the values of the Secrets
+func (m *moduleVariables) secretTFVarsJSON() ([]byte, error) {
+	values := map[string]json.RawMessage{}
+	for _, v := range m.variables {
+		if !v.sensitive {
+			continue
+		}
+		b, err := ctyjson.Marshal(v.value, v.value.Type())
+		if err != nil {
+			return nil, err
+		}
+		values[v.name] = b
+	}
+	return json.MarshalIndent(values, "", "  ")
+}
+
+// writeSOPSTFVars writes a tfvars.json file that sets the sensitive
+// variables to filename, encrypted with sops. The plain text is piped to
+// sops so it is never written to disk, and the creation rules in
+// .sops.yaml are matched against filename.
+func writeSOPSTFVars(filename string, variables *moduleVariables) error {
+	b, err := variables.secretTFVarsJSON()
+	if err != nil {
+		return err
+	}
+	encrypted, err := runSOPS(b, "--encrypt", "--input-type", "json", "--output-type", "json", "--filename-override", filename, "/dev/stdin")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filename, encrypted, 0644)
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const sopsSecretYAML = `apiVersion: v1
+kind: Secret
+metadata:
+    name: db
+stringData:
+    password: ENC[AES256_GCM,data:Tr7oHg0=,iv:aGVsbG8=,tag:d29ybGQ=,type:str]
+sops:
+    age:
+        - recipient: age1example
+    version: 3.8.1
+`
+
+func TestOpenInputSOPS(t *testing.T) {
+	defer func(f func([]byte, ...string) ([]byte, error)) { runSOPS = f }(runSOPS)
+
+	dir, err := ioutil.TempDir("", "tfk8s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	encrypted := filepath.Join(dir, "secret.enc.yaml")
+	plain := filepath.Join(dir, "configmap.yaml")
+	if err := ioutil.WriteFile(encrypted, []byte(sopsSecretYAML), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(plain, []byte("kind: ConfigMap\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var args []string
+	runSOPS = func(input []byte, a ...string) ([]byte, error) {
+		args = a
+		return []byte(strings.Replace(strings.Split(sopsSecretYAML, "sops:")[0], "ENC[AES256_GCM,data:Tr7oHg0=,iv:aGVsbG8=,tag:d29ybGQ=,type:str]", "hunter2", 1)), nil
+	}
+
+	r, err := openInput(encrypted, nil)
+	if err != nil {
+		t.Fatal("Decrypting failed:", err)
+	}
+	b, err := ioutil.ReadAll(r)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"--decrypt", encrypted}, args)
+	assert.Contains(t, string(b), "password: hunter2")
+	assert.NotContains(t, string(b), "sops:")
+
+	args = nil
+	r, err = openInput(plain, nil)
+	if err != nil {
+		t.Fatal("Opening failed:", err)
+	}
+	b, err = ioutil.ReadAll(r)
+	assert.NoError(t, err)
+	assert.Nil(t, args)
+	assert.Equal(t, "kind: ConfigMap\n", string(b))
+}
+
+func TestIsSOPSEncrypted(t *testing.T) {
+	assert.True(t, isSOPSEncrypted([]byte(sopsSecretYAML)))
+	assert.True(t, isSOPSEncrypted([]byte(`{"data": "ENC[AES256_GCM,data:aGk=,type:str]", "sops": {"version": "3.8.1"}}`)))
+	assert.False(t, isSOPSEncrypted([]byte("kind: Secret\nstringData:\n  password: hunter2\n")))
+	assert.False(t, isSOPSEncrypted([]byte("kind: ConfigMap\ndata:\n  sops: ENC[AES256_GCM,\n")))
+}
+
+func TestWriteSOPSTFVars(t *testing.T) {
+	defer func(f func([]byte, ...string) ([]byte, error)) { runSOPS = f }(runSOPS)
+
+	dir, err := ioutil.TempDir("", "tfk8s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	variables := newModuleVariables()
+	if _, err := convertResources(strings.NewReader(secretYAML), WithSecretVariables(variables)); err != nil {
+		t.Fatal(err)
+	}
+
+	var plaintext []byte
+	var args []string
+	runSOPS = func(input []byte, a ...string) ([]byte, error) {
+		args = a
+		plaintext = input
+		return []byte(`{"secret_db_password": "ENC[AES256_GCM,data:aGk=,type:str]"}`), nil
+	}
+
+	filename := filepath.Join(dir, "secrets.auto.tfvars.json")
+	assert.NoError(t, writeSOPSTFVars(filename, variables))
+
+	assert.Equal(t, []string{"--encrypt", "--input-type", "json", "--output-type", "json", "--filename-override", filename, "/dev/stdin"}, args)
+	assert.JSONEq(t, `{
+  "secret_db_password": "hunter2",
+  "secret_db_user": "admin"
+}`, string(plaintext))
+
+	b, err := ioutil.ReadFile(filename)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(b), "hunter2")
+	_, err = os.Stat(filepath.Join(dir, ".gitignore"))
+	assert.True(t, os.IsNotExist(err))
+}
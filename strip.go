@@ -0,0 +1,575 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	cty "github.com/zclconf/go-cty/cty"
+)
+
+// defaultStripFields are the fields --strip removes, they are set by the
+// server after the object is created
+var defaultStripFields = []string{
+	"metadata.creationTimestamp",
+	"metadata.resourceVersion",
+	"metadata.selfLink",
+	"metadata.uid",
+	"metadata.generation",
+	"metadata.managedFields",
+	"metadata.ownerReferences",
+	"metadata.finalizers",
+	`metadata.annotations."kubectl.kubernetes.io/last-applied-configuration"`,
+	"spec.finalizers",
+	"status",
+	"Service:spec.clusterIPs",
+	"Service:spec.ipFamilies",
+	"Service:spec.ipFamilyPolicy",
+	"Service:spec.ports[*].nodePort",
+	"PersistentVolumeClaim:spec.volumeName",
+	`PersistentVolumeClaim:metadata.annotations."pv.kubernetes.io/*"`,
+	`PersistentVolumeClaim:metadata.annotations."volume.beta.kubernetes.io/storage-provisioner"`,
+	`PersistentVolumeClaim:metadata.annotations."volume.kubernetes.io/storage-provisioner"`,
+	`PersistentVolumeClaim:metadata.annotations."volume.kubernetes.io/selected-node"`,
+	"PersistentVolume:spec.claimRef",
+	`PersistentVolume:metadata.annotations."pv.kubernetes.io/*"`,
+}
+
+// fieldStep is a step in a fieldPath, the name of an attribute or map key,
+// or the index of a list item if index is set. * in name matches any
+// characters, and an index of * matches every item. A recursive step
+// matches any number of levels, and match is set to startswith, endswith,
+// contains or test to match keys with a select() expression instead. If
+// value is set the key only matches when it is set to that string, and if
+// except is set it doesn't match when it is set to that string.
+type fieldStep struct {
+	name      string
+	index     bool
+	recursive bool
+	match     string
+	value     string
+	except    string
+}
+
+// matches returns true if the key k matches the step
+func (step fieldStep) matches(k string) bool {
+	switch step.match {
+	case "startswith":
+		return strings.HasPrefix(k, step.name)
+	case "endswith":
+		return strings.HasSuffix(k, step.name)
+	case "contains":
+		return strings.Contains(k, step.name)
+	case "test":
+		matched, _ := regexp.MatchString(step.name, k)
+		return matched
+	}
+	return globMatch(step.name, k)
+}
+
+// fieldPath is the path to a field in a manifest
+type fieldPath []fieldStep
+
+// is returns true if the path is the attributes names
+func (p fieldPath) is(names ...string) bool {
+	if len(p) != len(names) {
+		return false
+	}
+	for i, step := range p {
+		if step.index || step.recursive || step.match != "" || step.name != names[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// stripField is a field to remove from the manifests of kind, or every
+// manifest if kind is empty
+type stripField struct {
+	kind string
+	path fieldPath
+}
+
+// stripFieldKind matches the kind a field can start with, like Service:
+var stripFieldKind = regexp.MustCompile(`^([A-Za-z0-9]+):`)
+
+// parseStripField parses a field like spec.template.metadata.creationTimestamp,
+// metadata.annotations."deployment.kubernetes.io/revision" or
+// Service:spec.ports[*].nodePort. Steps with dots in them are quoted, * is
+// a wildcard, and [*] or [0] selects list items.
+func parseStripField(s string) (stripField, error) {
+	f := stripField{}
+	p := s
+	if m := stripFieldKind.FindStringSubmatch(p); m != nil {
+		f.kind, p = m[1], p[len(m[0]):]
+	}
+	path, err := parseFieldPath(p)
+	if err != nil {
+		return stripField{}, fmt.Errorf("invalid field %q: %s", s, err)
+	}
+	f.path = path
+	return f, nil
+}
+
+// parseFieldPath parses the steps of a field path. It can be written like
+// JSONPath, starting with $ and with keys in brackets like ['app.kubernetes.io/name'],
+// or like jq starting with a dot, and .. matches any number of levels.
+func parseFieldPath(s string) (fieldPath, error) {
+	path := fieldPath{}
+	i := 0
+	if strings.HasPrefix(s, "$") {
+		i++
+	}
+	for i < len(s) {
+		switch {
+		case strings.HasPrefix(s[i:], ".."):
+			path = append(path, fieldStep{recursive: true})
+			i += 2
+			if i == len(s) || s[i] == '.' {
+				return nil, fmt.Errorf(".. must be followed by a field")
+			}
+			continue
+		case s[i] == '.':
+			i++
+			if i == len(s) {
+				return nil, fmt.Errorf("there is an empty step")
+			}
+			continue
+		case s[i] == '[':
+			end := strings.IndexByte(s[i:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("[ isn't closed")
+			}
+			if q := s[i+1]; q == '"' || q == '\'' {
+				name, rest, err := unquoteStep(s[i+1:])
+				if err != nil {
+					return nil, err
+				}
+				if !strings.HasPrefix(rest, "]") {
+					return nil, fmt.Errorf("expected ] after %q", name)
+				}
+				path = append(path, fieldStep{name: name})
+				i = len(s) - len(rest) + 1
+				break
+			}
+			index := s[i+1 : i+end]
+			if _, err := strconv.Atoi(index); err != nil && index != "*" {
+				return nil, fmt.Errorf("the index %q must be a number or *", index)
+			}
+			path = append(path, fieldStep{name: index, index: true})
+			i += end + 1
+		case s[i] == '"':
+			name, rest, err := unquoteStep(s[i:])
+			if err != nil {
+				return nil, err
+			}
+			path = append(path, fieldStep{name: name})
+			i = len(s) - len(rest)
+		default:
+			end := strings.IndexAny(s[i:], ".[")
+			if end < 0 {
+				end = len(s) - i
+			}
+			path = append(path, fieldStep{name: s[i : i+end]})
+			i += end
+		}
+		if i < len(s) && s[i] != '.' && s[i] != '[' {
+			return nil, fmt.Errorf("expected . or [ after %q", path[len(path)-1].name)
+		}
+	}
+	if len(path) == 0 {
+		return nil, fmt.Errorf("it is empty")
+	}
+	return path, nil
+}
+
+// unquoteStep reads the step quoted with " or ' at the start of s, and
+// returns it and the rest of s
+func unquoteStep(s string) (string, string, error) {
+	q := s[0]
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case q:
+			if q == '\'' {
+				return strings.ReplaceAll(s[1:i], `\'`, "'"), s[i+1:], nil
+			}
+			name, err := strconv.Unquote(s[:i+1])
+			return name, s[i+1:], err
+		}
+	}
+	return "", "", fmt.Errorf("%s isn't closed", s)
+}
+
+// selectExpression matches a jq select() of the keys of an object, like
+// select(startswith("autoscaling."))
+var selectExpression = regexp.MustCompile(`^select\(\s*(startswith|endswith|contains|test)\(\s*("(?:[^"\\]|\\.)*")\s*\)\s*\)$`)
+
+// parseDeleteExpression parses a --delete expression, a field path that
+// can be followed by a jq select() of the keys to remove from the object
+// at the path, like metadata.annotations | select(startswith("autoscaling."))
+func parseDeleteExpression(s string) (stripField, error) {
+	expr, selector := s, ""
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		switch {
+		case quote != 0 && s[i] == '\\':
+			i++
+		case quote != 0 && s[i] == quote:
+			quote = 0
+		case quote == 0 && (s[i] == '"' || s[i] == '\''):
+			quote = s[i]
+		case quote == 0 && s[i] == '|':
+			expr, selector = strings.TrimSpace(s[:i]), strings.TrimSpace(s[i+1:])
+			i = len(s)
+		}
+	}
+	f, err := parseStripField(expr)
+	if err != nil {
+		return stripField{}, fmt.Errorf("invalid --delete %q: %s", s, strings.TrimPrefix(err.Error(), fmt.Sprintf("invalid field %q: ", expr)))
+	}
+	if selector == "" {
+		return f, nil
+	}
+	m := selectExpression.FindStringSubmatch(selector)
+	if m == nil {
+		return stripField{}, fmt.Errorf("invalid --delete %q: only select() with startswith, endswith, contains or test of the keys is supported", s)
+	}
+	arg, err := strconv.Unquote(m[2])
+	if err != nil {
+		return stripField{}, fmt.Errorf("invalid --delete %q: %s", s, err)
+	}
+	if m[1] == "test" {
+		if _, err := regexp.Compile(arg); err != nil {
+			return stripField{}, fmt.Errorf("invalid --delete %q: %s", s, err)
+		}
+	}
+	f.path = append(f.path, fieldStep{name: arg, match: m[1]})
+	return f, nil
+}
+
+// globMatch returns true if s matches pattern, where * matches any
+// characters
+func globMatch(pattern, s string) bool {
+	parts := strings.Split(pattern, "*")
+	if len(parts) == 1 {
+		return pattern == s
+	}
+	if !strings.HasPrefix(s, parts[0]) {
+		return false
+	}
+	s = s[len(parts[0]):]
+	for _, part := range parts[1 : len(parts)-1] {
+		i := strings.Index(s, part)
+		if i < 0 {
+			return false
+		}
+		s = s[i+len(part):]
+	}
+	return strings.HasSuffix(s, parts[len(parts)-1])
+}
+
+// removeField returns v without the fields at path. Objects and maps that
+// are left empty are removed too, unless they are at the top of the
+// manifest, like spec.
+func removeField(v cty.Value, path fieldPath) cty.Value {
+	return removeFieldAt(v, path, 0)
+}
+
+func removeFieldAt(v cty.Value, path fieldPath, depth int) cty.Value {
+	if len(path) == 0 || v.IsNull() || v.IsMarked() || !v.IsKnown() {
+		return v
+	}
+	step, rest := path[0], path[1:]
+	if step.recursive {
+		// remove the rest of the path here, and from every level below
+		v = removeFieldAt(v, rest, depth)
+		return mapChildren(v, depth, func(k string, i int, item cty.Value) (cty.Value, bool) {
+			return removeFieldAt(item, path, depth+1), true
+		})
+	}
+	ty := v.Type()
+	if step.index != (ty.IsTupleType() || ty.IsListType()) {
+		return v
+	}
+	return mapChildren(v, depth, func(k string, i int, item cty.Value) (cty.Value, bool) {
+		if step.index && step.name != "*" && step.name != strconv.Itoa(i) {
+			return item, true
+		}
+		if !step.index && !step.matches(k) {
+			return item, true
+		}
+		if step.value != "" && !isString(item, step.value) || step.except != "" && isString(item, step.except) {
+			return item, true
+		}
+		if len(rest) == 0 {
+			return cty.NilVal, false
+		}
+		return removeFieldAt(item, rest, depth+1), true
+	})
+}
+
+// mapChildren calls f with the key or index of each attribute, map element
+// or list item in v and returns v with them replaced by the values f
+// returns, or removed if it returns false. Objects and maps f leaves empty
+// are removed, unless v is at the top of the manifest.
+func mapChildren(v cty.Value, depth int, f func(k string, i int, item cty.Value) (cty.Value, bool)) cty.Value {
+	if v.IsNull() || v.IsMarked() || !v.IsKnown() {
+		return v
+	}
+	ty := v.Type()
+	switch {
+	case ty.IsObjectType() || ty.IsMapType():
+		m := v.AsValueMap()
+		if len(m) == 0 {
+			return v
+		}
+		for k, item := range m {
+			mapped, keep := f(k, 0, item)
+			if !keep || (depth > 0 && isEmptyCollection(mapped) && !isEmptyCollection(item)) {
+				delete(m, k)
+				continue
+			}
+			m[k] = mapped
+		}
+		if ty.IsMapType() {
+			if len(m) == 0 {
+				return cty.MapValEmpty(ty.ElementType())
+			}
+			return cty.MapVal(m)
+		}
+		return cty.ObjectVal(m)
+	case ty.IsTupleType() || ty.IsListType():
+		items := []cty.Value{}
+		for i, item := range v.AsValueSlice() {
+			if mapped, keep := f("", i, item); keep {
+				items = append(items, mapped)
+			}
+		}
+		return cty.TupleVal(items)
+	}
+	return v
+}
+
+// isEmptyCollection returns true if v is an empty object or map
+func isEmptyCollection(v cty.Value) bool {
+	if v.IsNull() || v.IsMarked() || !v.IsKnown() {
+		return false
+	}
+	ty := v.Type()
+	return (ty.IsObjectType() || ty.IsMapType()) && v.LengthInt() == 0
+}
+
+// meaningfulEmpty are the fields that mean something when they are empty,
+// like an emptyDir volume or a selector that selects everything
+var meaningfulEmpty = map[string]bool{
+	"emptyDir":          true,
+	"podSelector":       true,
+	"namespaceSelector": true,
+	"selector":          true,
+}
+
+// pruneEmpty removes the empty objects, maps and lists from the manifest
+// doc, and the ones left empty once they are removed. The fields at the top
+// of the manifest, list items and meaningfulEmpty fields are kept.
+func pruneEmpty(doc cty.Value) cty.Value {
+	return mapChildren(doc, 0, func(k string, i int, item cty.Value) (cty.Value, bool) {
+		return pruneEmptyField(item), true
+	})
+}
+
+func pruneEmptyField(v cty.Value) cty.Value {
+	isList := v.Type().IsTupleType() || v.Type().IsListType()
+	return mapChildren(v, 0, func(k string, i int, item cty.Value) (cty.Value, bool) {
+		item = pruneEmptyField(item)
+		if !isList && !meaningfulEmpty[k] && (isEmptyCollection(item) || isEmptyList(item)) {
+			return cty.NilVal, false
+		}
+		return item, true
+	})
+}
+
+// isEmptyList returns true if v is an empty list
+func isEmptyList(v cty.Value) bool {
+	if v.IsNull() || v.IsMarked() || !v.IsKnown() {
+		return false
+	}
+	ty := v.Type()
+	return (ty.IsTupleType() || ty.IsListType()) && v.LengthInt() == 0
+}
+
+// removeFields removes the fields for kind from doc
+func removeFields(doc cty.Value, kind string, fields []stripField) cty.Value {
+	for _, f := range fields {
+		if f.kind == "" || f.kind == kind {
+			doc = removeField(doc, f.path)
+		}
+	}
+	return doc
+}
+
+// parseStripFields parses fields with parseStripField
+func parseStripFields(fields []string) ([]stripField, error) {
+	parsed := []stripField{}
+	for _, s := range fields {
+		f, err := parseStripField(s)
+		if err != nil {
+			return nil, err
+		}
+		parsed = append(parsed, f)
+	}
+	return parsed, nil
+}
+
+// loadStripFields reads the fields in a file with one field on each line,
+// blank lines and lines starting with # are skipped
+func loadStripFields(filename string) ([]string, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	fields := []string{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields = append(fields, line)
+	}
+	return fields, scanner.Err()
+}
+
+// generatedLabels are the labels controllers add to the objects of each
+// kind, and to their selectors and pod templates
+var generatedLabels = map[string][]string{
+	"ReplicaSet": {"pod-template-hash"},
+	"Job": {
+		"controller-uid",
+		"job-name",
+		"batch.kubernetes.io/controller-uid",
+		"batch.kubernetes.io/job-name",
+	},
+	"Pod": {
+		"pod-template-hash",
+		"pod-template-generation",
+		"controller-revision-hash",
+		"statefulset.kubernetes.io/pod-name",
+		"apps.kubernetes.io/pod-index",
+		"controller-uid",
+		"job-name",
+		"batch.kubernetes.io/controller-uid",
+		"batch.kubernetes.io/job-name",
+		"batch.kubernetes.io/job-completion-index",
+	},
+}
+
+// generatedLabelFields returns the fields of the generatedLabels, in the
+// labels and matchLabels of each kind
+func generatedLabelFields() []stripField {
+	kinds := []string{}
+	for kind := range generatedLabels {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+
+	fields := []stripField{}
+	for _, kind := range kinds {
+		for _, label := range generatedLabels[kind] {
+			for _, labels := range []string{"labels", "matchLabels"} {
+				fields = append(fields, stripField{kind: kind, path: fieldPath{
+					{recursive: true}, {name: labels}, {name: label},
+				}})
+			}
+		}
+	}
+	return fields
+}
+
+// serverSideFields are the parsed defaultStripFields and generatedLabels
+var serverSideFields = append(append(mustParseStripFields(defaultStripFields), stripField{
+	// the clusterIP of a headless Service is None, which isn't assigned
+	kind: "Service", path: fieldPath{{name: "spec"}, {name: "clusterIP", except: "None"}},
+}), generatedLabelFields()...)
+
+// helmStripFields are the fields --strip-helm removes, the labels and
+// annotations Helm and Kustomize use to keep track of the objects they
+// manage, in the object and its pod template
+var helmStripFields = append(mustParseStripFields([]string{
+	`..metadata.labels."helm.sh/chart"`,
+	`..metadata.annotations."meta.helm.sh/*"`,
+	`..metadata.annotations."kustomize.toolkit.fluxcd.io/checksum"`,
+	`..metadata.annotations."config.kubernetes.io/origin"`,
+}), stripField{path: fieldPath{
+	{recursive: true}, {name: "metadata"}, {name: "labels"}, {name: "app.kubernetes.io/managed-by", value: "Helm"},
+}})
+
+// mustParseStripFields parses fields and panics if one of them is invalid
+func mustParseStripFields(fields []string) []stripField {
+	parsed, err := parseStripFields(fields)
+	if err != nil {
+		panic(err)
+	}
+	return parsed
+}
+
+// fieldsToStrip returns the fields to remove from the manifests, the
+// --strip-field fields and the defaults with --strip, unless they are
+// replaced
+func (o options) fieldsToStrip() []stripField {
+	fields := []stripField{}
+	if o.stripServerSide && !o.replaceStripDefaults {
+		for _, f := range serverSideFields {
+			if o.keepStatus && f.path.is("status") || o.keepManagedFields && f.path.is("metadata", "managedFields") {
+				continue
+			}
+			fields = append(fields, f)
+		}
+	}
+	if o.stripHelm {
+		fields = append(fields, helmStripFields...)
+	}
+	if o.stripGitOps {
+		fields = append(fields, gitopsStripFields...)
+	}
+	return append(fields, o.stripFields...)
+}
+
+// gitopsStripFields are the fields --strip-gitops removes, the labels and
+// annotations Argo CD and Flux use to track and sync the objects
+var gitopsStripFields = mustParseStripFields([]string{
+	`..metadata.labels."argocd.argoproj.io/*"`,
+	`..metadata.annotations."argocd.argoproj.io/*"`,
+	`..metadata.labels."kustomize.toolkit.fluxcd.io/*"`,
+	`..metadata.annotations."kustomize.toolkit.fluxcd.io/*"`,
+})
+
+// isString returns true if v is the string s
+func isString(v cty.Value, s string) bool {
+	return !v.IsMarked() && v.IsKnown() && !v.IsNull() && v.Type() == cty.String && v.AsString() == s
+}
+
+// stripServerSideFields removes fields that have been added on the
+// server side after the resource was created such as the status field,
+// and the default namespace with --strip unless it is kept
+func stripServerSideFields(doc cty.Value, kind string, o options) cty.Value {
+	doc = removeFields(doc, kind, o.fieldsToStrip())
+	if !o.stripServerSide || o.keepDefaultNamespace {
+		return doc
+	}
+
+	m := doc.AsValueMap()
+	metadata := m["metadata"].AsValueMap()
+	if ns, ok := stringAttr(metadata, "namespace"); ok && o.isDefaultNamespace(ns) {
+		delete(metadata, "namespace")
+	}
+	m["metadata"] = cty.ObjectVal(metadata)
+	return cty.ObjectVal(m)
+}
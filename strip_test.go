@@ -0,0 +1,662 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseStripField(t *testing.T) {
+	f, err := parseStripField(`metadata.annotations."deployment.kubernetes.io/revision"`)
+	assert.NoError(t, err)
+	assert.Equal(t, stripField{path: fieldPath{{name: "metadata"}, {name: "annotations"}, {name: "deployment.kubernetes.io/revision"}}}, f)
+
+	f, err = parseStripField("Service:spec.ports[*].nodePort")
+	assert.NoError(t, err)
+	assert.Equal(t, stripField{kind: "Service", path: fieldPath{{name: "spec"}, {name: "ports"}, {name: "*", index: true}, {name: "nodePort"}}}, f)
+
+	for _, invalid := range []string{"", "spec.", "spec..", "$", "spec.ports[a]", "metadata['name", `metadata."name`, `metadata."a"b`} {
+		_, err := parseStripField(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestParseDeleteExpression(t *testing.T) {
+	f, err := parseDeleteExpression(`metadata.annotations | select(startswith("autoscaling."))`)
+	assert.NoError(t, err)
+	assert.Equal(t, stripField{path: fieldPath{{name: "metadata"}, {name: "annotations"}, {name: "autoscaling.", match: "startswith"}}}, f)
+
+	f, err = parseDeleteExpression("$.spec..['app.kubernetes.io/managed-by']")
+	assert.NoError(t, err)
+	assert.Equal(t, stripField{path: fieldPath{{name: "spec"}, {recursive: true}, {name: "app.kubernetes.io/managed-by"}}}, f)
+
+	f, err = parseDeleteExpression(`Pod:.metadata["a|b"]`)
+	assert.NoError(t, err)
+	assert.Equal(t, stripField{kind: "Pod", path: fieldPath{{name: "metadata"}, {name: "a|b"}}}, f)
+
+	for _, invalid := range []string{"", "metadata.labels | keys", `metadata.labels | select(test("("))`, `| select(contains("a"))`} {
+		_, err := parseDeleteExpression(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestGlobMatch(t *testing.T) {
+	assert.True(t, globMatch("pv.kubernetes.io/*", "pv.kubernetes.io/bind-completed"))
+	assert.True(t, globMatch("*", "anything"))
+	assert.True(t, globMatch("a*c*e", "abcde"))
+	assert.False(t, globMatch("pv.kubernetes.io/*", "helm.sh/chart"))
+	assert.False(t, globMatch("name", "names"))
+}
+
+var stripYAML = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: default
+  annotations:
+    deployment.kubernetes.io/revision: "3"
+  resourceVersion: "1"
+spec:
+  template:
+    metadata:
+      creationTimestamp: null
+    spec:
+      containers:
+      - name: web
+        image: nginx
+        terminationMessagePath: /dev/termination-log
+      - name: sidecar
+        image: envoy
+        terminationMessagePath: /dev/termination-log
+status:
+  replicas: 1
+`
+
+func TestStripFields(t *testing.T) {
+	fields, err := parseStripFields([]string{
+		`metadata.annotations."deployment.kubernetes.io/revision"`,
+		"spec.template.metadata.creationTimestamp",
+		"spec.template.spec.containers[*].terminationMessagePath",
+		"Service:spec.clusterIP",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hcl, err := YAMLToTerraformResources(strings.NewReader(stripYAML), WithStripServerSide(true), WithStripFields(fields, false), WithMapOnly(true))
+	assert.NoError(t, err)
+	assert.Equal(t, `{
+  "apiVersion" = "apps/v1"
+  "kind" = "Deployment"
+  "metadata" = {
+    "name" = "web"
+  }
+  "spec" = {
+    "template" = {
+      "spec" = {
+        "containers" = [
+          {
+            "image" = "nginx"
+            "name" = "web"
+          },
+          {
+            "image" = "envoy"
+            "name" = "sidecar"
+          },
+        ]
+      }
+    }
+  }
+}
+`, hcl)
+
+	// the defaults are replaced, so the status is kept
+	hcl, err = YAMLToTerraformResources(strings.NewReader(stripYAML), WithStripServerSide(true), WithStripFields(fields[:1], true), WithMapOnly(true))
+	assert.NoError(t, err)
+	assert.Contains(t, hcl, `"status" = {`)
+	assert.Contains(t, hcl, `"resourceVersion" = "1"`)
+	assert.NotContains(t, hcl, "revision")
+	assert.NotContains(t, hcl, `"namespace"`)
+
+	// without --strip only the fields are removed
+	hcl, err = YAMLToTerraformResources(strings.NewReader(stripYAML), WithStripFields(fields[:1], false), WithMapOnly(true))
+	assert.NoError(t, err)
+	assert.Contains(t, hcl, `"namespace" = "default"`)
+	assert.NotContains(t, hcl, "annotations")
+}
+
+var deleteYAML = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  annotations:
+    autoscaling.alpha.kubernetes.io/conditions: "[]"
+    team: web
+spec:
+  template:
+    metadata:
+      annotations:
+        kubectl.kubernetes.io/restartedAt: "2021-01-01T00:00:00Z"
+    spec:
+      containers:
+      - name: web
+        image: nginx
+        terminationMessagePolicy: File
+        ports:
+        - containerPort: 80
+          protocol: TCP
+`
+
+func TestDeleteExpressions(t *testing.T) {
+	fields := []stripField{}
+	for _, expr := range []string{
+		`metadata.annotations | select(startswith("autoscaling."))`,
+		`$..annotations['kubectl.kubernetes.io/restartedAt']`,
+		`..protocol`,
+		`.spec.template.spec.containers[*] | select(endswith("Policy"))`,
+	} {
+		f, err := parseDeleteExpression(expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fields = append(fields, f)
+	}
+
+	hcl, err := YAMLToTerraformResources(strings.NewReader(deleteYAML), WithStripFields(fields, false), WithMapOnly(true))
+	assert.NoError(t, err)
+	assert.Equal(t, `{
+  "apiVersion" = "apps/v1"
+  "kind" = "Deployment"
+  "metadata" = {
+    "annotations" = {
+      "team" = "web"
+    }
+    "name" = "web"
+  }
+  "spec" = {
+    "template" = {
+      "spec" = {
+        "containers" = [
+          {
+            "image" = "nginx"
+            "name" = "web"
+            "ports" = [
+              {
+                "containerPort" = 80
+              },
+            ]
+          },
+        ]
+      }
+    }
+  }
+}
+`, hcl)
+}
+
+func TestKeepStatusAndManagedFields(t *testing.T) {
+	yaml := `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+  uid: 8f2c
+  managedFields:
+  - manager: kubectl
+    operation: Apply
+status:
+  phase: Active
+`
+	hcl, err := YAMLToTerraformResources(strings.NewReader(yaml), WithStripServerSide(true), WithKeepStatus(true), WithMapOnly(true))
+	assert.NoError(t, err)
+	assert.Contains(t, hcl, `"status" = {`)
+	assert.NotContains(t, hcl, "managedFields")
+	assert.NotContains(t, hcl, "uid")
+
+	hcl, err = YAMLToTerraformResources(strings.NewReader(yaml), WithStripServerSide(true), WithKeepManagedFields(true), WithMapOnly(true))
+	assert.NoError(t, err)
+	assert.Contains(t, hcl, `"manager" = "kubectl"`)
+	assert.NotContains(t, hcl, "status")
+	assert.NotContains(t, hcl, "uid")
+}
+
+var helmYAML = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  labels:
+    app: web
+    app.kubernetes.io/managed-by: Helm
+    helm.sh/chart: web-1.0.0
+  annotations:
+    meta.helm.sh/release-name: web
+    meta.helm.sh/release-namespace: default
+spec:
+  template:
+    metadata:
+      labels:
+        app: web
+        helm.sh/chart: web-1.0.0
+      annotations:
+        kustomize.toolkit.fluxcd.io/checksum: 0c8d
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+  labels:
+    app.kubernetes.io/managed-by: kustomize
+`
+
+func TestStripHelm(t *testing.T) {
+	hcl, err := YAMLToTerraformResources(strings.NewReader(helmYAML), WithStripHelm(true), WithMapOnly(true))
+	assert.NoError(t, err)
+	assert.Equal(t, `{
+  "apiVersion" = "apps/v1"
+  "kind" = "Deployment"
+  "metadata" = {
+    "labels" = {
+      "app" = "web"
+    }
+    "name" = "web"
+  }
+  "spec" = {
+    "template" = {
+      "metadata" = {
+        "labels" = {
+          "app" = "web"
+        }
+      }
+    }
+  }
+}
+
+{
+  "apiVersion" = "v1"
+  "kind" = "ConfigMap"
+  "metadata" = {
+    "labels" = {
+      "app.kubernetes.io/managed-by" = "kustomize"
+    }
+    "name" = "config"
+  }
+}
+`, hcl)
+}
+
+func TestStripGitOps(t *testing.T) {
+	yaml := `apiVersion: v1
+kind: Service
+metadata:
+  name: web
+  labels:
+    app: web
+    kustomize.toolkit.fluxcd.io/name: apps
+    kustomize.toolkit.fluxcd.io/namespace: flux-system
+  annotations:
+    argocd.argoproj.io/sync-wave: "1"
+    argocd.argoproj.io/tracking-id: web:/Service:default/web
+    prometheus.io/scrape: "true"
+spec:
+  selector:
+    app: web
+`
+	hcl, err := YAMLToTerraformResources(strings.NewReader(yaml), WithStripGitOps(true), WithMapOnly(true))
+	assert.NoError(t, err)
+	assert.Equal(t, `{
+  "apiVersion" = "v1"
+  "kind" = "Service"
+  "metadata" = {
+    "annotations" = {
+      "prometheus.io/scrape" = "true"
+    }
+    "labels" = {
+      "app" = "web"
+    }
+    "name" = "web"
+  }
+  "spec" = {
+    "selector" = {
+      "app" = "web"
+    }
+  }
+}
+`, hcl)
+}
+
+func TestStripServiceNetworking(t *testing.T) {
+	yaml := `apiVersion: v1
+kind: Service
+metadata:
+  name: web
+spec:
+  type: NodePort
+  clusterIP: 10.96.12.4
+  clusterIPs:
+  - 10.96.12.4
+  ipFamilies:
+  - IPv4
+  ipFamilyPolicy: SingleStack
+  ports:
+  - port: 80
+    nodePort: 30080
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: headless
+spec:
+  clusterIP: None
+  clusterIPs:
+  - None
+`
+	hcl, err := YAMLToTerraformResources(strings.NewReader(yaml), WithStripServerSide(true), WithMapOnly(true))
+	assert.NoError(t, err)
+	assert.Equal(t, `{
+  "apiVersion" = "v1"
+  "kind" = "Service"
+  "metadata" = {
+    "name" = "web"
+  }
+  "spec" = {
+    "ports" = [
+      {
+        "port" = 80
+      },
+    ]
+    "type" = "NodePort"
+  }
+}
+
+{
+  "apiVersion" = "v1"
+  "kind" = "Service"
+  "metadata" = {
+    "name" = "headless"
+  }
+  "spec" = {
+    "clusterIP" = "None"
+  }
+}
+`, hcl)
+}
+
+func TestStripVolumeBinding(t *testing.T) {
+	yaml := `apiVersion: v1
+kind: PersistentVolumeClaim
+metadata:
+  name: data
+  annotations:
+    pv.kubernetes.io/bind-completed: "yes"
+    pv.kubernetes.io/bound-by-controller: "yes"
+    volume.kubernetes.io/storage-provisioner: ebs.csi.aws.com
+spec:
+  accessModes:
+  - ReadWriteOnce
+  volumeName: pvc-3f1c
+---
+apiVersion: v1
+kind: PersistentVolume
+metadata:
+  name: pvc-3f1c
+  annotations:
+    pv.kubernetes.io/provisioned-by: ebs.csi.aws.com
+spec:
+  claimRef:
+    kind: PersistentVolumeClaim
+    name: data
+    namespace: default
+    uid: 3f1c
+  persistentVolumeReclaimPolicy: Retain
+`
+	hcl, err := YAMLToTerraformResources(strings.NewReader(yaml), WithStripServerSide(true), WithMapOnly(true))
+	assert.NoError(t, err)
+	assert.Equal(t, `{
+  "apiVersion" = "v1"
+  "kind" = "PersistentVolumeClaim"
+  "metadata" = {
+    "name" = "data"
+  }
+  "spec" = {
+    "accessModes" = [
+      "ReadWriteOnce",
+    ]
+  }
+}
+
+{
+  "apiVersion" = "v1"
+  "kind" = "PersistentVolume"
+  "metadata" = {
+    "name" = "pvc-3f1c"
+  }
+  "spec" = {
+    "persistentVolumeReclaimPolicy" = "Retain"
+  }
+}
+`, hcl)
+}
+
+func TestStripGeneratedLabels(t *testing.T) {
+	yaml := `apiVersion: apps/v1
+kind: ReplicaSet
+metadata:
+  name: web-5d8f7c9b6
+  labels:
+    app: web
+    pod-template-hash: 5d8f7c9b6
+spec:
+  selector:
+    matchLabels:
+      app: web
+      pod-template-hash: 5d8f7c9b6
+  template:
+    metadata:
+      labels:
+        app: web
+        pod-template-hash: 5d8f7c9b6
+---
+apiVersion: batch/v1
+kind: Job
+metadata:
+  name: migrate
+spec:
+  selector:
+    matchLabels:
+      controller-uid: 8b1e
+  template:
+    metadata:
+      labels:
+        controller-uid: 8b1e
+        job-name: migrate
+`
+	hcl, err := YAMLToTerraformResources(strings.NewReader(yaml), WithStripServerSide(true), WithMapOnly(true))
+	assert.NoError(t, err)
+	assert.Equal(t, `{
+  "apiVersion" = "apps/v1"
+  "kind" = "ReplicaSet"
+  "metadata" = {
+    "labels" = {
+      "app" = "web"
+    }
+    "name" = "web-5d8f7c9b6"
+  }
+  "spec" = {
+    "selector" = {
+      "matchLabels" = {
+        "app" = "web"
+      }
+    }
+    "template" = {
+      "metadata" = {
+        "labels" = {
+          "app" = "web"
+        }
+      }
+    }
+  }
+}
+
+{
+  "apiVersion" = "batch/v1"
+  "kind" = "Job"
+  "metadata" = {
+    "name" = "migrate"
+  }
+  "spec" = {}
+}
+`, hcl)
+}
+
+func TestDefaultNamespace(t *testing.T) {
+	yaml := `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+  namespace: team-a
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+  namespace: default
+`
+	hcl, err := YAMLToTerraformResources(strings.NewReader(yaml), WithStripServerSide(true), WithDefaultNamespace("team-a"))
+	assert.NoError(t, err)
+	assert.Equal(t, `resource "kubernetes_manifest" "configmap_config" {
+  manifest = {
+    "apiVersion" = "v1"
+    "kind" = "ConfigMap"
+    "metadata" = {
+      "name" = "config"
+    }
+  }
+}
+
+resource "kubernetes_manifest" "configmap_default_config" {
+  manifest = {
+    "apiVersion" = "v1"
+    "kind" = "ConfigMap"
+    "metadata" = {
+      "name" = "config"
+      "namespace" = "default"
+    }
+  }
+}
+`, hcl)
+}
+
+func TestKeepDefaultNamespace(t *testing.T) {
+	yaml := `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+  namespace: default
+  uid: 8f2c
+`
+	hcl, err := YAMLToTerraformResources(strings.NewReader(yaml), WithStripServerSide(true), WithKeepDefaultNamespace(true), WithMapOnly(true))
+	assert.NoError(t, err)
+	assert.Equal(t, `{
+  "apiVersion" = "v1"
+  "kind" = "ConfigMap"
+  "metadata" = {
+    "name" = "config"
+    "namespace" = "default"
+  }
+}
+`, hcl)
+}
+
+func TestPruneEmpty(t *testing.T) {
+	yaml := `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  labels: {}
+spec:
+  template:
+    metadata:
+      annotations: {}
+    spec:
+      nodeSelector: {}
+      tolerations: []
+      containers:
+      - name: web
+        resources: {}
+        securityContext:
+          capabilities: {}
+      volumes:
+      - name: cache
+        emptyDir: {}
+---
+apiVersion: networking.k8s.io/v1
+kind: NetworkPolicy
+metadata:
+  name: allow-all
+spec:
+  podSelector: {}
+  ingress:
+  - {}
+`
+	hcl, err := YAMLToTerraformResources(strings.NewReader(yaml), WithPruneEmpty(true), WithMapOnly(true))
+	assert.NoError(t, err)
+	assert.Equal(t, `{
+  "apiVersion" = "apps/v1"
+  "kind" = "Deployment"
+  "metadata" = {
+    "name" = "web"
+  }
+  "spec" = {
+    "template" = {
+      "spec" = {
+        "containers" = [
+          {
+            "name" = "web"
+          },
+        ]
+        "volumes" = [
+          {
+            "emptyDir" = {}
+            "name" = "cache"
+          },
+        ]
+      }
+    }
+  }
+}
+
+{
+  "apiVersion" = "networking.k8s.io/v1"
+  "kind" = "NetworkPolicy"
+  "metadata" = {
+    "name" = "allow-all"
+  }
+  "spec" = {
+    "ingress" = [
+      {},
+    ]
+    "podSelector" = {}
+  }
+}
+`, hcl)
+}
+
+func TestLoadStripFields(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tfk8s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "fields.txt")
+	err = ioutil.WriteFile(filename, []byte("# fields to strip\nmetadata.generation\n\n  spec.template.metadata.creationTimestamp\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields, err := loadStripFields(filename)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"metadata.generation", "spec.template.metadata.creationTimestamp"}, fields)
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+	"strings"
+
+	cty "github.com/zclconf/go-cty/cty"
+	yaml "sigs.k8s.io/yaml"
+)
+
+// substitution is a rule from the --substitutions file, the text match
+// matches in string values is replaced with a variable called variable
+type substitution struct {
+	match       *regexp.Regexp
+	variable    string
+	description string
+}
+
+// substitutionRule is how a rule is written in the --substitutions file
+type substitutionRule struct {
+	Match       string `json:"match"`
+	Variable    string `json:"variable"`
+	Description string `json:"description"`
+}
+
+// loadSubstitutions reads the rules of a --substitutions file, a YAML list
+// of regular expressions to match and the variables to replace them with
+func loadSubstitutions(path string) ([]substitution, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	rules := []substitutionRule{}
+	if err := yaml.UnmarshalStrict(b, &rules); err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	substitutions := []substitution{}
+	for i, rule := range rules {
+		if rule.Match == "" || rule.Variable == "" {
+			return nil, fmt.Errorf("%s: rule %d must have a match and a variable", path, i+1)
+		}
+		re, err := regexp.Compile(rule.Match)
+		if err != nil {
+			return nil, fmt.Errorf("%s: rule %d: %s", path, i+1, err)
+		}
+		if re.MatchString("") {
+			return nil, fmt.Errorf("%s: rule %d: %q matches an empty string", path, i+1, rule.Match)
+		}
+		substitutions = append(substitutions, substitution{re, strings.TrimPrefix(rule.Variable, "var."), rule.Description})
+	}
+	return substitutions, nil
+}
+
+// substitutedText is the text a rule matched, and the variable it was
+// replaced with
+type substitutedText struct {
+	text, expr string
+}
+
+// substitutionVariable returns the variable for the text a rule matched.
+// Every match of a rule is replaced with the same variable, so it is an
+// error for the rule to match different text, as the variable's default
+// can only be one of them.
+func (m *moduleVariables) substitutionVariable(s substitution, text, resourceName string, path cty.Path) (string, error) {
+	if matched, ok := m.substituted[s.variable]; ok {
+		if matched.text != text {
+			return "", fmt.Errorf("--substitutions rule %q for var.%s matched %q and %q at %s in %s, a rule can only match one value", s.match, s.variable, matched.text, text, formatPath(path), resourceName)
+		}
+		return matched.expr, nil
+	}
+	description := s.description
+	if description == "" {
+		description = fmt.Sprintf("Replaces %q", text)
+	}
+	expr := m.add(s.variable, description, "string", cty.StringVal(text), resourceName, path)
+	m.substituted[s.variable] = substitutedText{text, expr}
+	return expr, nil
+}
+
+// substitute replaces the text the substitutions match in s with their
+// variables, and returns the expression for it. ok is false if no rule
+// matches. The rules are applied in order, text a rule replaced isn't
+// matched again.
+func (m *moduleVariables) substitute(s, resourceName string, path cty.Path) (string, bool, error) {
+	// parts alternate between literal text and variables
+	type part struct {
+		text     string
+		variable string
+	}
+	parts := []part{{text: s}}
+	for _, sub := range m.substitutions {
+		next := []part{}
+		for _, p := range parts {
+			if p.variable != "" {
+				next = append(next, p)
+				continue
+			}
+			last := 0
+			for _, loc := range sub.match.FindAllStringIndex(p.text, -1) {
+				if loc[0] > last {
+					next = append(next, part{text: p.text[last:loc[0]]})
+				}
+				text := p.text[loc[0]:loc[1]]
+				variable, err := m.substitutionVariable(sub, text, resourceName, path)
+				if err != nil {
+					return "", false, err
+				}
+				next = append(next, part{text: text, variable: variable})
+				last = loc[1]
+			}
+			if last < len(p.text) {
+				next = append(next, part{text: p.text[last:]})
+			}
+		}
+		parts = next
+	}
+
+	if len(parts) == 1 && parts[0].variable != "" {
+		return parts[0].variable, true, nil
+	}
+	var buf strings.Builder
+	substituted := false
+	for _, p := range parts {
+		if p.variable != "" {
+			fmt.Fprintf(&buf, "${%s}", p.variable)
+			substituted = true
+			continue
+		}
+		quoted := strconv.Quote(templateEscaper.Replace(p.text))
+		buf.WriteString(quoted[1 : len(quoted)-1])
+	}
+	if !substituted {
+		return "", false, nil
+	}
+	return `"` + buf.String() + `"`, true, nil
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSubstitutions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tfk8s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	rules := filepath.Join(dir, "rules.yaml")
+	if err := ioutil.WriteFile(rules, []byte(`- match: example\.com
+  variable: var.domain
+  description: Domain the apps are served on
+- match: '\d+\.\d+\.\d+'
+  variable: version
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	substitutions, err := loadSubstitutions(rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	yaml := `apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  name: example.com
+  annotations:
+    note: '${literal} "quoted"'
+spec:
+  rules:
+  - host: api.example.com
+  - host: example.com
+  - host: v1.2.3.example.com
+  - host: v1.2.3.staging.example.com
+`
+	variables := newModuleVariables()
+	variables.extract = map[string]bool{}
+	variables.substitutions = substitutions
+	output, err := YAMLToTerraformResources(strings.NewReader(yaml), WithModuleVariables(variables))
+	if err != nil {
+		t.Fatal("Converting to HCL failed:", err)
+	}
+
+	expected := `
+resource "kubernetes_manifest" "ingress_example_com" {
+  manifest = {
+    "apiVersion" = "networking.k8s.io/v1"
+    "kind" = "Ingress"
+    "metadata" = {
+      "annotations" = {
+        "note" = "$${literal} \"quoted\""
+      }
+      "name" = var.domain
+    }
+    "spec" = {
+      "rules" = [
+        {
+          "host" = "api.${var.domain}"
+        },
+        {
+          "host" = var.domain
+        },
+        {
+          "host" = "v${var.version}.${var.domain}"
+        },
+        {
+          "host" = "v${var.version}.staging.${var.domain}"
+        },
+      ]
+    }
+  }
+}`
+	assert.Equal(t, strings.TrimSpace(expected), strings.TrimSpace(output))
+
+	assert.Equal(t, `variable "domain" {
+  description = "Domain the apps are served on, from metadata.name in ingress_example_com"
+  type        = string
+  default     = "example.com"
+}
+
+variable "version" {
+  description = "Replaces \"1.2.3\", from spec.rules[2].host in ingress_example_com"
+  type        = string
+  default     = "1.2.3"
+}
+`, variables.hcl())
+}
+
+func TestSubstitutionsConflict(t *testing.T) {
+	re := regexp.MustCompile(`\d+\.\d+\.\d+`)
+	yaml := `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: versions
+data:
+  api: 1.2.3
+  web: 2.0.0
+`
+	// every match of a rule is the same variable, so it can't have two values
+	variables := newModuleVariables()
+	variables.extract = map[string]bool{}
+	variables.substitutions = []substitution{{match: re, variable: "version"}}
+	_, err := YAMLToTerraformResources(strings.NewReader(yaml), WithModuleVariables(variables))
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), `--substitutions rule "\\d+\\.\\d+\\.\\d+" for var.version matched "1.2.3" and "2.0.0" at data.web in configmap_versions`)
+	}
+}
+
+func TestLoadSubstitutionsInvalid(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tfk8s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, rules := range []string{
+		"- match: example\\.com\n",
+		"- variable: domain\n",
+		"- match: '(example'\n  variable: domain\n",
+		"- match: 'a*'\n  variable: domain\n",
+		"- match: a\n  variable: domain\n  other: x\n",
+		"example\\.com: domain\n",
+	} {
+		path := filepath.Join(dir, "rules.yaml")
+		if err := ioutil.WriteFile(path, []byte(rules), 0644); err != nil {
+			t.Fatal(err)
+		}
+		_, err := loadSubstitutions(path)
+		assert.Error(t, err, rules)
+	}
+}
@@ -1,23 +1,18 @@
 package main
 
 import (
-	"bytes"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"os"
+	"path/filepath"
 	"regexp"
 	"runtime/debug"
 	"strings"
+	"time"
 
 	flag "github.com/spf13/pflag"
 
 	cty "github.com/zclconf/go-cty/cty"
-	ctyjson "github.com/zclconf/go-cty/cty/json"
-
-	yaml "sigs.k8s.io/yaml"
-
-	"github.com/jrhouston/tfk8s/contrib/hashicorp/terraform"
 )
 
 // toolVersion is the version that gets printed when you run --version
@@ -26,199 +21,951 @@ var toolVersion string
 // resourceType is the type of Terraform resource
 var resourceType = "kubernetes_manifest"
 
-// ignoreMetadata is the list of metadata fields to strip
-// when --strip is supplied
-var ignoreMetadata = []string{
-	"creationTimestamp",
-	"resourceVersion",
-	"selfLink",
-	"uid",
-	"managedFields",
-	"finalizers",
+// snakify converts "a-String LIKE this" to "a_string_like_this"
+func snakify(s string) string {
+	re := regexp.MustCompile(`\W`)
+	return strings.ToLower(re.ReplaceAllString(s, "_"))
 }
 
-// ignoreAnnotations is the list of annotations to strip
-// when --strip is supplied
-var ignoreAnnotations = []string{
-	"kubectl.kubernetes.io/last-applied-configuration",
+// options configures how manifests are converted to HCL
+type options struct {
+	providerAlias   string
+	stripServerSide bool
+	mapOnly         bool
+	stripKeyQuotes  bool
+	verbose         io.Writer
+	sourceName      string
+	onError         func(error)
+	skipInvalid     bool
+	warnings        io.Writer
+	binaryDataDir   string
+	binaryDataRef   string
+	// pending holds the files written while converting instead of
+	// writing them, if it is set
+	pending         *pendingFiles
+	format          string
+	crossplane      string
+	target          string
+	typed           bool
+	typedMapping    map[string]string
+	manifestDir     string
+	manifestRef     string
+	generateImports bool
+	module          *moduleVariables
+	modules         *moduleSet
+	wait            *waitConfig
+	fieldManager    string
+	forceConflicts  bool
+	// computedFields is set to add computed_fields for the fields
+	// controllers change, and the extraComputedFields
+	computedFields      bool
+	extraComputedFields []computedField
+	ignoreChanges       []ignoreChange
+	preventDestroy      map[string]bool
+	// stripFields are removed from every manifest, and replace the
+	// fields --strip removes if replaceStripDefaults is set
+	stripFields          []stripField
+	replaceStripDefaults bool
+	// keepStatus, keepManagedFields and keepDefaultNamespace keep the
+	// status, managedFields and default namespace that --strip removes
+	keepStatus           bool
+	keepManagedFields    bool
+	keepDefaultNamespace bool
+	// stripHelm and stripGitOps remove the labels and annotations of Helm
+	// and Kustomize, and of Argo CD and Flux
+	stripHelm   bool
+	stripGitOps bool
+	// serverDefaults is set to remove the fields the API server defaults
+	serverDefaults *serverDefaults
+	// owners are the field managers whose fields are kept
+	owners []string
+	// pruneEmpty removes the empty objects and lists from the manifests
+	pruneEmpty bool
+	// decodeSecrets moves the values of Secrets that are text to stringData
+	decodeSecrets bool
+	// redactSecrets replaces the values of Secrets with a placeholder
+	redactSecrets bool
+	// secrets are the variables the values of Secrets are replaced with
+	secrets *moduleVariables
+	// vault replaces the values of Secrets with references to Vault
+	vault *vaultSecrets
+	// externalSecrets replaces Secrets with ExternalSecrets
+	externalSecrets *externalSecrets
+	// randomKeys are the keys of Secrets whose values are generated with
+	// random_password
+	randomKeys []randomKey
+	// defaultNamespace is the namespace left out of the manifests and
+	// resource names, default if it isn't set
+	defaultNamespace string
 }
 
-// stripServerSideFields removes fields that have been added on the
-// server side after the resource was created such as the status field
-func stripServerSideFields(doc cty.Value) cty.Value {
-	m := doc.AsValueMap()
+// Option is a functional option for YAMLToTerraformResources
+type Option func(*options)
+
+// WithProviderAlias sets the provider attribute on each resource
+func WithProviderAlias(alias string) Option {
+	return func(o *options) {
+		o.providerAlias = alias
+	}
+}
+
+// WithStripServerSide strips out fields that were added by the server
+func WithStripServerSide(strip bool) Option {
+	return func(o *options) {
+		o.stripServerSide = strip
+	}
+}
+
+// WithStripFields removes fields from the manifests, as well as the fields
+// WithStripServerSide removes. With replace they are removed instead of
+// the server side fields.
+func WithStripFields(fields []stripField, replace bool) Option {
+	return func(o *options) {
+		o.stripFields = fields
+		o.replaceStripDefaults = replace
+	}
+}
+
+// WithKeepStatus keeps the status WithStripServerSide removes
+func WithKeepStatus(keep bool) Option {
+	return func(o *options) {
+		o.keepStatus = keep
+	}
+}
+
+// WithKeepManagedFields keeps the managedFields WithStripServerSide removes
+func WithKeepManagedFields(keep bool) Option {
+	return func(o *options) {
+		o.keepManagedFields = keep
+	}
+}
+
+// WithKeepDefaultNamespace keeps the default namespace WithStripServerSide
+// removes
+func WithKeepDefaultNamespace(keep bool) Option {
+	return func(o *options) {
+		o.keepDefaultNamespace = keep
+	}
+}
+
+// WithStripHelm removes the labels and annotations Helm and Kustomize add
+// to the objects they manage
+func WithStripHelm(strip bool) Option {
+	return func(o *options) {
+		o.stripHelm = strip
+	}
+}
+
+// WithStripGitOps removes the labels and annotations Argo CD and Flux add
+// to the objects they sync
+func WithStripGitOps(strip bool) Option {
+	return func(o *options) {
+		o.stripGitOps = strip
+	}
+}
+
+// WithStripDefaults removes the fields the API server of the cluster sets
+// to the same value when they are left out, found with server side dry runs
+func WithStripDefaults(cluster clusterOptions) Option {
+	return func(o *options) {
+		o.serverDefaults = &serverDefaults{cluster: cluster}
+	}
+}
+
+// WithOwnedBy only keeps the fields the field managers own in the
+// managedFields of each object, and removes the fields set by controllers
+func WithOwnedBy(managers ...string) Option {
+	return func(o *options) {
+		o.owners = managers
+	}
+}
+
+// WithDefaultNamespace sets the namespace WithStripServerSide leaves out of
+// the manifests, and the resource names leave out, instead of default
+func WithDefaultNamespace(namespace string) Option {
+	return func(o *options) {
+		o.defaultNamespace = namespace
+	}
+}
+
+// isDefaultNamespace returns true if namespace is the default namespace
+func (o options) isDefaultNamespace(namespace string) bool {
+	if o.defaultNamespace == "" {
+		return namespace == "default"
+	}
+	return namespace == o.defaultNamespace
+}
+
+// resourceName returns the label of the resource for the object of kind
+// called name in namespace, like deployment_nginx, the default namespace is
+// left out
+func (o options) resourceName(kind, namespace, name string) string {
+	resourceName := kind
+	if namespace != "" && !o.isDefaultNamespace(namespace) {
+		resourceName = resourceName + "_" + namespace
+	}
+	resourceName = resourceName + "_" + name
+	return snakify(resourceName)
+}
+
+// namespaceOrDefault returns namespace, or the default namespace if it is
+// empty
+func (o options) namespaceOrDefault(namespace string) string {
+	switch {
+	case namespace != "":
+		return namespace
+	case o.defaultNamespace != "":
+		return o.defaultNamespace
+	}
+	return "default"
+}
+
+// WithPruneEmpty removes the empty objects, maps and lists from the
+// manifests, except the ones that mean something like emptyDir
+func WithPruneEmpty(prune bool) Option {
+	return func(o *options) {
+		o.pruneEmpty = prune
+	}
+}
+
+// WithDecodeSecrets moves the base64 encoded values in the data of Secrets
+// to their stringData, decoded, when they are text
+func WithDecodeSecrets(decode bool) Option {
+	return func(o *options) {
+		o.decodeSecrets = decode
+	}
+}
+
+// WithRedactSecrets replaces the values in the data and stringData of
+// Secrets with a placeholder
+func WithRedactSecrets(redact bool) Option {
+	return func(o *options) {
+		o.redactSecrets = redact
+	}
+}
+
+// WithSecretVariables replaces the values in the data and stringData of
+// Secrets with sensitive variables, which are added to variables
+func WithSecretVariables(variables *moduleVariables) Option {
+	return func(o *options) {
+		o.secrets = variables
+	}
+}
+
+// WithVaultSecrets replaces the values in the data and stringData of
+// Secrets with references to a vault_kv_secret_v2 data source, which is
+// written with each Secret
+func WithVaultSecrets(vault *vaultSecrets) Option {
+	return func(o *options) {
+		o.vault = vault
+	}
+}
+
+// WithExternalSecrets replaces Secrets with External Secrets Operator
+// ExternalSecrets that read their values from a secret store, so the
+// values aren't in the config or the state
+func WithExternalSecrets(e *externalSecrets) Option {
+	return func(o *options) {
+		o.externalSecrets = e
+	}
+}
+
+// WithRandomPasswords replaces the values of keys in the data and
+// stringData of Secrets with the result of a random_password, which is
+// written with each Secret
+func WithRandomPasswords(keys ...randomKey) Option {
+	return func(o *options) {
+		o.randomKeys = append(o.randomKeys, keys...)
+	}
+}
+
+// WithMapOnly outputs only the HCL map structure of each manifest
+func WithMapOnly(mapOnly bool) Option {
+	return func(o *options) {
+		o.mapOnly = mapOnly
+	}
+}
+
+// WithStripKeyQuotes removes quotes from map keys unless they are required
+func WithStripKeyQuotes(strip bool) Option {
+	return func(o *options) {
+		o.stripKeyQuotes = strip
+	}
+}
+
+// WithVerbose writes notes about documents that were skipped to w
+func WithVerbose(w io.Writer) Option {
+	return func(o *options) {
+		o.verbose = w
+	}
+}
+
+// WithSourceName sets the name of the input used in notes and errors
+func WithSourceName(name string) Option {
+	return func(o *options) {
+		o.sourceName = name
+	}
+}
+
+// WithContinueOnError carries on converting the rest of the documents when
+// one fails, calling f with the error for each document that failed
+func WithContinueOnError(f func(error)) Option {
+	return func(o *options) {
+		o.onError = f
+	}
+}
+
+// WithSkipInvalid skips documents that don't have an apiVersion and kind,
+// instead of failing, so streams with other YAML in them can be converted
+func WithSkipInvalid(skip bool) Option {
+	return func(o *options) {
+		o.skipInvalid = skip
+	}
+}
+
+// WithWarnings writes warnings, such as documents skipped because they
+// were invalid, to w
+func WithWarnings(w io.Writer) Option {
+	return func(o *options) {
+		o.warnings = w
+	}
+}
+
+// WithExtractBinaryData writes ConfigMap binaryData and Secret data to
+// files in dir and reads them with filebase64() instead of inlining them.
+// ref is the path to dir from the directory the output is written to.
+func WithExtractBinaryData(dir, ref string) Option {
+	return func(o *options) {
+		o.binaryDataDir = dir
+		o.binaryDataRef = ref
+	}
+}
+
+// WithPendingFiles holds the files written while converting, like the
+// files of WithExtractBinaryData, in files instead of writing them
+func WithPendingFiles(files *pendingFiles) Option {
+	return func(o *options) {
+		o.pending = files
+	}
+}
+
+// WithCrossplaneObject wraps each manifest in a Crossplane
+// provider-kubernetes Object that uses the ProviderConfig providerConfig
+func WithCrossplaneObject(providerConfig string) Option {
+	return func(o *options) {
+		o.crossplane = providerConfig
+	}
+}
+
+// WithTarget sets the type of resource to generate, kubernetes_manifest or
+// kubectl_manifest for the kubectl provider. The default is
+// kubernetes_manifest.
+func WithTarget(target string) Option {
+	return func(o *options) {
+		o.target = target
+	}
+}
+
+// WithTyped generates the Kubernetes provider's native resources, like
+// kubernetes_deployment_v1, instead of kubernetes_manifest. Kinds that
+// don't have one are still converted to kubernetes_manifest.
+func WithTyped(typed bool) Option {
+	return func(o *options) {
+		o.typed = typed
+	}
+}
+
+// WithTypedMapping overrides and extends the resource types used with
+// WithTyped, it maps the apiVersion and kind, like apps/v1/Deployment, to
+// a resource type
+func WithTypedMapping(mapping map[string]string) Option {
+	return func(o *options) {
+		o.typedMapping = mapping
+	}
+}
+
+// WithManifestDir sets the directory --format yamlref writes the manifests
+// to. ref is the path to dir from the directory the output is written to.
+func WithManifestDir(dir, ref string) Option {
+	return func(o *options) {
+		o.manifestDir = dir
+		o.manifestRef = ref
+	}
+}
+
+// WithGenerateImports adds an import block after each resource, so
+// Terraform adopts objects that already exist in the cluster. It is only
+// supported by the formats that write Terraform config.
+func WithGenerateImports(generate bool) Option {
+	return func(o *options) {
+		o.generateImports = generate
+	}
+}
+
+// WithModuleVariables makes the namespace, image tags and replica counts
+// of the manifests into module variables, which are added to variables
+func WithModuleVariables(variables *moduleVariables) Option {
+	return func(o *options) {
+		o.module = variables
+	}
+}
+
+// WithModulePer groups the resources into modules by the value of a
+// label, with the variables of each module added to modules
+func WithModulePer(modules *moduleSet) Option {
+	return func(o *options) {
+		o.modules = modules
+	}
+}
+
+// WithWait sets what kubernetes_manifest resources wait for after they are
+// applied, like the rollout of Deployments or a status condition
+func WithWait(wait *waitConfig) Option {
+	return func(o *options) {
+		o.wait = wait
+	}
+}
+
+// WithFieldManager adds a field_manager block to kubernetes_manifest
+// resources, so the objects are applied as the field manager name. With
+// force the resources take over fields other field managers own, like
+// kubectl or Helm when adopting objects they created.
+func WithFieldManager(name string, force bool) Option {
+	return func(o *options) {
+		o.fieldManager = name
+		o.forceConflicts = force
+	}
+}
+
+// WithComputedFields sets computed_fields on kubernetes_manifest resources
+// to the fields controllers and admission plugins are known to change,
+// like the clusterIP of a Service, and the extra fields
+func WithComputedFields(extra ...computedField) Option {
+	return func(o *options) {
+		o.computedFields = true
+		o.extraComputedFields = extra
+	}
+}
+
+// WithIgnoreChanges adds the attributes in changes to the ignore_changes of
+// the resources' lifecycle blocks
+func WithIgnoreChanges(changes ...ignoreChange) Option {
+	return func(o *options) {
+		o.ignoreChanges = changes
+	}
+}
+
+// WithPreventDestroy adds prevent_destroy to the lifecycle of the
+// resources of kinds, to protect stateful objects like
+// PersistentVolumeClaims from being destroyed by mistake
+func WithPreventDestroy(kinds ...string) Option {
+	return func(o *options) {
+		o.preventDestroy = preventDestroyKinds(kinds)
+	}
+}
+
+// WithFormat sets the syntax the resources are written in, like hcl or
+// tfjson. The default is hcl.
+func WithFormat(format string) Option {
+	return func(o *options) {
+		o.format = format
+	}
+}
+
+// note writes a message to the verbose writer if one was set
+func (o options) note(format string, a ...interface{}) {
+	o.log(o.verbose, "note", format, a...)
+}
+
+// warn writes a message to the warnings writer if one was set
+func (o options) warn(format string, a ...interface{}) {
+	o.log(o.warnings, "warning", format, a...)
+}
+
+// log writes a message with its level and the name of the input to w
+func (o options) log(w io.Writer, level, format string, a ...interface{}) {
+	if w == nil {
+		return
+	}
+	msg := fmt.Sprintf(format, a...)
+	if o.sourceName != "" && o.sourceName != "-" {
+		msg = o.sourceName + ": " + msg
+	}
+	fmt.Fprintf(w, "%s: %s\n", level, msg)
+}
+
+// writeFile writes a file the config reads, or holds it if the files are
+// pending
+func (o options) writeFile(f pendingFile) error {
+	if o.pending != nil {
+		o.pending.files = append(o.pending.files, f)
+		return nil
+	}
+	return writeFile(f)
+}
+
+// sourceError adds the name of the input to err
+func (o options) sourceError(err error) error {
+	if de, ok := err.(*documentError); ok {
+		de.source = o.sourceName
+		return de
+	}
+	if o.sourceName == "" || o.sourceName == "-" {
+		return err
+	}
+	return fmt.Errorf("%s: %s", o.sourceName, err)
+}
 
-	// strip server-side metadata
-	metadata := m["metadata"].AsValueMap()
-	for _, f := range ignoreMetadata {
-		delete(metadata, f)
+// listItems returns the items of a List document such as the output of
+// kubectl get -o json. Lists returned by the API like DeploymentList leave
+// out the apiVersion and kind of their items, so they are set from the list.
+func listItems(list cty.Value) []cty.Value {
+	m := list.AsValueMap()
+	items, ok := m["items"]
+	if !ok || items.IsNull() || !items.CanIterateElements() {
+		return nil
 	}
-	if v, ok := metadata["annotations"]; ok {
-		annotations := v.AsValueMap()
-		for _, a := range ignoreAnnotations {
-			delete(annotations, a)
+
+	kind := strings.TrimSuffix(m["kind"].AsString(), "List")
+	apiVersion, hasAPIVersion := m["apiVersion"]
+
+	docs := []cty.Value{}
+	for _, item := range items.AsValueSlice() {
+		if !item.Type().IsObjectType() {
+			docs = append(docs, item)
+			continue
+		}
+		mm := item.AsValueMap()
+		if mm == nil {
+			mm = map[string]cty.Value{}
 		}
-		if len(annotations) == 0 {
-			delete(metadata, "annotations")
-		} else {
-			metadata["annotations"] = cty.ObjectVal(annotations)
+		if _, ok := mm["kind"]; !ok && kind != "" {
+			mm["kind"] = cty.StringVal(kind)
 		}
+		if _, ok := mm["apiVersion"]; !ok && hasAPIVersion {
+			mm["apiVersion"] = apiVersion
+		}
+		docs = append(docs, cty.ObjectVal(mm))
 	}
-	if ns, ok := metadata["namespace"]; ok && ns.AsString() == "default" {
-		delete(metadata, "namespace")
+	return docs
+}
+
+// stringAttr returns the value of a string attribute, and false if it is
+// missing, empty, not a string or a Terraform expression
+func stringAttr(m map[string]cty.Value, attr string) (string, bool) {
+	v, ok := m[attr]
+	if !ok || v.IsMarked() || v.IsNull() || v.Type() != cty.String || v.AsString() == "" {
+		return "", false
 	}
-	m["metadata"] = cty.ObjectVal(metadata)
+	return v.AsString(), true
+}
 
-	// strip finalizer from spec
-	if v, ok := m["spec"]; ok {
-		mm := v.AsValueMap()
-		delete(mm, "finalizers")
-		m["spec"] = cty.ObjectVal(mm)
+// isManifest returns true if the document has an apiVersion and a kind
+func isManifest(doc cty.Value) bool {
+	if !doc.Type().IsObjectType() {
+		return false
 	}
+	m := doc.AsValueMap()
+	_, hasAPIVersion := stringAttr(m, "apiVersion")
+	_, hasKind := stringAttr(m, "kind")
+	return hasAPIVersion && hasKind
+}
 
-	// strip status field
-	delete(m, "status")
+// validateManifest checks that the manifest has the fields needed to
+// generate a resource for it, and returns an error naming the field that
+// is missing if it doesn't. The name is only needed for the resource
+// label so it isn't required when outputting maps.
+func validateManifest(doc cty.Value, requireName bool) error {
+	if !doc.Type().IsObjectType() {
+		return fmt.Errorf("the manifest must be an object")
+	}
+	m := doc.AsValueMap()
+	kind, ok := stringAttr(m, "kind")
+	if !ok {
+		return fmt.Errorf("the manifest is missing kind")
+	}
+	if strings.HasSuffix(kind, "List") {
+		// the items of a list are checked on their own
+		return nil
+	}
 
-	return cty.ObjectVal(m)
+	md, ok := m["metadata"]
+	if !ok || md.IsNull() || !md.Type().IsObjectType() {
+		return fmt.Errorf("%s is missing metadata", kind)
+	}
+	metadata := md.AsValueMap()
+	if v, ok := metadata["namespace"]; ok && !v.IsNull() && v.Type() != cty.String {
+		return fmt.Errorf("%s has a metadata.namespace that is not a string", kind)
+	}
+	if !requireName {
+		return nil
+	}
+	_, hasName := stringAttr(metadata, "name")
+	_, hasGenerateName := stringAttr(metadata, "generateName")
+	if !hasName && !hasGenerateName {
+		return fmt.Errorf("%s is missing metadata.name, set metadata.name or metadata.generateName", kind)
+	}
+	return nil
 }
 
-// snakify converts "a-String LIKE this" to "a_string_like_this"
-func snakify(s string) string {
-	re := regexp.MustCompile(`\W`)
-	return strings.ToLower(re.ReplaceAllString(s, "_"))
+// resource is a manifest that has been converted to Terraform config
+type resource struct {
+	// resourceType is the type of the Terraform resource, like
+	// kubernetes_manifest
+	resourceType string
+	// name is the label of the resource, like deployment_nginx
+	name string
+	// objectName is the name of the object in the manifest
+	objectName string
+	kind       string
+	namespace  string
+	// manifest is the manifest after fields have been stripped and
+	// binary data extracted, the output formats are written from it
+	manifest cty.Value
+	// typed is set if the resource is one of the provider's native
+	// resources instead of a manifest
+	typed bool
+	// provider is the provider alias set with --provider
+	provider string
+	// source is the document the manifest came from as it was written, it
+	// is empty if the manifest was changed while it was converted
+	source string
+	// importID is the ID of the object to import with --generate-imports
+	importID string
+	// module is the module --module-per puts the resource in
+	module string
+	// providerFunctions is set if the config calls the provider's
+	// functions, which need Terraform 1.8
+	providerFunctions bool
+	// wait is the wait block of the resource, nil if it doesn't wait
+	wait *resourceWait
+	// computedFields is the computed_fields argument of the resource
+	computedFields []string
+	// ignoreChanges is the ignore_changes of the resource's lifecycle
+	ignoreChanges []string
+	// preventDestroy is set if the resource's lifecycle has
+	// prevent_destroy
+	preventDestroy bool
+	// vault is the data source the values of a Secret are read from, nil
+	// unless --secrets-to-vault is used
+	vault *vaultSecret
+	// randomPasswords are the labels of the random_passwords that generate
+	// the values of a Secret
+	randomPasswords []string
+	// dependsOn is the addresses of the resources --depends-on found this
+	// resource needs to be created first
+	dependsOn []string
+	// forEach is the map --consolidate sets for_each to, it is null unless
+	// the resource stands for several objects
+	forEach cty.Value
+	// text is the config for the resource in the output format
+	text string
 }
 
-// escape incidences of ${} with $${} to prevent Terraform trying to interpolate them
-func escapeShellVars(s string) string {
-	r := regexp.MustCompile(`(\${.*?})`)
-	return r.ReplaceAllString(s, `$$$1`)
+// changesManifests returns true if the manifests are changed while they are
+// converted, so they aren't the documents they were read from anymore
+func (o options) changesManifests() bool {
+	return o.stripServerSide || len(o.fieldsToStrip()) > 0 || o.serverDefaults != nil || len(o.owners) > 0 ||
+		o.pruneEmpty || o.decodeSecrets || o.redactSecrets || o.secrets != nil || o.vault != nil || len(o.randomKeys) > 0 || o.externalSecrets != nil || o.binaryDataDir != "" || o.crossplane != ""
 }
 
-// yamlToHCL converts a single YAML document Terraform HCL
-func yamlToHCL(
-	doc cty.Value, providerAlias string,
-	stripServerSide bool, mapOnly bool, stripKeyQuotes bool) (string, error) {
-	m := doc.AsValueMap()
+// yamlToResources converts a single YAML document to Terraform resources,
+// Lists produce one resource for each item. source is the text of the
+// document.
+func yamlToResources(doc cty.Value, source string, opts options) ([]resource, error) {
+	format, err := lookupFormat(opts.format)
+	if err != nil {
+		return nil, err
+	}
+	target, err := lookupTarget(opts.target)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateManifest(doc, !opts.mapOnly); err != nil {
+		return nil, err
+	}
 	docs := []cty.Value{doc}
-	if strings.HasSuffix(m["kind"].AsString(), "List") {
-		docs = m["items"].AsValueSlice()
+	isList := strings.HasSuffix(doc.GetAttr("kind").AsString(), "List")
+	if isList {
+		docs = listItems(doc)
 	}
 
-	hcl := ""
+	resources := []resource{}
 	for i, doc := range docs {
+		if err := validateManifest(doc, !opts.mapOnly); err != nil {
+			if isList {
+				return nil, fmt.Errorf("item %d: %s", i+1, err)
+			}
+			return nil, err
+		}
 		mm := doc.AsValueMap()
 		kind := mm["kind"].AsString()
 		metadata := mm["metadata"].AsValueMap()
-		var namespace string
-		if v, ok := metadata["namespace"]; ok {
-			namespace = v.AsString()
-		}
-
-		var name string
-		if n, ok := metadata["name"]; ok {
-			name = n.AsString()
-		} else if n, ok := metadata["generateName"]; ok {
-			name = n.AsString()
-			if name[len(name)-1] == '-' {
-				name = name[:len(name)-1]
+		namespace, _ := stringAttr(metadata, "namespace")
+
+		name, ok := stringAttr(metadata, "name")
+		if !ok {
+			name, _ = stringAttr(metadata, "generateName")
+			if !opts.mapOnly {
+				opts.warn("%s %s uses metadata.generateName, %s needs metadata.name to be set before it can be applied", kind, name, target)
 			}
+			name = strings.TrimSuffix(name, "-")
 		}
 
-		resourceName := kind
-		if namespace != "" && namespace != "default" {
-			resourceName = resourceName + "_" + namespace
+		if opts.externalSecrets != nil && kind == "Secret" {
+			kind = "ExternalSecret"
+			doc, err = opts.externalSecrets.externalSecret(doc, opts.namespaceOrDefault(namespace), name, opts.resourceName(kind, namespace, name))
+			if err != nil {
+				return nil, err
+			}
+			mm = doc.AsValueMap()
 		}
-		resourceName = resourceName + "_" + name
-		resourceName = snakify(resourceName)
+		resourceName := opts.resourceName(kind, namespace, name)
 
-		if stripServerSide {
-			doc = stripServerSideFields(doc)
+		if len(opts.owners) > 0 {
+			var owned bool
+			if doc, owned = keepOwnedFields(doc, opts.owners); !owned {
+				opts.warn("%s %s has no fields managed by %s in its managedFields, they are all kept", kind, name, strings.Join(opts.owners, " or "))
+			}
 		}
-		s := terraform.FormatValue(doc, 0, stripKeyQuotes)
-		s = escapeShellVars(s)
-
-		if mapOnly {
-			hcl += fmt.Sprintf("%v\n", s)
-		} else {
-			hcl += fmt.Sprintf("resource %q %q {\n", resourceType, resourceName)
-			if providerAlias != "" {
-				hcl += fmt.Sprintf("  provider = %v\n\n", providerAlias)
+		if opts.serverDefaults != nil {
+			if doc, err = opts.serverDefaults.strip(doc); err != nil {
+				opts.warn("the defaults of %s %s can't be found, they are kept: %s", kind, name, err)
+			}
+		}
+		variables, module := opts.module, ""
+		if opts.modules != nil {
+			module, variables = opts.modules.module(metadata)
+		}
+		if variables != nil && variables.sharedNamespace {
+			doc = variables.parameterizeNamespace(doc, kind, opts.namespaceOrDefault(namespace), resourceName)
+		}
+		if opts.stripServerSide || len(opts.fieldsToStrip()) > 0 {
+			doc = stripServerSideFields(doc, kind, opts)
+		}
+		if opts.pruneEmpty {
+			doc = pruneEmpty(doc)
+		}
+		if opts.decodeSecrets && kind == "Secret" {
+			doc = decodeSecret(doc)
+		}
+		if opts.redactSecrets && kind == "Secret" {
+			doc = redactSecret(doc)
+		}
+		var passwords []string
+		if len(opts.randomKeys) > 0 && kind == "Secret" {
+			doc, passwords = randomPasswords(doc, name, resourceName, opts.randomKeys)
+		}
+		if opts.secrets != nil && kind == "Secret" {
+			doc = opts.secrets.secretVariables(doc, name, resourceName)
+		}
+		var vault *vaultSecret
+		if opts.vault != nil && kind == "Secret" {
+			if doc, vault, err = opts.vault.reference(doc, opts.namespaceOrDefault(namespace), name, resourceName); err != nil {
+				return nil, err
+			}
+		}
+		if opts.binaryDataDir != "" {
+			doc, err = extractBinaryData(doc, name, resourceName, opts)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if variables != nil {
+			doc, err = variables.parameterize(doc, kind, name, resourceName)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if opts.crossplane != "" {
+			doc = crossplaneObject(doc, resourceName, opts.crossplane)
+		}
+		typ, typed := target, false
+		if opts.typed {
+			apiVersion := mm["apiVersion"].AsString()
+			if t, ok := typedResourceType(apiVersion, kind, opts.typedMapping); ok {
+				typ, typed = t, true
+			} else {
+				opts.note("there is no typed resource for %s %s, using %s", apiVersion, kind, target)
+			}
+		}
+		r := resource{
+			resourceType:    typ,
+			typed:           typed,
+			name:            resourceName,
+			objectName:      name,
+			kind:            kind,
+			namespace:       namespace,
+			manifest:        doc,
+			provider:        opts.providerAlias,
+			module:          module,
+			vault:           vault,
+			randomPasswords: passwords,
+		}
+		if typ == resourceType && opts.crossplane == "" {
+			r.wait = opts.wait.forKind(kind)
+			if opts.computedFields {
+				r.computedFields = resourceComputedFields(doc, kind, opts.extraComputedFields)
 			}
-			hcl += fmt.Sprintf("  manifest = %v\n", strings.ReplaceAll(s, "\n", "\n  "))
-			hcl += fmt.Sprintf("}\n")
 		}
-		if i != len(docs)-1 {
-			hcl += "\n"
+		r.ignoreChanges = resourceIgnoreChanges(kind, opts.ignoreChanges)
+		r.preventDestroy = opts.preventDestroy[kind]
+		if !isList && !opts.changesManifests() && variables == nil {
+			r.source = source
 		}
+		if opts.generateImports {
+			if r.importID = importID(r); r.importID == "" {
+				opts.note("%s %s has no metadata.name, so no import block is generated", kind, name)
+			}
+		}
+		text, err := format.resource(r, opts)
+		if err != nil {
+			return nil, err
+		}
+		r.text = text
+		resources = append(resources, r)
 	}
 
-	return hcl, nil
+	return resources, nil
 }
 
-var yamlSeparator = "\n---"
+// documentToResources converts a single document from a stream to
+// Terraform resources. Empty documents are skipped and produce no resources.
+func documentToResources(d document, o options) ([]resource, error) {
+	doc, err := parseDocument(d.json)
+	if err != nil {
+		return nil, err
+	}
 
-// YAMLToTerraformResources takes a file containing one or more Kubernetes configs
-// and converts it to resources that can be used by the Terraform Kubernetes Provider
-//
-// FIXME this function has too many arguments now, use functional options instead
-func YAMLToTerraformResources(
-	r io.Reader, providerAlias string, stripServerSide bool,
-	mapOnly bool, stripKeyQuotes bool) (string, error) {
-	hcl := ""
-
-	buf := bytes.Buffer{}
-	_, err := buf.ReadFrom(r)
+	if doc.IsNull() {
+		// skip empty YAML docs
+		o.note("skipping empty document %d", d.index)
+		return nil, nil
+	}
+
+	if o.skipInvalid && !isManifest(doc) {
+		o.warn("skipping document %d, it is not a Kubernetes manifest with an apiVersion and kind", d.index)
+		return nil, nil
+	}
+
+	if !doc.Type().IsObjectType() {
+		return nil, fmt.Errorf("the manifest must be a YAML or JSON document")
+	}
+
+	resources, err := yamlToResources(doc, d.raw, o)
 	if err != nil {
-		return "", err
+		return nil, fmt.Errorf("error converting YAML to HCL: %s", err)
 	}
+	return resources, nil
+}
 
-	count := 0
-	manifest := string(buf.Bytes())
-	docs := strings.Split(manifest, yamlSeparator)
-	for _, doc := range docs {
-		if strings.TrimSpace(doc) == "" {
-			// some manifests have empty documents
-			continue
+// streamResources reads a stream of Kubernetes configs and calls emit with
+// each resource as soon as the document it came from has been converted
+func streamResources(r io.Reader, o options, emit func(resource) error) error {
+	docs := newDocumentReader(r)
+	for {
+		d, err := docs.next()
+		if err == io.EOF {
+			return nil
 		}
-
-		var b []byte
-		b, err = yaml.YAMLToJSON([]byte(doc))
 		if err != nil {
-			return "", err
+			if _, ok := err.(*documentError); ok && o.onError != nil {
+				o.onError(o.sourceError(err))
+				continue
+			}
+			return o.sourceError(err)
 		}
 
-		t, err := ctyjson.ImpliedType(b)
+		resources, err := documentToResources(d, o)
 		if err != nil {
-			return "", err
+			err = o.sourceError(&documentError{index: d.index, line: d.line, err: err})
+			if o.onError != nil {
+				o.onError(err)
+				continue
+			}
+			return err
 		}
 
-		doc, err := ctyjson.Unmarshal(b, t)
-		if err != nil {
-			return "", err
+		for _, r := range resources {
+			if err := emit(r); err != nil {
+				return err
+			}
 		}
+	}
+}
 
-		if doc.IsNull() {
-			// skip empty YAML docs
-			continue
-		}
+// newOptions applies opts to the default options
+func newOptions(opts []Option) options {
+	o := options{}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
 
-		if !doc.Type().IsObjectType() {
-			return "", fmt.Errorf("the manifest must be a YAML document")
-		}
+// convertResources converts every Kubernetes config in r to Terraform resources
+func convertResources(r io.Reader, opts ...Option) ([]resource, error) {
+	resources := []resource{}
+	err := streamResources(r, newOptions(opts), func(r resource) error {
+		resources = append(resources, r)
+		return nil
+	})
+	return resources, err
+}
 
-		formatted, err := yamlToHCL(doc, providerAlias, stripServerSide, mapOnly, stripKeyQuotes)
+// StreamYAMLToTerraformResources reads a stream of Kubernetes configs and
+// writes each one to w as a Terraform resource as soon as it has been read,
+// so it can be used with watch pipelines like kubectl get -w
+func StreamYAMLToTerraformResources(r io.Reader, w io.Writer, opts ...Option) error {
+	o := newOptions(opts)
+	format, err := lookupFormat(o.format)
+	if err != nil {
+		return err
+	}
+	if _, ok := format.(hclFormat); !ok {
+		// the other formats are a single document, so they can only be
+		// written once every resource has been converted
+		resources := []resource{}
+		err := streamResources(r, o, func(r resource) error {
+			resources = append(resources, r)
+			return nil
+		})
 		if err != nil {
-			return "", fmt.Errorf("error converting YAML to HCL: %s", err)
+			return err
 		}
-
-		if count > 0 {
-			hcl += "\n"
+		text, err := joinResources(format, resources)
+		if err != nil {
+			return err
 		}
-		hcl += formatted
-		count++
+		_, err = io.WriteString(w, text)
+		return err
 	}
 
-	return hcl, nil
+	count := 0
+	return streamResources(r, o, func(r resource) error {
+		for _, r := range withSecretBlocks([]resource{r}) {
+			hcl := r.text
+			if count > 0 {
+				hcl = "\n" + hcl
+			}
+			count++
+			if _, err := io.WriteString(w, hcl); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// YAMLToTerraformResources takes a file containing one or more Kubernetes configs
+// and converts it to resources that can be used by the Terraform Kubernetes Provider.
+// The configs can be either YAML or JSON.
+func YAMLToTerraformResources(r io.Reader, opts ...Option) (string, error) {
+	var hcl strings.Builder
+	if err := StreamYAMLToTerraformResources(r, &hcl, opts...); err != nil {
+		return "", err
+	}
+	return hcl.String(), nil
 }
 
 func capturePanic() {
@@ -235,16 +982,127 @@ func capturePanic() {
 	}
 }
 
+// exitOnFailures prints the errors collected with --continue-on-error
+// and exits with a non-zero status if there were any
+func exitOnFailures(failures []error) {
+	if len(failures) == 0 {
+		return
+	}
+	for _, err := range failures {
+		fmt.Fprintln(os.Stderr, "error:", err)
+	}
+	fmt.Fprintf(os.Stderr, "%d documents failed to convert\n", len(failures))
+	os.Exit(1)
+}
+
 func main() {
 	defer capturePanic()
 
-	infile := flag.StringP("file", "f", "-", "Input file containing Kubernetes YAML manifests")
-	outfile := flag.StringP("output", "o", "-", "Output file to write Terraform config")
+	if len(os.Args) > 1 && os.Args[1] == compareCommand {
+		os.Exit(runCompare(os.Args[2:]))
+	}
+
+	infiles := flag.StringArrayP("file", "f", []string{"-"}, "Input file, directory, URL, OCI artifact or git repository containing Kubernetes YAML or JSON manifests, can be repeated")
+	outfile := flag.StringP("output", "o", "-", "Output file to write Terraform config")
+	appendOutput := flag.Bool("append", false, "Add the resources to the --output file instead of overwriting it, resources already in the file are left alone")
+	replaceExisting := flag.Bool("replace-existing", false, "With --append, replace the resources that are already in the --output file")
+	outputDir := flag.String("output-dir", "", "Directory to write each resource to its own file in, instead of using --output")
+	maxResourcesPerFile := flag.Int("max-resources-per-file", 0, "Split files with more resources than this into numbered files when using --output or --output-dir")
+	filenameTemplate := flag.String("filename-template", "", "Go template for the file each resource is written to when using --output-dir, like '{{.Namespace}}_{{.Kind}}_{{.Name}}.tf'")
+	groupBy := flag.String("group-by", "", "Group resources into files by namespace or kind when using --output-dir")
 	providerAlias := flag.StringP("provider", "p", "", "Provider alias to populate the `provider` attribute")
 	stripServerSide := flag.BoolP("strip", "s", false, "Strip out server side fields - use if you are piping from kubectl get")
+	stripFieldFlags := flag.StringArray("strip-field", nil, "Field to remove from the manifests, like spec.template.metadata.creationTimestamp or metadata.annotations.\"deployment.kubernetes.io/revision\", can be repeated. Start it with a kind like Service: to only remove it from that kind")
+	stripFieldsFile := flag.String("strip-fields-file", "", "File with a --strip-field on each line")
+	stripHelm := flag.Bool("strip-helm", false, "Remove the labels and annotations Helm and Kustomize use to keep track of the objects, like helm.sh/chart, app.kubernetes.io/managed-by: Helm and meta.helm.sh/release-name")
+	stripGitOps := flag.Bool("strip-gitops", false, "Remove the argocd.argoproj.io/* and kustomize.toolkit.fluxcd.io/* labels and annotations Argo CD and Flux use to track the objects")
+	stripDefaults := flag.Bool("strip-defaults", false, "Remove the fields the API server sets to their default values, found with a server side dry run of each object in the cluster of --kubeconfig and --context")
+	ownedBy := flag.StringSlice("owned-by", nil, "Only keep the fields these field managers own in metadata.managedFields, like kubectl-client-side-apply, and remove the fields controllers set")
+	defaultNamespace := flag.String("default-namespace", "default", "The namespace --strip leaves out of the manifests and the resource names leave out, for clusters that use another namespace as their default")
+	decodeSecrets := flag.Bool("decode-secrets", false, "Move the values in the data of Secrets to stringData, decoded, when they are text")
+	redactSecrets := flag.Bool("redact-secrets", false, "Replace the values in the data and stringData of Secrets with \"REDACTED\", so the config can be committed")
+	secretsToVars := flag.Bool("secrets-to-vars", false, "Replace the values in the data and stringData of Secrets with sensitive variables, and write a variables.tf that declares them next to the output")
+	secretsTFVars := flag.String("secrets-tfvars", "", "Write a tfvars file that sets the --secrets-to-vars variables to the values of the Secrets, and add it to the .gitignore next to it")
+	secretsRandom := flag.StringSlice("secrets-random", nil, "Keys of Secrets whose values are throwaway credentials to generate with a random_password, written with each Secret, like password or db:password to only generate it in the Secret db")
+	failOnSecrets := flag.Bool("fail-on-secrets", false, "Exit with an error and a report instead of writing the output if it has the values of Secrets, private keys or high entropy strings that look like credentials, so CI can block them from being committed")
+	secretsToExternalSecrets := flag.Bool("secrets-to-external-secrets", false, "Replace Secrets with External Secrets Operator ExternalSecrets that read their values from the --secret-store, so the values aren't in the state")
+	secretStore := flag.String("secret-store", "", "The SecretStore the ExternalSecrets read the values of Secrets from with --secrets-to-external-secrets")
+	secretStoreKind := flag.String("secret-store-kind", "SecretStore", "The kind of the --secret-store, SecretStore or ClusterSecretStore")
+	externalSecretKey := flag.String("external-secret-key", defaultSecretPath, "Go template for the key of the secret in the --secret-store each Secret's values are read from, like 'k8s/{{.Namespace}}/{{.Name}}'")
+	secretsToVault := flag.Bool("secrets-to-vault", false, "Replace the values in the data and stringData of Secrets with references to a vault_kv_secret_v2 data source, written with each Secret")
+	vaultMount := flag.String("vault-mount", "secret", "The mount of the KV version 2 secrets engine --secrets-to-vault reads the values from")
+	vaultPath := flag.String("vault-path", defaultVaultPath, "Go template for the name of the secret in the --vault-mount each Secret's values are read from, like 'k8s/{{.Namespace}}/{{.Name}}'")
+	sopsEncrypt := flag.Bool("sops-encrypt", false, "Encrypt the --secrets-tfvars file with sops instead of adding it to the .gitignore, it must end in .tfvars.json")
+	pruneEmptyFields := flag.Bool("prune-empty", false, "Remove the empty objects and lists from the manifests, like labels: {} left after stripping, except the ones that mean something like emptyDir: {}")
+	keepDefaultNamespace := flag.Bool("keep-default-namespace", false, "Keep the metadata.namespace that --strip removes when it is the default namespace")
+	keepStatus := flag.Bool("keep-status", false, "Keep the status that --strip removes")
+	keepManagedFields := flag.Bool("keep-managed-fields", false, "Keep the metadata.managedFields that --strip removes")
+	deleteExprs := flag.StringArray("delete", nil, "Field to remove with a JSONPath or jq style expression, like $..protocol or 'metadata.annotations | select(startswith(\"autoscaling.\"))', can be repeated")
+	replaceStripDefaults := flag.Bool("replace-strip-defaults", false, "Only remove the --strip-field fields with --strip, instead of adding them to the fields it removes")
 	version := flag.BoolP("version", "V", false, "Show tool version")
 	mapOnly := flag.BoolP("map-only", "M", false, "Output only an HCL map structure")
+	target := flag.String("target", resourceType, "Type of resource to generate, kubernetes_manifest or kubectl_manifest for the kubectl provider")
+	typed := flag.Bool("typed", false, "Generate the Kubernetes provider's native resources, like kubernetes_deployment_v1, instead of kubernetes_manifest")
+	typedMapping := flag.String("typed-mapping", "", "YAML file mapping apiVersion/kind to the resource type to use with --typed, like 'apps/v1/Deployment: kubernetes_deployment'")
+	generateImports := flag.Bool("generate-imports", false, "Add an import block for each resource so Terraform 1.5 and later adopts the objects that are already in the cluster")
+	importScript := flag.String("import-script", "", "Write a shell script to this file that runs terraform import for each resource, for Terraform versions before 1.5")
+	initScaffold := flag.Bool("init-scaffold", false, "Also write a versions.tf next to the output with the terraform block and the providers the resources need, so the directory can be initialized straight away")
+	backend := flag.String("backend", "", "Add a backend block to the --init-scaffold terraform block, one of "+strings.Join(backends, ", "))
+	backendBucket := flag.String("backend-bucket", "", "Bucket to store the state in with --backend s3 or gcs")
+	backendKey := flag.String("backend-key", "", "Key of the state in the bucket with --backend s3, the prefix with gcs, or the path of the state file with local")
+	backendRegion := flag.String("backend-region", "", "Region of the bucket with --backend s3")
+	asModule := flag.Bool("as-module", false, "Write the resources to --output-dir as a module with main.tf, variables.tf and outputs.tf, making the namespace, image tags and replica counts into variables")
+	extract := flag.StringSlice("extract", nil, "Only make these values into variables, instead of the namespace, image tags and replica counts --extract-variables and --as-module make: images for the image of each container, replicas for the replica counts and resources for the resource requests and limits of each container")
+	parameterizeNamespace := flag.Bool("parameterize-namespace", false, "Make the namespaces of the objects, and the namespaces RoleBindings, webhooks and APIServices refer to into a namespace variable, so the config can be applied to any namespace. Can be used with --extract")
+	substitutionsFile := flag.String("substitutions", "", "YAML file with a list of rules like '- {match: example\\.com, variable: domain}' that replace the text the regular expressions match in string values with variables, written to a variables.tf with the matched text as their defaults")
+	extractVariables := flag.Bool("extract-variables", false, "Make the namespace, image tags and replica counts into variables, and write a variables.tf with their current values as defaults next to the output")
+	referenceNamesFlag := flag.Bool("reference-names", false, "Replace the names of the ConfigMaps, Secrets and ServiceAccounts workloads refer to with references to their resources, when they are converted in the same run")
+	crdsOutput := flag.String("crds-output", "", "Write the CustomResourceDefinitions to this file instead of the output, so they can be applied before the custom resources that need them")
+	waitRollout := flag.Bool("wait-rollout", false, "Add a wait block to Deployments, StatefulSets and DaemonSets so terraform apply waits for their rollout to finish")
+	waitConditions := flag.StringArray("wait-condition", nil, "Add a wait block so terraform apply waits for a status condition, like Ready, Job:Complete or Certificate:Ready=True, can be repeated. Conditions without a kind are waited for on every kind with a status")
+	waitFields := flag.StringArray("wait-fields", nil, "Add a wait block so terraform apply waits for a field to match, like status.phase=Running or Pod:status.phase=Running, can be repeated. Fields without a kind are waited for on every kind with a status")
+	waitDefaultsFlag := flag.StringSlice("wait-defaults", nil, "Wait for the rollout of Deployments, StatefulSets and DaemonSets, and for Jobs to complete, use --wait-defaults=Job,Deployment to only wait on some of them")
+	flag.Lookup("wait-defaults").NoOptDefVal = strings.Join(waitDefaultKinds(), ",")
+	fieldManager := flag.String("field-manager", "", "Add a field_manager block to the kubernetes_manifest resources so the objects are applied with this field manager name")
+	forceConflicts := flag.Bool("force-conflicts", false, "With --field-manager, take over the fields other field managers like kubectl or Helm own, when adopting objects they created")
+	computedFieldsFlag := flag.Bool("computed-fields", false, "Add computed_fields to the kubernetes_manifest resources for the fields controllers change, like the clusterIP of Services, webhook caBundles and the replicas of workloads an autoscaler scales")
+	extraComputedFields := flag.StringArray("extra-computed-fields", nil, "Another field to add to computed_fields with --computed-fields, like spec.replicas or Deployment:spec.replicas, can be repeated")
+	ignoreChanges := flag.StringSlice("ignore-changes", nil, "Attributes to add to the ignore_changes of each resource, like manifest.spec.replicas or Deployment:manifest.spec.replicas")
+	ignoreScaledReplicasFlag := flag.Bool("ignore-scaled-replicas", false, "Ignore changes to the replicas of the workloads a HorizontalPodAutoscaler converted in the same run scales")
+	preventDestroy := flag.StringSlice("prevent-destroy", nil, "Kinds to add prevent_destroy to, like PersistentVolumeClaim,Namespace, to protect stateful objects from being destroyed by mistake")
+	createNamespacesFlag := flag.Bool("create-namespaces", false, "Add a Namespace resource for each namespace the resources are in that isn't converted in the same run, and make the resources depend on it, so they can be applied to a new cluster")
+	dependsOn := flag.Bool("depends-on", false, "Add depends_on to each resource for its Namespace, the CustomResourceDefinition of its kind, and the ConfigMaps and Secrets a workload refers to, when they are converted in the same run")
+	sortByKind := flag.Bool("sort", false, "Write the resources in the order Helm installs them, with Namespaces, CRDs and RBAC before the workloads and webhooks last, instead of the order they were read in")
+	decodeMultiManifests := flag.Bool("decode-multi", false, "Write the manifests to a single YAML file in the --manifest-dir, and one kubernetes_manifest with for_each over provider::kubernetes::manifest_decode_multi() that reads it, for Terraform 1.8 and later")
+	consolidate := flag.Bool("consolidate", false, "Write resources that only differ by the name and namespace of the object as a single resource with for_each")
+	hoistCommonLabels := flag.Bool("hoist-common-labels", false, "Move the labels and annotations the resources share to common_labels and common_annotations in a locals.tf next to the output, and merge them into each manifest")
+	withOutputs := flag.Bool("with-outputs", false, "Also write an outputs.tf next to the output with the name and namespace of each object, and the cluster IP of Services")
+	tfvars := flag.String("tfvars", "", "Write a tfvars file like terraform.tfvars that sets the variables --extract-variables or --as-module make to the values in the manifests")
+	modulePer := flag.String("module-per", "", "Write the resources to a module in --output-dir for each value of this label, like app.kubernetes.io/name, and a main.tf that calls them")
+	manifestDir := flag.String("manifest-dir", "", "Directory --format yamlref and --decode-multi write the manifests to, the default is manifests next to the output")
+	format := flag.String("format", "hcl", "Syntax to write the resources in, one of "+strings.Join(formatNames(), ", "))
 	stripKeyQuotes := flag.BoolP("strip-key-quotes", "Q", false, "Strip out quotes from HCL map keys unless they are required.")
+	timeout := flag.Duration("timeout", 30*time.Second, "Timeout for fetching manifests from a URL")
+	insecureSkipTLSVerify := flag.Bool("insecure-skip-tls-verify", false, "Don't verify TLS certificates when fetching manifests from a URL")
+	fromCluster := flag.Bool("from-cluster", false, "Read resources from the cluster using kubectl, pass the resources to export as arguments like kubectl get")
+	kubeconfig := flag.String("kubeconfig", "", "Path to the kubeconfig file to use with --from-cluster and --strip-defaults")
+	kubeContext := flag.String("context", "", "The kubeconfig context to use with --from-cluster and --strip-defaults")
+	namespace := flag.StringP("namespace", "n", "", "Namespace to read resources from when using --from-cluster")
+	labelSelector := flag.StringP("selector", "l", "", "Label selector to filter resources when using --from-cluster")
+	allResources := flag.Bool("all", false, "Export every namespaced resource type when using --from-cluster")
+	excludeKinds := flag.StringSlice("exclude-kinds", excludeKinds, "Kinds to skip when using --all")
+	helmChart := flag.String("helm-chart", "", "Render a Helm chart the same as helm template and convert the rendered manifests")
+	helmValues := flag.StringArray("helm-values", nil, "Values file to use when rendering --helm-chart, can be repeated")
+	helmRelease := flag.String("helm-release", "", "Convert the manifest of an installed Helm release, use --namespace to set the release namespace")
+	helmGroupBySource := flag.Bool("helm-group-by-source", false, "Write one file per chart template into the --output directory")
+	ytt := flag.Bool("ytt", false, "Render the --file inputs as Carvel ytt templates before converting them")
+	yttDataValues := flag.StringArray("ytt-data-values", nil, "Data values file to use when rendering with --ytt, can be repeated")
+	verbose := flag.BoolP("verbose", "v", false, "Print notes about skipped documents to stderr")
+	skipInvalid := flag.Bool("skip-invalid", false, "Skip documents that don't have an apiVersion and kind with a warning, instead of failing")
+	extractBinaryData := flag.String("extract-binary-data", "", "Write ConfigMap binaryData and Secret data to files in this directory and read them with filebase64()")
+	crossplaneObject := flag.Bool("crossplane-object", false, "Wrap each manifest in a Crossplane provider-kubernetes Object, use --format yaml to write the Objects as YAML")
+	crossplaneProviderConfig := flag.String("crossplane-provider-config", "default", "The ProviderConfig the Objects use with --crossplane-object")
+	continueOnError := flag.Bool("continue-on-error", false, "Convert every document that can be converted and report all the failures at the end")
 	flag.Parse()
 
 	if *version {
@@ -252,28 +1110,751 @@ func main() {
 		os.Exit(0)
 	}
 
-	var file *os.File
-	if *infile == "-" {
-		file = os.Stdin
-	} else {
+	// when run as a kubectl plugin the arguments are the resources to
+	// export, like kubectl get
+	if isKubectlPlugin(os.Args[0]) && (flag.NArg() > 0 || *allResources) {
+		*fromCluster = true
+	}
+
+	var sources []source
+	if *fromCluster {
+		sources = []source{clusterSource(clusterOptions{
+			kubeconfig:    *kubeconfig,
+			context:       *kubeContext,
+			namespace:     *namespace,
+			labelSelector: *labelSelector,
+			all:           *allResources,
+			resources:     flag.Args(),
+			excludeKinds:  *excludeKinds,
+		})}
+	} else if *helmChart != "" || *helmRelease != "" {
 		var err error
-		file, err = os.Open(*infile)
+		sources, err = helmSources(helmOptions{
+			chart:       *helmChart,
+			values:      *helmValues,
+			namespace:   *namespace,
+			release:     *helmRelease,
+			kubeconfig:  *kubeconfig,
+			kubeContext: *kubeContext,
+		}, *helmGroupBySource)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\r\n", err.Error())
+			os.Exit(1)
+		}
+	} else if *ytt {
+		sources = []source{yttSource(yttOptions{
+			files:      *infiles,
+			dataValues: *yttDataValues,
+		})}
+	} else {
+		paths, err := expandInputPaths(*infiles)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\r\n", err.Error())
+			os.Exit(1)
+		}
+		sources, err = fileSources(paths, newHTTPClient(*timeout, *insecureSkipTLSVerify))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\r\n", err.Error())
+			os.Exit(1)
+		}
+	}
+
+	if *helmGroupBySource && *outfile == "-" {
+		fmt.Fprintf(os.Stderr, "--helm-group-by-source requires --output to be a directory\r\n")
+		os.Exit(1)
+	}
+	if *outputDir != "" && (*outfile != "-" || *helmGroupBySource) {
+		fmt.Fprintf(os.Stderr, "--output-dir can't be used with --output or --helm-group-by-source\r\n")
+		os.Exit(1)
+	}
+	outFormat, err := lookupFormat(*format)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\r\n", err.Error())
+		os.Exit(1)
+	}
+	if _, err := lookupTarget(*target); err != nil {
+		fmt.Fprintf(os.Stderr, "%s\r\n", err.Error())
+		os.Exit(1)
+	}
+	if *target != resourceType && *format != "hcl" && *format != "tfjson" && *format != "jsondecode" {
+		fmt.Fprintf(os.Stderr, "--target can only be used with --format hcl, tfjson or jsondecode\r\n")
+		os.Exit(1)
+	}
+	if *typed && (*format != "hcl" || *target != resourceType || *mapOnly) {
+		fmt.Fprintf(os.Stderr, "--typed can only be used with --format hcl, and can't be used with --target or --map-only\r\n")
+		os.Exit(1)
+	}
+	if *typedMapping != "" && !*typed {
+		fmt.Fprintf(os.Stderr, "--typed-mapping requires --typed\r\n")
+		os.Exit(1)
+	}
+	if *generateImports && (*mapOnly || *appendOutput || (*format != "hcl" && *format != "tfjson" && *format != "heredoc" && *format != "jsondecode" && *format != "yamlref")) {
+		fmt.Fprintf(os.Stderr, "--generate-imports can only be used with --format hcl, tfjson, heredoc, jsondecode or yamlref, and can't be used with --map-only or --append\r\n")
+		os.Exit(1)
+	}
+	if *importScript != "" && *mapOnly {
+		fmt.Fprintf(os.Stderr, "--import-script can't be used with --map-only\r\n")
+		os.Exit(1)
+	}
+	if *initScaffold && (*mapOnly || (*outfile == "-" && *outputDir == "") || (*format != "hcl" && *format != "tfjson" && *format != "heredoc" && *format != "jsondecode" && *format != "yamlref")) {
+		fmt.Fprintf(os.Stderr, "--init-scaffold requires --output or --output-dir, can only be used with --format hcl, tfjson, heredoc, jsondecode or yamlref, and can't be used with --map-only\r\n")
+		os.Exit(1)
+	}
+	var backendConfig *backendConfig
+	if *backend != "" {
+		if !*initScaffold {
+			fmt.Fprintf(os.Stderr, "--backend requires --init-scaffold\r\n")
+			os.Exit(1)
+		}
+		backendConfig, err = newBackend(*backend, *backendBucket, *backendKey, *backendRegion)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\r\n", err.Error())
+			os.Exit(1)
+		}
+	} else if *backendBucket != "" || *backendKey != "" || *backendRegion != "" {
+		fmt.Fprintf(os.Stderr, "--backend-bucket, --backend-key and --backend-region require --backend\r\n")
+		os.Exit(1)
+	}
+	if *modulePer != "" {
+		*asModule = true
+	}
+	extractions, err := parseExtract(*extract)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\r\n", err.Error())
+		os.Exit(1)
+	}
+	if *parameterizeNamespace {
+		extractions["namespace"] = true
+	}
+	var substitutions []substitution
+	if *substitutionsFile != "" {
+		if substitutions, err = loadSubstitutions(*substitutionsFile); err != nil {
+			fmt.Fprintf(os.Stderr, "%s\r\n", err.Error())
+			os.Exit(1)
+		}
+	}
+	switch {
+	case len(extractions) == 0 && (*extractVariables || *asModule || substitutions == nil):
+		// make the defaults into variables
+		extractions = nil
+	case !*asModule:
+		*extractVariables = true
+	}
+	if *asModule && (*outputDir == "" || *format != "hcl" || *mapOnly || *groupBy != "" || *filenameTemplate != "" || *maxResourcesPerFile > 0) {
+		fmt.Fprintf(os.Stderr, "--as-module and --module-per require --output-dir, can only be used with --format hcl, and can't be used with --map-only, --group-by, --filename-template or --max-resources-per-file\r\n")
+		os.Exit(1)
+	}
+	if *extractVariables && (*asModule || *mapOnly || (*outfile == "-" && *outputDir == "") || (*format != "hcl" && *format != "tfjson")) {
+		fmt.Fprintf(os.Stderr, "--extract-variables requires --output or --output-dir, can only be used with --format hcl or tfjson, and can't be used with --map-only, --as-module or --module-per\r\n")
+		os.Exit(1)
+	}
+	if *withOutputs && (*asModule || *mapOnly || (*outfile == "-" && *outputDir == "") || (*format != "hcl" && *format != "tfjson" && *format != "heredoc" && *format != "jsondecode" && *format != "yamlref")) {
+		fmt.Fprintf(os.Stderr, "--with-outputs requires --output or --output-dir, can only be used with --format hcl, tfjson, heredoc, jsondecode or yamlref, and can't be used with --map-only, --as-module or --module-per\r\n")
+		os.Exit(1)
+	}
+	if *consolidate && (*asModule || *mapOnly || *helmGroupBySource || *generateImports || *importScript != "" || *withOutputs || (*format != "hcl" && *format != "tfjson")) {
+		fmt.Fprintf(os.Stderr, "--consolidate can only be used with --format hcl or tfjson, and can't be used with --map-only, --helm-group-by-source, --as-module, --module-per, --generate-imports, --import-script or --with-outputs\r\n")
+		os.Exit(1)
+	}
+	if *decodeMultiManifests && (*format != "hcl" || *typed || *target != resourceType || *mapOnly || *outputDir != "" || *appendOutput || *helmGroupBySource || *maxResourcesPerFile > 0 || *asModule || *consolidate || *hoistCommonLabels || *extractVariables || *generateImports || *importScript != "" || *withOutputs) {
+		fmt.Fprintf(os.Stderr, "--decode-multi can only be used with --format hcl and --output, and can't be used with --typed, --target, --map-only, --append, --max-resources-per-file, --as-module, --module-per, --consolidate, --hoist-common-labels, --extract-variables, --generate-imports, --import-script or --with-outputs\r\n")
+		os.Exit(1)
+	}
+	if *referenceNamesFlag && (*mapOnly || *decodeMultiManifests || (*format != "hcl" && *format != "tfjson" && *format != "heredoc" && *format != "jsondecode")) {
+		fmt.Fprintf(os.Stderr, "--reference-names can only be used with --format hcl, tfjson, heredoc or jsondecode, and can't be used with --map-only or --decode-multi\r\n")
+		os.Exit(1)
+	}
+	if *dependsOn && (*mapOnly || *decodeMultiManifests || (*format != "hcl" && *format != "tfjson" && *format != "heredoc" && *format != "jsondecode" && *format != "yamlref")) {
+		fmt.Fprintf(os.Stderr, "--depends-on can only be used with --format hcl, tfjson, heredoc, jsondecode or yamlref, and can't be used with --map-only or --decode-multi\r\n")
+		os.Exit(1)
+	}
+	waits := *waitRollout || len(*waitConditions) > 0 || len(*waitFields) > 0 || len(*waitDefaultsFlag) > 0
+	if waits && (*mapOnly || *target != resourceType || *decodeMultiManifests || (*format != "hcl" && *format != "tfjson" && *format != "heredoc" && *format != "jsondecode" && *format != "yamlref")) {
+		fmt.Fprintf(os.Stderr, "--wait-rollout, --wait-condition, --wait-fields and --wait-defaults can only be used with --format hcl, tfjson, heredoc, jsondecode or yamlref, and can't be used with --target, --map-only or --decode-multi\r\n")
+		os.Exit(1)
+	}
+	if *forceConflicts && *fieldManager == "" {
+		fmt.Fprintf(os.Stderr, "--force-conflicts requires --field-manager\r\n")
+		os.Exit(1)
+	}
+	if *fieldManager != "" && (*mapOnly || *target != resourceType || *decodeMultiManifests || (*format != "hcl" && *format != "tfjson" && *format != "heredoc" && *format != "jsondecode" && *format != "yamlref")) {
+		fmt.Fprintf(os.Stderr, "--field-manager can only be used with --format hcl, tfjson, heredoc, jsondecode or yamlref, and can't be used with --target, --map-only or --decode-multi\r\n")
+		os.Exit(1)
+	}
+	if len(*extraComputedFields) > 0 && !*computedFieldsFlag && !*ignoreScaledReplicasFlag {
+		fmt.Fprintf(os.Stderr, "--extra-computed-fields requires --computed-fields\r\n")
+		os.Exit(1)
+	}
+	if *computedFieldsFlag && (*mapOnly || *target != resourceType || *decodeMultiManifests || (*format != "hcl" && *format != "tfjson" && *format != "heredoc" && *format != "jsondecode" && *format != "yamlref")) {
+		fmt.Fprintf(os.Stderr, "--computed-fields can only be used with --format hcl, tfjson, heredoc, jsondecode or yamlref, and can't be used with --target, --map-only or --decode-multi\r\n")
+		os.Exit(1)
+	}
+	if (len(*ignoreChanges) > 0 || *ignoreScaledReplicasFlag) && (*mapOnly || *decodeMultiManifests || (*format != "hcl" && *format != "tfjson" && *format != "heredoc" && *format != "jsondecode" && *format != "yamlref")) {
+		fmt.Fprintf(os.Stderr, "--ignore-changes and --ignore-scaled-replicas can only be used with --format hcl, tfjson, heredoc, jsondecode or yamlref, and can't be used with --map-only or --decode-multi\r\n")
+		os.Exit(1)
+	}
+	if len(*preventDestroy) > 0 && (*mapOnly || *decodeMultiManifests || (*format != "hcl" && *format != "tfjson" && *format != "heredoc" && *format != "jsondecode" && *format != "yamlref")) {
+		fmt.Fprintf(os.Stderr, "--prevent-destroy can only be used with --format hcl, tfjson, heredoc, jsondecode or yamlref, and can't be used with --map-only or --decode-multi\r\n")
+		os.Exit(1)
+	}
+	if *crdsOutput != "" && (*asModule || *helmGroupBySource || *decodeMultiManifests || (*format != "hcl" && *format != "tfjson" && *format != "heredoc" && *format != "jsondecode")) {
+		fmt.Fprintf(os.Stderr, "--crds-output can only be used with --format hcl, tfjson, heredoc or jsondecode, and can't be used with --as-module, --module-per, --helm-group-by-source or --decode-multi\r\n")
+		os.Exit(1)
+	}
+	if *createNamespacesFlag && (*mapOnly || *modulePer != "" || *helmGroupBySource || *consolidate || *decodeMultiManifests || (*format != "hcl" && *format != "tfjson" && *format != "heredoc" && *format != "jsondecode" && *format != "yamlref")) {
+		fmt.Fprintf(os.Stderr, "--create-namespaces can only be used with --format hcl, tfjson, heredoc, jsondecode or yamlref, and can't be used with --map-only, --module-per, --helm-group-by-source, --consolidate or --decode-multi\r\n")
+		os.Exit(1)
+	}
+	if *hoistCommonLabels && (*asModule || *mapOnly || *helmGroupBySource || (*outfile == "-" && *outputDir == "") || (*format != "hcl" && *format != "tfjson")) {
+		fmt.Fprintf(os.Stderr, "--hoist-common-labels requires --output or --output-dir, can only be used with --format hcl or tfjson, and can't be used with --map-only, --helm-group-by-source, --as-module or --module-per\r\n")
+		os.Exit(1)
+	}
+	if *secretsToVars && (*modulePer != "" || *mapOnly || *redactSecrets || (*outfile == "-" && *outputDir == "") || (*format != "hcl" && *format != "tfjson")) {
+		fmt.Fprintf(os.Stderr, "--secrets-to-vars requires --output or --output-dir, can only be used with --format hcl or tfjson, and can't be used with --map-only, --module-per or --redact-secrets\r\n")
+		os.Exit(1)
+	}
+	if *secretsTFVars != "" && !*secretsToVars {
+		fmt.Fprintf(os.Stderr, "--secrets-tfvars requires --secrets-to-vars\r\n")
+		os.Exit(1)
+	}
+	if *secretsToVault && (*secretsToVars || *redactSecrets || *mapOnly || (*format != "hcl" && *format != "tfjson")) {
+		fmt.Fprintf(os.Stderr, "--secrets-to-vault can only be used with --format hcl or tfjson, and can't be used with --secrets-to-vars, --redact-secrets or --map-only\r\n")
+		os.Exit(1)
+	}
+	if len(*secretsRandom) > 0 && (*mapOnly || (*format != "hcl" && *format != "tfjson")) {
+		fmt.Fprintf(os.Stderr, "--secrets-random can only be used with --format hcl or tfjson, and can't be used with --map-only\r\n")
+		os.Exit(1)
+	}
+	if *secretsToExternalSecrets && (*secretsToVars || *secretsToVault || len(*secretsRandom) > 0) {
+		fmt.Fprintf(os.Stderr, "--secrets-to-external-secrets can't be used with --secrets-to-vars, --secrets-to-vault or --secrets-random\r\n")
+		os.Exit(1)
+	}
+	if *sopsEncrypt && !strings.HasSuffix(*secretsTFVars, ".tfvars.json") {
+		fmt.Fprintf(os.Stderr, "--sops-encrypt requires a --secrets-tfvars file ending in .tfvars.json\r\n")
+		os.Exit(1)
+	}
+	if *tfvars != "" && (!*extractVariables && !*asModule || *modulePer != "") {
+		fmt.Fprintf(os.Stderr, "--tfvars requires --extract-variables or --as-module, and can't be used with --module-per\r\n")
+		os.Exit(1)
+	}
+	if *mapOnly && *format != "hcl" {
+		fmt.Fprintf(os.Stderr, "--map-only can only be used with --format hcl\r\n")
+		os.Exit(1)
+	}
+	layout := outputLayout{groupBy: *groupBy, maxPerFile: *maxResourcesPerFile, format: outFormat}
+	if *filenameTemplate != "" {
+		tmpl, err := parseFilenameTemplate(*filenameTemplate)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "%s\r\n", err.Error())
 			os.Exit(1)
 		}
+		layout.template = tmpl
+	}
+	if err := layout.validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "%s\r\n", err.Error())
+		os.Exit(1)
+	}
+	if (*groupBy != "" || *filenameTemplate != "") && *outputDir == "" {
+		fmt.Fprintf(os.Stderr, "--group-by and --filename-template require --output-dir\r\n")
+		os.Exit(1)
+	}
+	if *replaceExisting && !*appendOutput {
+		fmt.Fprintf(os.Stderr, "--replace-existing requires --append\r\n")
+		os.Exit(1)
+	}
+	if *appendOutput && (*outfile == "-" || *outputDir != "" || *helmGroupBySource || *maxResourcesPerFile > 0 || *mapOnly || *format != "hcl") {
+		fmt.Fprintf(os.Stderr, "--append requires --output to be a file, and can't be used with --max-resources-per-file, --map-only or --format\r\n")
+		os.Exit(1)
+	}
+	if *maxResourcesPerFile > 0 && *outfile == "-" && *outputDir == "" {
+		fmt.Fprintf(os.Stderr, "--max-resources-per-file requires --output or --output-dir\r\n")
+		os.Exit(1)
 	}
 
-	hcl, err := YAMLToTerraformResources(
-		file, *providerAlias, *stripServerSide, *mapOnly, *stripKeyQuotes)
+	if *replaceStripDefaults && !*stripServerSide {
+		fmt.Fprintf(os.Stderr, "--replace-strip-defaults requires --strip\r\n")
+		os.Exit(1)
+	}
+	if (*keepStatus || *keepManagedFields) && !*stripServerSide {
+		fmt.Fprintf(os.Stderr, "--keep-status and --keep-managed-fields require --strip\r\n")
+		os.Exit(1)
+	}
+	fieldsToStrip := *stripFieldFlags
+	if *stripFieldsFile != "" {
+		fields, err := loadStripFields(*stripFieldsFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\r\n", err.Error())
+			os.Exit(1)
+		}
+		fieldsToStrip = append(fields, fieldsToStrip...)
+	}
+	stripFields, err := parseStripFields(fieldsToStrip)
 	if err != nil {
-		fmt.Println("error:", err)
+		fmt.Fprintf(os.Stderr, "%s\r\n", err.Error())
 		os.Exit(1)
 	}
+	for _, expr := range *deleteExprs {
+		f, err := parseDeleteExpression(expr)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\r\n", err.Error())
+			os.Exit(1)
+		}
+		stripFields = append(stripFields, f)
+	}
 
-	if *outfile == "-" {
-		fmt.Print(hcl)
-	} else {
-		ioutil.WriteFile(*outfile, []byte(hcl), 0644)
+	opts := []Option{
+		WithProviderAlias(*providerAlias),
+		WithStripServerSide(*stripServerSide),
+		WithStripFields(stripFields, *replaceStripDefaults),
+		WithKeepStatus(*keepStatus),
+		WithKeepManagedFields(*keepManagedFields),
+		WithKeepDefaultNamespace(*keepDefaultNamespace),
+		WithStripHelm(*stripHelm),
+		WithStripGitOps(*stripGitOps),
+		WithOwnedBy(*ownedBy...),
+		WithDefaultNamespace(*defaultNamespace),
+		WithPruneEmpty(*pruneEmptyFields),
+		WithDecodeSecrets(*decodeSecrets),
+		WithRedactSecrets(*redactSecrets),
+		WithMapOnly(*mapOnly),
+		WithStripKeyQuotes(*stripKeyQuotes),
+		WithFormat(*format),
+		WithTarget(*target),
+		WithTyped(*typed),
+		WithGenerateImports(*generateImports),
+		WithFieldManager(*fieldManager, *forceConflicts),
+		WithSkipInvalid(*skipInvalid),
+		WithWarnings(os.Stderr),
+	}
+	if *verbose {
+		opts = append(opts, WithVerbose(os.Stderr))
+	}
+	if *stripDefaults {
+		opts = append(opts, WithStripDefaults(clusterOptions{kubeconfig: *kubeconfig, context: *kubeContext}))
+	}
+	if *typedMapping != "" {
+		mapping, err := loadTypedMapping(*typedMapping)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\r\n", err.Error())
+			os.Exit(1)
+		}
+		opts = append(opts, WithTypedMapping(mapping))
+	}
+	if waits {
+		wait := &waitConfig{rollout: *waitRollout}
+		if err := wait.setDefaults(*waitDefaultsFlag); err != nil {
+			fmt.Fprintf(os.Stderr, "%s\r\n", err.Error())
+			os.Exit(1)
+		}
+		for _, s := range *waitConditions {
+			c, err := parseWaitCondition(s)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s\r\n", err.Error())
+				os.Exit(1)
+			}
+			wait.conditions = append(wait.conditions, c)
+		}
+		for _, s := range *waitFields {
+			f, err := parseWaitField(s)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s\r\n", err.Error())
+				os.Exit(1)
+			}
+			wait.fields = append(wait.fields, f)
+		}
+		opts = append(opts, WithWait(wait))
+	}
+	if *computedFieldsFlag {
+		extra := []computedField{}
+		for _, s := range *extraComputedFields {
+			f, err := parseComputedField(s)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s\r\n", err.Error())
+				os.Exit(1)
+			}
+			extra = append(extra, f)
+		}
+		opts = append(opts, WithComputedFields(extra...))
+	}
+	if len(*ignoreChanges) > 0 {
+		changes := []ignoreChange{}
+		for _, s := range *ignoreChanges {
+			c, err := parseIgnoreChange(s)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s\r\n", err.Error())
+				os.Exit(1)
+			}
+			changes = append(changes, c)
+		}
+		opts = append(opts, WithIgnoreChanges(changes...))
+	}
+	if len(*preventDestroy) > 0 {
+		opts = append(opts, WithPreventDestroy(*preventDestroy...))
+	}
+	if *crossplaneObject {
+		opts = append(opts, WithCrossplaneObject(*crossplaneProviderConfig))
 	}
+	var moduleVariables *moduleVariables
+	var modules *moduleSet
+	if *modulePer != "" {
+		modules = newModuleSet(*modulePer)
+		modules.extract = extractions
+		modules.sharedNamespace = *parameterizeNamespace
+		modules.substitutions = substitutions
+		opts = append(opts, WithModulePer(modules))
+	} else if *asModule || *extractVariables {
+		moduleVariables = newModuleVariables()
+		moduleVariables.extract = extractions
+		moduleVariables.sharedNamespace = *parameterizeNamespace
+		moduleVariables.substitutions = substitutions
+		opts = append(opts, WithModuleVariables(moduleVariables))
+	}
+	secretVariables := moduleVariables
+	if *secretsToVars {
+		if secretVariables == nil {
+			secretVariables = newModuleVariables()
+		}
+		opts = append(opts, WithSecretVariables(secretVariables))
+	}
+	if *secretsToExternalSecrets {
+		e, err := newExternalSecrets(*secretStore, *secretStoreKind, *externalSecretKey)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\r\n", err.Error())
+			os.Exit(1)
+		}
+		opts = append(opts, WithExternalSecrets(e))
+	}
+	if len(*secretsRandom) > 0 {
+		keys := []randomKey{}
+		for _, s := range *secretsRandom {
+			k, err := parseRandomKey(s)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s\r\n", err.Error())
+				os.Exit(1)
+			}
+			keys = append(keys, k)
+		}
+		opts = append(opts, WithRandomPasswords(keys...))
+	}
+	if *secretsToVault {
+		vault, err := newVaultSecrets(*vaultMount, *vaultPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\r\n", err.Error())
+			os.Exit(1)
+		}
+		opts = append(opts, WithVaultSecrets(vault))
+	}
+
+	output, outputIsDir := *outfile, *helmGroupBySource
+	if *outputDir != "" {
+		output, outputIsDir = *outputDir, true
+	}
+	if *extractBinaryData != "" {
+		ref, err := outputRef(output, *extractBinaryData, outputIsDir)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\r\n", err.Error())
+			os.Exit(1)
+		}
+		opts = append(opts, WithExtractBinaryData(*extractBinaryData, ref))
+	}
+	if *format == "yamlref" || *decodeMultiManifests {
+		dir := *manifestDir
+		if dir == "" {
+			dir = defaultManifestDir
+			if output != "-" && outputIsDir {
+				dir = filepath.Join(output, defaultManifestDir)
+			} else if output != "-" {
+				dir = filepath.Join(filepath.Dir(output), defaultManifestDir)
+			}
+		}
+		ref, err := outputRef(output, dir, outputIsDir)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\r\n", err.Error())
+			os.Exit(1)
+		}
+		opts = append(opts, WithManifestDir(dir, ref))
+	}
+
+	var failures []error
+	if *continueOnError {
+		opts = append(opts, WithContinueOnError(func(err error) {
+			failures = append(failures, err)
+		}))
+	}
+
+	if len(sources) == 1 && sources[0].name == "-" && *outfile == "-" && *outputDir == "" && *format == "hcl" && *importScript == "" && !*consolidate && !*decodeMultiManifests && !*sortByKind && !*dependsOn && !*referenceNamesFlag && !*createNamespacesFlag && *crdsOutput == "" && !*computedFieldsFlag && !*ignoreScaledReplicasFlag && !*failOnSecrets {
+		// convert stdin as it arrives so watch pipelines produce output incrementally
+		if err := StreamYAMLToTerraformResources(os.Stdin, os.Stdout, opts...); err != nil {
+			fmt.Println("error:", err)
+			os.Exit(1)
+		}
+		exitOnFailures(failures)
+		return
+	}
+
+	// the files the config reads are written once everything has been
+	// converted and checked
+	pending := &pendingFiles{}
+	opts = append(opts, WithPendingFiles(pending))
+
+	resources := []resource{}
+	for _, s := range sources {
+		r, err := s.open()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\r\n", err.Error())
+			os.Exit(1)
+		}
+
+		converted, err := convertResources(r, append(opts, WithSourceName(s.name))...)
+		r.Close()
+		if err != nil {
+			fmt.Println("error:", err)
+			os.Exit(1)
+		}
+		resources = append(resources, converted...)
+
+		if *helmGroupBySource {
+			if *computedFieldsFlag {
+				if converted, err = computeScaledReplicas(converted, opts...); err != nil {
+					fmt.Fprintf(os.Stderr, "%s\r\n", err.Error())
+					os.Exit(1)
+				}
+			}
+			if *ignoreScaledReplicasFlag {
+				if converted, err = ignoreScaledReplicas(converted, opts...); err != nil {
+					fmt.Fprintf(os.Stderr, "%s\r\n", err.Error())
+					os.Exit(1)
+				}
+			}
+			if *sortByKind {
+				sortResources(converted)
+			}
+			if *referenceNamesFlag {
+				if converted, err = referenceNames(converted, opts...); err != nil {
+					fmt.Fprintf(os.Stderr, "%s\r\n", err.Error())
+					os.Exit(1)
+				}
+			}
+			if *dependsOn {
+				if converted, err = inferDependencies(converted, opts...); err != nil {
+					fmt.Fprintf(os.Stderr, "%s\r\n", err.Error())
+					os.Exit(1)
+				}
+			}
+			text, err := joinResources(outFormat, converted)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s\r\n", err.Error())
+				os.Exit(1)
+			}
+			if text != "" {
+				pending.files = append(pending.files, pendingFile{filename: helmSourceFilename(*outfile, s.name, outFormat.extension()), data: []byte(text)})
+			}
+		}
+	}
+
+	if *failOnSecrets {
+		// the manifests are checked before they are hoisted or written to
+		// files, so every value they have is checked
+		if findings := findSecrets(resources, pending); len(findings) > 0 {
+			fmt.Fprintf(os.Stderr, "%s", secretsReport(findings))
+			os.Exit(1)
+		}
+	}
+
+	locals := ""
+	if *hoistCommonLabels {
+		var err error
+		if resources, locals, err = hoistCommonMetadata(resources, opts...); err != nil {
+			fmt.Fprintf(os.Stderr, "%s\r\n", err.Error())
+			os.Exit(1)
+		}
+	}
+
+	if *createNamespacesFlag {
+		var err error
+		if resources, err = createNamespaces(resources, opts...); err != nil {
+			fmt.Fprintf(os.Stderr, "%s\r\n", err.Error())
+			os.Exit(1)
+		}
+	}
+	if *computedFieldsFlag {
+		var err error
+		if resources, err = computeScaledReplicas(resources, opts...); err != nil {
+			fmt.Fprintf(os.Stderr, "%s\r\n", err.Error())
+			os.Exit(1)
+		}
+	}
+	if *ignoreScaledReplicasFlag {
+		var err error
+		if resources, err = ignoreScaledReplicas(resources, opts...); err != nil {
+			fmt.Fprintf(os.Stderr, "%s\r\n", err.Error())
+			os.Exit(1)
+		}
+	}
+	if *sortByKind {
+		sortResources(resources)
+	}
+	if *decodeMultiManifests && len(resources) > 0 {
+		r, err := decodeMulti(resources, opts...)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\r\n", err.Error())
+			os.Exit(1)
+		}
+		resources = []resource{r}
+	}
+	if *consolidate {
+		var err error
+		if resources, err = consolidateResources(resources, opts...); err != nil {
+			fmt.Fprintf(os.Stderr, "%s\r\n", err.Error())
+			os.Exit(1)
+		}
+	}
+	var crds []resource
+	if *crdsOutput != "" {
+		crds, resources = separateCRDs(resources)
+	} else if !*mapOnly {
+		for _, w := range crdWarnings(resources) {
+			fmt.Fprintf(os.Stderr, "warning: %s\n", w)
+		}
+	}
+	if *referenceNamesFlag {
+		var err error
+		if resources, err = referenceNames(resources, opts...); err != nil {
+			fmt.Fprintf(os.Stderr, "%s\r\n", err.Error())
+			os.Exit(1)
+		}
+	}
+	if *dependsOn {
+		var err error
+		if resources, err = inferDependencies(resources, opts...); err != nil {
+			fmt.Fprintf(os.Stderr, "%s\r\n", err.Error())
+			os.Exit(1)
+		}
+	}
+
+	if err := pending.write(); err != nil {
+		fmt.Fprintf(os.Stderr, "%s\r\n", err.Error())
+		os.Exit(1)
+	}
+
+	switch {
+	case *helmGroupBySource:
+	case modules != nil:
+		if err := writeModules(*outputDir, resources, modules); err != nil {
+			fmt.Fprintf(os.Stderr, "%s\r\n", err.Error())
+			os.Exit(1)
+		}
+	case *asModule:
+		if err := writeModule(*outputDir, resources, moduleVariables); err != nil {
+			fmt.Fprintf(os.Stderr, "%s\r\n", err.Error())
+			os.Exit(1)
+		}
+		// print an example of calling the module from the directory above
+		fmt.Print(moduleCall(filepath.Base(filepath.Clean(*outputDir)), moduleSource(*outputDir), moduleVariables))
+	case *outputDir != "":
+		if err := writeOutputDir(*outputDir, layout, resources); err != nil {
+			fmt.Fprintf(os.Stderr, "%s\r\n", err.Error())
+			os.Exit(1)
+		}
+	case *appendOutput:
+		skipped, err := appendResources(*outfile, withSecretBlocks(resources), *replaceExisting)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\r\n", err.Error())
+			os.Exit(1)
+		}
+		for _, address := range skipped {
+			fmt.Fprintf(os.Stderr, "warning: %s is already in %s, use --replace-existing to update it\n", address, *outfile)
+		}
+	case *outfile != "-" && layout.maxPerFile > 0:
+		layout.file = filepath.Base(*outfile)
+		if err := writeOutputDir(filepath.Dir(*outfile), layout, resources); err != nil {
+			fmt.Fprintf(os.Stderr, "%s\r\n", err.Error())
+			os.Exit(1)
+		}
+	default:
+		text, err := joinResources(outFormat, resources)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\r\n", err.Error())
+			os.Exit(1)
+		}
+		if *outfile == "-" {
+			fmt.Print(text)
+			break
+		}
+		if err := writeFileAtomic(*outfile, []byte(text), 0644); err != nil {
+			fmt.Fprintf(os.Stderr, "%s\r\n", err.Error())
+			os.Exit(1)
+		}
+	}
+
+	if len(crds) > 0 {
+		text, err := joinResources(outFormat, crds)
+		if err == nil {
+			err = os.MkdirAll(filepath.Dir(*crdsOutput), 0755)
+		}
+		if err == nil {
+			err = writeFileAtomic(*crdsOutput, []byte(text), 0644)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\r\n", err.Error())
+			os.Exit(1)
+		}
+	}
+
+	dir := filepath.Dir(output)
+	if outputIsDir {
+		dir = output
+	}
+	if *extractVariables || *secretsToVars && !*asModule {
+		if err := writeVariables(dir, secretVariables); err != nil {
+			fmt.Fprintf(os.Stderr, "%s\r\n", err.Error())
+			os.Exit(1)
+		}
+	}
+	if locals != "" {
+		if err := writeLocals(dir, locals); err != nil {
+			fmt.Fprintf(os.Stderr, "%s\r\n", err.Error())
+			os.Exit(1)
+		}
+	}
+	if *withOutputs {
+		if err := writeOutputs(dir, resources); err != nil {
+			fmt.Fprintf(os.Stderr, "%s\r\n", err.Error())
+			os.Exit(1)
+		}
+	}
+	if *tfvars != "" {
+		if err := writeTFVars(*tfvars, moduleVariables); err != nil {
+			fmt.Fprintf(os.Stderr, "%s\r\n", err.Error())
+			os.Exit(1)
+		}
+	}
+	if *secretsTFVars != "" {
+		write := writeSecretTFVars
+		if *sopsEncrypt {
+			write = writeSOPSTFVars
+		}
+		if err := write(*secretsTFVars, secretVariables); err != nil {
+			fmt.Fprintf(os.Stderr, "%s\r\n", err.Error())
+			os.Exit(1)
+		}
+	}
+	if *initScaffold {
+		filename, written, err := writeScaffold(dir, resources, backendConfig, *format == "tfjson")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\r\n", err.Error())
+			os.Exit(1)
+		}
+		if !written {
+			fmt.Fprintf(os.Stderr, "warning: %s already exists, it was left alone\n", filename)
+		}
+	}
+	if *importScript != "" {
+		if err := writeImportScript(*importScript, append(crds, resources...)); err != nil {
+			fmt.Fprintf(os.Stderr, "%s\r\n", err.Error())
+			os.Exit(1)
+		}
+	}
+
+	exitOnFailures(failures)
 }
@@ -1,8 +1,10 @@
 package main
 
 import (
+	"io"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -17,7 +19,7 @@ data:
   TEST: test`
 
 	r := strings.NewReader(yaml)
-	output, err := YAMLToTerraformResources(r, "", false, false, false)
+	output, err := YAMLToTerraformResources(r)
 
 	if err != nil {
 		t.Fatal("Converting to HCL failed:", err)
@@ -49,8 +51,9 @@ metadata:
 data:
   TEST: test`
 
+	var warnings strings.Builder
 	r := strings.NewReader(yaml)
-	output, err := YAMLToTerraformResources(r, "", false, false, false)
+	output, err := YAMLToTerraformResources(r, WithWarnings(&warnings))
 
 	if err != nil {
 		t.Fatal("Converting to HCL failed:", err)
@@ -71,6 +74,7 @@ resource "kubernetes_manifest" "configmap_test_name" {
 }`
 
 	assert.Equal(t, strings.TrimSpace(expected), strings.TrimSpace(output))
+	assert.Equal(t, "warning: ConfigMap test-name- uses metadata.generateName, kubernetes_manifest needs metadata.name to be set before it can be applied\n", warnings.String())
 }
 
 func TestYAMLToTerraformResourcesEscapeShell(t *testing.T) {
@@ -84,7 +88,7 @@ data:
     echo Hello, ${USER} your homedir is ${HOME}`
 
 	r := strings.NewReader(yaml)
-	output, err := YAMLToTerraformResources(r, "", false, false, false)
+	output, err := YAMLToTerraformResources(r)
 
 	if err != nil {
 		t.Fatal("Converting to HCL failed:", err)
@@ -129,7 +133,7 @@ data:
   TEST: two`
 
 	r := strings.NewReader(yaml)
-	output, err := YAMLToTerraformResources(r, "", false, false, false)
+	output, err := YAMLToTerraformResources(r)
 
 	if err != nil {
 		t.Fatal("Converting to HCL failed:", err)
@@ -192,7 +196,7 @@ items:
 `
 
 	r := strings.NewReader(yaml)
-	output, err := YAMLToTerraformResources(r, "", false, false, false)
+	output, err := YAMLToTerraformResources(r)
 
 	if err != nil {
 		t.Fatal("Converting to HCL failed:", err)
@@ -252,7 +256,7 @@ data:
   TEST: test`
 
 	r := strings.NewReader(yaml)
-	output, err := YAMLToTerraformResources(r, "kubernetes-alpha", false, false, false)
+	output, err := YAMLToTerraformResources(r, WithProviderAlias("kubernetes-alpha"))
 
 	if err != nil {
 		t.Fatal("Converting to HCL failed:", err)
@@ -293,11 +297,18 @@ metadata:
   resourceVersion: "677134"
   selfLink: /api/v1/namespaces/default/configmaps/test
   uid: bea6500b-0637-4d2d-b726-e0bda0b595dd
+  generation: 2
+  ownerReferences:
+  - apiVersion: apps/v1
+    kind: Deployment
+    name: test
+    uid: 1e9b8f4a-3c5d-4e2f-9a6b-7c8d9e0f1a2b
+    controller: true
   finalizers:
   - test`
 
 	r := strings.NewReader(yaml)
-	output, err := YAMLToTerraformResources(r, "", true, false, false)
+	output, err := YAMLToTerraformResources(r, WithStripServerSide(true))
 
 	if err != nil {
 		t.Fatal("Converting to HCL failed:", err)
@@ -334,7 +345,7 @@ metadata:
   uid: bea6500b-0637-4d2d-b726-e0bda0b595dd`
 
 	r := strings.NewReader(yaml)
-	output, err := YAMLToTerraformResources(r, "", true, true, false)
+	output, err := YAMLToTerraformResources(r, WithStripServerSide(true), WithMapOnly(true))
 
 	if err != nil {
 		t.Fatal("Converting to HCL failed:", err)
@@ -381,7 +392,7 @@ metadata:
   uid: bea6500b-0637-4d2d-b726-e0bda0b595dd`
 
 	r := strings.NewReader(yaml)
-	output, err := YAMLToTerraformResources(r, "", true, false, false)
+	output, err := YAMLToTerraformResources(r, WithStripServerSide(true))
 
 	if err != nil {
 		t.Fatal("Converting to HCL failed:", err)
@@ -416,3 +427,617 @@ resource "kubernetes_manifest" "configmap_test2" {
 
 	assert.Equal(t, strings.TrimSpace(expected), strings.TrimSpace(output))
 }
+
+func TestYAMLToTerraformResourcesJSON(t *testing.T) {
+	json := `{
+	"apiVersion": "v1",
+	"kind": "ConfigMap",
+	"metadata": {
+		"name": "test"
+	},
+	"data": {
+		"TEST": "test"
+	}
+}`
+
+	r := strings.NewReader(json)
+	output, err := YAMLToTerraformResources(r)
+
+	if err != nil {
+		t.Fatal("Converting to HCL failed:", err)
+	}
+
+	expected := `
+resource "kubernetes_manifest" "configmap_test" {
+  manifest = {
+    "apiVersion" = "v1"
+    "data" = {
+      "TEST" = "test"
+    }
+    "kind" = "ConfigMap"
+    "metadata" = {
+      "name" = "test"
+    }
+  }
+}`
+
+	assert.Equal(t, strings.TrimSpace(expected), strings.TrimSpace(output))
+}
+
+func TestYAMLToTerraformResourcesJSONList(t *testing.T) {
+	json := `{
+    "apiVersion": "v1",
+    "items": [
+        {
+            "apiVersion": "v1",
+            "data": {
+                "TEST": "one"
+            },
+            "kind": "ConfigMap",
+            "metadata": {
+                "name": "one",
+                "namespace": "default",
+                "resourceVersion": "677134",
+                "uid": "bea6500b-0637-4d2d-b726-e0bda0b595dd"
+            }
+        }
+    ],
+    "kind": "List",
+    "metadata": {
+        "resourceVersion": ""
+    }
+}
+{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "two"}, "data": {"TEST": "two"}}`
+
+	r := strings.NewReader(json)
+	output, err := YAMLToTerraformResources(r, WithStripServerSide(true))
+
+	if err != nil {
+		t.Fatal("Converting to HCL failed:", err)
+	}
+
+	expected := `
+resource "kubernetes_manifest" "configmap_one" {
+  manifest = {
+    "apiVersion" = "v1"
+    "data" = {
+      "TEST" = "one"
+    }
+    "kind" = "ConfigMap"
+    "metadata" = {
+      "name" = "one"
+    }
+  }
+}
+
+resource "kubernetes_manifest" "configmap_two" {
+  manifest = {
+    "apiVersion" = "v1"
+    "data" = {
+      "TEST" = "two"
+    }
+    "kind" = "ConfigMap"
+    "metadata" = {
+      "name" = "two"
+    }
+  }
+}`
+
+	assert.Equal(t, strings.TrimSpace(expected), strings.TrimSpace(output))
+}
+
+// chanWriter sends everything written to it on a channel
+type chanWriter chan string
+
+func (w chanWriter) Write(p []byte) (int, error) {
+	w <- string(p)
+	return len(p), nil
+}
+
+func TestStreamYAMLToTerraformResources(t *testing.T) {
+	pr, pw := io.Pipe()
+	w := make(chanWriter)
+	errs := make(chan error)
+	go func() {
+		errs <- StreamYAMLToTerraformResources(pr, w)
+		close(w)
+	}()
+
+	io.WriteString(pw, "---\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: one\n")
+	select {
+	case out := <-w:
+		t.Fatal("Document was converted before its terminating separator arrived:", out)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	io.WriteString(pw, "---\n")
+	select {
+	case out := <-w:
+		assert.Contains(t, out, `resource "kubernetes_manifest" "configmap_one"`)
+	case <-time.After(time.Second):
+		t.Fatal("Document was not converted after its terminating separator arrived")
+	}
+
+	io.WriteString(pw, "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: two\n")
+	pw.Close()
+	out := <-w
+	assert.True(t, strings.HasPrefix(out, "\nresource \"kubernetes_manifest\" \"configmap_two\""))
+	assert.NoError(t, <-errs)
+}
+
+func TestYAMLToTerraformResourcesJSONTypedList(t *testing.T) {
+	json := `{
+    "apiVersion": "apps/v1",
+    "kind": "DeploymentList",
+    "metadata": {
+        "resourceVersion": "1234"
+    },
+    "items": [
+        {
+            "metadata": {
+                "name": "nginx",
+                "namespace": "web"
+            },
+            "spec": {
+                "replicas": 2
+            }
+        },
+        {
+            "apiVersion": "apps/v1",
+            "kind": "Deployment",
+            "metadata": {
+                "name": "api",
+                "namespace": "web"
+            },
+            "spec": {
+                "replicas": 1
+            }
+        }
+    ]
+}
+{"apiVersion": "v1", "kind": "ServiceList", "metadata": {}, "items": []}`
+
+	r := strings.NewReader(json)
+	output, err := YAMLToTerraformResources(r)
+
+	if err != nil {
+		t.Fatal("Converting to HCL failed:", err)
+	}
+
+	expected := `
+resource "kubernetes_manifest" "deployment_web_nginx" {
+  manifest = {
+    "apiVersion" = "apps/v1"
+    "kind" = "Deployment"
+    "metadata" = {
+      "name" = "nginx"
+      "namespace" = "web"
+    }
+    "spec" = {
+      "replicas" = 2
+    }
+  }
+}
+
+resource "kubernetes_manifest" "deployment_web_api" {
+  manifest = {
+    "apiVersion" = "apps/v1"
+    "kind" = "Deployment"
+    "metadata" = {
+      "name" = "api"
+      "namespace" = "web"
+    }
+    "spec" = {
+      "replicas" = 1
+    }
+  }
+}`
+
+	assert.Equal(t, strings.TrimSpace(expected), strings.TrimSpace(output))
+}
+
+func TestYAMLToTerraformResourcesSeparatorInBlockScalar(t *testing.T) {
+	yaml := `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: test
+data:
+  notes.md: |
+    Title
+    ---
+    body
+----: dashes
+...
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: test2
+`
+
+	r := strings.NewReader(yaml)
+	output, err := YAMLToTerraformResources(r)
+
+	if err != nil {
+		t.Fatal("Converting to HCL failed:", err)
+	}
+
+	expected := `resource "kubernetes_manifest" "configmap_test" {
+  manifest = {
+    "----" = "dashes"
+    "apiVersion" = "v1"
+    "data" = {
+      "notes.md" = <<-EOT
+      Title
+      ---
+      body
+      
+      EOT
+    }
+    "kind" = "ConfigMap"
+    "metadata" = {
+      "name" = "test"
+    }
+  }
+}
+
+resource "kubernetes_manifest" "configmap_test2" {
+  manifest = {
+    "apiVersion" = "v1"
+    "kind" = "ConfigMap"
+    "metadata" = {
+      "name" = "test2"
+    }
+  }
+}
+`
+
+	assert.Equal(t, strings.TrimSpace(expected), strings.TrimSpace(output))
+}
+
+func TestYAMLToTerraformResourcesCommentOnlyDocSkip(t *testing.T) {
+	yaml := `---
+# this document is only a comment
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: test
+---
+~
+---
+`
+
+	var notes strings.Builder
+	r := strings.NewReader(yaml)
+	output, err := YAMLToTerraformResources(r, WithVerbose(&notes), WithSourceName("test.yaml"))
+
+	if err != nil {
+		t.Fatal("Converting to HCL failed:", err)
+	}
+
+	expected := `resource "kubernetes_manifest" "configmap_test" {
+  manifest = {
+    "apiVersion" = "v1"
+    "kind" = "ConfigMap"
+    "metadata" = {
+      "name" = "test"
+    }
+  }
+}
+`
+
+	assert.Equal(t, strings.TrimSpace(expected), strings.TrimSpace(output))
+	assert.Equal(t, `note: test.yaml: skipping empty document 1
+note: test.yaml: skipping empty document 3
+note: test.yaml: skipping empty document 4
+`, notes.String())
+}
+
+func TestYAMLToTerraformResourcesCRLFAndBOM(t *testing.T) {
+	yaml := "---\n" +
+		"apiVersion: v1\n" +
+		"kind: ConfigMap\n" +
+		"metadata:\n" +
+		"  name: test\n" +
+		"data:\n" +
+		"  script.sh: |\n" +
+		"    echo hello\n" +
+		"    echo world\n"
+
+	lf, err := YAMLToTerraformResources(strings.NewReader(yaml))
+	if err != nil {
+		t.Fatal("Converting to HCL failed:", err)
+	}
+
+	windows := "\xef\xbb\xbf" + strings.ReplaceAll(yaml, "\n", "\r\n")
+	crlf, err := YAMLToTerraformResources(strings.NewReader(windows))
+	if err != nil {
+		t.Fatal("Converting to HCL failed:", err)
+	}
+
+	assert.Equal(t, lf, crlf)
+
+	doc, err := newDocumentReader(strings.NewReader(windows)).next()
+	if err != nil {
+		t.Fatal("Reading document failed:", err)
+	}
+	assert.Equal(t, strings.TrimPrefix(yaml, "---\n"), doc.raw)
+
+	json := "\xef\xbb\xbf{\r\n  \"apiVersion\": \"v1\",\r\n  \"kind\": \"Namespace\",\r\n  \"metadata\": {\"name\": \"test\"}\r\n}\r\n"
+	_, err = YAMLToTerraformResources(strings.NewReader(json))
+	assert.NoError(t, err)
+}
+
+func TestYAMLToTerraformResourcesErrorPosition(t *testing.T) {
+	yaml := `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: test
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: test2
+ data: broken
+`
+
+	r := strings.NewReader(yaml)
+	_, err := YAMLToTerraformResources(r, WithSourceName("configmaps.yaml"))
+	assert.EqualError(t, err, "configmaps.yaml: document 2, line 9: did not find expected key")
+
+	yaml = `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: test
+---
+- not a manifest
+`
+
+	r = strings.NewReader(yaml)
+	_, err = YAMLToTerraformResources(r)
+	assert.EqualError(t, err, "document 2, line 6: the manifest must be a YAML or JSON document")
+
+	json := `{"apiVersion": "v1", "kind": "Namespace", "metadata": {"name": "test"}} {"apiVersion": }`
+
+	r = strings.NewReader(json)
+	_, err = YAMLToTerraformResources(r, WithSourceName("ns.json"))
+	assert.EqualError(t, err, "ns.json: document 2: invalid character '}' looking for beginning of value")
+}
+
+func TestYAMLToTerraformResourcesContinueOnError(t *testing.T) {
+	yaml := `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: test
+---
+kind: [
+---
+foo: bar
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: test2
+`
+
+	errs := []string{}
+	r := strings.NewReader(yaml)
+	output, err := YAMLToTerraformResources(r, WithContinueOnError(func(err error) {
+		errs = append(errs, err.Error())
+	}))
+
+	if err != nil {
+		t.Fatal("Converting to HCL failed:", err)
+	}
+
+	assert.Contains(t, output, `resource "kubernetes_manifest" "configmap_test"`)
+	assert.Contains(t, output, `resource "kubernetes_manifest" "configmap_test2"`)
+	assert.Equal(t, []string{
+		"document 2, line 6: did not find expected node content",
+		"document 3, line 8: error converting YAML to HCL: the manifest is missing kind",
+	}, errs)
+}
+
+func TestYAMLToTerraformResourcesSkipInvalid(t *testing.T) {
+	yaml := `# values.yaml
+replicas: 3
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: test
+---
+- a
+- b
+`
+
+	var warnings strings.Builder
+	r := strings.NewReader(yaml)
+	output, err := YAMLToTerraformResources(r, WithSkipInvalid(true), WithWarnings(&warnings))
+
+	if err != nil {
+		t.Fatal("Converting to HCL failed:", err)
+	}
+
+	expected := `resource "kubernetes_manifest" "configmap_test" {
+  manifest = {
+    "apiVersion" = "v1"
+    "kind" = "ConfigMap"
+    "metadata" = {
+      "name" = "test"
+    }
+  }
+}
+`
+
+	assert.Equal(t, strings.TrimSpace(expected), strings.TrimSpace(output))
+	assert.Equal(t, `warning: skipping document 1, it is not a Kubernetes manifest with an apiVersion and kind
+warning: skipping document 3, it is not a Kubernetes manifest with an apiVersion and kind
+`, warnings.String())
+
+	r = strings.NewReader(yaml)
+	_, err = YAMLToTerraformResources(r)
+	assert.Error(t, err)
+}
+
+func TestYAMLToTerraformResourcesMissingFields(t *testing.T) {
+	tests := map[string]string{
+		`apiVersion: v1
+metadata:
+  name: test`: "document 1, line 1: error converting YAML to HCL: the manifest is missing kind",
+		`apiVersion: v1
+kind: ConfigMap`: "document 1, line 1: error converting YAML to HCL: ConfigMap is missing metadata",
+		`apiVersion: v1
+kind: ConfigMap
+metadata:
+  labels:
+    app: test`: "document 1, line 1: error converting YAML to HCL: ConfigMap is missing metadata.name, set metadata.name or metadata.generateName",
+		`apiVersion: v1
+kind: ConfigMapList
+items:
+- metadata:
+    name: test
+- data:
+    TEST: test`: "document 1, line 1: error converting YAML to HCL: item 2: ConfigMap is missing metadata",
+	}
+
+	for yaml, expected := range tests {
+		_, err := YAMLToTerraformResources(strings.NewReader(yaml))
+		assert.EqualError(t, err, expected)
+	}
+
+	// the name is only needed for the resource label
+	output, err := YAMLToTerraformResources(strings.NewReader(`apiVersion: v1
+kind: ConfigMap
+metadata: {}`), WithMapOnly(true))
+	assert.NoError(t, err)
+	assert.Contains(t, output, `"kind" = "ConfigMap"`)
+}
+
+func TestYAMLToTerraformResourcesIntegerPrecision(t *testing.T) {
+	yaml := `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: test
+numbers:
+  maxInt64: 9223372036854775807
+  minInt64: -9223372036854775808
+  maxUint64: 18446744073709551615
+  overUint64: 18446744073709551616
+  huge: 123456789012345678901234567890
+  negative: -123456789012345678901234567890
+  float: 1.5
+  exponent: 1e3
+  quoted: "123456789012345678901234567890"
+`
+
+	expected := `{
+  "apiVersion" = "v1"
+  "kind" = "ConfigMap"
+  "metadata" = {
+    "name" = "test"
+  }
+  "numbers" = {
+    "exponent" = 1000
+    "float" = 1.5
+    "huge" = 123456789012345678901234567890
+    "maxInt64" = 9223372036854775807
+    "maxUint64" = 18446744073709551615
+    "minInt64" = -9223372036854775808
+    "negative" = -123456789012345678901234567890
+    "overUint64" = 18446744073709551616
+    "quoted" = "123456789012345678901234567890"
+  }
+}
+`
+
+	output, err := YAMLToTerraformResources(strings.NewReader(yaml), WithMapOnly(true))
+	if err != nil {
+		t.Fatal("Converting to HCL failed:", err)
+	}
+	assert.Equal(t, expected, output)
+
+	json := `{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "test"}, "numbers": {
+  "maxInt64": 9223372036854775807, "minInt64": -9223372036854775808,
+  "maxUint64": 18446744073709551615, "overUint64": 18446744073709551616,
+  "huge": 123456789012345678901234567890, "negative": -123456789012345678901234567890,
+  "float": 1.5, "exponent": 1e3, "quoted": "123456789012345678901234567890"}}`
+
+	output, err = YAMLToTerraformResources(strings.NewReader(json), WithMapOnly(true))
+	if err != nil {
+		t.Fatal("Converting to HCL failed:", err)
+	}
+	assert.Equal(t, expected, output)
+}
+
+func TestYAMLToTerraformResourcesQuotedScalars(t *testing.T) {
+	yaml := `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: countries
+data:
+  norway: "NO"
+  answer: 'no'
+  "on": "on"
+  confirm: 'yes'
+  octal: "022"
+  hex: '0x1F'
+  exponent: "1e3"
+  empty: "null"
+  tilde: "~"
+  tagged: !!str off
+  block: |
+    no
+  folded: >-
+    on
+`
+
+	expected := `{
+  "apiVersion" = "v1"
+  "data" = {
+    "answer" = "no"
+    "block" = <<-EOT
+    no
+    
+    EOT
+    "confirm" = "yes"
+    "empty" = "null"
+    "exponent" = "1e3"
+    "folded" = "on"
+    "hex" = "0x1F"
+    "norway" = "NO"
+    "octal" = "022"
+    "on" = "on"
+    "tagged" = "off"
+    "tilde" = "~"
+  }
+  "kind" = "ConfigMap"
+  "metadata" = {
+    "name" = "countries"
+  }
+}
+`
+
+	output, err := YAMLToTerraformResources(strings.NewReader(yaml), WithMapOnly(true))
+	if err != nil {
+		t.Fatal("Converting to HCL failed:", err)
+	}
+	assert.Equal(t, expected, output)
+
+	// unquoted values follow YAML 1.1 like Kubernetes does
+	yaml = `apiVersion: v1
+kind: Secret
+metadata:
+  name: test
+immutable: yes
+defaultMode: 0644
+`
+
+	output, err = YAMLToTerraformResources(strings.NewReader(yaml), WithMapOnly(true))
+	if err != nil {
+		t.Fatal("Converting to HCL failed:", err)
+	}
+	assert.Contains(t, output, `"immutable" = true`)
+	assert.Contains(t, output, `"defaultMode" = 420`)
+}
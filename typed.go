@@ -0,0 +1,289 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"unicode"
+
+	cty "github.com/zclconf/go-cty/cty"
+
+	"github.com/jrhouston/tfk8s/contrib/hashicorp/terraform"
+	yaml "sigs.k8s.io/yaml"
+)
+
+// typedResourceTypes maps the apiVersion and kind of a manifest to the
+// native resource of the Kubernetes provider used with --typed
+var typedResourceTypes = map[string]string{
+	"v1/ConfigMap":             "kubernetes_config_map_v1",
+	"v1/Secret":                "kubernetes_secret_v1",
+	"v1/Service":               "kubernetes_service_v1",
+	"v1/ServiceAccount":        "kubernetes_service_account_v1",
+	"v1/Namespace":             "kubernetes_namespace_v1",
+	"v1/Pod":                   "kubernetes_pod_v1",
+	"v1/PersistentVolumeClaim": "kubernetes_persistent_volume_claim_v1",
+	"v1/LimitRange":            "kubernetes_limit_range_v1",
+	"v1/ResourceQuota":         "kubernetes_resource_quota_v1",
+
+	"apps/v1/Deployment":  "kubernetes_deployment_v1",
+	"apps/v1/StatefulSet": "kubernetes_stateful_set_v1",
+	"apps/v1/DaemonSet":   "kubernetes_daemon_set_v1",
+
+	"batch/v1/Job":     "kubernetes_job_v1",
+	"batch/v1/CronJob": "kubernetes_cron_job_v1",
+
+	"networking.k8s.io/v1/Ingress":       "kubernetes_ingress_v1",
+	"networking.k8s.io/v1/NetworkPolicy": "kubernetes_network_policy_v1",
+
+	"rbac.authorization.k8s.io/v1/Role":               "kubernetes_role_v1",
+	"rbac.authorization.k8s.io/v1/RoleBinding":        "kubernetes_role_binding_v1",
+	"rbac.authorization.k8s.io/v1/ClusterRole":        "kubernetes_cluster_role_v1",
+	"rbac.authorization.k8s.io/v1/ClusterRoleBinding": "kubernetes_cluster_role_binding_v1",
+
+	"storage.k8s.io/v1/StorageClass":                                 "kubernetes_storage_class_v1",
+	"policy/v1/PodDisruptionBudget":                                  "kubernetes_pod_disruption_budget_v1",
+	"autoscaling/v2/HorizontalPodAutoscaler":                         "kubernetes_horizontal_pod_autoscaler_v2",
+	"scheduling.k8s.io/v1/PriorityClass":                             "kubernetes_priority_class_v1",
+	"admissionregistration.k8s.io/v1/ValidatingWebhookConfiguration": "kubernetes_validating_webhook_configuration_v1",
+}
+
+// typedMetadataFields are the metadata fields the typed resources accept
+var typedMetadataFields = []string{"name", "generateName", "namespace", "labels", "annotations"}
+
+// typedMapFields are the fields that are map attributes in the provider
+// schema rather than blocks
+var typedMapFields = map[string]bool{
+	"labels":               true,
+	"annotations":          true,
+	"data":                 true,
+	"binaryData":           true,
+	"stringData":           true,
+	"matchLabels":          true,
+	"nodeSelector":         true,
+	"limits":               true,
+	"requests":             true,
+	"hard":                 true,
+	"parameters":           true,
+	"max":                  true,
+	"min":                  true,
+	"default":              true,
+	"defaultRequest":       true,
+	"maxLimitRequestRatio": true,
+}
+
+// typedMapPaths are the fields that are only map attributes in some
+// places, like the selector of a Service
+var typedMapPaths = map[string]bool{
+	"Service.spec.selector": true,
+}
+
+// typedFieldRenames are the fields the provider names differently to the
+// API, keyed by kind and path
+var typedFieldRenames = map[string]string{
+	// the provider takes the decoded values in data, so the base64 values
+	// in the manifest are set as binary_data
+	"Secret.data":       "binary_data",
+	"Secret.stringData": "data",
+}
+
+// typedBlockNames are the lists of objects that keep their plural name as
+// a block, the rest are made singular like containers becomes container
+var typedBlockNames = map[string]string{
+	"env":              "env",
+	"envFrom":          "env_from",
+	"tls":              "tls",
+	"items":            "items",
+	"matchExpressions": "match_expressions",
+	"matchFields":      "match_fields",
+	"imagePullSecrets": "image_pull_secrets",
+	"hostAliases":      "host_aliases",
+	"ingress":          "ingress",
+	"egress":           "egress",
+}
+
+// typedNames are the field names that don't follow the usual snake_case
+// conversion
+var typedNames = map[string]string{
+	"clusterIPs":  "cluster_ips",
+	"externalIPs": "external_ips",
+	"podIPs":      "pod_ips",
+}
+
+// typedResourceType returns the typed resource for a manifest and whether
+// there is one. mapping overrides and extends typedResourceTypes, and can
+// map a kind to kubernetes_manifest to stop it being typed.
+func typedResourceType(apiVersion, kind string, mapping map[string]string) (string, bool) {
+	key := apiVersion + "/" + kind
+	t, ok := mapping[key]
+	if !ok {
+		t, ok = typedResourceTypes[key]
+	}
+	if !ok || t == resourceType {
+		return "", false
+	}
+	return t, true
+}
+
+// loadTypedMapping reads a --typed-mapping file, a YAML map of apiVersion
+// and kind to resource type like
+//
+//	apps/v1/Deployment: kubernetes_deployment
+func loadTypedMapping(path string) (map[string]string, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	mapping := map[string]string{}
+	if err := yaml.Unmarshal(b, &mapping); err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	for key := range mapping {
+		if !strings.Contains(key, "/") {
+			return nil, fmt.Errorf("%s: %q must be the apiVersion and kind, like apps/v1/Deployment", path, key)
+		}
+	}
+	return mapping, nil
+}
+
+// snakeCase converts a field name like loadBalancerIP to the name the
+// provider uses, like load_balancer_ip
+func snakeCase(name string) string {
+	if s, ok := typedNames[name]; ok {
+		return s
+	}
+	runes := []rune(name)
+	var buf strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) && i > 0 {
+			prev := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextLower) {
+				buf.WriteRune('_')
+			}
+		}
+		buf.WriteRune(unicode.ToLower(r))
+	}
+	return buf.String()
+}
+
+// blockName returns the name of the blocks a list of objects becomes
+func blockName(field string) string {
+	if name, ok := typedBlockNames[field]; ok {
+		return name
+	}
+	name := snakeCase(field)
+	switch {
+	case strings.HasSuffix(name, "ies"):
+		return strings.TrimSuffix(name, "ies") + "y"
+	case strings.HasSuffix(name, "ss"):
+		return name
+	}
+	return strings.TrimSuffix(name, "s")
+}
+
+// isObjectList returns true if v is a list of objects, which become
+// repeated blocks
+func isObjectList(v cty.Value) bool {
+	ty := v.Type()
+	if !(ty.IsTupleType() || ty.IsListType()) || v.LengthInt() == 0 {
+		return false
+	}
+	for it := v.ElementIterator(); it.Next(); {
+		_, item := it.Element()
+		if item.IsMarked() || item.IsNull() || !item.Type().IsObjectType() {
+			return false
+		}
+	}
+	return true
+}
+
+// typedBody writes the attributes and blocks for an object in the manifest.
+// path is the kind and the path to the object, like Deployment.spec, and is
+// used to look up the fields that are special.
+func typedBody(buf *strings.Builder, v cty.Value, path string, depth int) {
+	m := v.AsValueMap()
+	keys := []string{}
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pad := strings.Repeat("  ", depth)
+	blocks := []string{}
+	for _, k := range keys {
+		v := m[k]
+		fieldPath := path + "." + k
+		name, ok := typedFieldRenames[fieldPath]
+		if !ok {
+			name = snakeCase(k)
+		}
+
+		switch {
+		case v.IsNull():
+			continue
+		case !v.IsMarked() && v.Type().IsObjectType() && !typedMapFields[k] && !typedMapPaths[fieldPath]:
+			blocks = append(blocks, k)
+			continue
+		case !v.IsMarked() && isObjectList(v):
+			blocks = append(blocks, k)
+			continue
+		}
+		value := terraform.FormatValue(v, len(pad), false)
+		fmt.Fprintf(buf, "%s%s = %s\n", pad, name, value)
+	}
+
+	for _, k := range blocks {
+		v := m[k]
+		fieldPath := path + "." + k
+		if v.Type().IsObjectType() {
+			typedBlock(buf, snakeCase(k), v, fieldPath, depth)
+			continue
+		}
+		for it := v.ElementIterator(); it.Next(); {
+			_, item := it.Element()
+			typedBlock(buf, blockName(k), item, fieldPath, depth)
+		}
+	}
+}
+
+// typedBlock writes an object in the manifest as a block
+func typedBlock(buf *strings.Builder, name string, v cty.Value, path string, depth int) {
+	pad := strings.Repeat("  ", depth)
+	if v.LengthInt() == 0 {
+		fmt.Fprintf(buf, "%s%s {}\n", pad, name)
+		return
+	}
+	fmt.Fprintf(buf, "%s%s {\n", pad, name)
+	typedBody(buf, v, path, depth+1)
+	fmt.Fprintf(buf, "%s}\n", pad)
+}
+
+// typedResource returns the body of a typed resource for a manifest. Only
+// the metadata the provider accepts is kept, and status is left out.
+func typedResource(manifest cty.Value, kind string) string {
+	m := manifest.AsValueMap()
+	delete(m, "apiVersion")
+	delete(m, "kind")
+	delete(m, "status")
+
+	metadata := map[string]cty.Value{}
+	if v, ok := m["metadata"]; ok {
+		mm := v.AsValueMap()
+		for _, f := range typedMetadataFields {
+			if v, ok := mm[f]; ok {
+				metadata[f] = v
+			}
+		}
+	}
+	delete(m, "metadata")
+
+	// metadata goes first, like in the provider's examples
+	var buf strings.Builder
+	typedBlock(&buf, "metadata", cty.ObjectVal(metadata), kind+".metadata", 1)
+	if len(m) > 0 {
+		buf.WriteString("\n")
+		typedBody(&buf, cty.ObjectVal(m), kind, 1)
+	}
+	return buf.String()
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestYAMLToTerraformResourcesTyped(t *testing.T) {
+	yaml := `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: nginx
+  labels:
+    app: nginx
+  uid: 6c5bd9a4-4b4c-4b68-8d2a-6d2f5c3a4b1e
+spec:
+  replicas: 2
+  selector:
+    matchLabels:
+      app: nginx
+  template:
+    metadata:
+      labels:
+        app: nginx
+    spec:
+      containers:
+      - name: nginx
+        image: nginx:1.21
+        ports:
+        - containerPort: 80
+      volumes:
+      - name: cache
+        emptyDir: {}
+status:
+  replicas: 2
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: nginx
+spec:
+  clusterIP: 10.0.0.10
+  selector:
+    app: nginx
+  ports:
+  - port: 80
+    targetPort: 8080
+`
+
+	output, err := YAMLToTerraformResources(strings.NewReader(yaml), WithTyped(true))
+	if err != nil {
+		t.Fatal("Converting to HCL failed:", err)
+	}
+
+	expected := `resource "kubernetes_deployment_v1" "deployment_nginx" {
+  metadata {
+    labels = {
+      "app" = "nginx"
+    }
+    name = "nginx"
+  }
+
+  spec {
+    replicas = 2
+    selector {
+      match_labels = {
+        "app" = "nginx"
+      }
+    }
+    template {
+      metadata {
+        labels = {
+          "app" = "nginx"
+        }
+      }
+      spec {
+        container {
+          image = "nginx:1.21"
+          name = "nginx"
+          port {
+            container_port = 80
+          }
+        }
+        volume {
+          name = "cache"
+          empty_dir {}
+        }
+      }
+    }
+  }
+}
+
+resource "kubernetes_service_v1" "service_nginx" {
+  metadata {
+    name = "nginx"
+  }
+
+  spec {
+    cluster_ip = "10.0.0.10"
+    selector = {
+      "app" = "nginx"
+    }
+    port {
+      port = 80
+      target_port = 8080
+    }
+  }
+}
+`
+	assert.Equal(t, expected, output)
+}
+
+func TestYAMLToTerraformResourcesTypedSecret(t *testing.T) {
+	output, err := YAMLToTerraformResources(strings.NewReader(`apiVersion: v1
+kind: Secret
+metadata:
+  name: test
+type: Opaque
+data:
+  password: c2VjcmV0
+stringData:
+  username: admin
+`), WithTyped(true))
+	if err != nil {
+		t.Fatal("Converting to HCL failed:", err)
+	}
+	assert.Contains(t, output, "  binary_data = {\n    \"password\" = \"c2VjcmV0\"\n  }\n")
+	assert.Contains(t, output, "  data = {\n    \"username\" = \"admin\"\n  }\n")
+}
+
+func TestYAMLToTerraformResourcesTypedMapping(t *testing.T) {
+	yaml := `apiVersion: example.com/v1
+kind: Widget
+metadata:
+  name: test
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: test
+`
+
+	// kinds without a typed resource fall back to kubernetes_manifest
+	var notes strings.Builder
+	output, err := YAMLToTerraformResources(strings.NewReader(yaml), WithTyped(true), WithVerbose(&notes))
+	if err != nil {
+		t.Fatal("Converting to HCL failed:", err)
+	}
+	assert.Contains(t, output, `resource "kubernetes_manifest" "widget_test" {`)
+	assert.Contains(t, output, `resource "kubernetes_config_map_v1" "configmap_test" {`)
+	assert.Equal(t, "note: there is no typed resource for example.com/v1 Widget, using kubernetes_manifest\n", notes.String())
+
+	output, err = YAMLToTerraformResources(strings.NewReader(yaml), WithTyped(true), WithTypedMapping(map[string]string{
+		"example.com/v1/Widget": "example_widget",
+		"v1/ConfigMap":          "kubernetes_manifest",
+	}))
+	if err != nil {
+		t.Fatal("Converting to HCL failed:", err)
+	}
+	assert.Contains(t, output, `resource "example_widget" "widget_test" {`)
+	assert.Contains(t, output, `resource "kubernetes_manifest" "configmap_test" {`)
+}
+
+func TestLoadTypedMapping(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tfk8s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "mapping.yaml")
+	if err := ioutil.WriteFile(path, []byte("apps/v1/Deployment: kubernetes_deployment\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	mapping, err := loadTypedMapping(path)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"apps/v1/Deployment": "kubernetes_deployment"}, mapping)
+
+	if err := ioutil.WriteFile(path, []byte("Deployment: kubernetes_deployment\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, err = loadTypedMapping(path)
+	assert.Error(t, err)
+}
+
+func TestSnakeCase(t *testing.T) {
+	tests := map[string]string{
+		"containerPort":                 "container_port",
+		"loadBalancerIP":                "load_balancer_ip",
+		"clusterIPs":                    "cluster_ips",
+		"hostIPC":                       "host_ipc",
+		"terminationGracePeriodSeconds": "termination_grace_period_seconds",
+		"name":                          "name",
+	}
+	for in, expected := range tests {
+		assert.Equal(t, expected, snakeCase(in))
+	}
+}
+
+func TestBlockName(t *testing.T) {
+	tests := map[string]string{
+		"containers":       "container",
+		"volumeMounts":     "volume_mount",
+		"policies":         "policy",
+		"env":              "env",
+		"matchExpressions": "match_expressions",
+		"ingress":          "ingress",
+	}
+	for in, expected := range tests {
+		assert.Equal(t, expected, blockName(in))
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+
+	cty "github.com/zclconf/go-cty/cty"
+
+	"github.com/jrhouston/tfk8s/contrib/hashicorp/terraform"
+)
+
+// vaultDataSource is the data source --secrets-to-vault reads the values of
+// Secrets with
+const vaultDataSource = "vault_kv_secret_v2"
+
+// defaultVaultPath is the path in the KV mount --secrets-to-vault reads the
+// values of a Secret from by default
+const defaultVaultPath = defaultSecretPath
+
+// vaultSecrets replaces the values of Secrets with references to a
+// vault_kv_secret_v2 data source for each Secret
+type vaultSecrets struct {
+	// mount is the path of the KV version 2 secrets engine
+	mount string
+	// path is executed with the Secret's filenameData to get the name of
+	// the secret in the mount
+	path *template.Template
+}
+
+// newVaultSecrets returns the vaultSecrets for a KV mount and a path
+// template like {{.Namespace}}/{{.Name}}
+func newVaultSecrets(mount, path string) (*vaultSecrets, error) {
+	if mount == "" {
+		return nil, fmt.Errorf("--vault-mount must not be empty")
+	}
+	t, err := parseSecretPath("--vault-path", path)
+	if err != nil {
+		return nil, err
+	}
+	return &vaultSecrets{mount: mount, path: t}, nil
+}
+
+// vaultSecret is the vault_kv_secret_v2 data source a Secret's values are
+// read from
+type vaultSecret struct {
+	mount string
+	name  string
+}
+
+// reference replaces the values in the data and stringData of the Secret
+// doc with the values of the same keys in the Vault secret it returns, nil
+// if the Secret has no values. The data values that are text are read
+// decoded and encoded again with base64encode(), binary values are read as
+// they are.
+func (v *vaultSecrets) reference(doc cty.Value, namespace, name, resourceName string) (cty.Value, *vaultSecret, error) {
+	path, err := secretPath(v.path, "--vault-path", namespace, name, resourceName)
+	if err != nil {
+		return cty.NilVal, nil, err
+	}
+	address := fmt.Sprintf("data.%s.%s", vaultDataSource, resourceName)
+	referenced := false
+	doc = replaceSecretValues(doc, func(field, k string, _ cty.Value, binary bool) (cty.Value, bool) {
+		referenced = true
+		ref := fmt.Sprintf("%s.data[%q]", address, k)
+		if field == "data" && !binary {
+			ref = fmt.Sprintf("base64encode(%s)", ref)
+		}
+		return cty.StringVal(ref).Mark(terraform.Expression), true
+	})
+	if !referenced {
+		return doc, nil, nil
+	}
+	return doc, &vaultSecret{mount: v.mount, name: path}, nil
+}
+
+// vaultDataBlock returns the data source r reads the values of its Secret
+// from as a resource of its own, so --append finds it by its address like
+// any other resource, or nil if it doesn't have one
+func vaultDataBlock(r resource) []resource {
+	if r.vault == nil {
+		return nil
+	}
+	var buf strings.Builder
+	fmt.Fprintf(&buf, "data %q %q {\n", vaultDataSource, r.name)
+	fmt.Fprintf(&buf, "  mount = %q\n", r.vault.mount)
+	fmt.Fprintf(&buf, "  name  = %q\n", r.vault.name)
+	buf.WriteString("}\n")
+	return []resource{{resourceType: "data." + vaultDataSource, name: r.name, text: buf.String()}}
+}
+
+// vaultDataJSON returns the vault_kv_secret_v2 data sources of resources for
+// Terraform JSON, by label, or nil if there are none
+func vaultDataJSON(resources []resource) map[string]interface{} {
+	sources := map[string]interface{}{}
+	for _, r := range resources {
+		if r.vault != nil {
+			sources[r.name] = map[string]string{
+				"mount": r.vault.mount,
+				"name":  r.vault.name,
+			}
+		}
+	}
+	if len(sources) == 0 {
+		return nil
+	}
+	return sources
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVaultSecrets(t *testing.T) {
+	yaml := `apiVersion: v1
+kind: Secret
+metadata:
+  name: db
+  annotations:
+    kubectl.kubernetes.io/last-applied-configuration: '{"stringData":{"user":"admin"}}'
+data:
+  password: aHVudGVyMg==
+  tls.key: /w==
+stringData:
+  user: admin
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: db
+data:
+  user: admin
+`
+	vault, err := newVaultSecrets("kv", "k8s/{{.Namespace}}/{{.Name}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	output, err := YAMLToTerraformResources(strings.NewReader(yaml), WithVaultSecrets(vault))
+	if err != nil {
+		t.Fatal("Converting to HCL failed:", err)
+	}
+
+	expected := `
+data "vault_kv_secret_v2" "secret_db" {
+  mount = "kv"
+  name  = "k8s/default/db"
+}
+
+resource "kubernetes_manifest" "secret_db" {
+  manifest = {
+    "apiVersion" = "v1"
+    "data" = {
+      "password" = base64encode(data.vault_kv_secret_v2.secret_db.data["password"])
+      "tls.key" = data.vault_kv_secret_v2.secret_db.data["tls.key"]
+    }
+    "kind" = "Secret"
+    "metadata" = {
+      "name" = "db"
+    }
+    "stringData" = {
+      "user" = data.vault_kv_secret_v2.secret_db.data["user"]
+    }
+  }
+}
+
+resource "kubernetes_manifest" "configmap_db" {
+  manifest = {
+    "apiVersion" = "v1"
+    "data" = {
+      "user" = "admin"
+    }
+    "kind" = "ConfigMap"
+    "metadata" = {
+      "name" = "db"
+    }
+  }
+}`
+
+	assert.Equal(t, strings.TrimSpace(expected), strings.TrimSpace(output))
+}
+
+func TestVaultSecretsJSON(t *testing.T) {
+	vault, err := newVaultSecrets("secret", defaultVaultPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	output, err := YAMLToTerraformResources(strings.NewReader(`apiVersion: v1
+kind: Secret
+metadata:
+  name: db
+  namespace: prod
+stringData:
+  user: admin
+`), WithVaultSecrets(vault), WithFormat("tfjson"))
+	if err != nil {
+		t.Fatal("Converting to JSON failed:", err)
+	}
+
+	assert.Contains(t, output, `"user": "${data.vault_kv_secret_v2.secret_prod_db.data[\"user\"]}"`)
+	assert.Contains(t, output, `"data": {
+    "vault_kv_secret_v2": {
+      "secret_prod_db": {
+        "mount": "secret",
+        "name": "prod/db"
+      }
+    }
+  }`)
+}
+
+func TestVaultSecretsAppend(t *testing.T) {
+	vault, err := newVaultSecrets("secret", defaultVaultPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resources, err := convertResources(strings.NewReader(`apiVersion: v1
+kind: Secret
+metadata:
+  name: db
+  namespace: prod
+stringData:
+  user: admin
+`), WithVaultSecrets(vault))
+	if err != nil {
+		t.Fatal("Converting to HCL failed:", err)
+	}
+
+	merged, _, err := mergeResources("", withSecretBlocks(resources), false)
+	assert.NoError(t, err)
+	merged, skipped, err := mergeResources(merged, withSecretBlocks(resources), false)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"data.vault_kv_secret_v2.secret_prod_db", "kubernetes_manifest.secret_prod_db"}, skipped)
+	merged, _, err = mergeResources(merged, withSecretBlocks(resources), true)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, strings.Count(merged, `data "vault_kv_secret_v2" "secret_prod_db"`))
+	assert.Equal(t, 1, strings.Count(merged, `resource "kubernetes_manifest" "secret_prod_db"`))
+}
+
+func TestNewVaultSecretsInvalid(t *testing.T) {
+	_, err := newVaultSecrets("", defaultVaultPath)
+	assert.Error(t, err)
+	_, err = newVaultSecrets("secret", "{{.Name")
+	assert.Error(t, err)
+}
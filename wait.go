@@ -0,0 +1,241 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// rolloutKinds are the kinds the provider can wait for the rollout of
+var rolloutKinds = map[string]bool{
+	"Deployment":  true,
+	"StatefulSet": true,
+	"DaemonSet":   true,
+}
+
+// statuslessKinds are the kinds that don't have a status to wait for, the
+// conditions and fields that don't name a kind aren't waited for on them
+var statuslessKinds = map[string]bool{
+	"ConfigMap":                      true,
+	"Secret":                         true,
+	"ServiceAccount":                 true,
+	"Service":                        true,
+	"Endpoints":                      true,
+	"Namespace":                      true,
+	"Role":                           true,
+	"RoleBinding":                    true,
+	"ClusterRole":                    true,
+	"ClusterRoleBinding":             true,
+	"NetworkPolicy":                  true,
+	"LimitRange":                     true,
+	"ResourceQuota":                  true,
+	"StorageClass":                   true,
+	"PriorityClass":                  true,
+	"IngressClass":                   true,
+	"MutatingWebhookConfiguration":   true,
+	"ValidatingWebhookConfiguration": true,
+}
+
+// waitDefaults are what --wait-defaults waits for on each kind
+var waitDefaults = map[string]resourceWait{
+	"Deployment":  {rollout: true},
+	"StatefulSet": {rollout: true},
+	"DaemonSet":   {rollout: true},
+	"Job":         {conditions: []waitCondition{{kind: "Job", typ: "Complete", status: "True"}}},
+}
+
+// waitDefaultKinds returns the kinds --wait-defaults knows in order
+func waitDefaultKinds() []string {
+	kinds := []string{}
+	for kind := range waitDefaults {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	return kinds
+}
+
+// waitCondition is a status condition to wait for, on the resources of
+// kind or every kind with a status if kind is empty
+type waitCondition struct {
+	kind   string
+	typ    string
+	status string
+}
+
+// waitField is a field to wait for to have a value, on the resources of
+// kind or every kind with a status if kind is empty
+type waitField struct {
+	kind  string
+	path  string
+	value string
+}
+
+// waitConfig is what the resources wait for after they are applied
+type waitConfig struct {
+	rollout    bool
+	conditions []waitCondition
+	fields     []waitField
+	// defaults are the kinds that wait for what waitDefaults has for them
+	defaults map[string]bool
+}
+
+// setDefaults makes the resources of kinds wait for what waitDefaults has
+// for them
+func (w *waitConfig) setDefaults(kinds []string) error {
+	w.defaults = map[string]bool{}
+	for _, kind := range kinds {
+		if _, ok := waitDefaults[kind]; !ok {
+			return fmt.Errorf("--wait-defaults doesn't know what to wait for on %s, it must be one of %s", kind, strings.Join(waitDefaultKinds(), ", "))
+		}
+		w.defaults[kind] = true
+	}
+	return nil
+}
+
+// parseWaitCondition parses a condition like Ready, Job:Complete or
+// Certificate:Ready=False, the status is True if it isn't set
+func parseWaitCondition(s string) (waitCondition, error) {
+	c := waitCondition{status: "True"}
+	typ := s
+	if i := strings.Index(typ, ":"); i >= 0 {
+		c.kind, typ = typ[:i], typ[i+1:]
+	}
+	if i := strings.Index(typ, "="); i >= 0 {
+		typ, c.status = typ[:i], typ[i+1:]
+	}
+	c.typ = typ
+	if c.typ == "" || c.status == "" {
+		return waitCondition{}, fmt.Errorf("invalid --wait-condition %q, must be like Ready, Job:Complete or Certificate:Ready=True", s)
+	}
+	return c, nil
+}
+
+// parseWaitField parses a field like status.phase=Running or
+// Pod:status.phase=Running
+func parseWaitField(s string) (waitField, error) {
+	f := waitField{}
+	i := strings.Index(s, "=")
+	if i < 0 {
+		return waitField{}, fmt.Errorf("invalid --wait-fields %q, must be like status.phase=Running or Pod:status.phase=Running", s)
+	}
+	f.path, f.value = s[:i], s[i+1:]
+	if j := strings.Index(f.path, ":"); j >= 0 {
+		f.kind, f.path = f.path[:j], f.path[j+1:]
+	}
+	if f.path == "" {
+		return waitField{}, fmt.Errorf("invalid --wait-fields %q, must be like status.phase=Running or Pod:status.phase=Running", s)
+	}
+	return f, nil
+}
+
+// resourceWait is the wait block of a single resource
+type resourceWait struct {
+	rollout    bool
+	conditions []waitCondition
+	fields     map[string]string
+}
+
+// forKind returns the wait block for a resource of kind, or nil if it
+// doesn't wait for anything
+func (w *waitConfig) forKind(kind string) *resourceWait {
+	if w == nil {
+		return nil
+	}
+	applies := func(k string) bool {
+		if k == "" {
+			return !statuslessKinds[kind]
+		}
+		return k == kind
+	}
+	rw := &resourceWait{rollout: w.rollout && rolloutKinds[kind]}
+	if w.defaults[kind] {
+		d := waitDefaults[kind]
+		rw.rollout = rw.rollout || d.rollout
+		rw.conditions = append(rw.conditions, d.conditions...)
+	}
+	for _, c := range w.conditions {
+		if applies(c.kind) && !rw.hasCondition(c.typ) {
+			rw.conditions = append(rw.conditions, c)
+		}
+	}
+	for _, f := range w.fields {
+		if applies(f.kind) {
+			if rw.fields == nil {
+				rw.fields = map[string]string{}
+			}
+			rw.fields[f.path] = f.value
+		}
+	}
+	if !rw.rollout && len(rw.conditions) == 0 && len(rw.fields) == 0 {
+		return nil
+	}
+	return rw
+}
+
+// hasCondition returns true if the resource already waits for the
+// condition typ
+func (rw *resourceWait) hasCondition(typ string) bool {
+	for _, c := range rw.conditions {
+		if c.typ == typ {
+			return true
+		}
+	}
+	return false
+}
+
+// waitBlock returns the wait block of r, or "" if it doesn't wait for
+// anything
+func waitBlock(r resource) string {
+	if r.wait == nil {
+		return ""
+	}
+	var buf strings.Builder
+	buf.WriteString("\n  wait {\n")
+	if r.wait.rollout {
+		buf.WriteString("    rollout = true\n")
+	}
+	for _, c := range r.wait.conditions {
+		buf.WriteString("    condition {\n")
+		fmt.Fprintf(&buf, "      type   = %q\n", c.typ)
+		fmt.Fprintf(&buf, "      status = %q\n", c.status)
+		buf.WriteString("    }\n")
+	}
+	if len(r.wait.fields) > 0 {
+		buf.WriteString("    fields = {\n")
+		for _, path := range sortedKeys(r.wait.fields) {
+			fmt.Fprintf(&buf, "      %q = %q\n", path, r.wait.fields[path])
+		}
+		buf.WriteString("    }\n")
+	}
+	buf.WriteString("  }\n")
+	return buf.String()
+}
+
+// waitJSON returns the wait block w for Terraform JSON
+func waitJSON(w *resourceWait) map[string]interface{} {
+	block := map[string]interface{}{}
+	if w.rollout {
+		block["rollout"] = true
+	}
+	if len(w.conditions) > 0 {
+		conditions := []map[string]string{}
+		for _, c := range w.conditions {
+			conditions = append(conditions, map[string]string{"type": c.typ, "status": c.status})
+		}
+		block["condition"] = conditions
+	}
+	if len(w.fields) > 0 {
+		block["fields"] = w.fields
+	}
+	return block
+}
+
+// sortedKeys returns the keys of m in order
+func sortedKeys(m map[string]string) []string {
+	keys := []string{}
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var waitYAML = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+---
+apiVersion: batch/v1
+kind: Job
+metadata:
+  name: migrate
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+---
+apiVersion: cert-manager.io/v1
+kind: Certificate
+metadata:
+  name: web
+`
+
+func TestParseWaitCondition(t *testing.T) {
+	c, err := parseWaitCondition("Ready")
+	assert.NoError(t, err)
+	assert.Equal(t, waitCondition{typ: "Ready", status: "True"}, c)
+
+	c, err = parseWaitCondition("Job:Failed=False")
+	assert.NoError(t, err)
+	assert.Equal(t, waitCondition{kind: "Job", typ: "Failed", status: "False"}, c)
+
+	_, err = parseWaitCondition("Job:")
+	assert.Error(t, err)
+
+	f, err := parseWaitField("Pod:status.phase=Running")
+	assert.NoError(t, err)
+	assert.Equal(t, waitField{kind: "Pod", path: "status.phase", value: "Running"}, f)
+
+	_, err = parseWaitField("status.phase")
+	assert.Error(t, err)
+}
+
+func TestWait(t *testing.T) {
+	wait := &waitConfig{
+		rollout:    true,
+		conditions: []waitCondition{{kind: "Job", typ: "Complete", status: "True"}, {typ: "Ready", status: "True"}},
+	}
+	resources, err := convertResources(strings.NewReader(waitYAML), WithWait(wait))
+	if err != nil {
+		t.Fatal("Converting to HCL failed:", err)
+	}
+
+	assert.Contains(t, resources[0].text, `
+  wait {
+    rollout = true
+    condition {
+      type   = "Ready"
+      status = "True"
+    }
+  }
+}
+`)
+	assert.Contains(t, resources[1].text, `
+  wait {
+    condition {
+      type   = "Complete"
+      status = "True"
+    }
+    condition {
+      type   = "Ready"
+      status = "True"
+    }
+  }
+`)
+	assert.NotContains(t, resources[2].text, "wait")
+	assert.Contains(t, resources[3].text, `type   = "Ready"`)
+
+	resources, err = convertResources(strings.NewReader(waitYAML), WithWait(wait), WithFormat("tfjson"))
+	if err != nil {
+		t.Fatal("Converting to JSON failed:", err)
+	}
+	assert.Contains(t, resources[0].text, `"wait": {
+    "condition": [
+      {
+        "status": "True",
+        "type": "Ready"
+      }
+    ],
+    "rollout": true
+  }`)
+}
+
+func TestWaitDefaults(t *testing.T) {
+	wait := &waitConfig{}
+	assert.Error(t, wait.setDefaults([]string{"ConfigMap"}))
+	assert.NoError(t, wait.setDefaults([]string{"Job", "Deployment"}))
+	// the default condition isn't repeated when it is also set
+	wait.conditions = []waitCondition{{typ: "Complete", status: "True"}}
+
+	resources, err := convertResources(strings.NewReader(waitYAML), WithWait(wait))
+	if err != nil {
+		t.Fatal("Converting to HCL failed:", err)
+	}
+	assert.Contains(t, resources[0].text, "  wait {\n    rollout = true\n    condition {\n")
+	assert.Equal(t, &resourceWait{
+		conditions: []waitCondition{{kind: "Job", typ: "Complete", status: "True"}},
+	}, resources[1].wait)
+
+	assert.NoError(t, wait.setDefaults([]string{"Job"}))
+	wait.conditions = nil
+	resources, err = convertResources(strings.NewReader(waitYAML), WithWait(wait))
+	if err != nil {
+		t.Fatal("Converting to HCL failed:", err)
+	}
+	assert.Nil(t, resources[0].wait)
+	assert.NotNil(t, resources[1].wait)
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	cty "github.com/zclconf/go-cty/cty"
+	yamlv2 "gopkg.in/yaml.v2"
+	yamlv3 "gopkg.in/yaml.v3"
+
+	"github.com/jrhouston/tfk8s/contrib/hashicorp/terraform"
+)
+
+// scalarNode returns a YAML scalar
+func scalarNode(tag, value string) *yamlv3.Node {
+	return &yamlv3.Node{Kind: yamlv3.ScalarNode, Tag: tag, Value: value}
+}
+
+// stringNode returns a YAML string or key, quoted if YAML 1.1 would read
+// it as something else when it is plain. kubectl and the API server read YAML
+// 1.1, where strings like NO, on and 0755 are a bool and a number.
+func stringNode(s string) *yamlv3.Node {
+	n := scalarNode("!!str", s)
+	if strings.Contains(s, "\n") {
+		n.Style = yamlv3.LiteralStyle
+		return n
+	}
+	var v interface{}
+	if err := yamlv2.Unmarshal([]byte(s), &v); err != nil || v != s {
+		n.Style = yamlv3.DoubleQuotedStyle
+	}
+	return n
+}
+
+// mappingNode returns a YAML mapping of the keys and values in pairs
+func mappingNode(pairs ...*yamlv3.Node) *yamlv3.Node {
+	return &yamlv3.Node{Kind: yamlv3.MappingNode, Tag: "!!map", Content: pairs}
+}
+
+// yamlEncoding is how a format writes the strings in a manifest as YAML
+type yamlEncoding struct {
+	// flag is the flag that chose the encoding, like --format yaml, used
+	// in errors
+	flag string
+	// escape is applied to strings for formats that interpolate them
+	escape func(string) string
+	// expression returns the string for a Terraform expression, it is nil
+	// for formats that can't evaluate them
+	expression func(expr string) string
+}
+
+// yamlNode converts a manifest value to a YAML node
+func yamlNode(v cty.Value, enc yamlEncoding) (*yamlv3.Node, error) {
+	if v.HasMark(terraform.Expression) {
+		expr, _ := v.Unmark()
+		if enc.expression == nil {
+			return nil, fmt.Errorf("%s is a Terraform expression, it can't be used with %s", expr.AsString(), enc.flag)
+		}
+		return scalarNode("!!str", enc.expression(expr.AsString())), nil
+	}
+	if v.IsNull() {
+		return scalarNode("!!null", "null"), nil
+	}
+
+	ty := v.Type()
+	switch {
+	case ty == cty.String:
+		return stringNode(enc.escape(v.AsString())), nil
+	case ty == cty.Number:
+		bf := v.AsBigFloat()
+		if bf.IsInt() {
+			return scalarNode("!!int", bf.Text('f', -1)), nil
+		}
+		return scalarNode("!!float", bf.Text('f', -1)), nil
+	case ty == cty.Bool:
+		if v.True() {
+			return scalarNode("!!bool", "true"), nil
+		}
+		return scalarNode("!!bool", "false"), nil
+	case ty.IsObjectType() || ty.IsMapType():
+		n := mappingNode()
+		for it := v.ElementIterator(); it.Next(); {
+			k, v := it.Element()
+			value, err := yamlNode(v, enc)
+			if err != nil {
+				return nil, err
+			}
+			key := stringNode(k.AsString())
+			if key.Style == yamlv3.LiteralStyle {
+				// keys can't be block scalars
+				key.Style = yamlv3.DoubleQuotedStyle
+			}
+			n.Content = append(n.Content, key, value)
+		}
+		return n, nil
+	case ty.IsTupleType() || ty.IsListType() || ty.IsSetType():
+		n := &yamlv3.Node{Kind: yamlv3.SequenceNode, Tag: "!!seq"}
+		for it := v.ElementIterator(); it.Next(); {
+			_, v := it.Element()
+			item, err := yamlNode(v, enc)
+			if err != nil {
+				return nil, err
+			}
+			n.Content = append(n.Content, item)
+		}
+		return n, nil
+	}
+	return scalarNode("!!null", "null"), nil
+}
+
+// encodeYAML writes n as YAML indented by two spaces
+func encodeYAML(n *yamlv3.Node) (string, error) {
+	var buf bytes.Buffer
+	enc := yamlv3.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(n); err != nil {
+		return "", err
+	}
+	if err := enc.Close(); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// yamlFormat writes the manifests as a stream of YAML documents, after
+// they have been stripped or wrapped, so they can be applied with kubectl
+type yamlFormat struct{}
+
+func (yamlFormat) resource(r resource, o options) (string, error) {
+	if o.mapOnly {
+		return "", fmt.Errorf("--map-only can't be used with --format yaml")
+	}
+	n, err := yamlNode(r.manifest, yamlEncoding{
+		flag:   "--format yaml",
+		escape: func(s string) string { return s },
+	})
+	if err != nil {
+		return "", err
+	}
+	return encodeYAML(n)
+}
+
+func (yamlFormat) join(resources []resource) (string, error) {
+	docs := make([]string, len(resources))
+	for i, r := range resources {
+		docs[i] = r.text
+	}
+	return strings.Join(docs, "---\n"), nil
+}
+
+func (yamlFormat) extension() string {
+	return ".yaml"
+}
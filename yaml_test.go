@@ -0,0 +1,87 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	yamlv2 "gopkg.in/yaml.v2"
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+func TestYAMLToTerraformResourcesYAML(t *testing.T) {
+	output, err := YAMLToTerraformResources(strings.NewReader(`apiVersion: v1
+kind: Namespace
+metadata:
+  name: one
+  creationTimestamp: "2021-01-01T00:00:00Z"
+---
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: "two"
+  labels:
+    enabled: "true"
+`), WithFormat("yaml"), WithStripServerSide(true))
+	if err != nil {
+		t.Fatal("Converting to YAML failed:", err)
+	}
+
+	expected := `apiVersion: v1
+kind: Namespace
+metadata:
+  name: one
+---
+apiVersion: v1
+kind: Namespace
+metadata:
+  labels:
+    enabled: "true"
+  name: two
+`
+	assert.Equal(t, expected, output)
+}
+
+func TestYAMLFormatQuotesStrings(t *testing.T) {
+	data := map[string]string{
+		"country": "NO",
+		"enabled": "on",
+		"answer":  "y",
+		"mode":    "0755",
+		"version": "1.10",
+		"time":    "1:20",
+		"empty":   "",
+		"missing": "~",
+		"nothing": "null",
+		"date":    "2021-01-01",
+		"name":    "nginx",
+	}
+	manifest := "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: test\ndata:\n"
+	for k, v := range data {
+		manifest += "  " + k + ": \"" + v + "\"\n"
+	}
+	output, err := YAMLToTerraformResources(strings.NewReader(manifest), WithFormat("yaml"))
+	if err != nil {
+		t.Fatal("Converting to YAML failed:", err)
+	}
+	assert.Contains(t, output, "  name: nginx\n")
+
+	// YAML 1.1, like kubectl
+	var v1 struct {
+		Data map[string]interface{}
+	}
+	if err := yamlv2.Unmarshal([]byte(output), &v1); err != nil {
+		t.Fatal(err)
+	}
+	// YAML 1.2
+	var v2 struct {
+		Data map[string]interface{}
+	}
+	if err := yamlv3.Unmarshal([]byte(output), &v2); err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range data {
+		assert.Equal(t, v, v1.Data[k], k)
+		assert.Equal(t, v, v2.Data[k], k)
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+// defaultManifestDir is where --format yamlref writes the manifests, next
+// to the config, when --manifest-dir isn't set
+const defaultManifestDir = "manifests"
+
+// manifestYAML returns the YAML of a manifest. The document is used as it
+// was written if the manifest wasn't changed while it was converted, so
+// formatting and comments are kept. flag is the flag that writes the YAML,
+// used in errors.
+func manifestYAML(r resource, flag string) (string, error) {
+	if r.source != "" {
+		return r.source, nil
+	}
+	n, err := yamlNode(r.manifest, yamlEncoding{
+		flag:   flag,
+		escape: func(s string) string { return s },
+	})
+	if err != nil {
+		return "", err
+	}
+	return encodeYAML(n)
+}
+
+// yamlRefFormat writes each manifest to its own YAML file and config that
+// reads it with yamldecode(file()), so the YAML stays the source of truth
+type yamlRefFormat struct {
+	hclFormat
+}
+
+func (yamlRefFormat) resource(r resource, o options) (string, error) {
+	if o.mapOnly || r.typed || r.resourceType != resourceType {
+		return "", fmt.Errorf("--format yamlref can't be used with --map-only, --typed or --target")
+	}
+
+	dir, ref := o.manifestDir, o.manifestRef
+	if dir == "" {
+		dir, ref = defaultManifestDir, defaultManifestDir
+	}
+	body, err := manifestYAML(r, "--format yamlref")
+	if err != nil {
+		return "", err
+	}
+	if err := o.writeFile(pendingFile{filename: filepath.Join(dir, r.name+".yaml"), data: []byte(body)}); err != nil {
+		return "", err
+	}
+
+	hcl := fmt.Sprintf("resource %q %q {\n", r.resourceType, r.name)
+	if o.providerAlias != "" {
+		hcl += fmt.Sprintf("  provider = %v\n\n", o.providerAlias)
+	}
+	hcl += fmt.Sprintf("  manifest = yamldecode(file(%q))\n", modulePath(ref, r.name+".yaml"))
+	hcl += waitBlock(r)
+	hcl += fieldManagerBlock(r, o)
+	hcl += computedFieldsAttribute(r)
+	hcl += dependsOnAttribute(r)
+	hcl += lifecycleBlock(r)
+	hcl += "}\n"
+	hcl += importBlock(r)
+	return hcl, nil
+}
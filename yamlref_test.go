@@ -0,0 +1,73 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestYAMLToTerraformResourcesYAMLRef(t *testing.T) {
+	yaml := `# the config for the app
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: test # inline
+data:
+  TEMPLATE: "${HOME}"
+`
+
+	dir, err := ioutil.TempDir("", "tfk8s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	output, err := YAMLToTerraformResources(strings.NewReader(yaml), WithFormat("yamlref"), WithManifestDir(dir, "manifests"))
+	if err != nil {
+		t.Fatal("Converting to HCL failed:", err)
+	}
+
+	expected := `resource "kubernetes_manifest" "configmap_test" {
+  manifest = yamldecode(file("${path.module}/manifests/configmap_test.yaml"))
+}
+`
+	assert.Equal(t, expected, output)
+
+	// the document is copied as it was written
+	b, err := ioutil.ReadFile(filepath.Join(dir, "configmap_test.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, yaml, string(b))
+}
+
+func TestYAMLRefStrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tfk8s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	_, err = YAMLToTerraformResources(strings.NewReader(`apiVersion: v1
+kind: Namespace
+metadata:
+  name: test
+  uid: 0b2b9a3e
+status:
+  phase: Active
+`), WithFormat("yamlref"), WithManifestDir(dir, "manifests"), WithStripServerSide(true))
+	if err != nil {
+		t.Fatal("Converting to HCL failed:", err)
+	}
+
+	// the stripped manifest is written when it was changed
+	b, err := ioutil.ReadFile(filepath.Join(dir, "namespace_test.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "apiVersion: v1\nkind: Namespace\nmetadata:\n  name: test\n", string(b))
+}
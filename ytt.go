@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+)
+
+// yttCommand is the command used to render Carvel ytt templates
+var yttCommand = "ytt"
+
+// runYtt runs ytt with the supplied arguments and returns its output
+var runYtt = func(args ...string) ([]byte, error) {
+	return runCommand(yttCommand, args...)
+}
+
+// yttOptions configures how ytt templates are rendered
+type yttOptions struct {
+	files      []string
+	dataValues []string
+}
+
+// renderYtt renders the templates and returns the resulting YAML
+func renderYtt(opts yttOptions) ([]byte, error) {
+	args := []string{}
+	for _, f := range opts.files {
+		args = append(args, "-f", f)
+	}
+	for _, v := range opts.dataValues {
+		args = append(args, "--data-values-file", v)
+	}
+	return runYtt(args...)
+}
+
+// yttSource returns a source that renders the templates with ytt
+func yttSource(opts yttOptions) source {
+	return source{
+		name: "ytt",
+		open: func() (io.ReadCloser, error) {
+			b, err := renderYtt(opts)
+			if err != nil {
+				return nil, err
+			}
+			return ioutil.NopCloser(bytes.NewReader(b)), nil
+		},
+	}
+}
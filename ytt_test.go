@@ -0,0 +1,46 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestYttSource(t *testing.T) {
+	defer func(f func(...string) ([]byte, error)) { runYtt = f }(runYtt)
+
+	var args []string
+	runYtt = func(a ...string) ([]byte, error) {
+		args = a
+		return []byte(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: web
+data:
+  replicas: "3"
+`), nil
+	}
+
+	s := yttSource(yttOptions{
+		files:      []string{"config/", "overlays/prod.yml"},
+		dataValues: []string{"values/prod.yml"},
+	})
+	r, err := s.open()
+	if err != nil {
+		t.Fatal("Rendering ytt templates failed:", err)
+	}
+
+	expected := []string{
+		"-f", "config/",
+		"-f", "overlays/prod.yml",
+		"--data-values-file", "values/prod.yml",
+	}
+	assert.Equal(t, expected, args)
+
+	output, err := YAMLToTerraformResources(r)
+	if err != nil {
+		t.Fatal("Converting to HCL failed:", err)
+	}
+	assert.True(t, strings.HasPrefix(output, `resource "kubernetes_manifest" "configmap_web" {`))
+}